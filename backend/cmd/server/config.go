@@ -0,0 +1,113 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sort"
+	"strings"
+
+	"github.com/lRoccoon/utils-helper/internal/compress"
+	"github.com/lRoccoon/utils-helper/internal/config"
+	"github.com/lRoccoon/utils-helper/internal/service"
+	"github.com/lRoccoon/utils-helper/internal/static"
+	"github.com/lRoccoon/utils-helper/internal/tlsconfig"
+)
+
+// loadConfig loads the configuration and checks the settings whose values
+// are defined by the packages using them
+func loadConfig(args []string) (*config.Config, error) {
+	cfg, err := config.Load(args)
+	if err != nil {
+		return nil, err
+	}
+	if err := checkConfig(cfg); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// checkConfig validates codings, MIME types, API keys and TLS parameters
+// against their packages, reporting all problems at once like
+// config.Validate
+func checkConfig(cfg *config.Config) error {
+	var errs []error
+	fail := func(key string, err error) {
+		errs = append(errs, fmt.Errorf("%s: %w", key, err))
+	}
+
+	for _, list := range []struct {
+		key     string
+		codings []string
+	}{
+		{"compression.encodings", cfg.Compression.Encodings},
+		{"compression.precompress", cfg.Compression.Precompress},
+	} {
+		for _, coding := range list.codings {
+			if !slices.Contains(compress.Encodings, coding) {
+				fail(list.key, fmt.Errorf("unknown coding %q, expected some of %s", coding, strings.Join(compress.Encodings, ", ")))
+			}
+		}
+	}
+
+	exts := make([]string, 0, len(cfg.Frontend.MIMETypes))
+	for ext := range cfg.Frontend.MIMETypes {
+		exts = append(exts, ext)
+	}
+	sort.Strings(exts)
+	for _, ext := range exts {
+		if err := static.ValidateType(ext, cfg.Frontend.MIMETypes[ext]); err != nil {
+			fail("frontend.mime_types", err)
+		}
+	}
+
+	for _, key := range configKeys(cfg.Auth) {
+		if err := service.ValidateAPIKey(key); err != nil {
+			fail("auth.keys", err)
+		}
+	}
+
+	if cfg.TLS.Enabled() {
+		if _, err := tlsOptions(cfg.TLS); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// configKeys returns the API keys of the config file sorted by ID
+func configKeys(cfg config.Auth) []service.APIKey {
+	keys := make([]service.APIKey, 0, len(cfg.Keys))
+	for id, k := range cfg.Keys {
+		keys = append(keys, service.APIKey{ID: id, Name: k.Name, Hash: k.Hash, Scopes: k.Scopes})
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	return keys
+}
+
+// tlsOptions converts the TLS section to the listener's options
+func tlsOptions(cfg config.TLS) (tlsconfig.Options, error) {
+	var errs []error
+	version, err := tlsconfig.ParseVersion(cfg.MinVersion)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("tls.min_version: %w", err))
+	}
+	suites, err := tlsconfig.ParseCipherSuites(cfg.CipherSuites)
+	if err != nil {
+		errs = append(errs, fmt.Errorf("tls.cipher_suites: %w", err))
+	}
+	clientAuth, ok := tlsconfig.ClientAuthModes[cfg.ClientAuth]
+	if !ok {
+		errs = append(errs, fmt.Errorf("tls.client_auth: must be require or verify_if_given, got %q", cfg.ClientAuth))
+	}
+
+	return tlsconfig.Options{
+		CertFile:     cfg.CertFile,
+		KeyFile:      cfg.KeyFile,
+		ClientCAFile: cfg.ClientCAFile,
+		ClientAuth:   clientAuth,
+		MinVersion:   version,
+		CipherSuites: suites,
+		HTTP2:        cfg.HTTP2,
+	}, errors.Join(errs...)
+}
@@ -0,0 +1,91 @@
+package main
+
+import (
+	"crypto/tls"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/lRoccoon/utils-helper/internal/api/handler"
+	"github.com/lRoccoon/utils-helper/internal/compress"
+	"github.com/lRoccoon/utils-helper/internal/config"
+	"github.com/lRoccoon/utils-helper/internal/service"
+)
+
+func TestCheckConfig(t *testing.T) {
+	if err := checkConfig(config.Default()); err != nil {
+		t.Fatalf("checkConfig(Default()) = %v", err)
+	}
+
+	cfg := config.Default()
+	cfg.Compression.Encodings = []string{"zstd", "deflate"}
+	cfg.Frontend.MIMETypes = map[string]string{"wasm": "application/wasm"}
+	cfg.Auth.Keys = map[string]config.AuthKey{"nothex": {Hash: "md5:00", Scopes: []string{"root"}}}
+	cfg.TLS.Listen = ":8443"
+	cfg.TLS.MinVersion = "1.0"
+	cfg.TLS.CipherSuites = []string{"TLS_RSA_WITH_RC4_128_SHA"}
+	cfg.TLS.ClientAuth = "optional"
+
+	err := checkConfig(cfg)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{
+		`compression.encodings: unknown coding "deflate"`,
+		`frontend.mime_types: invalid extension "wasm"`,
+		`auth.keys: invalid API key ID "nothex"`,
+		"tls.min_version: unsupported TLS version",
+		"tls.cipher_suites: cipher suite TLS_RSA_WITH_RC4_128_SHA is insecure",
+		"tls.client_auth: must be require or verify_if_given",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestTLSOptions(t *testing.T) {
+	cfg := config.Default().TLS
+	cfg.CertFile = "/etc/tls/tls.crt"
+	cfg.ClientCAFile = "/etc/tls/ca.crt"
+	cfg.ClientAuth = "verify_if_given"
+	cfg.CipherSuites = []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}
+
+	opts, err := tlsOptions(cfg)
+	if err != nil {
+		t.Fatalf("tlsOptions() error = %v", err)
+	}
+	if !opts.HTTP2 || opts.MinVersion != tls.VersionTLS12 || len(opts.CipherSuites) != 1 ||
+		opts.ClientAuth != tls.VerifyClientCertIfGiven || opts.CertFile != "/etc/tls/tls.crt" {
+		t.Errorf("tlsOptions() = %+v", opts)
+	}
+}
+
+func TestConfigKeys(t *testing.T) {
+	keys := configKeys(config.Auth{Keys: map[string]config.AuthKey{
+		"b2c3d4e5f6a1": {Name: "web", Scopes: []string{"read:holiday"}},
+		"a1b2c3d4e5f6": {Name: "ci", Scopes: []string{"write:ip"}},
+	}})
+	if len(keys) != 2 || keys[0].ID != "a1b2c3d4e5f6" || keys[0].Name != "ci" || !keys[0].HasScope("read:ip") {
+		t.Errorf("configKeys() = %+v", keys)
+	}
+}
+
+// The config package keeps its defaults as plain values; they must match
+// those of the packages applying them
+func TestConfigDefaultsMatchPackages(t *testing.T) {
+	cfg := config.Default()
+	if !slices.Equal(cfg.CORS.ExposeHeaders, []string{handler.RequestIDHeader}) {
+		t.Errorf("cors.expose_headers = %v, want %s", cfg.CORS.ExposeHeaders, handler.RequestIDHeader)
+	}
+	if cfg.Compression.MinLength != compress.DefaultMinLength ||
+		!slices.Equal(cfg.Compression.Encodings, compress.DefaultMiddlewareEncodings) {
+		t.Errorf("compression = %+v, want the compress package defaults", cfg.Compression)
+	}
+	if cfg.GeoIP.ReloadInterval.Duration != service.DefaultGeoReloadInterval {
+		t.Errorf("geoip.reload_interval = %s, want %s", cfg.GeoIP.ReloadInterval, service.DefaultGeoReloadInterval)
+	}
+	if cfg.DNS.Timeout.Duration != service.DefaultDNSTimeout {
+		t.Errorf("dns.timeout = %s, want %s", cfg.DNS.Timeout, service.DefaultDNSTimeout)
+	}
+}
@@ -5,21 +5,30 @@ import (
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lRoccoon/utils-helper/internal/config"
 )
 
 func init() {
 	gin.SetMode(gin.TestMode)
 }
 
+// testConfig returns the default configuration in gin test mode
+func testConfig() *config.Config {
+	cfg := config.Default()
+	cfg.Server.Mode = gin.TestMode
+	cfg.Log.Access = false
+	return cfg
+}
+
 func TestSetupRouter(t *testing.T) {
-	r := setupRouter()
+	r := setupRouter(testConfig(), nil)
 	if r == nil {
 		t.Fatal("setupRouter returned nil")
 	}
 }
 
 func TestCORSMiddleware(t *testing.T) {
-	r := setupRouter()
+	r := setupRouter(testConfig(), nil)
 
 	tests := []struct {
 		name           string
@@ -64,7 +73,7 @@ func TestCORSMiddleware(t *testing.T) {
 }
 
 func TestStaticFileServing(t *testing.T) {
-	r := setupRouter()
+	r := setupRouter(testConfig(), nil)
 
 	tests := []struct {
 		name           string
@@ -106,7 +115,7 @@ func TestStaticFileServing(t *testing.T) {
 }
 
 func TestAPIRoutes(t *testing.T) {
-	r := setupRouter()
+	r := setupRouter(testConfig(), nil)
 
 	tests := []struct {
 		name           string
@@ -143,3 +152,29 @@ func TestAPIRoutes(t *testing.T) {
 	}
 }
 
+
+func TestRateLimitedRouter(t *testing.T) {
+	cfg := testConfig()
+	cfg.RateLimit.Enabled = true
+	cfg.RateLimit.Requests = 1
+	r := setupRouter(cfg, nil)
+
+	codes := make([]int, 0, 2)
+	for i := 0; i < 2; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/api/holiday?date=2024-01-01", nil))
+		codes = append(codes, w.Code)
+	}
+	if codes[0] != 200 || codes[1] != 429 {
+		t.Errorf("status codes = %v, want [200 429]", codes)
+	}
+
+	// Health probes are not limited
+	for i := 0; i < 3; i++ {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+		if w.Code != 200 {
+			t.Fatalf("GET /health = %d, want 200", w.Code)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	"github.com/lRoccoon/utils-helper/internal/config"
+	"github.com/lRoccoon/utils-helper/internal/service"
+)
+
+const keysUsage = `usage: utils-helper keys create -name NAME -scopes SCOPES [-file PATH | -print]
+       utils-helper keys list [-file PATH]
+       utils-helper keys revoke [-file PATH] ID
+
+Scopes are admin, read:<module> and write:<module>, e.g. read:holiday,write:ip.
+The key file defaults to auth.key_file of the configuration.`
+
+// runKeysCommand manages the API key file. The server picks up changes
+// within a few seconds, so keys can be created and revoked while it runs.
+func runKeysCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || (args[0] != "create" && args[0] != "list" && args[0] != "revoke") {
+		fmt.Fprintln(stderr, keysUsage)
+		return 2
+	}
+
+	fs := flag.NewFlagSet("keys "+args[0], flag.ContinueOnError)
+	fs.SetOutput(stderr)
+	fs.Usage = func() { fmt.Fprintln(stderr, keysUsage) }
+	file := fs.String("file", "", "API key file")
+	name := fs.String("name", "", "name of the new key, e.g. the client using it")
+	scopes := fs.String("scopes", "", "comma-separated scopes of the new key")
+	printEntry := fs.Bool("print", false, "print a config file entry instead of writing the key file")
+	if err := fs.Parse(args[1:]); err != nil {
+		if errors.Is(err, flag.ErrHelp) {
+			return 0
+		}
+		return 2
+	}
+
+	if *file == "" && !*printEntry {
+		cfg, err := config.Load(nil)
+		if err != nil {
+			fmt.Fprintf(stderr, "Invalid configuration:\n%v\n", err)
+			return 1
+		}
+		if *file = cfg.Auth.KeyFile; *file == "" {
+			fmt.Fprintln(stderr, "No key file, set auth.key_file or AUTH_KEY_FILE or pass -file")
+			return 1
+		}
+	}
+
+	var err error
+	switch args[0] {
+	case "create":
+		err = createKey(stdout, stderr, *file, *name, splitScopes(*scopes), *printEntry)
+	case "list":
+		err = listKeys(stdout, *file)
+	case "revoke":
+		if fs.NArg() != 1 {
+			fmt.Fprintln(stderr, keysUsage)
+			return 2
+		}
+		if err = service.RevokeAPIKey(*file, fs.Arg(0)); err == nil {
+			fmt.Fprintf(stderr, "Revoked API key %s\n", fs.Arg(0))
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+// createKey generates a key and adds it to the key file, or prints the
+// config file entry for it. The key itself is written to stdout alone, so
+// it can be piped into a secret store; it is not shown again.
+func createKey(stdout, stderr io.Writer, file, name string, scopes []string, printEntry bool) error {
+	if name == "" {
+		return errors.New("-name is required")
+	}
+	secret, key, err := service.GenerateAPIKey(name, scopes)
+	if err != nil {
+		return err
+	}
+
+	if printEntry {
+		fmt.Fprintln(stdout, secret)
+		fmt.Fprintf(stderr, "Add the key to the config file:\n\nauth:\n  keys:\n    %s:\n      name: %q\n      hash: %s\n      scopes: [%s]\n",
+			key.ID, key.Name, key.Hash, strings.Join(key.Scopes, ", "))
+		return nil
+	}
+
+	keys, err := service.ReadAPIKeyFile(file)
+	if err != nil {
+		return err
+	}
+	if err := service.WriteAPIKeyFile(file, append(keys, key)); err != nil {
+		return err
+	}
+	fmt.Fprintln(stdout, secret)
+	fmt.Fprintf(stderr, "Created API key %s (%s) with scopes %s in %s. Store it now, it cannot be shown again.\n",
+		key.ID, key.Name, strings.Join(key.Scopes, ","), file)
+	return nil
+}
+
+// listKeys writes the keys of the key file as a table
+func listKeys(stdout io.Writer, file string) error {
+	keys, err := service.ReadAPIKeyFile(file)
+	if err != nil {
+		return err
+	}
+	w := tabwriter.NewWriter(stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tSCOPES\tCREATED")
+	for _, k := range keys {
+		created := "-"
+		if !k.Created.IsZero() {
+			created = k.Created.Format("2006-01-02 15:04:05Z07:00")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", k.ID, k.Name, strings.Join(k.Scopes, ","), created)
+	}
+	return w.Flush()
+}
+
+// splitScopes splits a comma-separated scope list
+func splitScopes(s string) []string {
+	var scopes []string
+	for _, scope := range strings.Split(s, ",") {
+		if scope = strings.TrimSpace(scope); scope != "" {
+			scopes = append(scopes, scope)
+		}
+	}
+	return scopes
+}
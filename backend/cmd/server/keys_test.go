@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/lRoccoon/utils-helper/internal/service"
+)
+
+func TestKeysCommand(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "apikeys.json")
+	var stdout, stderr bytes.Buffer
+
+	code := runKeysCommand([]string{"create", "-file", file, "-name", "ci", "-scopes", "read:holiday, write:ip"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("create exit code %d, stderr: %s", code, stderr.String())
+	}
+	secret := strings.TrimSpace(stdout.String())
+	if !strings.HasPrefix(secret, service.APIKeyPrefix) {
+		t.Fatalf("create stdout = %q, want only the key", secret)
+	}
+
+	store, err := service.NewAPIKeyStore(file, nil)
+	if err != nil {
+		t.Fatalf("NewAPIKeyStore() error = %v", err)
+	}
+	key, ok := store.Authenticate(secret)
+	if !ok || key.Name != "ci" || !key.HasScope("write:ip") {
+		t.Fatalf("created key = %+v, %v", key, ok)
+	}
+
+	stdout.Reset()
+	if code := runKeysCommand([]string{"list", "-file", file}, &stdout, &stderr); code != 0 {
+		t.Fatalf("list exit code %d", code)
+	}
+	if out := stdout.String(); !strings.Contains(out, key.ID) || !strings.Contains(out, "read:holiday,write:ip") || strings.Contains(out, secret) {
+		t.Errorf("list output:\n%s", out)
+	}
+
+	if code := runKeysCommand([]string{"revoke", "-file", file, key.ID}, &stdout, &stderr); code != 0 {
+		t.Fatalf("revoke exit code %d, stderr: %s", code, stderr.String())
+	}
+	if code := runKeysCommand([]string{"revoke", "-file", file, key.ID}, &stdout, &stderr); code != 1 {
+		t.Errorf("revoking a missing key exit code = %d, want 1", code)
+	}
+	if keys, _ := service.ReadAPIKeyFile(file); len(keys) != 0 {
+		t.Errorf("keys after revoke = %+v", keys)
+	}
+
+	// Config file keys are printed rather than written
+	stdout.Reset()
+	stderr.Reset()
+	if code := runKeysCommand([]string{"create", "-print", "-name", "ops", "-scopes", "admin"}, &stdout, &stderr); code != 0 {
+		t.Fatalf("create -print exit code %d, stderr: %s", code, stderr.String())
+	}
+	if !strings.Contains(stderr.String(), "hash: sha256:") || !strings.Contains(stderr.String(), "scopes: [admin]") {
+		t.Errorf("create -print stderr:\n%s", stderr.String())
+	}
+
+	for _, args := range [][]string{nil, {"rotate"}, {"revoke", "-file", file}} {
+		if code := runKeysCommand(args, &stdout, &stderr); code != 2 {
+			t.Errorf("keys %v exit code = %d, want 2", args, code)
+		}
+	}
+	if code := runKeysCommand([]string{"create", "-file", file, "-name", "x", "-scopes", "root"}, &stdout, &stderr); code != 1 {
+		t.Errorf("create with an invalid scope exit code = %d, want 1", code)
+	}
+}
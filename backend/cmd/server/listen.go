@@ -0,0 +1,169 @@
+package main
+
+import (
+	"context"
+	"crypto/tls"
+	"log/slog"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lRoccoon/utils-helper/internal/config"
+	"github.com/lRoccoon/utils-helper/internal/tlsconfig"
+	"golang.org/x/net/http2"
+	"golang.org/x/net/http2/h2c"
+)
+
+// listen opens the HTTPS listener, if configured, and the plain one, which
+// serves h alongside or redirects to HTTPS. The returned reloader serves
+// the certificate of the HTTPS listener.
+func listen(cfg *config.Config, h http.Handler) ([]endpoint, *tlsconfig.Reloader, error) {
+	var endpoints []endpoint
+	closeAll := func() {
+		for _, e := range endpoints {
+			e.ln.Close()
+		}
+	}
+
+	var certs *tlsconfig.Reloader
+	if cfg.TLS.Enabled() {
+		opts, err := tlsOptions(cfg.TLS)
+		if err != nil {
+			return nil, nil, err
+		}
+		if certs, err = tlsconfig.New(opts); err != nil {
+			return nil, nil, err
+		}
+		ln, err := net.Listen("tcp", cfg.TLS.Listen)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		tlsCfg := cfg.Server
+		tlsCfg.Listen = cfg.TLS.Listen
+		srv := newServer(tlsCfg, h)
+		srv.TLSConfig = certs.Config()
+		if !cfg.TLS.HTTP2 {
+			// A non-nil map keeps net/http from adding HTTP/2 itself
+			srv.TLSNextProto = map[string]func(*http.Server, *tls.Conn, http.Handler){}
+		}
+		endpoints = append(endpoints, endpoint{srv, tls.NewListener(ln, srv.TLSConfig)})
+		slog.Info("TLS certificate loaded", "certificate", certs.Describe(), "http2", cfg.TLS.HTTP2, "client_ca", cfg.TLS.ClientCAFile)
+	}
+
+	if cfg.Server.Listen != "" {
+		plain := h
+		if cfg.TLS.RedirectHTTP {
+			plain = redirectToHTTPS(h, httpsPort(cfg.TLS))
+		}
+		if cfg.Server.H2C {
+			proxies, err := cfg.TrustedProxyPrefixes()
+			if err != nil {
+				closeAll()
+				return nil, nil, err
+			}
+			plain = h2cFromProxies(plain, proxies)
+		}
+		ln, err := net.Listen("tcp", cfg.Server.Listen)
+		if err != nil {
+			closeAll()
+			return nil, nil, err
+		}
+		endpoints = append(endpoints, endpoint{newServer(cfg.Server, plain), ln})
+	}
+	return endpoints, certs, nil
+}
+
+// watchCertificate reloads the certificate files whenever they change,
+// e.g. after a renewal by cert-manager or certbot, until ctx is done
+func watchCertificate(ctx context.Context, certs *tlsconfig.Reloader, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		changed, err := certs.Reload()
+		switch {
+		case err != nil:
+			slog.Error("Failed to reload TLS certificate, keeping the current one", "error", err)
+		case changed:
+			slog.Info("TLS certificate reloaded", "certificate", certs.Describe())
+		}
+	}
+}
+
+// httpsPort returns the port redirects to HTTPS point to
+func httpsPort(cfg config.TLS) string {
+	if cfg.RedirectPort > 0 {
+		return strconv.Itoa(cfg.RedirectPort)
+	}
+	_, port, _ := net.SplitHostPort(cfg.Listen)
+	return port
+}
+
+// redirectToHTTPS redirects plain requests to the same URL over HTTPS.
+// Health checks are still answered by h, since probes usually speak plain
+// HTTP to the pod.
+func redirectToHTTPS(h http.Handler, port string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/health" || r.URL.Path == "/ready" {
+			h.ServeHTTP(w, r)
+			return
+		}
+
+		host := r.Host
+		if hostname, _, err := net.SplitHostPort(host); err == nil {
+			host = hostname
+		}
+		host = strings.Trim(host, "[]")
+		if port == "443" {
+			if strings.Contains(host, ":") {
+				host = "[" + host + "]"
+			}
+		} else {
+			host = net.JoinHostPort(host, port)
+		}
+
+		// 308 keeps the method and body of anything but GET and HEAD
+		code := http.StatusPermanentRedirect
+		if r.Method == http.MethodGet || r.Method == http.MethodHead {
+			code = http.StatusMovedPermanently
+		}
+		http.Redirect(w, r, "https://"+host+r.URL.RequestURI(), code)
+	})
+}
+
+// h2cFromProxies accepts HTTP/2 without TLS from the trusted proxies, which
+// terminate TLS in front of the server and speak h2c to it. Other peers
+// are answered over HTTP/1.1 only.
+func h2cFromProxies(h http.Handler, proxies []netip.Prefix) http.Handler {
+	withH2C := h2c.NewHandler(h, &http2.Server{})
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if trustedPeer(r.RemoteAddr, proxies) {
+			withH2C.ServeHTTP(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// trustedPeer reports whether the connection peer is one of the proxies
+func trustedPeer(remoteAddr string, proxies []netip.Prefix) bool {
+	addrPort, err := netip.ParseAddrPort(remoteAddr)
+	if err != nil {
+		return false
+	}
+	addr := addrPort.Addr().Unmap()
+	for _, p := range proxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,202 @@
+package main
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/lRoccoon/utils-helper/internal/config"
+	"golang.org/x/net/http2"
+)
+
+// writeTestCertificate writes a self-signed certificate for 127.0.0.1 and
+// returns a pool trusting it
+func writeTestCertificate(t *testing.T, certFile, keyFile string) *x509.CertPool {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	tmpl := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "utils-helper test"},
+		IPAddresses:  []net.IP{net.IPv4(127, 0, 0, 1)},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, tmpl, &key.PublicKey, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(certFile, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	cert, _ := x509.ParseCertificate(der)
+	pool := x509.NewCertPool()
+	pool.AddCert(cert)
+	return pool
+}
+
+func TestListenTLS(t *testing.T) {
+	dir := t.TempDir()
+	cfg := testConfig()
+	cfg.Server.Listen = "127.0.0.1:0"
+	cfg.Server.ShutdownDelay = config.Duration{}
+	cfg.TLS.Listen = "127.0.0.1:0"
+	cfg.TLS.CertFile = filepath.Join(dir, "tls.crt")
+	cfg.TLS.KeyFile = filepath.Join(dir, "tls.key")
+	cfg.TLS.RedirectHTTP = true
+	cfg.TLS.RedirectPort = 8443
+	pool := writeTestCertificate(t, cfg.TLS.CertFile, cfg.TLS.KeyFile)
+
+	endpoints, certs, err := listen(cfg, setupRouter(cfg, nil))
+	if err != nil {
+		t.Fatalf("listen() error = %v", err)
+	}
+	if certs == nil || len(endpoints) != 2 {
+		t.Fatalf("listen() = %d endpoints, reloader %v", len(endpoints), certs)
+	}
+	secure, plain := endpoints[0].ln.Addr().String(), endpoints[1].ln.Addr().String()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, cfg.Server, endpoints...)
+	}()
+
+	client := &http.Client{
+		Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool}, ForceAttemptHTTP2: true},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+	defer client.CloseIdleConnections()
+
+	resp, err := client.Get("https://" + secure + "/api/holiday/2024-10-01")
+	if err != nil {
+		t.Fatalf("HTTPS request error = %v", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 || resp.ProtoMajor != 2 {
+		t.Errorf("HTTPS request = %d over %s, want 200 over HTTP/2", resp.StatusCode, resp.Proto)
+	}
+
+	// Plain requests are redirected, except health checks
+	resp, err = client.Get("http://" + plain + "/api/holiday/2024-10-01?x=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if want := "https://127.0.0.1:8443/api/holiday/2024-10-01?x=1"; resp.StatusCode != 301 || resp.Header.Get("Location") != want {
+		t.Errorf("plain GET = %d to %q, want 301 to %q", resp.StatusCode, resp.Header.Get("Location"), want)
+	}
+	resp, err = client.Post("http://"+plain+"/api/net/cidr/aggregate", "application/json", strings.NewReader("{}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 308 {
+		t.Errorf("plain POST = %d, want 308", resp.StatusCode)
+	}
+	resp, err = client.Get("http://" + plain + "/health")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != 200 {
+		t.Errorf("plain /health = %d, want 200", resp.StatusCode)
+	}
+
+	cancel()
+	if err := <-served; err != nil {
+		t.Errorf("serve = %v", err)
+	}
+}
+
+func TestRedirectToHTTPS(t *testing.T) {
+	next := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})
+	tests := []struct {
+		host, port, want string
+	}{
+		{"example.com", "443", "https://example.com/a?b=c"},
+		{"example.com:8080", "8443", "https://example.com:8443/a?b=c"},
+		{"[2001:db8::1]:8080", "443", "https://[2001:db8::1]/a?b=c"},
+		{"[2001:db8::1]", "8443", "https://[2001:db8::1]:8443/a?b=c"},
+	}
+	for _, tt := range tests {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(http.MethodGet, "/a?b=c", nil)
+		req.Host = tt.host
+		redirectToHTTPS(next, tt.port).ServeHTTP(w, req)
+		if got := w.Header().Get("Location"); w.Code != 301 || got != tt.want {
+			t.Errorf("redirect of %s to port %s = %d %q, want %q", tt.host, tt.port, w.Code, got, tt.want)
+		}
+	}
+}
+
+func TestH2CFromTrustedProxies(t *testing.T) {
+	proto := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, r.Proto)
+	})
+	h2cClient := &http.Client{Transport: &http2.Transport{
+		AllowHTTP: true,
+		DialTLSContext: func(ctx context.Context, network, addr string, _ *tls.Config) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, network, addr)
+		},
+	}}
+
+	trusted := httptest.NewServer(h2cFromProxies(proto, []netip.Prefix{netip.MustParsePrefix("127.0.0.0/8")}))
+	defer trusted.Close()
+	resp, err := h2cClient.Get(trusted.URL)
+	if err != nil {
+		t.Fatalf("h2c request from a trusted proxy error = %v", err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "HTTP/2.0" {
+		t.Errorf("protocol = %q, want HTTP/2.0", body)
+	}
+
+	untrusted := httptest.NewServer(h2cFromProxies(proto, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}))
+	defer untrusted.Close()
+	if resp, err := h2cClient.Get(untrusted.URL); err == nil {
+		resp.Body.Close()
+		t.Errorf("h2c request from another peer = %s, want it refused", resp.Status)
+	}
+	// HTTP/1.1 keeps working for everyone
+	resp, err = http.Get(untrusted.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ = io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "HTTP/1.1" {
+		t.Errorf("protocol = %q, want HTTP/1.1", body)
+	}
+}
@@ -1,145 +1,457 @@
 package main
 
 import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
 	"io"
-	"log"
+	"log/slog"
+	"net"
 	"net/http"
 	"os"
+	"os/signal"
+	"sort"
 	"strings"
+	"syscall"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/lRoccoon/utils-helper/internal/api"
+	"github.com/lRoccoon/utils-helper/internal/api/handler"
+	"github.com/lRoccoon/utils-helper/internal/compress"
+	"github.com/lRoccoon/utils-helper/internal/config"
+	"github.com/lRoccoon/utils-helper/internal/metrics"
+	"github.com/lRoccoon/utils-helper/internal/ratelimit"
+	"github.com/lRoccoon/utils-helper/internal/service"
 	"github.com/lRoccoon/utils-helper/internal/static"
 )
 
 func main() {
-	port := os.Getenv("PORT")
-	if port == "" {
-		port = "8080"
+	if len(os.Args) > 1 && os.Args[1] == "config" {
+		os.Exit(runConfigCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
+	if len(os.Args) > 1 && os.Args[1] == "keys" {
+		os.Exit(runKeysCommand(os.Args[2:], os.Stdout, os.Stderr))
+	}
+
+	cfg, err := loadConfig(os.Args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return
+	}
+	if err != nil {
+		// Logging is not configured yet, and the errors read best unwrapped
+		fmt.Fprintf(os.Stderr, "Invalid configuration:\n%v\n", err)
+		os.Exit(1)
+	}
+
+	setupLogging(cfg.Log)
+	if err := configureServices(cfg); err != nil {
+		fatal("Failed to start server", err)
+	}
+
+	var m *metrics.Metrics
+	if cfg.Metrics.Enabled {
+		m = metrics.New()
+	}
+	endpoints, certs, err := listen(cfg, setupRouter(cfg, m))
+	if err != nil {
+		fatal("Failed to start server", err)
+	}
+	if m != nil && cfg.Metrics.Listen != "" {
+		admin, err := serveMetrics(cfg, m)
+		if err != nil {
+			fatal("Failed to start metrics listener", err)
+		}
+		// Scrapes keep working while the main server drains
+		defer admin.Close()
+	}
+
+	// After the first signal the default handling is restored, so a second
+	// one exits immediately instead of waiting for the drain
+	ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		<-ctx.Done()
+		stop()
+	}()
+
+	if certs != nil {
+		go watchCertificate(ctx, certs, cfg.TLS.ReloadInterval.Duration)
 	}
 
-	r := setupRouter()
+	addrs := make([]string, 0, len(endpoints))
+	for _, e := range endpoints {
+		addrs = append(addrs, e.ln.Addr().String())
+	}
+	slog.Info("Server starting", "listen", strings.Join(addrs, ","), "modules", strings.Join(cfg.Modules, ","))
+	if err := serve(ctx, cfg.Server, endpoints...); err != nil {
+		fatal("Server stopped", err)
+	}
+	slog.Info("Server stopped")
+}
 
-	log.Printf("Server starting on port %s", port)
-	if err := r.Run(":" + port); err != nil {
-		log.Fatalf("Failed to start server: %v", err)
+// newServer creates the HTTP server with the configured timeouts
+func newServer(cfg config.Server, h http.Handler) *http.Server {
+	return &http.Server{
+		Addr:              cfg.Listen,
+		Handler:           h,
+		ReadHeaderTimeout: cfg.ReadHeaderTimeout.Duration,
+		ReadTimeout:       cfg.ReadTimeout.Duration,
+		WriteTimeout:      cfg.WriteTimeout.Duration,
+		IdleTimeout:       cfg.IdleTimeout.Duration,
+		MaxHeaderBytes:    cfg.MaxHeaderBytes,
 	}
 }
 
-// setupRouter configures and returns the Gin router
-func setupRouter() *gin.Engine {
-	r := gin.Default()
+// serveMetrics serves the metrics on their own admin listener, which can
+// be kept off the public network
+func serveMetrics(cfg *config.Config, m *metrics.Metrics) (*http.Server, error) {
+	mux := http.NewServeMux()
+	mux.Handle(cfg.Metrics.Path, m.AdminHandler())
 
-	// CORS middleware
-	r.Use(func(c *gin.Context) {
-		c.Writer.Header().Set("Access-Control-Allow-Origin", "*")
-		c.Writer.Header().Set("Access-Control-Allow-Methods", "GET, POST, OPTIONS")
-		c.Writer.Header().Set("Access-Control-Allow-Headers", "Content-Type")
+	adminCfg := cfg.Server
+	adminCfg.Listen = cfg.Metrics.Listen
+	srv := newServer(adminCfg, mux)
+	ln, err := net.Listen("tcp", adminCfg.Listen)
+	if err != nil {
+		return nil, err
+	}
 
-		if c.Request.Method == "OPTIONS" {
-			c.AbortWithStatus(204)
-			return
+	go func() {
+		if err := srv.Serve(ln); !errors.Is(err, http.ErrServerClosed) {
+			slog.Error("Metrics listener stopped", "error", err)
 		}
+	}()
+	slog.Info("Metrics listener starting", "listen", ln.Addr().String(), "path", cfg.Metrics.Path)
+	return srv, nil
+}
 
-		c.Next()
-	})
+// endpoint is a server together with the listener it serves
+type endpoint struct {
+	srv *http.Server
+	ln  net.Listener
+}
 
-	// Register API routes
-	api.RegisterRoutes(r)
+// serve runs the servers on their listeners until ctx is cancelled, then
+// shuts down gracefully: /ready fails for the shutdown delay so load
+// balancers stop routing to this instance, the listeners close and
+// in-flight requests get the shutdown timeout to finish before their
+// connections are closed. If any server fails, the others are closed.
+func serve(ctx context.Context, cfg config.Server, endpoints ...endpoint) error {
+	handler.SetReady(true)
 
-	// Serve static files from embedded frontend
-	staticFS, err := static.GetFS()
-	if err != nil {
-		log.Printf("Warning: Failed to load embedded static files: %v", err)
-	} else {
-		// Handle all routes for SPA
-		r.NoRoute(func(c *gin.Context) {
-			path := c.Request.URL.Path
+	errc := make(chan error, len(endpoints))
+	for _, e := range endpoints {
+		go func(e endpoint) {
+			errc <- e.srv.Serve(e.ln)
+		}(e)
+	}
+
+	select {
+	case err := <-errc:
+		for _, e := range endpoints {
+			e.srv.Close()
+		}
+		return err
+	case <-ctx.Done():
+	}
 
-			// Skip if it's an API route
-			if strings.HasPrefix(path, "/api/") {
-				c.JSON(http.StatusNotFound, gin.H{"error": "API endpoint not found"})
+	handler.SetReady(false)
+	slog.Info("Shutting down", "delay", cfg.ShutdownDelay.String(), "timeout", cfg.ShutdownTimeout.String())
+	time.Sleep(cfg.ShutdownDelay.Duration)
+
+	shutdownCtx, cancel := context.WithTimeout(context.Background(), cfg.ShutdownTimeout.Duration)
+	defer cancel()
+	shutdown := make(chan error, len(endpoints))
+	for _, e := range endpoints {
+		go func(srv *http.Server) {
+			if err := srv.Shutdown(shutdownCtx); err != nil {
+				srv.Close()
+				shutdown <- fmt.Errorf("requests still running after %s: %w", cfg.ShutdownTimeout, err)
 				return
 			}
+			shutdown <- nil
+		}(e.srv)
+	}
 
-			// Serve static files
-			path = strings.TrimPrefix(path, "/")
-			if path == "" {
-				path = "index.html"
-			}
+	var errs []error
+	for range endpoints {
+		errs = append(errs, <-shutdown)
+	}
+	for range endpoints {
+		if err := <-errc; !errors.Is(err, http.ErrServerClosed) {
+			errs = append(errs, err)
+		}
+	}
+	return errors.Join(errs...)
+}
 
-			// Try to read the file from embedded FS
-			data, err := staticFS.Open(path)
-			if err == nil {
-				defer data.Close()
-				stat, _ := data.Stat()
-				if !stat.IsDir() {
-					content, _ := io.ReadAll(data)
-					c.Data(http.StatusOK, getContentType(path), content)
-					return
-				}
-			}
+// runConfigCommand runs "config print", which writes the effective
+// configuration after applying the file, environment and flags
+func runConfigCommand(args []string, stdout, stderr io.Writer) int {
+	if len(args) == 0 || args[0] != "print" {
+		fmt.Fprintln(stderr, "usage: utils-helper config print [flags]")
+		return 2
+	}
 
-			// Try with .html extension
-			data, err = staticFS.Open(path + ".html")
-			if err == nil {
-				defer data.Close()
-				content, _ := io.ReadAll(data)
-				c.Data(http.StatusOK, "text/html; charset=utf-8", content)
-				return
-			}
+	cfg, err := loadConfig(args[1:])
+	if errors.Is(err, flag.ErrHelp) {
+		return 0
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "Invalid configuration:\n%v\n", err)
+		return 1
+	}
+	if err := cfg.Print(stdout); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
 
-			// Try index.html in subdirectory
-			data, err = staticFS.Open(path + "/index.html")
-			if err == nil {
-				defer data.Close()
-				content, _ := io.ReadAll(data)
-				c.Data(http.StatusOK, "text/html; charset=utf-8", content)
-				return
-			}
+// setupLogging installs the default slog logger in the configured format
+// and level
+func setupLogging(cfg config.Log) {
+	var level slog.Level
+	_ = level.UnmarshalText([]byte(cfg.Level))
 
-			// Fallback to root index.html for SPA routing
-			data, err = staticFS.Open("index.html")
-			if err == nil {
-				defer data.Close()
-				content, _ := io.ReadAll(data)
-				c.Data(http.StatusOK, "text/html; charset=utf-8", content)
-			} else {
-				c.String(http.StatusNotFound, "404 page not found")
-			}
-		})
+	opts := &slog.HandlerOptions{Level: level}
+	var h slog.Handler = slog.NewTextHandler(os.Stderr, opts)
+	if cfg.Format == "json" {
+		h = slog.NewJSONHandler(os.Stderr, opts)
 	}
+	slog.SetDefault(slog.New(h))
+}
 
-	return r
+// fatal logs an error and exits
+func fatal(msg string, err error) {
+	slog.Error(msg, "error", err)
+	os.Exit(1)
 }
 
-func getContentType(path string) string {
-	if strings.HasSuffix(path, ".html") {
-		return "text/html; charset=utf-8"
+// configureServices applies the configuration of the enabled modules
+func configureServices(cfg *config.Config) error {
+	proxies, err := cfg.TrustedProxyPrefixes()
+	if err != nil {
+		return err
 	}
-	if strings.HasSuffix(path, ".js") {
-		return "application/javascript"
+	handler.SetTrustedProxies(proxies)
+
+	if err := service.ConfigureAPIKeys(cfg.Auth.KeyFile, configKeys(cfg.Auth)); err != nil {
+		return fmt.Errorf("load API keys: %w", err)
 	}
-	if strings.HasSuffix(path, ".css") {
-		return "text/css"
+	handler.SetAnonymousModules(cfg.Auth.Anonymous)
+
+	if cfg.ModuleEnabled("ip") || cfg.IPSets.AccessControl() {
+		if err := service.ConfigureIPSets(cfg.IPSets.Path); err != nil {
+			return fmt.Errorf("load IP rule sets: %w", err)
+		}
 	}
-	if strings.HasSuffix(path, ".json") {
-		return "application/json"
+	if cfg.ModuleEnabled("ip") {
+		if err := service.ConfigureGeo(geoConfig(cfg.GeoIP)); err != nil {
+			return fmt.Errorf("configure geolocation: %w", err)
+		}
 	}
-	if strings.HasSuffix(path, ".png") {
-		return "image/png"
+
+	if cfg.ModuleEnabled("dns") {
+		if err := service.ConfigureDNS(cfg.DNS.Upstream, cfg.DNS.Timeout.Duration); err != nil {
+			return fmt.Errorf("configure DNS resolver: %w", err)
+		}
 	}
-	if strings.HasSuffix(path, ".jpg") || strings.HasSuffix(path, ".jpeg") {
-		return "image/jpeg"
+
+	if cfg.ModuleEnabled("request") {
+		handler.SetRedactedHeaders(cfg.Echo.RedactHeaders)
+	}
+
+	if cfg.ModuleEnabled("ua") && cfg.Data.UARulesPath != "" {
+		if err := service.LoadUserAgentRules(cfg.Data.UARulesPath); err != nil {
+			return fmt.Errorf("load user agent rules: %w", err)
+		}
+	}
+
+	// /api/ip names the vendor of EUI-64 addresses, so it uses the registry too
+	if (cfg.ModuleEnabled("mac") || cfg.ModuleEnabled("ip")) && len(cfg.Data.OUIRegistryPaths) > 0 {
+		if err := service.LoadOUIRegistry(cfg.Data.OUIRegistryPaths...); err != nil {
+			return fmt.Errorf("load OUI registry: %w", err)
+		}
+	}
+
+	if cfg.ModuleEnabled("ports") && cfg.Data.PortsRegistryPath != "" {
+		if err := service.LoadPortRegistry(cfg.Data.PortsRegistryPath); err != nil {
+			return fmt.Errorf("load port registry: %w", err)
+		}
+	}
+	return nil
+}
+
+// geoConfig converts the geoip section to the provider chain settings
+func geoConfig(cfg config.GeoIP) service.GeoConfig {
+	return service.GeoConfig{
+		Providers:      cfg.Providers,
+		StaticPath:     cfg.StaticPath,
+		MMDBPath:       cfg.MMDBPath,
+		CSVPath:        cfg.CSVPath,
+		HTTPProvider:   cfg.HTTPProvider,
+		HTTPBaseURL:    cfg.HTTPURL,
+		HTTPToken:      cfg.HTTPToken,
+		ReloadInterval: cfg.ReloadInterval.Duration,
+		ProbeIPs:       cfg.ProbeIPs,
+	}
+}
+
+// setupRouter configures and returns the Gin router. m may be nil when
+// metrics are disabled.
+func setupRouter(cfg *config.Config, m *metrics.Metrics) *gin.Engine {
+	gin.SetMode(cfg.Server.Mode)
+	r := gin.New()
+	if m != nil {
+		r.Use(m.Middleware())
+	}
+	r.Use(handler.RequestID)
+	if cfg.Log.Access {
+		r.Use(api.AccessLog(slog.Default(), "/health", "/ready", cfg.Metrics.Path))
+	}
+	r.Use(api.Recovery(slog.Default()))
+	if err := r.SetTrustedProxies(cfg.Server.TrustedProxies); err != nil {
+		slog.Warn("Invalid trusted proxies", "error", err)
+	}
+
+	r.Use(api.NoSniff())
+	if cfg.IPSets.AccessControl() {
+		r.Use(handler.IPAccessControl(cfg.IPSets.Allow, cfg.IPSets.Deny, "/health", "/ready"))
+	}
+	r.Use(corsMiddleware(cfg.CORS))
+	// Keys are identified before rate limiting so callers are counted per
+	// key, but rejected after it so failed guesses use up the client's
+	// bucket
+	r.Use(api.IdentifyAPIKey)
+	if cfg.RateLimit.Enabled {
+		r.Use(rateLimitMiddleware(cfg.RateLimit))
+	}
+	r.Use(api.Authenticate)
+	if cfg.RateLimit.Enabled && cfg.RateLimit.MaxConcurrent > 0 {
+		r.Use(api.ConcurrencyLimit(cfg.RateLimit.MaxConcurrent, cfg.RateLimit.ConcurrencyPaths...))
+	}
+	if cfg.Compression.Enabled {
+		r.Use(compress.Middleware(compress.Options{
+			Encodings: cfg.Compression.Encodings,
+			MinLength: cfg.Compression.MinLength,
+		}))
+	}
+
+	// Metrics on the public listener are registered after the middleware
+	// chain so they are rate limited and access controlled, and need an
+	// admin key
+	if m != nil && cfg.Metrics.Listen == "" {
+		r.GET(cfg.Metrics.Path, handler.RequireScope(service.ScopeAdmin), gin.WrapH(m.Handler()))
+	}
+
+	// Register API routes
+	api.RegisterRoutes(r, cfg.Modules...)
+	if !cfg.ModuleEnabled("frontend") {
+		r.NoRoute(func(c *gin.Context) {
+			c.JSON(http.StatusNotFound, handler.ErrorResponse(c, "Not found"))
+		})
+		return r
+	}
+
+	// Serve static files from embedded frontend
+	staticFS, err := static.GetFS()
+	var types *static.Types
+	if err == nil {
+		types, err = static.NewTypes(cfg.Frontend.MIMETypes)
+	}
+	if err == nil {
+		opts := static.Options{
+			ModTime: buildTime(),
+			Types:   types,
+		}
+		if cfg.Compression.Enabled {
+			opts.Precompress = cfg.Compression.Precompress
+		}
+		var files *static.Server
+		files, err = static.NewServer(staticFS, opts)
+		if err == nil {
+			// Handle all routes for SPA
+			r.NoRoute(func(c *gin.Context) {
+				// Skip if it's an API route
+				if strings.HasPrefix(c.Request.URL.Path, "/api/") {
+					c.JSON(http.StatusNotFound, handler.ErrorResponse(c, "API endpoint not found"))
+					return
+				}
+				files.ServeHTTP(c.Writer, c.Request)
+				if m != nil {
+					m.ObserveStatic(c.Writer.Status())
+				}
+			})
+		}
 	}
-	if strings.HasSuffix(path, ".svg") {
-		return "image/svg+xml"
+	if err != nil {
+		slog.Warn("Failed to load embedded static files", "error", err)
 	}
-	if strings.HasSuffix(path, ".woff") {
-		return "font/woff"
+
+	return r
+}
+
+// buildTime returns the modification time of the executable, which is when
+// the embedded frontend was last built into it
+func buildTime() time.Time {
+	if exe, err := os.Executable(); err == nil {
+		if fi, err := os.Stat(exe); err == nil {
+			return fi.ModTime()
+		}
 	}
-	if strings.HasSuffix(path, ".woff2") {
-		return "font/woff2"
+	return time.Now()
+}
+
+// corsMiddleware builds the CORS middleware with the per-group overrides
+func corsMiddleware(cfg config.CORS) gin.HandlerFunc {
+	prefixes := make([]string, 0, len(cfg.Groups))
+	for prefix := range cfg.Groups {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	groups := make([]api.CORSGroup, 0, len(prefixes))
+	for _, prefix := range prefixes {
+		groups = append(groups, api.CORSGroup{Prefix: prefix, Policy: corsPolicy(cfg.Group(prefix))})
+	}
+	return api.CORS(corsPolicy(cfg), groups...)
+}
+
+// rateLimitMiddleware builds the rate limit middleware with the per-group
+// limits
+func rateLimitMiddleware(cfg config.RateLimit) gin.HandlerFunc {
+	prefixes := make([]string, 0, len(cfg.Groups))
+	for prefix := range cfg.Groups {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+
+	opts := api.RateLimitOptions{
+		Limit:       rateLimit(cfg),
+		Paths:       cfg.Paths,
+		IdleTimeout: cfg.IdleTimeout.Duration,
+	}
+	for _, prefix := range prefixes {
+		opts.Groups = append(opts.Groups, api.RateLimitGroup{Prefix: prefix, Limit: rateLimit(cfg.Group(prefix))})
+	}
+	return api.RateLimit(opts)
+}
+
+// rateLimit converts a rate limit config section to a bucket limit
+func rateLimit(cfg config.RateLimit) ratelimit.Limit {
+	return ratelimit.Limit{Requests: cfg.Requests, Period: cfg.Period.Duration, Burst: cfg.Burst}
+}
+
+// corsPolicy converts a CORS config section to the middleware policy
+func corsPolicy(cfg config.CORS) api.CORSPolicy {
+	return api.CORSPolicy{
+		AllowOrigins:     cfg.AllowOrigins,
+		AllowCredentials: cfg.AllowCredentials,
+		AllowMethods:     cfg.AllowMethods,
+		AllowHeaders:     cfg.AllowHeaders,
+		ExposeHeaders:    cfg.ExposeHeaders,
+		MaxAge:           cfg.MaxAge.Duration,
 	}
-	return "application/octet-stream"
 }
@@ -1,9 +1,21 @@
 package main
 
 import (
+	"bytes"
+	"context"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
 	"os"
+	"strings"
 	"testing"
 	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lRoccoon/utils-helper/internal/config"
+	"github.com/lRoccoon/utils-helper/internal/metrics"
+	"github.com/lRoccoon/utils-helper/internal/service"
 )
 
 func TestMainEnvironment(t *testing.T) {
@@ -32,32 +44,61 @@ func TestMainDefaultPort(t *testing.T) {
 	}
 }
 
-func TestGetContentType(t *testing.T) {
-	tests := []struct {
-		path     string
-		expected string
-	}{
-		{"index.html", "text/html; charset=utf-8"},
-		{"script.js", "application/javascript"},
-		{"style.css", "text/css"},
-		{"data.json", "application/json"},
-		{"image.png", "image/png"},
-		{"photo.jpg", "image/jpeg"},
-		{"photo.jpeg", "image/jpeg"},
-		{"icon.svg", "image/svg+xml"},
-		{"font.woff", "font/woff"},
-		{"font.woff2", "font/woff2"},
-		{"unknown.xyz", "application/octet-stream"},
-		{"", "application/octet-stream"},
-	}
-
-	for _, tt := range tests {
-		t.Run(tt.path, func(t *testing.T) {
-			got := getContentType(tt.path)
-			if got != tt.expected {
-				t.Errorf("getContentType(%q) = %q, want %q", tt.path, got, tt.expected)
-			}
-		})
+func TestGeoConfigFromEnv(t *testing.T) {
+	os.Setenv("GEOIP_MMDB_PATH", "/data/GeoLite2-City.mmdb")
+	os.Setenv("GEOIP_HTTP_PROVIDER", "ipinfo")
+	os.Setenv("GEOIP_PROVIDERS", "mmdb, http")
+	defer func() {
+		os.Unsetenv("GEOIP_MMDB_PATH")
+		os.Unsetenv("GEOIP_HTTP_PROVIDER")
+		os.Unsetenv("GEOIP_PROVIDERS")
+	}()
+
+	loaded, err := config.Load(nil)
+	if err != nil {
+		t.Fatalf("Load: %v", err)
+	}
+	cfg := geoConfig(loaded.GeoIP)
+	if cfg.MMDBPath != "/data/GeoLite2-City.mmdb" {
+		t.Errorf("MMDBPath = %q", cfg.MMDBPath)
+	}
+	if cfg.HTTPProvider != "ipinfo" {
+		t.Errorf("HTTPProvider = %q", cfg.HTTPProvider)
+	}
+	if len(cfg.Providers) != 2 || cfg.Providers[0] != "mmdb" || cfg.Providers[1] != "http" {
+		t.Errorf("Providers = %v", cfg.Providers)
+	}
+}
+
+func TestConfigPrintCommand(t *testing.T) {
+	os.Setenv("GEOIP_HTTP_TOKEN", "s3cret")
+	defer os.Unsetenv("GEOIP_HTTP_TOKEN")
+
+	var stdout, stderr bytes.Buffer
+	code := runConfigCommand([]string{"print", "-server.listen", ":9090", "-modules", "ip,net"}, &stdout, &stderr)
+	if code != 0 {
+		t.Fatalf("exit code %d, stderr: %s", code, stderr.String())
+	}
+	out := stdout.String()
+	for _, want := range []string{`listen: :9090`, "- ip\n", "- net\n", "http_token: <redacted>"} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output missing %q:\n%s", want, out)
+		}
+	}
+	if strings.Contains(out, "s3cret") {
+		t.Error("output contains the geolocation service token")
+	}
+
+	stderr.Reset()
+	if code := runConfigCommand([]string{"print", "-log.level", "loud"}, &stdout, &stderr); code != 1 {
+		t.Errorf("invalid config exit code = %d, want 1", code)
+	}
+	if !strings.Contains(stderr.String(), "log.level") {
+		t.Errorf("stderr = %q, want the failing key", stderr.String())
+	}
+
+	if code := runConfigCommand(nil, &stdout, &stderr); code != 2 {
+		t.Errorf("missing subcommand exit code = %d, want 2", code)
 	}
 }
 
@@ -70,3 +111,201 @@ func TestMain(m *testing.M) {
 
 	os.Exit(code)
 }
+
+func TestMetricsEndpoint(t *testing.T) {
+	admin, adminKey, err := service.GenerateAPIKey("admin", []string{service.ScopeAdmin})
+	if err != nil {
+		t.Fatal(err)
+	}
+	reader, readerKey, err := service.GenerateAPIKey("reader", []string{"read:ip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	store, err := service.NewAPIKeyStore("", []service.APIKey{adminKey, readerKey})
+	if err != nil {
+		t.Fatal(err)
+	}
+	service.SetAPIKeyStore(store)
+	t.Cleanup(func() { service.SetAPIKeyStore(nil) })
+
+	cfg := testConfig()
+	r := setupRouter(cfg, metrics.New())
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/holiday/2024-10-01", nil))
+
+	// On the public listener the metrics need an admin key
+	for _, tt := range []struct {
+		key  string
+		want int
+	}{
+		{"", http.StatusUnauthorized},
+		{reader, http.StatusForbidden},
+		{admin, http.StatusOK},
+	} {
+		req := httptest.NewRequest("GET", "/metrics", nil)
+		if tt.key != "" {
+			req.Header.Set("Authorization", "Bearer "+tt.key)
+		}
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+		if w.Code != tt.want {
+			t.Fatalf("GET /metrics with key %q = %d, want %d", tt.key, w.Code, tt.want)
+		}
+		if tt.want == http.StatusOK {
+			if want := `route="/api/holiday/:date"`; !strings.Contains(w.Body.String(), want) {
+				t.Errorf("metrics do not contain %s", want)
+			}
+		}
+	}
+
+	// Clients outside the allow sets are turned away before the key check
+	cfg.IPSets.Allow = []string{"office"}
+	r = setupRouter(cfg, metrics.New())
+	req := httptest.NewRequest("GET", "/metrics", nil)
+	req.Header.Set("Authorization", "Bearer "+admin)
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	if w.Code != http.StatusForbidden {
+		t.Errorf("GET /metrics outside the allow sets = %d, want 403", w.Code)
+	}
+	cfg.IPSets.Allow = nil
+
+	// With an admin listener the main router does not serve them
+	cfg.Metrics.Listen = "127.0.0.1:9100"
+	r = setupRouter(cfg, metrics.New())
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/metrics", nil))
+	if w.Code != http.StatusNotFound {
+		t.Errorf("GET /metrics with an admin listener = %d, want 404", w.Code)
+	}
+}
+
+func TestServeGracefulShutdown(t *testing.T) {
+	cfg := testConfig()
+	cfg.Server.ShutdownDelay = config.Duration{Duration: 50 * time.Millisecond}
+	cfg.Server.ShutdownTimeout = config.Duration{Duration: 2 * time.Second}
+
+	started := make(chan struct{})
+	release := make(chan struct{})
+	r := setupRouter(cfg, nil)
+	r.GET("/slow", func(c *gin.Context) {
+		close(started)
+		<-release
+		c.String(http.StatusOK, "done")
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	base := "http://" + ln.Addr().String()
+	srv := newServer(cfg.Server, r)
+	if srv.ReadHeaderTimeout != cfg.Server.ReadHeaderTimeout.Duration || srv.MaxHeaderBytes != cfg.Server.MaxHeaderBytes {
+		t.Errorf("server limits not applied: %+v", srv)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, cfg.Server, endpoint{srv, ln})
+	}()
+
+	slow := make(chan string, 1)
+	go func() {
+		resp, err := http.Get(base + "/slow")
+		if err != nil {
+			slow <- err.Error()
+			return
+		}
+		defer resp.Body.Close()
+		body, _ := io.ReadAll(resp.Body)
+		slow <- string(body)
+	}()
+	<-started
+
+	resp, err := http.Get(base + "/ready")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		t.Errorf("/ready before shutdown = %d, want 200", resp.StatusCode)
+	}
+
+	cancel()
+	time.Sleep(10 * time.Millisecond)
+
+	// During the shutdown delay the listener stays open and readiness fails
+	resp, err = http.Get(base + "/ready")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode != http.StatusServiceUnavailable {
+		t.Errorf("/ready while draining = %d, want 503", resp.StatusCode)
+	}
+
+	close(release)
+	if got := <-slow; got != "done" {
+		t.Errorf("in-flight request = %q, want it to complete", got)
+	}
+	if err := <-served; err != nil {
+		t.Errorf("serve = %v", err)
+	}
+	if _, err := http.Get(base + "/health"); err == nil {
+		t.Error("server still accepting connections after shutdown")
+	}
+}
+
+func TestServeShutdownTimeout(t *testing.T) {
+	cfg := testConfig()
+	cfg.Server.ShutdownDelay = config.Duration{}
+	cfg.Server.ShutdownTimeout = config.Duration{Duration: 50 * time.Millisecond}
+
+	started := make(chan struct{})
+	r := gin.New()
+	r.GET("/hang", func(c *gin.Context) {
+		close(started)
+		<-c.Request.Context().Done()
+	})
+
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx, cancel := context.WithCancel(context.Background())
+	served := make(chan error, 1)
+	go func() {
+		served <- serve(ctx, cfg.Server, endpoint{newServer(cfg.Server, r), ln})
+	}()
+
+	go http.Get("http://" + ln.Addr().String() + "/hang")
+	<-started
+	cancel()
+
+	select {
+	case err := <-served:
+		if err == nil {
+			t.Error("serve returned nil with a request still running")
+		}
+	case <-time.After(2 * time.Second):
+		t.Fatal("serve did not give up after the shutdown timeout")
+	}
+}
+
+func TestIPAccessControlConfig(t *testing.T) {
+	cfg := testConfig()
+	cfg.IPSets.Allow = []string{"office"}
+	r := setupRouter(cfg, nil)
+
+	// No rule set holds the test client, so only health checks get through
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/api/holiday/2024-10-01", nil))
+	if w.Code != http.StatusForbidden {
+		t.Errorf("GET /api/holiday outside the allow sets = %d, want 403", w.Code)
+	}
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest("GET", "/health", nil))
+	if w.Code != http.StatusOK {
+		t.Errorf("GET /health outside the allow sets = %d, want 200", w.Code)
+	}
+}
@@ -0,0 +1,68 @@
+package api
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lRoccoon/utils-helper/internal/api/handler"
+	"github.com/lRoccoon/utils-helper/internal/service"
+)
+
+// APIKeyHeader carries an API key for clients that cannot set Authorization
+const APIKeyHeader = "X-API-Key"
+
+// Context keys recording that the API key of a request was checked, and
+// that the key presented was invalid
+const (
+	apiKeyCheckedKey = "apiKeyChecked"
+	apiKeyInvalidKey = "apiKeyInvalid"
+)
+
+// IdentifyAPIKey checks the API key a request presents like Authenticate,
+// but lets requests with an invalid key continue. Placed before RateLimit,
+// it gives valid keys their own buckets while failed guesses are counted
+// against the client address; Authenticate later in the chain rejects them.
+func IdentifyAPIKey(c *gin.Context) {
+	identifyAPIKey(c)
+	c.Next()
+}
+
+// Authenticate checks the API key a request presents in X-API-Key or as an
+// Authorization bearer token, and records it for scope checks and per-key
+// rate limits. Requests without a key continue anonymously; which routes
+// need one is decided by the route guards. Bearer tokens without the API
+// key prefix are ignored.
+func Authenticate(c *gin.Context) {
+	if !c.GetBool(apiKeyCheckedKey) {
+		identifyAPIKey(c)
+	}
+	if c.GetBool(apiKeyInvalidKey) {
+		c.Header("WWW-Authenticate", `Bearer realm="utils-helper", error="invalid_token"`)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, handler.ErrorResponse(c, "Invalid API key"))
+		return
+	}
+	c.Next()
+}
+
+// identifyAPIKey records the API key a request presents, or marks the
+// request if the key is invalid
+func identifyAPIKey(c *gin.Context) {
+	c.Set(apiKeyCheckedKey, true)
+	key := c.GetHeader(APIKeyHeader)
+	if key == "" {
+		bearer, ok := strings.CutPrefix(c.GetHeader("Authorization"), "Bearer ")
+		if !ok || !strings.HasPrefix(bearer, service.APIKeyPrefix) {
+			return
+		}
+		key = bearer
+	}
+
+	stored, ok := service.GetAPIKeyStore().Authenticate(strings.TrimSpace(key))
+	if !ok {
+		c.Set(apiKeyInvalidKey, true)
+		return
+	}
+	handler.SetAPIKey(c, stored)
+	c.Set(RateLimitKey, stored.ID)
+}
@@ -0,0 +1,197 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lRoccoon/utils-helper/internal/api/handler"
+	"github.com/lRoccoon/utils-helper/internal/ratelimit"
+	"github.com/lRoccoon/utils-helper/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// useTestAPIKeys installs a key store with a key per scope list and returns
+// the secrets in the same order
+func useTestAPIKeys(t *testing.T, scopes ...[]string) []string {
+	t.Helper()
+	var secrets []string
+	var keys []service.APIKey
+	for i, s := range scopes {
+		secret, key, err := service.GenerateAPIKey("key"+string(rune('a'+i)), s)
+		require.NoError(t, err)
+		secrets = append(secrets, secret)
+		keys = append(keys, key)
+	}
+	store, err := service.NewAPIKeyStore("", keys)
+	require.NoError(t, err)
+	service.SetAPIKeyStore(store)
+	t.Cleanup(func() { service.SetAPIKeyStore(nil) })
+	return secrets
+}
+
+func authRequest(r http.Handler, method, path string, headers map[string]string) *httptest.ResponseRecorder {
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(method, path, strings.NewReader(`{"prefixes": ["10.8.0.0/16"]}`))
+	req.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestAuthenticate(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secrets := useTestAPIKeys(t, []string{"read:holiday"}, []string{"admin"})
+	reader, admin := secrets[0], secrets[1]
+
+	var limitKey string
+	r := gin.New()
+	r.Use(handler.RequestID, Authenticate, func(c *gin.Context) { limitKey = c.GetString(RateLimitKey) })
+	RegisterRoutes(r, "holiday")
+
+	// Public endpoints stay open to anonymous callers
+	w := authRequest(r, http.MethodGet, "/api/holiday/2024-10-01", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, limitKey)
+
+	// Keys are accepted in either header and identify the caller
+	w = authRequest(r, http.MethodGet, "/api/holiday/2024-10-01", map[string]string{"X-API-Key": reader})
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, strings.Split(reader, "_")[1], limitKey)
+	w = authRequest(r, http.MethodGet, "/api/holiday/2024-10-01", map[string]string{"Authorization": "Bearer " + reader})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// A wrong key is rejected even where none is needed
+	w = authRequest(r, http.MethodGet, "/api/holiday/2024-10-01", map[string]string{"X-API-Key": reader + "x"})
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), `error="invalid_token"`)
+	assert.Contains(t, w.Body.String(), "request_id")
+
+	// Other bearer tokens are left to the handlers
+	w = authRequest(r, http.MethodGet, "/api/holiday/2024-10-01", map[string]string{"Authorization": "Bearer legacy"})
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	// Key usage is for administrators only and counts every request
+	w = authRequest(r, http.MethodGet, "/api/auth/keys", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = authRequest(r, http.MethodGet, "/api/auth/keys", map[string]string{"X-API-Key": reader})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = authRequest(r, http.MethodGet, "/api/auth/keys", map[string]string{"X-API-Key": admin})
+	require.Equal(t, http.StatusOK, w.Code)
+
+	var list handler.APIKeyListResponse
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	require.Len(t, list.Keys, 2)
+	for _, k := range list.Keys {
+		switch k.Name {
+		case "keya":
+			assert.Equal(t, uint64(3), k.Requests)
+		case "keyb":
+			assert.Equal(t, uint64(1), k.Requests)
+		}
+		assert.NotContains(t, w.Body.String(), "sha256:")
+	}
+}
+
+func TestInvalidKeysAreRateLimited(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	reader := useTestAPIKeys(t, []string{"read:holiday"})[0]
+
+	r := gin.New()
+	r.Use(handler.RequestID, IdentifyAPIKey, RateLimit(RateLimitOptions{
+		Limit:       ratelimit.Limit{Requests: 2, Period: time.Minute},
+		Paths:       []string{"/api"},
+		IdleTimeout: time.Minute,
+	}), Authenticate)
+	RegisterRoutes(r, "holiday")
+
+	// Failed guesses are rejected and use up the client's bucket
+	bad := map[string]string{"X-API-Key": reader + "x"}
+	assert.Equal(t, http.StatusUnauthorized, authRequest(r, http.MethodGet, "/api/holiday/2024-10-01", bad).Code)
+	assert.Equal(t, http.StatusUnauthorized, authRequest(r, http.MethodGet, "/api/holiday/2024-10-01", bad).Code)
+	assert.Equal(t, http.StatusTooManyRequests, authRequest(r, http.MethodGet, "/api/holiday/2024-10-01", bad).Code)
+	assert.Equal(t, http.StatusTooManyRequests, authRequest(r, http.MethodGet, "/api/holiday/2024-10-01", nil).Code)
+
+	// A valid key has a bucket of its own
+	w := authRequest(r, http.MethodGet, "/api/holiday/2024-10-01", map[string]string{"X-API-Key": reader})
+	assert.Equal(t, http.StatusOK, w.Code)
+}
+
+func TestLockedDownModules(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secrets := useTestAPIKeys(t, []string{"read:holiday"}, []string{"write:ip"}, []string{"read:ipsets"})
+	holidayKey, ipKey, setsKey := secrets[0], secrets[1], secrets[2]
+
+	store, err := service.NewIPRuleStore(filepath.Join(t.TempDir(), "ipsets.json"))
+	require.NoError(t, err)
+	service.SetIPRuleStore(store)
+	t.Cleanup(func() { service.SetIPRuleStore(nil) })
+
+	handler.SetAnonymousModules([]string{"ip"})
+	t.Cleanup(func() {
+		handler.SetAnonymousModules([]string{"ip", "request", "ua", "dns", "mac", "ports", "net", "holiday"})
+	})
+
+	r := gin.New()
+	r.Use(handler.RequestID, Authenticate)
+	RegisterRoutes(r, "ip", "holiday", "net")
+
+	// Rule sets are not readable anonymously even in an open module
+	assert.Equal(t, http.StatusOK, authRequest(r, http.MethodGet, "/api/ip/match?ip=10.0.0.1", nil).Code)
+	assert.Equal(t, http.StatusUnauthorized, authRequest(r, http.MethodGet, "/api/ip/sets", nil).Code)
+	assert.Equal(t, http.StatusForbidden, authRequest(r, http.MethodGet, "/api/ip/sets", map[string]string{"X-API-Key": ipKey}).Code)
+	assert.Equal(t, http.StatusOK, authRequest(r, http.MethodGet, "/api/ip/sets", map[string]string{"X-API-Key": setsKey}).Code)
+
+	w := authRequest(r, http.MethodGet, "/api/holiday/2024-10-01", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	assert.Equal(t, `Bearer realm="utils-helper"`, w.Header().Get("WWW-Authenticate"))
+	assert.Equal(t, http.StatusOK, authRequest(r, http.MethodGet, "/api/holiday/2024-10-01", map[string]string{"X-API-Key": holidayKey}).Code)
+
+	// Locked down modules need their own scope, whatever the method
+	w = authRequest(r, http.MethodPost, "/api/net/cidr/aggregate", map[string]string{"X-API-Key": holidayKey})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	assert.Contains(t, w.Header().Get("WWW-Authenticate"), `scope="read:net"`)
+
+	// Rule set changes need write:ip
+	w = authRequest(r, http.MethodPut, "/api/ip/sets/vpn", map[string]string{"X-API-Key": holidayKey})
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = authRequest(r, http.MethodPut, "/api/ip/sets/vpn", map[string]string{"Authorization": "Bearer " + ipKey})
+	assert.Equal(t, http.StatusOK, w.Code)
+	w = authRequest(r, http.MethodDelete, "/api/ip/sets/vpn", nil)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = authRequest(r, http.MethodDelete, "/api/ip/sets/vpn", map[string]string{"X-API-Key": ipKey})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+}
+
+func TestLockedDownIPAlias(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	secrets := useTestAPIKeys(t, []string{"read:ip"}, []string{"read:holiday"})
+	ipKey, holidayKey := secrets[0], secrets[1]
+
+	handler.SetAnonymousModules([]string{"holiday"})
+	t.Cleanup(func() {
+		handler.SetAnonymousModules([]string{"ip", "request", "ua", "dns", "mac", "ports", "net", "holiday"})
+	})
+
+	r := gin.New()
+	r.Use(handler.RequestID, Authenticate)
+	RegisterRoutes(r, "ip", "holiday")
+
+	// The /ip alias is guarded like the routes of the ip module
+	curl := map[string]string{"User-Agent": "curl/8.5.0"}
+	assert.Equal(t, http.StatusUnauthorized, authRequest(r, http.MethodGet, "/ip", curl).Code)
+	curl["X-API-Key"] = holidayKey
+	assert.Equal(t, http.StatusForbidden, authRequest(r, http.MethodGet, "/ip", curl).Code)
+	curl["X-API-Key"] = ipKey
+	w := authRequest(r, http.MethodGet, "/ip", curl)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "192.0.2.1\n", w.Body.String())
+}
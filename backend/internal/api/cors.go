@@ -0,0 +1,192 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// CORSPolicy controls which browser origins may call the API
+type CORSPolicy struct {
+	// AllowOrigins lists origins such as https://portal.example.com, wildcard
+	// subdomains such as https://*.example.com, or "*" for any origin
+	AllowOrigins     []string
+	AllowCredentials bool
+	AllowMethods     []string
+	AllowHeaders     []string // "*" allows whatever the preflight asks for
+	ExposeHeaders    []string
+	MaxAge           time.Duration // how long browsers may cache a preflight
+}
+
+// CORSGroup applies a policy to the routes under a path prefix
+type CORSGroup struct {
+	Prefix string
+	Policy CORSPolicy
+}
+
+// compiledCORS is a policy with its header values prepared
+type compiledCORS struct {
+	anyOrigin   bool
+	origins     map[string]bool
+	wildcards   []wildcardOrigin
+	credentials bool
+	methods     string
+	headers     string
+	anyHeader   bool
+	expose      string
+	maxAge      string
+}
+
+// wildcardOrigin matches subdomains of a host for one scheme and port
+type wildcardOrigin struct {
+	scheme string // "https://"
+	suffix string // ".example.com" plus the port, if any
+}
+
+// CORS returns a middleware applying the policy of the longest matching
+// group prefix, or the default policy outside all groups. Preflight
+// requests are answered directly with 204.
+func CORS(policy CORSPolicy, groups ...CORSGroup) gin.HandlerFunc {
+	def := compileCORS(policy)
+	type group struct {
+		prefix string
+		policy *compiledCORS
+	}
+	compiled := make([]group, 0, len(groups))
+	for _, g := range groups {
+		compiled = append(compiled, group{strings.TrimSuffix(g.Prefix, "/"), compileCORS(g.Policy)})
+	}
+
+	return func(c *gin.Context) {
+		p, longest := def, -1
+		path := c.Request.URL.Path
+		for _, g := range compiled {
+			if len(g.prefix) > longest && underPrefix(path, g.prefix) {
+				p, longest = g.policy, len(g.prefix)
+			}
+		}
+
+		if p.handle(c) {
+			c.AbortWithStatus(http.StatusNoContent)
+			return
+		}
+		c.Next()
+	}
+}
+
+// underPrefix reports whether path is prefix or below it, matching whole
+// segments only
+func underPrefix(path, prefix string) bool {
+	return path == prefix || strings.HasPrefix(path, prefix+"/")
+}
+
+// compileCORS prepares the header values of a policy
+func compileCORS(policy CORSPolicy) *compiledCORS {
+	p := &compiledCORS{
+		origins:     make(map[string]bool),
+		credentials: policy.AllowCredentials,
+		methods:     strings.Join(policy.AllowMethods, ", "),
+		expose:      strings.Join(policy.ExposeHeaders, ", "),
+	}
+	for _, origin := range policy.AllowOrigins {
+		origin = strings.ToLower(origin)
+		switch {
+		case origin == "*":
+			p.anyOrigin = true
+		case strings.Contains(origin, "://*."):
+			scheme, host, _ := strings.Cut(origin, "://*")
+			p.wildcards = append(p.wildcards, wildcardOrigin{scheme + "://", host})
+		default:
+			p.origins[origin] = true
+		}
+	}
+	var headers []string
+	for _, h := range policy.AllowHeaders {
+		if h == "*" {
+			p.anyHeader = true
+		} else {
+			headers = append(headers, h)
+		}
+	}
+	p.headers = strings.Join(headers, ", ")
+	if policy.MaxAge > 0 {
+		p.maxAge = strconv.Itoa(int(policy.MaxAge.Seconds()))
+	}
+	return p
+}
+
+// allowed reports whether a request origin is allowed
+func (p *compiledCORS) allowed(origin string) bool {
+	if p.anyOrigin {
+		return true
+	}
+	origin = strings.ToLower(origin)
+	if p.origins[origin] {
+		return true
+	}
+	for _, w := range p.wildcards {
+		if w.match(origin) {
+			return true
+		}
+	}
+	return false
+}
+
+// match reports whether origin is a subdomain, at any depth, of the
+// wildcard's host with the same scheme and port
+func (w wildcardOrigin) match(origin string) bool {
+	rest, ok := strings.CutPrefix(origin, w.scheme)
+	if !ok || !strings.HasSuffix(rest, w.suffix) {
+		return false
+	}
+	sub := rest[:len(rest)-len(w.suffix)]
+	return sub != "" && !strings.ContainsAny(sub, "/:@")
+}
+
+// handle writes the CORS headers and reports whether the request is a
+// preflight that has been answered
+func (p *compiledCORS) handle(c *gin.Context) bool {
+	h := c.Writer.Header()
+	origin := c.GetHeader("Origin")
+	preflight := c.Request.Method == http.MethodOptions
+
+	// A response for any origin without credentials is the same for every
+	// caller, so it can be cached regardless of Origin
+	if p.anyOrigin && !p.credentials {
+		h.Set("Access-Control-Allow-Origin", "*")
+	} else {
+		h.Add("Vary", "Origin")
+		if origin == "" || !p.allowed(origin) {
+			return preflight
+		}
+		h.Set("Access-Control-Allow-Origin", origin)
+		if p.credentials {
+			h.Set("Access-Control-Allow-Credentials", "true")
+		}
+	}
+
+	if !preflight {
+		if p.expose != "" {
+			h.Set("Access-Control-Expose-Headers", p.expose)
+		}
+		return false
+	}
+
+	h.Add("Vary", "Access-Control-Request-Method")
+	h.Add("Vary", "Access-Control-Request-Headers")
+	h.Set("Access-Control-Allow-Methods", p.methods)
+	headers := p.headers
+	if p.anyHeader {
+		headers = c.GetHeader("Access-Control-Request-Headers")
+	}
+	if headers != "" {
+		h.Set("Access-Control-Allow-Headers", headers)
+	}
+	if p.maxAge != "" {
+		h.Set("Access-Control-Max-Age", p.maxAge)
+	}
+	return true
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func corsRouter(policy CORSPolicy, groups ...CORSGroup) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(CORS(policy, groups...))
+	r.GET("/api/ip", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	r.GET("/api/ip/sets", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	return r
+}
+
+func corsRequest(r *gin.Engine, method, path, origin string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if origin != "" {
+		req.Header.Set("Origin", origin)
+	}
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestCORSAnyOrigin(t *testing.T) {
+	r := corsRouter(CORSPolicy{
+		AllowOrigins: []string{"*"},
+		AllowMethods: []string{"GET", "POST", "OPTIONS"},
+		AllowHeaders: []string{"Content-Type"},
+	})
+
+	w := corsRequest(r, http.MethodGet, "/api/ip", "https://anywhere.example", nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Empty(t, w.Header().Get("Vary"))
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+
+	w = corsRequest(r, http.MethodOptions, "/api/ip", "https://anywhere.example", map[string]string{
+		"Access-Control-Request-Method": "POST",
+	})
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	assert.Equal(t, "GET, POST, OPTIONS", w.Header().Get("Access-Control-Allow-Methods"))
+	assert.Equal(t, "Content-Type", w.Header().Get("Access-Control-Allow-Headers"))
+}
+
+func TestCORSOriginAllowlist(t *testing.T) {
+	r := corsRouter(CORSPolicy{
+		AllowOrigins:     []string{"https://portal.example.com", "https://*.corp.example.com", "http://*.dev.example.com:3000"},
+		AllowCredentials: true,
+		AllowMethods:     []string{"GET", "PUT"},
+		AllowHeaders:     []string{"*"},
+		ExposeHeaders:    []string{"X-Request-ID"},
+		MaxAge:           10 * time.Minute,
+	})
+
+	tests := []struct {
+		origin  string
+		allowed bool
+	}{
+		{"https://portal.example.com", true},
+		{"HTTPS://Portal.Example.com", true},
+		{"https://a.corp.example.com", true},
+		{"https://a.b.corp.example.com", true},
+		{"http://app.dev.example.com:3000", true},
+		{"https://corp.example.com", false},
+		{"https://evilcorp.example.com", false},
+		{"http://a.corp.example.com", false},
+		{"https://a.corp.example.com:8443", false},
+		{"http://app.dev.example.com", false},
+		{"https://portal.example.com.evil.test", false},
+		{"null", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.origin, func(t *testing.T) {
+			w := corsRequest(r, http.MethodGet, "/api/ip", tt.origin, nil)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Contains(t, w.Header().Values("Vary"), "Origin")
+			if tt.allowed {
+				assert.Equal(t, tt.origin, w.Header().Get("Access-Control-Allow-Origin"))
+				assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+				assert.Equal(t, "X-Request-ID", w.Header().Get("Access-Control-Expose-Headers"))
+			} else {
+				assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+				assert.Empty(t, w.Header().Get("Access-Control-Allow-Credentials"))
+			}
+		})
+	}
+
+	t.Run("preflight", func(t *testing.T) {
+		w := corsRequest(r, http.MethodOptions, "/api/ip", "https://a.corp.example.com", map[string]string{
+			"Access-Control-Request-Method":  "PUT",
+			"Access-Control-Request-Headers": "Authorization, X-Custom",
+		})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Equal(t, "https://a.corp.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Equal(t, "GET, PUT", w.Header().Get("Access-Control-Allow-Methods"))
+		assert.Equal(t, "Authorization, X-Custom", w.Header().Get("Access-Control-Allow-Headers"))
+		assert.Equal(t, "600", w.Header().Get("Access-Control-Max-Age"))
+		assert.ElementsMatch(t, []string{"Origin", "Access-Control-Request-Method", "Access-Control-Request-Headers"}, w.Header().Values("Vary"))
+	})
+
+	t.Run("preflight from disallowed origin", func(t *testing.T) {
+		w := corsRequest(r, http.MethodOptions, "/api/ip", "https://evil.test", map[string]string{
+			"Access-Control-Request-Method": "PUT",
+		})
+		assert.Equal(t, http.StatusNoContent, w.Code)
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+		assert.Empty(t, w.Header().Get("Access-Control-Allow-Methods"))
+	})
+}
+
+func TestCORSGroups(t *testing.T) {
+	r := corsRouter(
+		CORSPolicy{AllowOrigins: []string{"*"}, AllowMethods: []string{"GET"}},
+		CORSGroup{Prefix: "/api/ip/sets", Policy: CORSPolicy{
+			AllowOrigins:     []string{"https://admin.example.com"},
+			AllowCredentials: true,
+			AllowMethods:     []string{"GET", "PUT", "DELETE"},
+		}},
+	)
+
+	w := corsRequest(r, http.MethodGet, "/api/ip", "https://admin.example.com", nil)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+
+	w = corsRequest(r, http.MethodGet, "/api/ip/sets", "https://admin.example.com", nil)
+	assert.Equal(t, "https://admin.example.com", w.Header().Get("Access-Control-Allow-Origin"))
+	assert.Equal(t, "true", w.Header().Get("Access-Control-Allow-Credentials"))
+
+	w = corsRequest(r, http.MethodOptions, "/api/ip/sets/office", "https://admin.example.com", map[string]string{
+		"Access-Control-Request-Method": "DELETE",
+	})
+	assert.Equal(t, "GET, PUT, DELETE", w.Header().Get("Access-Control-Allow-Methods"))
+
+	w = corsRequest(r, http.MethodGet, "/api/ip/sets", "https://other.example.com", nil)
+	assert.Empty(t, w.Header().Get("Access-Control-Allow-Origin"))
+
+	// The prefix matches whole path segments only
+	w = corsRequest(r, http.MethodGet, "/api/ip/setsx", "https://admin.example.com", nil)
+	assert.Equal(t, "*", w.Header().Get("Access-Control-Allow-Origin"))
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lRoccoon/utils-helper/internal/service"
+)
+
+// apiKeyKey stores the authenticated API key in the Gin context
+const apiKeyKey = "apiKey"
+
+// authRealm names the protection space in WWW-Authenticate challenges
+const authRealm = `Bearer realm="utils-helper"`
+
+var (
+	// anonymousModules are the modules readable without an API key; nil
+	// means every module
+	anonymousModules   map[string]bool
+	anonymousModulesMu sync.RWMutex
+)
+
+// APIKeyInfo represents an API key and its usage since startup
+type APIKeyInfo struct {
+	ID       string     `json:"id"`
+	Name     string     `json:"name"`
+	Scopes   []string   `json:"scopes"`
+	Created  *time.Time `json:"created,omitempty"`
+	Requests uint64     `json:"requests"`
+	LastUsed *time.Time `json:"last_used,omitempty"`
+}
+
+// APIKeyListResponse represents the list of API keys
+type APIKeyListResponse struct {
+	Keys []APIKeyInfo `json:"keys"`
+}
+
+// SetAnonymousModules sets the modules whose endpoints may be read without
+// an API key. All others need a key with the read:<module> scope.
+func SetAnonymousModules(modules []string) {
+	set := make(map[string]bool, len(modules))
+	for _, m := range modules {
+		set[m] = true
+	}
+	anonymousModulesMu.Lock()
+	anonymousModules = set
+	anonymousModulesMu.Unlock()
+}
+
+// SetAPIKey records the API key a request was authenticated with
+func SetAPIKey(c *gin.Context, key service.APIKey) {
+	c.Set(apiKeyKey, key)
+}
+
+// GetAPIKey returns the API key of the current request, if it presented one
+func GetAPIKey(c *gin.Context) (service.APIKey, bool) {
+	v, ok := c.Get(apiKeyKey)
+	if !ok {
+		return service.APIKey{}, false
+	}
+	key, ok := v.(service.APIKey)
+	return key, ok
+}
+
+// RequireScope returns a middleware that admits only requests made with an
+// API key granting scope
+func RequireScope(scope string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authorizeScope(c, scope) {
+			c.Next()
+		}
+	}
+}
+
+// RequireRead returns a middleware guarding the endpoints of a module,
+// which anonymous callers may use unless the module is locked down
+func RequireRead(module string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		if authorizeRead(c, module) {
+			c.Next()
+		}
+	}
+}
+
+// authorizeRead checks that the caller may read a module, responding with
+// an error if not
+func authorizeRead(c *gin.Context, module string) bool {
+	anonymousModulesMu.RLock()
+	open := anonymousModules == nil || anonymousModules[module]
+	anonymousModulesMu.RUnlock()
+
+	return open || authorizeScope(c, "read:"+module)
+}
+
+// ListAPIKeys handles GET /api/auth/keys requests
+func ListAPIKeys(c *gin.Context) {
+	usage := service.GetAPIKeyStore().Usage()
+	keys := make([]APIKeyInfo, 0, len(usage))
+	for _, u := range usage {
+		info := APIKeyInfo{ID: u.ID, Name: u.Name, Scopes: u.Scopes, Requests: u.Requests}
+		if !u.Created.IsZero() {
+			info.Created = &u.Created
+		}
+		if !u.LastUsed.IsZero() {
+			info.LastUsed = &u.LastUsed
+		}
+		keys = append(keys, info)
+	}
+	respond(c, http.StatusOK, APIKeyListResponse{Keys: keys})
+}
+
+// authorizeScope checks the API key of a request grants scope, aborting
+// with 401 when there is no key and 403 when the key falls short
+func authorizeScope(c *gin.Context, scope string) bool {
+	key, ok := GetAPIKey(c)
+	if !ok {
+		c.Header("WWW-Authenticate", authRealm)
+		c.AbortWithStatusJSON(http.StatusUnauthorized, ErrorResponse(c, "API key required"))
+		return false
+	}
+	if !key.HasScope(scope) {
+		c.Header("WWW-Authenticate", authRealm+`, error="insufficient_scope", scope="`+scope+`"`)
+		c.AbortWithStatusJSON(http.StatusForbidden, ErrorResponse(c, "API key lacks the "+scope+" scope"))
+		return false
+	}
+	return true
+}
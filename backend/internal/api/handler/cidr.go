@@ -0,0 +1,236 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lRoccoon/utils-helper/internal/service"
+)
+
+// CIDRSetRequest represents the JSON body of /api/net/cidr/aggregate
+type CIDRSetRequest struct {
+	Prefixes  []string `json:"prefixes"`
+	Exclude   []string `json:"exclude"`
+	Intersect []string `json:"intersect"`
+}
+
+// CIDRSetResponse represents the aggregated address set response
+type CIDRSetResponse struct {
+	Prefixes []string      `json:"prefixes"`
+	Counts   CIDRSetCounts `json:"counts"`
+}
+
+// CIDRSetCounts summarizes an aggregation
+type CIDRSetCounts struct {
+	InputEntries     int    `json:"input_entries"`
+	OutputPrefixes   int    `json:"output_prefixes"`
+	IPv4Prefixes     int    `json:"ipv4_prefixes"`
+	IPv6Prefixes     int    `json:"ipv6_prefixes"`
+	IPv4Addresses    string `json:"ipv4_addresses"`
+	IPv6Addresses    string `json:"ipv6_addresses"`
+	RemovedAddresses string `json:"removed_addresses"`
+}
+
+// maxCIDRSetBodySize bounds the address list accepted by /api/net/cidr/aggregate
+const maxCIDRSetBodySize = 10 << 20
+
+// firewallNamePattern restricts chain and set names inserted into rules
+var firewallNamePattern = regexp.MustCompile(`^[A-Za-z0-9_-]{1,64}$`)
+
+// PlainText returns one prefix per line for text/plain responses
+func (r CIDRSetResponse) PlainText() string {
+	return strings.Join(r.Prefixes, "\n")
+}
+
+// AggregateCIDRs handles GET and POST /api/net/cidr/aggregate requests. It
+// merges the given addresses, prefixes and ranges into the fewest prefixes,
+// optionally keeping only those inside intersect and removing exclude.
+// A POST body may be JSON or a plain list such as a vendor range file.
+func AggregateCIDRs(c *gin.Context) {
+	var req CIDRSetRequest
+	if c.Request.Method == http.MethodPost {
+		c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, maxCIDRSetBodySize)
+		if strings.HasPrefix(c.ContentType(), "application/json") {
+			if err := c.ShouldBindJSON(&req); err != nil {
+				if bodyTooLarge(err) {
+					respondBodyTooLarge(c, maxCIDRSetBodySize)
+					return
+				}
+				respond(c, http.StatusBadRequest, ErrorResponse(c, "Invalid JSON body: "+err.Error()))
+				return
+			}
+		} else {
+			body, err := io.ReadAll(c.Request.Body)
+			if err != nil {
+				if bodyTooLarge(err) {
+					respondBodyTooLarge(c, maxCIDRSetBodySize)
+					return
+				}
+				respond(c, http.StatusBadRequest, ErrorResponse(c, "Failed to read request body"))
+				return
+			}
+			req.Prefixes = service.ParseIPSetList(string(body))
+		}
+	}
+	req.Prefixes = append(req.Prefixes, queryList(c, "prefixes")...)
+	req.Exclude = append(req.Exclude, queryList(c, "exclude")...)
+	req.Intersect = append(req.Intersect, queryList(c, "intersect")...)
+
+	if len(req.Prefixes) == 0 {
+		respond(c, http.StatusBadRequest, ErrorResponse(c, "prefixes are required"))
+		return
+	}
+
+	input, err := service.NewIPSet(req.Prefixes)
+	if err != nil {
+		respond(c, http.StatusBadRequest, ErrorResponse(c, err.Error()))
+		return
+	}
+	result := input
+	if len(req.Intersect) > 0 {
+		intersect, err := service.NewIPSet(req.Intersect)
+		if err != nil {
+			respond(c, http.StatusBadRequest, ErrorResponse(c, "intersect: "+err.Error()))
+			return
+		}
+		result = result.Intersect(intersect)
+	}
+	if len(req.Exclude) > 0 {
+		exclude, err := service.NewIPSet(req.Exclude)
+		if err != nil {
+			respond(c, http.StatusBadRequest, ErrorResponse(c, "exclude: "+err.Error()))
+			return
+		}
+		result = result.Subtract(exclude)
+	}
+
+	prefixes := result.Prefixes()
+
+	if syntax := c.Query("syntax"); syntax != "" {
+		rules, err := firewallRules(prefixes, syntax, c.DefaultQuery("action", "allow"), c.DefaultQuery("chain", "INPUT"), c.DefaultQuery("set", "allowlist"))
+		if err != nil {
+			respond(c, http.StatusBadRequest, ErrorResponse(c, err.Error()))
+			return
+		}
+		c.String(http.StatusOK, rules)
+		return
+	}
+
+	respond(c, http.StatusOK, buildCIDRSetResponse(len(req.Prefixes), input, result, prefixes))
+}
+
+// queryList collects a repeatable, comma-separated query parameter
+func queryList(c *gin.Context, key string) []string {
+	var entries []string
+	for _, v := range c.QueryArray(key) {
+		entries = append(entries, service.ParseIPSetList(v)...)
+	}
+	return entries
+}
+
+// buildCIDRSetResponse counts prefixes and addresses before and after the set operations
+func buildCIDRSetResponse(entries int, input, result *service.IPSet, prefixes []netip.Prefix) CIDRSetResponse {
+	response := CIDRSetResponse{
+		Prefixes: make([]string, 0, len(prefixes)),
+		Counts: CIDRSetCounts{
+			InputEntries:   entries,
+			OutputPrefixes: len(prefixes),
+		},
+	}
+	for _, p := range prefixes {
+		response.Prefixes = append(response.Prefixes, p.String())
+		if p.Addr().Is4() {
+			response.Counts.IPv4Prefixes++
+		} else {
+			response.Counts.IPv6Prefixes++
+		}
+	}
+
+	inV4, inV6 := input.AddressCounts()
+	outV4, outV6 := result.AddressCounts()
+	removed := inV4.Add(inV4, inV6)
+	removed.Sub(removed, outV4)
+	removed.Sub(removed, outV6)
+
+	response.Counts.IPv4Addresses = outV4.String()
+	response.Counts.IPv6Addresses = outV6.String()
+	response.Counts.RemovedAddresses = removed.String()
+	return response
+}
+
+// firewallRules renders prefixes as iptables, nftables or nginx configuration
+func firewallRules(prefixes []netip.Prefix, syntax, action, chain, set string) (string, error) {
+	if action != "allow" && action != "deny" {
+		return "", fmt.Errorf("invalid action %q, use allow or deny", action)
+	}
+	if !firewallNamePattern.MatchString(chain) || !firewallNamePattern.MatchString(set) {
+		return "", fmt.Errorf("chain and set names may only contain letters, digits, '_' and '-'")
+	}
+
+	var sb strings.Builder
+	switch syntax {
+	case "plain", "text":
+		for _, p := range prefixes {
+			sb.WriteString(p.String() + "\n")
+		}
+	case "iptables":
+		target := "ACCEPT"
+		if action == "deny" {
+			target = "DROP"
+		}
+		for _, p := range prefixes {
+			cmd := "iptables"
+			if p.Addr().Is6() {
+				cmd = "ip6tables"
+			}
+			fmt.Fprintf(&sb, "%s -A %s -s %s -j %s\n", cmd, chain, p, target)
+		}
+	case "nftables":
+		var v4, v6 []string
+		for _, p := range prefixes {
+			if p.Addr().Is4() {
+				v4 = append(v4, p.String())
+			} else {
+				v6 = append(v6, p.String())
+			}
+		}
+		writeNftSet(&sb, set+"_v4", "ipv4_addr", v4)
+		writeNftSet(&sb, set+"_v6", "ipv6_addr", v6)
+	case "nginx":
+		for _, p := range prefixes {
+			fmt.Fprintf(&sb, "%s %s;\n", action, p)
+		}
+		if action == "allow" {
+			sb.WriteString("deny all;\n")
+		}
+	default:
+		return "", fmt.Errorf("unsupported syntax %q, use plain, iptables, nftables or nginx", syntax)
+	}
+	return sb.String(), nil
+}
+
+// writeNftSet writes an nftables interval set definition, skipping empty sets
+func writeNftSet(sb *strings.Builder, name, addrType string, elements []string) {
+	if len(elements) == 0 {
+		return
+	}
+	fmt.Fprintf(sb, "set %s {\n\ttype %s\n\tflags interval\n\telements = {\n\t\t%s\n\t}\n}\n",
+		name, addrType, strings.Join(elements, ",\n\t\t"))
+}
+
+// bodyTooLarge reports whether reading a body failed at its size limit
+func bodyTooLarge(err error) bool {
+	var maxErr *http.MaxBytesError
+	return errors.As(err, &maxErr)
+}
+
+// respondBodyTooLarge rejects a body over limit bytes
+func respondBodyTooLarge(c *gin.Context, limit int64) {
+	respond(c, http.StatusRequestEntityTooLarge, ErrorResponse(c, fmt.Sprintf("Request body exceeds %d bytes", limit)))
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAggregateCIDRs(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		method         string
+		url            string
+		contentType    string
+		body           string
+		expectedStatus int
+		checkResponse  func(*testing.T, *httptest.ResponseRecorder)
+	}{
+		{
+			name:           "JSON body with exclusions",
+			method:         http.MethodPost,
+			url:            "/api/net/cidr/aggregate",
+			contentType:    "application/json",
+			body:           `{"prefixes": ["10.0.0.0/25", "10.0.0.128/25", "10.0.0.5", "2001:db8::/48"], "exclude": ["10.0.0.0/26"]}`,
+			expectedStatus: http.StatusOK,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var response CIDRSetResponse
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.Equal(t, []string{"10.0.0.64/26", "10.0.0.128/25", "2001:db8::/48"}, response.Prefixes)
+				assert.Equal(t, 4, response.Counts.InputEntries)
+				assert.Equal(t, 2, response.Counts.IPv4Prefixes)
+				assert.Equal(t, 1, response.Counts.IPv6Prefixes)
+				assert.Equal(t, "192", response.Counts.IPv4Addresses)
+				assert.Equal(t, "64", response.Counts.RemovedAddresses)
+			},
+		},
+		{
+			name:           "Plain list body with intersect",
+			method:         http.MethodPost,
+			url:            "/api/net/cidr/aggregate?intersect=10.0.0.0/8&format=text",
+			contentType:    "text/plain",
+			body:           "# vendor ranges\n10.1.0.0/16\n10.2.0.0/16\n192.0.2.0/24\n",
+			expectedStatus: http.StatusOK,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Equal(t, "10.1.0.0/16\n10.2.0.0/16\n", w.Body.String())
+			},
+		},
+		{
+			name:           "iptables syntax",
+			method:         http.MethodGet,
+			url:            "/api/net/cidr/aggregate?prefixes=192.0.2.0/24,2001:db8::/32&syntax=iptables&action=deny",
+			expectedStatus: http.StatusOK,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Equal(t, "iptables -A INPUT -s 192.0.2.0/24 -j DROP\nip6tables -A INPUT -s 2001:db8::/32 -j DROP\n", w.Body.String())
+			},
+		},
+		{
+			name:           "nftables syntax",
+			method:         http.MethodGet,
+			url:            "/api/net/cidr/aggregate?prefixes=192.0.2.0/24,198.51.100.0/24&syntax=nftables&set=office",
+			expectedStatus: http.StatusOK,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Equal(t, "set office_v4 {\n\ttype ipv4_addr\n\tflags interval\n\telements = {\n\t\t192.0.2.0/24,\n\t\t198.51.100.0/24\n\t}\n}\n", w.Body.String())
+			},
+		},
+		{
+			name:           "nginx syntax",
+			method:         http.MethodGet,
+			url:            "/api/net/cidr/aggregate?prefixes=192.0.2.0/25&prefixes=192.0.2.128/25&syntax=nginx",
+			expectedStatus: http.StatusOK,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Equal(t, "allow 192.0.2.0/24;\ndeny all;\n", w.Body.String())
+			},
+		},
+		{
+			name:           "Unsafe chain name",
+			method:         http.MethodGet,
+			url:            "/api/net/cidr/aggregate?prefixes=192.0.2.0/24&syntax=iptables&chain=INPUT%3Brm",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Invalid entry",
+			method:         http.MethodGet,
+			url:            "/api/net/cidr/aggregate?prefixes=10.0.0.0/33",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "Oversized JSON body",
+			method:         http.MethodPost,
+			url:            "/api/net/cidr/aggregate",
+			contentType:    "application/json",
+			body:           `{"prefixes": ["10.0.0.0/8"]` + strings.Repeat(" ", maxCIDRSetBodySize) + `}`,
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "Oversized plain list body",
+			method:         http.MethodPost,
+			url:            "/api/net/cidr/aggregate",
+			contentType:    "text/plain",
+			body:           strings.Repeat("10.0.0.0/8\n", maxCIDRSetBodySize/10),
+			expectedStatus: http.StatusRequestEntityTooLarge,
+		},
+		{
+			name:           "Missing prefixes",
+			method:         http.MethodGet,
+			url:            "/api/net/cidr/aggregate",
+			expectedStatus: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+
+			req := httptest.NewRequest(tt.method, tt.url, strings.NewReader(tt.body))
+			if tt.contentType != "" {
+				req.Header.Set("Content-Type", tt.contentType)
+			}
+			c.Request = req
+
+			AggregateCIDRs(c)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.checkResponse != nil {
+				tt.checkResponse(t, w)
+			}
+		})
+	}
+}
@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lRoccoon/utils-helper/internal/service"
+)
+
+// IPConvertResponse represents the IP representation converter response
+type IPConvertResponse struct {
+	Input        string `json:"input"`
+	IP           string `json:"ip"`
+	Version      string `json:"version"`
+	Expanded     string `json:"expanded,omitempty"`
+	Decimal      string `json:"decimal"`
+	Hex          string `json:"hex"`
+	Binary       string `json:"binary"`
+	IPv4Mapped   string `json:"ipv4_mapped,omitempty"`
+	SixToFour    string `json:"six_to_four,omitempty"`
+	NAT64        string `json:"nat64,omitempty"`
+	PTR          string `json:"ptr"`
+	EmbeddedIPv4 string `json:"embedded_ipv4,omitempty"`
+	Embedding    string `json:"embedding,omitempty"`
+}
+
+// ConvertIP handles GET /api/net/convert requests.
+// Without an ip query parameter the caller's own address is converted.
+func ConvertIP(c *gin.Context) {
+	input := c.Query("ip")
+	if input == "" {
+		input = getRealIP(c)
+	}
+
+	conv, err := service.ConvertIP(input)
+	if err != nil {
+		respond(c, http.StatusBadRequest, ErrorResponse(c, err.Error()))
+		return
+	}
+
+	respond(c, http.StatusOK, IPConvertResponse{
+		Input:        input,
+		IP:           conv.IP,
+		Version:      conv.Version,
+		Expanded:     conv.Expanded,
+		Decimal:      conv.Decimal,
+		Hex:          conv.Hex,
+		Binary:       conv.Binary,
+		IPv4Mapped:   conv.IPv4Mapped,
+		SixToFour:    conv.SixToFour,
+		NAT64:        conv.NAT64,
+		PTR:          conv.PTR,
+		EmbeddedIPv4: conv.EmbeddedIPv4,
+		Embedding:    conv.Embedding,
+	})
+}
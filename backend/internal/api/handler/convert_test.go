@@ -0,0 +1,78 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConvertIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		url            string
+		headers        map[string]string
+		expectedStatus int
+		checkResponse  func(*testing.T, *httptest.ResponseRecorder)
+	}{
+		{
+			name:           "Decimal input",
+			url:            "/api/net/convert?ip=167772161",
+			expectedStatus: http.StatusOK,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var response IPConvertResponse
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.Equal(t, "167772161", response.Input)
+				assert.Equal(t, "10.0.0.1", response.IP)
+				assert.Equal(t, "1.0.0.10.in-addr.arpa", response.PTR)
+			},
+		},
+		{
+			name: "Defaults to caller IP",
+			url:  "/api/net/convert",
+			headers: map[string]string{
+				"X-Forwarded-For": "2001:db8::1",
+			},
+			expectedStatus: http.StatusOK,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var response IPConvertResponse
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.Equal(t, "IPv6", response.Version)
+				assert.Equal(t, "2001:0db8:0000:0000:0000:0000:0000:0001", response.Expanded)
+			},
+		},
+		{
+			name:           "Invalid input",
+			url:            "/api/net/convert?ip=bogus",
+			expectedStatus: http.StatusBadRequest,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, w.Body.String(), "error")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			c.Request = req
+
+			ConvertIP(c)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			tt.checkResponse(t, w)
+		})
+	}
+}
@@ -0,0 +1,138 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lRoccoon/utils-helper/internal/service"
+)
+
+// DNSLookupResponse represents the DNS lookup response
+type DNSLookupResponse struct {
+	Name               string      `json:"name"`
+	Type               string      `json:"type"`
+	Resolver           string      `json:"resolver"`
+	Protocol           string      `json:"protocol"`
+	RCode              string      `json:"rcode"`
+	Authoritative      bool        `json:"authoritative"`
+	RecursionAvailable bool        `json:"recursion_available"`
+	Truncated          bool        `json:"truncated"`
+	Answers            []DNSAnswer `json:"answers"`
+	Authority          []DNSAnswer `json:"authority,omitempty"`
+	QueryTimeMS        float64     `json:"query_time_ms"`
+}
+
+// DNSAnswer represents one resource record in a DNS response
+type DNSAnswer struct {
+	Name       string   `json:"name"`
+	Type       string   `json:"type"`
+	TTL        uint32   `json:"ttl"`
+	Data       string   `json:"data"`
+	Address    string   `json:"address,omitempty"`
+	Target     string   `json:"target,omitempty"`
+	Preference uint16   `json:"preference,omitempty"`
+	Priority   uint16   `json:"priority,omitempty"`
+	Weight     uint16   `json:"weight,omitempty"`
+	Port       uint16   `json:"port,omitempty"`
+	Text       []string `json:"text,omitempty"`
+	SOA        *DNSSOA  `json:"soa,omitempty"`
+	CAA        *DNSCAA  `json:"caa,omitempty"`
+}
+
+// DNSSOA represents the fields of an SOA record
+type DNSSOA struct {
+	NS      string `json:"ns"`
+	Mbox    string `json:"mbox"`
+	Serial  uint32 `json:"serial"`
+	Refresh uint32 `json:"refresh"`
+	Retry   uint32 `json:"retry"`
+	Expire  uint32 `json:"expire"`
+	MinTTL  uint32 `json:"min_ttl"`
+}
+
+// DNSCAA represents the fields of a CAA record
+type DNSCAA struct {
+	Flag  uint8  `json:"flag"`
+	Tag   string `json:"tag"`
+	Value string `json:"value"`
+}
+
+// PlainText lists the answer data one record per line, like dig +short
+func (r DNSLookupResponse) PlainText() string {
+	lines := make([]string, 0, len(r.Answers))
+	for _, a := range r.Answers {
+		lines = append(lines, a.Data)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// LookupDNS handles GET /api/dns requests
+func LookupDNS(c *gin.Context) {
+	name := c.Query("name")
+	if name == "" {
+		respond(c, http.StatusBadRequest, ErrorResponse(c, "name query parameter is required"))
+		return
+	}
+
+	result, err := service.LookupDNS(c.Request.Context(), name, c.DefaultQuery("type", "A"))
+	if err != nil {
+		if errors.Is(err, service.ErrInvalidDNSQuery) {
+			respond(c, http.StatusBadRequest, ErrorResponse(c, err.Error()))
+			return
+		}
+		respond(c, http.StatusBadGateway, ErrorResponse(c, "DNS lookup failed: "+err.Error()))
+		return
+	}
+
+	respond(c, http.StatusOK, DNSLookupResponse{
+		Name:               result.Name,
+		Type:               result.Type,
+		Resolver:           result.Server,
+		Protocol:           result.Protocol,
+		RCode:              result.RCode,
+		Authoritative:      result.Authoritative,
+		RecursionAvailable: result.RecursionAvailable,
+		Truncated:          result.Truncated,
+		Answers:            dnsAnswers(result.Answers),
+		Authority:          dnsAnswers(result.Authority),
+		QueryTimeMS:        float64(result.Duration.Microseconds()) / 1000,
+	})
+}
+
+// dnsAnswers converts service records to their response form
+func dnsAnswers(records []service.DNSRecord) []DNSAnswer {
+	answers := make([]DNSAnswer, 0, len(records))
+	for _, rec := range records {
+		answer := DNSAnswer{
+			Name:       rec.Name,
+			Type:       rec.Type,
+			TTL:        rec.TTL,
+			Data:       rec.Data,
+			Address:    rec.Address,
+			Target:     rec.Target,
+			Preference: rec.Preference,
+			Priority:   rec.Priority,
+			Weight:     rec.Weight,
+			Port:       rec.Port,
+			Text:       rec.Text,
+		}
+		if rec.SOA != nil {
+			answer.SOA = &DNSSOA{
+				NS:      rec.SOA.NS,
+				Mbox:    rec.SOA.Mbox,
+				Serial:  rec.SOA.Serial,
+				Refresh: rec.SOA.Refresh,
+				Retry:   rec.SOA.Retry,
+				Expire:  rec.SOA.Expire,
+				MinTTL:  rec.SOA.MinTTL,
+			}
+		}
+		if rec.CAA != nil {
+			answer.CAA = &DNSCAA{Flag: rec.CAA.Flag, Tag: rec.CAA.Tag, Value: rec.CAA.Value}
+		}
+		answers = append(answers, answer)
+	}
+	return answers
+}
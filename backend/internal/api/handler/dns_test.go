@@ -0,0 +1,146 @@
+package handler
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lRoccoon/utils-helper/internal/service"
+	"github.com/stretchr/testify/assert"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// startDNSStub answers every A query with 192.0.2.53 and everything else with NXDOMAIN
+func startDNSStub(t *testing.T) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { pc.Close() })
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			var p dnsmessage.Parser
+			h, err := p.Start(buf[:n])
+			if err != nil {
+				continue
+			}
+			q, err := p.Question()
+			if err != nil {
+				continue
+			}
+
+			msg := dnsmessage.Message{
+				Header:    dnsmessage.Header{ID: h.ID, Response: true, RecursionAvailable: true},
+				Questions: []dnsmessage.Question{q},
+			}
+			if q.Type == dnsmessage.TypeA {
+				msg.Answers = []dnsmessage.Resource{{
+					Header: dnsmessage.ResourceHeader{Name: q.Name, Type: dnsmessage.TypeA, Class: dnsmessage.ClassINET, TTL: 120},
+					Body:   &dnsmessage.AResource{A: [4]byte{192, 0, 2, 53}},
+				}}
+			} else {
+				msg.Header.RCode = dnsmessage.RCodeNameError
+			}
+			resp, _ := msg.Pack()
+			pc.WriteTo(resp, addr)
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+func TestLookupDNS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	addr := startDNSStub(t)
+	resolver, err := service.NewDNSResolver(addr, 0)
+	assert.NoError(t, err)
+	service.SetDNSResolver(resolver)
+	defer service.SetDNSResolver(nil)
+
+	tests := []struct {
+		name       string
+		url        string
+		wantStatus int
+		wantRCode  string
+		wantCount  int
+	}{
+		{"A record", "/api/dns?name=example.com", http.StatusOK, "NOERROR", 1},
+		{"NXDOMAIN", "/api/dns?name=example.com&type=mx", http.StatusOK, "NXDOMAIN", 0},
+		{"Missing name", "/api/dns", http.StatusBadRequest, "", 0},
+		{"Unsupported type", "/api/dns?name=example.com&type=ANY", http.StatusBadRequest, "", 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, tt.url, nil)
+
+			LookupDNS(c)
+
+			assert.Equal(t, tt.wantStatus, w.Code)
+			if tt.wantStatus != http.StatusOK {
+				return
+			}
+
+			var response DNSLookupResponse
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantRCode, response.RCode)
+			assert.Equal(t, addr, response.Resolver)
+			assert.Equal(t, "udp", response.Protocol)
+			assert.Len(t, response.Answers, tt.wantCount)
+		})
+	}
+}
+
+func TestLookupDNSPlainText(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	resolver, err := service.NewDNSResolver(startDNSStub(t), 0)
+	assert.NoError(t, err)
+	service.SetDNSResolver(resolver)
+	defer service.SetDNSResolver(nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/dns?name=example.com&format=text", nil)
+
+	LookupDNS(c)
+
+	assert.Equal(t, "192.0.2.53\n", w.Body.String())
+}
+
+func TestLookupDNSUpstreamFailure(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	// Nothing listens on this TCP port, so the query fails
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	assert.NoError(t, err)
+	addr := ln.Addr().String()
+	ln.Close()
+
+	resolver, err := service.NewDNSResolver("tcp://"+addr, 0)
+	assert.NoError(t, err)
+	service.SetDNSResolver(resolver)
+	defer service.SetDNSResolver(nil)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/dns?name=example.com", nil)
+
+	LookupDNS(c)
+
+	assert.Equal(t, http.StatusBadGateway, w.Code)
+	assert.Contains(t, w.Body.String(), "DNS lookup failed")
+}
@@ -0,0 +1,31 @@
+package handler
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// notReady is set once the server starts shutting down
+var notReady atomic.Bool
+
+// SetReady switches /ready between passing and failing. The server fails
+// it before draining so load balancers stop sending new requests.
+func SetReady(ready bool) {
+	notReady.Store(!ready)
+}
+
+// Health handles GET /health requests; it passes while the process runs
+func Health(c *gin.Context) {
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
+
+// Ready handles GET /ready requests; it fails with 503 while shutting down
+func Ready(c *gin.Context) {
+	if notReady.Load() {
+		c.JSON(http.StatusServiceUnavailable, gin.H{"status": "shutting down"})
+		return
+	}
+	c.JSON(http.StatusOK, gin.H{"status": "ok"})
+}
@@ -22,7 +22,7 @@ func GetHolidayInfo(c *gin.Context) {
 	today := time.Now().Format("2006-01-02")
 	info := service.GetHolidayInfo(today)
 
-	c.JSON(http.StatusOK, HolidayResponse{
+	respond(c, http.StatusOK, HolidayResponse{
 		Date:      today,
 		IsHoliday: info.IsHoliday,
 		IsWorkday: info.IsWorkday,
@@ -37,13 +37,13 @@ func GetHolidayByDate(c *gin.Context) {
 
 	// Validate date format
 	if _, err := time.Parse("2006-01-02", date); err != nil {
-		c.JSON(http.StatusBadRequest, gin.H{"error": "Invalid date format. Use YYYY-MM-DD"})
+		respond(c, http.StatusBadRequest, ErrorResponse(c, "Invalid date format. Use YYYY-MM-DD"))
 		return
 	}
 
 	info := service.GetHolidayInfo(date)
 
-	c.JSON(http.StatusOK, HolidayResponse{
+	respond(c, http.StatusOK, HolidayResponse{
 		Date:      date,
 		IsHoliday: info.IsHoliday,
 		IsWorkday: info.IsWorkday,
@@ -1,9 +1,14 @@
 package handler
 
 import (
+	"fmt"
 	"net"
 	"net/http"
+	"net/netip"
+	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/lRoccoon/utils-helper/internal/service"
@@ -19,10 +24,82 @@ type IPInfoResponse struct {
 	City        string  `json:"city,omitempty"`
 	Latitude    float64 `json:"latitude,omitempty"`
 	Longitude   float64 `json:"longitude,omitempty"`
+
+	Classification *IPClassification `json:"classification,omitempty"`
+	MatchedSets    []IPSetMatchInfo  `json:"matched_sets,omitempty"`
+	MAC            *IPMACInfo        `json:"mac,omitempty"`
+}
+
+// IPClassification represents the IANA special-purpose classification of an address
+type IPClassification struct {
+	Category          string `json:"category"`
+	Name              string `json:"name"`
+	Prefix            string `json:"prefix,omitempty"`
+	RFC               string `json:"rfc,omitempty"`
+	Forwardable       bool   `json:"forwardable"`
+	GloballyReachable bool   `json:"globally_reachable"`
+	MulticastScope    string `json:"multicast_scope,omitempty"`
 }
 
+// PlainText returns the bare address for text/plain responses
+func (r IPInfoResponse) PlainText() string {
+	return r.IP
+}
+
+// cliUserAgents are User-Agent prefixes of command-line HTTP clients
+var cliUserAgents = []string{"curl/", "wget/", "httpie/", "xh/", "fetch libfetch/"}
+
 // GetIPInfo handles GET /api/ip requests
 func GetIPInfo(c *gin.Context) {
+	respond(c, http.StatusOK, buildIPInfo(c))
+}
+
+// IPAlias serves the caller's address at /ip like ifconfig.me, defaulting to
+// plain text for curl, wget and similar clients. Browser navigations continue
+// to the next handler so the frontend's /ip page is still reachable.
+func IPAlias(c *gin.Context) {
+	path := c.Request.URL.Path
+	if (path != "/ip" && path != "/ip/") || (c.Request.Method != http.MethodGet && c.Request.Method != http.MethodHead) {
+		c.Next()
+		return
+	}
+	if c.Query("format") == "" && strings.Contains(c.GetHeader("Accept"), "text/html") {
+		c.Next()
+		return
+	}
+	// The alias is no route of the ip module, so it checks access itself
+	if !authorizeRead(c, "ip") {
+		return
+	}
+
+	fallback := formatJSON
+	if isCLIClient(c.GetHeader("User-Agent")) {
+		fallback = formatText
+	}
+	format, err := negotiateFormat(c, fallback)
+	if err != nil {
+		c.AbortWithStatusJSON(http.StatusBadRequest, ErrorResponse(c, err.Error()))
+		return
+	}
+
+	render(c, http.StatusOK, buildIPInfo(c), format)
+	c.Abort()
+}
+
+// isCLIClient reports whether a User-Agent belongs to a command-line HTTP client
+func isCLIClient(userAgent string) bool {
+	ua := strings.ToLower(userAgent)
+	for _, prefix := range cliUserAgents {
+		if strings.HasPrefix(ua, prefix) {
+			return true
+		}
+	}
+	// Invoke-WebRequest sends a browser-like string ending in PowerShell/x.y
+	return strings.Contains(ua, "powershell/")
+}
+
+// buildIPInfo collects the address, classification and location of the caller
+func buildIPInfo(c *gin.Context) IPInfoResponse {
 	ip := getRealIP(c)
 
 	// Determine IP version
@@ -40,6 +117,25 @@ func GetIPInfo(c *gin.Context) {
 		Version: version,
 	}
 
+	if class, ok := service.ClassifyIP(ip); ok {
+		response.Classification = &IPClassification{
+			Category:          class.Category,
+			Name:              class.Name,
+			Prefix:            class.Prefix,
+			RFC:               class.RFC,
+			Forwardable:       class.Forwardable,
+			GloballyReachable: class.Global,
+			MulticastScope:    class.MulticastScope,
+		}
+	}
+
+	if matches := service.MatchIPSets(ip); len(matches) > 0 {
+		response.MatchedSets = ipSetMatches(matches)
+	}
+
+	// SLAAC addresses without privacy extensions embed the interface MAC
+	response.MAC = eui64MAC(ip)
+
 	// Try to get geolocation info
 	if geoInfo, err := service.GetGeoLocation(ip); err == nil {
 		response.Country = geoInfo.Country
@@ -50,25 +146,339 @@ func GetIPInfo(c *gin.Context) {
 		response.Longitude = geoInfo.Longitude
 	}
 
-	c.JSON(http.StatusOK, response)
+	return response
+}
+
+// GeoStatsResponse represents the geolocation provider statistics response
+type GeoStatsResponse struct {
+	Providers []GeoProviderStats `json:"providers"`
+	Cache     GeoCacheStats      `json:"cache"`
+}
+
+// GeoProviderStats represents lookup counters for one geolocation provider
+type GeoProviderStats struct {
+	Name   string `json:"name"`
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Errors uint64 `json:"errors"`
+}
+
+// GeoCacheStats represents geolocation cache counters
+type GeoCacheStats struct {
+	Hits   uint64 `json:"hits"`
+	Misses uint64 `json:"misses"`
+	Size   int    `json:"size"`
+}
+
+// GetGeoStats handles GET /api/ip/geo/stats requests
+func GetGeoStats(c *gin.Context) {
+	stats := service.GetGeoStats()
+
+	response := GeoStatsResponse{
+		Providers: []GeoProviderStats{},
+		Cache: GeoCacheStats{
+			Hits:   stats.CacheHits,
+			Misses: stats.CacheMisses,
+			Size:   stats.CacheSize,
+		},
+	}
+	for _, p := range stats.Providers {
+		response.Providers = append(response.Providers, GeoProviderStats{
+			Name:   p.Name,
+			Hits:   p.Hits,
+			Misses: p.Misses,
+			Errors: p.Errors,
+		})
+	}
+
+	respond(c, http.StatusOK, response)
+}
+
+// GeoMetaResponse represents the geolocation database metadata response
+type GeoMetaResponse struct {
+	Databases []GeoDatabaseMeta `json:"databases"`
+}
+
+// GeoDatabaseMeta describes one loaded geolocation database file
+type GeoDatabaseMeta struct {
+	Provider     string `json:"provider"`
+	Path         string `json:"path,omitempty"`
+	Type         string `json:"type,omitempty"`
+	Description  string `json:"description,omitempty"`
+	BuildDate    string `json:"build_date,omitempty"`
+	Records      int    `json:"records"`
+	LoadedAt     string `json:"loaded_at,omitempty"`
+	FileModified string `json:"file_modified,omitempty"`
+	FileSize     int64  `json:"file_size,omitempty"`
+	Reloads      uint64 `json:"reloads"`
+	LastChecked  string `json:"last_checked,omitempty"`
+	LastError    string `json:"last_error,omitempty"`
+}
+
+// PlainText returns one line per database
+func (r GeoMetaResponse) PlainText() string {
+	lines := make([]string, 0, len(r.Databases))
+	for _, db := range r.Databases {
+		label := db.Provider
+		if db.Type != "" && db.Type != db.Provider {
+			label += " (" + db.Type + ")"
+		}
+		line := fmt.Sprintf("%s: %d records", label, db.Records)
+		if db.BuildDate != "" {
+			line += ", built " + db.BuildDate
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// GetGeoMeta handles GET /api/ip/meta requests
+func GetGeoMeta(c *gin.Context) {
+	response := GeoMetaResponse{Databases: []GeoDatabaseMeta{}}
+	for _, db := range service.GetGeoDatabases() {
+		response.Databases = append(response.Databases, GeoDatabaseMeta{
+			Provider:     db.Provider,
+			Path:         db.Path,
+			Type:         db.Type,
+			Description:  db.Description,
+			BuildDate:    formatMetaTime(db.BuildTime),
+			Records:      db.Records,
+			LoadedAt:     formatMetaTime(db.LoadedAt),
+			FileModified: formatMetaTime(db.ModTime),
+			FileSize:     db.Size,
+			Reloads:      db.Reloads,
+			LastChecked:  formatMetaTime(db.LastCheck),
+			LastError:    db.LastError,
+		})
+	}
+
+	respond(c, http.StatusOK, response)
+}
+
+// formatMetaTime formats a timestamp as RFC 3339, or empty if unset
+func formatMetaTime(t time.Time) string {
+	if t.IsZero() {
+		return ""
+	}
+	return t.UTC().Format(time.RFC3339)
+}
+
+// CIDRResponse represents the subnet calculator response
+type CIDRResponse struct {
+	Prefix       string `json:"prefix"`
+	Version      string `json:"version"`
+	PrefixLength int    `json:"prefix_length"`
+	Network      string `json:"network"`
+	Broadcast    string `json:"broadcast,omitempty"`
+	Netmask      string `json:"netmask"`
+	Wildcard     string `json:"wildcard"`
+	FirstHost    string `json:"first_host"`
+	LastHost     string `json:"last_host"`
+	Addresses    string `json:"addresses"`
+	UsableHosts  string `json:"usable_hosts"`
+}
+
+// GetCIDRInfo handles GET /api/net/cidr requests
+func GetCIDRInfo(c *gin.Context) {
+	info, err := service.GetCIDRInfo(c.Query("prefix"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, ErrorResponse(c, err.Error()))
+		return
+	}
+
+	respond(c, http.StatusOK, CIDRResponse{
+		Prefix:       info.Prefix,
+		Version:      info.Version,
+		PrefixLength: info.PrefixLength,
+		Network:      info.Network,
+		Broadcast:    info.Broadcast,
+		Netmask:      info.Netmask,
+		Wildcard:     info.Wildcard,
+		FirstHost:    info.FirstHost,
+		LastHost:     info.LastHost,
+		Addresses:    info.Addresses.String(),
+		UsableHosts:  info.UsableHosts.String(),
+	})
+}
+
+// CIDRSplitResponse represents the subnet split response
+type CIDRSplitResponse struct {
+	Prefix  string   `json:"prefix"`
+	NewBits int      `json:"new_prefix_length"`
+	Count   int      `json:"count"`
+	Subnets []string `json:"subnets"`
+}
+
+// PlainText returns one subnet per line for text/plain responses
+func (r CIDRSplitResponse) PlainText() string {
+	return strings.Join(r.Subnets, "\n")
+}
+
+// SplitCIDR handles GET /api/net/cidr/split requests
+func SplitCIDR(c *gin.Context) {
+	newBits, err := strconv.Atoi(c.Query("new_prefix"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, ErrorResponse(c, "Invalid new_prefix. Use a prefix length such as 24"))
+		return
+	}
+
+	subnets, err := service.SplitCIDR(c.Query("prefix"), newBits)
+	if err != nil {
+		respond(c, http.StatusBadRequest, ErrorResponse(c, err.Error()))
+		return
+	}
+
+	prefix, _ := service.ParseCIDR(c.Query("prefix"))
+	respond(c, http.StatusOK, CIDRSplitResponse{
+		Prefix:  prefix.String(),
+		NewBits: newBits,
+		Count:   len(subnets),
+		Subnets: subnets,
+	})
+}
+
+// CIDRContainsResponse represents the containment check response
+type CIDRContainsResponse struct {
+	Prefix   string `json:"prefix"`
+	IP       string `json:"ip"`
+	Contains bool   `json:"contains"`
+}
+
+// PlainText returns true or false for text/plain responses
+func (r CIDRContainsResponse) PlainText() string {
+	return strconv.FormatBool(r.Contains)
+}
+
+// CheckCIDRContains handles GET /api/net/cidr/contains requests
+func CheckCIDRContains(c *gin.Context) {
+	contains, err := service.CIDRContains(c.Query("prefix"), c.Query("ip"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, ErrorResponse(c, err.Error()))
+		return
+	}
+
+	prefix, _ := service.ParseCIDR(c.Query("prefix"))
+	respond(c, http.StatusOK, CIDRContainsResponse{
+		Prefix:   prefix.String(),
+		IP:       c.Query("ip"),
+		Contains: contains,
+	})
+}
+
+// IPResolutionStep records one source checked while resolving the client IP
+type IPResolutionStep struct {
+	Source string `json:"source"`
+	Value  string `json:"value,omitempty"`
+	Used   bool   `json:"used"`
+	Note   string `json:"note"`
 }
 
 // getRealIP extracts the real client IP from request
 func getRealIP(c *gin.Context) string {
+	ip, _ := resolveRealIP(c)
+	return ip
+}
+
+// ClientIP returns the client address of a request, taking forwarding
+// headers from trusted proxies into account like /api/ip does
+func ClientIP(c *gin.Context) string {
+	return getRealIP(c)
+}
+
+var (
+	trustedProxies   []netip.Prefix
+	trustedProxiesMu sync.RWMutex
+)
+
+// SetTrustedProxies honours X-Forwarded-For and X-Real-IP only on requests
+// whose peer is one of the given proxies. With no proxies configured the
+// headers are ignored and the client is the connection peer; 0.0.0.0/0 and
+// ::/0 trust every peer.
+func SetTrustedProxies(prefixes []netip.Prefix) {
+	trustedProxiesMu.Lock()
+	trustedProxies = prefixes
+	trustedProxiesMu.Unlock()
+}
+
+// resolveRealIP extracts the real client IP and records every source it
+// checked, so /api/request can show which header won
+func resolveRealIP(c *gin.Context) (string, []IPResolutionStep) {
+	var trail []IPResolutionStep
+
+	trustedProxiesMu.RLock()
+	proxies := trustedProxies
+	trustedProxiesMu.RUnlock()
+
+	if !isTrustedProxy(proxies, c.RemoteIP()) {
+		for _, header := range []string{"X-Forwarded-For", "X-Real-IP"} {
+			step := IPResolutionStep{Source: header, Value: c.GetHeader(header), Note: "header not present"}
+			if step.Value != "" {
+				step.Note = "ignored, connection peer is not a trusted proxy"
+			}
+			trail = append(trail, step)
+		}
+		trail = append(trail, IPResolutionStep{
+			Source: "RemoteAddr",
+			Value:  c.Request.RemoteAddr,
+			Used:   true,
+			Note:   "connection peer address",
+		})
+		return c.RemoteIP(), trail
+	}
+
 	// Try X-Forwarded-For header first
 	if xff := c.GetHeader("X-Forwarded-For"); xff != "" {
 		ips := strings.Split(xff, ",")
 		if len(ips) > 0 {
-			return strings.TrimSpace(ips[0])
+			// Proxies append the address they received the request from,
+			// so the client is the last hop not added by one of ours
+			var ip string
+			for i := len(ips) - 1; i >= 0; i-- {
+				ip = strings.TrimSpace(ips[i])
+				if !isTrustedProxy(proxies, ip) {
+					break
+				}
+			}
+			trail = append(trail, IPResolutionStep{
+				Source: "X-Forwarded-For",
+				Value:  xff,
+				Used:   true,
+				Note:   "last address not belonging to a trusted proxy",
+			})
+			return ip, trail
 		}
 	}
+	trail = append(trail, IPResolutionStep{Source: "X-Forwarded-For", Note: "header not present"})
 
 	// Try X-Real-IP header
 	if xri := c.GetHeader("X-Real-IP"); xri != "" {
-		return xri
+		trail = append(trail, IPResolutionStep{Source: "X-Real-IP", Value: xri, Used: true, Note: "header value"})
+		return xri, trail
 	}
+	trail = append(trail, IPResolutionStep{Source: "X-Real-IP", Note: "header not present"})
 
 	// Fall back to RemoteAddr
-	ip := c.ClientIP()
-	return ip
+	ip := c.RemoteIP()
+	trail = append(trail, IPResolutionStep{
+		Source: "RemoteAddr",
+		Value:  c.Request.RemoteAddr,
+		Used:   true,
+		Note:   "connection peer address",
+	})
+	return ip, trail
+}
+
+// isTrustedProxy reports whether ip is in one of the proxy prefixes
+func isTrustedProxy(proxies []netip.Prefix, ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+	addr = addr.Unmap().WithZone("")
+	for _, p := range proxies {
+		if p.Contains(addr) {
+			return true
+		}
+	}
+	return false
 }
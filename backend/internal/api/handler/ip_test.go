@@ -4,9 +4,13 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"net/netip"
+	"os"
+	"path/filepath"
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lRoccoon/utils-helper/internal/service"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -82,6 +86,25 @@ func TestGetIPInfo(t *testing.T) {
 				assert.Equal(t, "127.0.0.1", response.IP)
 				assert.Equal(t, "IPv4", response.Version)
 				assert.Equal(t, "Local", response.Country)
+				assert.Equal(t, "loopback", response.Classification.Category)
+			},
+		},
+		{
+			name: "Documentation address classification",
+			headers: map[string]string{
+				"X-Forwarded-For": "203.0.113.1",
+			},
+			expectedIP: "203.0.113.1",
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Equal(t, http.StatusOK, w.Code)
+
+				var response IPInfoResponse
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.NotEqual(t, "Unknown", response.Country)
+				assert.Equal(t, "documentation", response.Classification.Category)
+				assert.Equal(t, "203.0.113.0/24", response.Classification.Prefix)
+				assert.False(t, response.Classification.GloballyReachable)
 			},
 		},
 	}
@@ -128,7 +151,7 @@ func TestGetRealIP(t *testing.T) {
 			headers: map[string]string{
 				"X-Forwarded-For": "203.0.113.1, 198.51.100.1",
 			},
-			expectedIP: "203.0.113.1",
+			expectedIP: "198.51.100.1",
 		},
 		{
 			name: "X-Real-IP",
@@ -165,3 +188,349 @@ func TestGetRealIP(t *testing.T) {
 		})
 	}
 }
+
+func TestGetGeoStats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/ip/geo/stats", nil)
+
+	GetGeoStats(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response GeoStatsResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.NotNil(t, response.Providers)
+	assert.Contains(t, w.Body.String(), `"cache"`)
+}
+
+func TestGetGeoMeta(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	path := filepath.Join(t.TempDir(), "ranges.csv")
+	err := os.WriteFile(path, []byte("8.8.8.0,8.8.8.255,US\n203.208.60.0,203.208.60.255,CN\n"), 0o644)
+	assert.NoError(t, err)
+	assert.NoError(t, service.ConfigureGeo(service.GeoConfig{CSVPath: path}))
+	defer service.SetGeoChain(service.NewGeoChain(0, 0, 0))
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/ip/meta", nil)
+
+	GetGeoMeta(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response GeoMetaResponse
+	err = json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	if assert.Len(t, response.Databases, 1) {
+		db := response.Databases[0]
+		assert.Equal(t, "csv", db.Provider)
+		assert.Equal(t, path, db.Path)
+		assert.Equal(t, 2, db.Records)
+		assert.NotEmpty(t, db.LoadedAt)
+		assert.Empty(t, db.LastError)
+	}
+	assert.Equal(t, "csv: 2 records", response.PlainText())
+}
+
+func TestIPAlias(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.Use(IPAlias)
+	r.NoRoute(func(c *gin.Context) {
+		c.String(http.StatusOK, "frontend")
+	})
+
+	tests := []struct {
+		name        string
+		url         string
+		headers     map[string]string
+		body        string
+		contentType string
+	}{
+		{
+			name: "curl gets plain text",
+			url:  "/ip",
+			headers: map[string]string{
+				"User-Agent":      "curl/8.5.0",
+				"Accept":          "*/*",
+				"X-Forwarded-For": "203.0.113.9",
+			},
+			body:        "203.0.113.9\n",
+			contentType: "text/plain; charset=utf-8",
+		},
+		{
+			name: "wget gets plain text",
+			url:  "/ip",
+			headers: map[string]string{
+				"User-Agent":      "Wget/1.21.4",
+				"X-Forwarded-For": "2001:db8::9",
+			},
+			body:        "2001:db8::9\n",
+			contentType: "text/plain; charset=utf-8",
+		},
+		{
+			name: "curl can ask for JSON",
+			url:  "/ip",
+			headers: map[string]string{
+				"User-Agent":      "curl/8.5.0",
+				"Accept":          "application/json",
+				"X-Forwarded-For": "203.0.113.9",
+			},
+			contentType: "application/json; charset=utf-8",
+		},
+		{
+			name: "Other clients default to JSON",
+			url:  "/ip",
+			headers: map[string]string{
+				"User-Agent":      "Go-http-client/1.1",
+				"X-Forwarded-For": "203.0.113.9",
+			},
+			contentType: "application/json; charset=utf-8",
+		},
+		{
+			name: "Browser reaches the frontend",
+			url:  "/ip",
+			headers: map[string]string{
+				"User-Agent": "Mozilla/5.0",
+				"Accept":     "text/html,application/xhtml+xml,*/*;q=0.8",
+			},
+			body:        "frontend",
+			contentType: "text/plain; charset=utf-8",
+		},
+		{
+			name: "Browser with explicit format",
+			url:  "/ip?format=text",
+			headers: map[string]string{
+				"Accept":          "text/html",
+				"X-Forwarded-For": "198.51.100.4",
+			},
+			body:        "198.51.100.4\n",
+			contentType: "text/plain; charset=utf-8",
+		},
+		{
+			name: "Other paths are untouched",
+			url:  "/ipv6",
+			headers: map[string]string{
+				"User-Agent": "curl/8.5.0",
+			},
+			body:        "frontend",
+			contentType: "text/plain; charset=utf-8",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			for k, v := range tt.headers {
+				req.Header.Set(k, v)
+			}
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+			if tt.body != "" {
+				assert.Equal(t, tt.body, w.Body.String())
+			}
+		})
+	}
+}
+
+func TestIsCLIClient(t *testing.T) {
+	for ua, want := range map[string]bool{
+		"curl/8.5.0":   true,
+		"Wget/1.21.4":  true,
+		"HTTPie/3.2.2": true,
+		"xh/0.20.1":    true,
+		"Mozilla/5.0 (Windows NT 10.0; Microsoft Windows 10.0.19045; en-US) PowerShell/7.4.1":     true,
+		"Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 Chrome/120.0 Safari/537.36": false,
+		"": false,
+	} {
+		assert.Equal(t, want, isCLIClient(ua), ua)
+	}
+}
+
+func TestResolveRealIPTrail(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	req := httptest.NewRequest(http.MethodGet, "/", nil)
+	req.Header.Set("X-Real-IP", "198.51.100.1")
+	c.Request = req
+
+	ip, trail := resolveRealIP(c)
+
+	assert.Equal(t, "198.51.100.1", ip)
+	assert.Len(t, trail, 2)
+	assert.Equal(t, "X-Forwarded-For", trail[0].Source)
+	assert.False(t, trail[0].Used)
+	assert.Equal(t, "X-Real-IP", trail[1].Source)
+	assert.True(t, trail[1].Used)
+}
+
+func TestResolveRealIPTrustedProxies(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	defer SetTrustedProxies(testProxies)
+
+	private := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	all := []netip.Prefix{netip.MustParsePrefix("0.0.0.0/0"), netip.MustParsePrefix("::/0")}
+	tests := []struct {
+		name       string
+		proxies    []netip.Prefix
+		remoteAddr string
+		xff        string
+		expectedIP string
+		usedSource string
+	}{
+		{"untrusted peer ignores headers", private, "192.0.2.10:1234", "203.0.113.1", "192.0.2.10", "RemoteAddr"},
+		{"trusted peer uses header", private, "10.1.2.3:1234", "203.0.113.1", "203.0.113.1", "X-Forwarded-For"},
+		{"skips trusted hops from the right", private, "10.1.2.3:1234", "198.51.100.7, 203.0.113.1, 10.4.4.4", "203.0.113.1", "X-Forwarded-For"},
+		{"all hops trusted uses the first", private, "10.1.2.3:1234", "10.9.9.9, 10.4.4.4", "10.9.9.9", "X-Forwarded-For"},
+		{"no proxies ignores headers", nil, "10.1.2.3:1234", "203.0.113.1", "10.1.2.3", "RemoteAddr"},
+		{"trusting every peer uses the first", all, "192.0.2.10:1234", "203.0.113.1, 198.51.100.7", "203.0.113.1", "X-Forwarded-For"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			SetTrustedProxies(tt.proxies)
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			req := httptest.NewRequest(http.MethodGet, "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			req.Header.Set("X-Forwarded-For", tt.xff)
+			c.Request = req
+
+			ip, trail := resolveRealIP(c)
+			assert.Equal(t, tt.expectedIP, ip)
+			for _, step := range trail {
+				if step.Used {
+					assert.Equal(t, tt.usedSource, step.Source)
+				}
+			}
+		})
+	}
+}
+
+// testProxies holds the peer address of httptest requests. Trusting it lets
+// the tests set the client address with forwarding headers.
+var testProxies = []netip.Prefix{netip.MustParsePrefix("192.0.2.1/32")}
+
+func TestMain(m *testing.M) {
+	SetTrustedProxies(testProxies)
+	os.Exit(m.Run())
+}
+
+func TestGetCIDRInfo(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		query          string
+		expectedStatus int
+		checkResponse  func(*testing.T, *httptest.ResponseRecorder)
+	}{
+		{
+			name:           "IPv4 prefix",
+			query:          "?prefix=10.0.0.0/22",
+			expectedStatus: http.StatusOK,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var response CIDRResponse
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.Equal(t, "10.0.0.0/22", response.Prefix)
+				assert.Equal(t, "10.0.3.255", response.Broadcast)
+				assert.Equal(t, "255.255.252.0", response.Netmask)
+				assert.Equal(t, "1022", response.UsableHosts)
+			},
+		},
+		{
+			name:           "IPv6 prefix",
+			query:          "?prefix=2001:db8::/126",
+			expectedStatus: http.StatusOK,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				var response CIDRResponse
+				err := json.Unmarshal(w.Body.Bytes(), &response)
+				assert.NoError(t, err)
+				assert.Equal(t, "IPv6", response.Version)
+				assert.Empty(t, response.Broadcast)
+				assert.Equal(t, "2001:db8::3", response.LastHost)
+				assert.Equal(t, "4", response.Addresses)
+			},
+		},
+		{
+			name:           "Missing prefix",
+			query:          "",
+			expectedStatus: http.StatusBadRequest,
+			checkResponse: func(t *testing.T, w *httptest.ResponseRecorder) {
+				assert.Contains(t, w.Body.String(), "error")
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/net/cidr"+tt.query, nil)
+
+			GetCIDRInfo(c)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			tt.checkResponse(t, w)
+		})
+	}
+}
+
+func TestSplitCIDR(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/net/cidr/split?prefix=10.0.0.0/22&new_prefix=24", nil)
+
+	SplitCIDR(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response CIDRSplitResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, 4, response.Count)
+	assert.Equal(t, "10.0.3.0/24", response.Subnets[3])
+
+	// Invalid new prefix length
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/net/cidr/split?prefix=10.0.0.0/22&new_prefix=abc", nil)
+
+	SplitCIDR(c)
+
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+}
+
+func TestCheckCIDRContains(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/net/cidr/contains?prefix=10.0.0.0/22&ip=10.0.3.7", nil)
+
+	CheckCIDRContains(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response CIDRContainsResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.True(t, response.Contains)
+	assert.Equal(t, "10.0.3.7", response.IP)
+}
@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"errors"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lRoccoon/utils-helper/internal/service"
+)
+
+// IPSetMatchInfo represents a rule set containing an address
+type IPSetMatchInfo struct {
+	Set    string `json:"set"`
+	Prefix string `json:"prefix"`
+}
+
+// IPMatchResponse represents the rule set match response
+type IPMatchResponse struct {
+	IP      string           `json:"ip"`
+	Matched bool             `json:"matched"`
+	Sets    []IPSetMatchInfo `json:"sets"`
+}
+
+// IPSetResponse represents a named rule set
+type IPSetResponse struct {
+	Name        string   `json:"name"`
+	Description string   `json:"description,omitempty"`
+	Prefixes    []string `json:"prefixes"`
+}
+
+// IPSetListResponse represents the list of rule sets
+type IPSetListResponse struct {
+	Sets []IPSetResponse `json:"sets"`
+}
+
+// IPSetRequest represents the body of PUT /api/ip/sets/:name
+type IPSetRequest struct {
+	Description string   `json:"description"`
+	Prefixes    []string `json:"prefixes"`
+}
+
+// PlainText returns the matching set names, one per line
+func (r IPMatchResponse) PlainText() string {
+	names := make([]string, 0, len(r.Sets))
+	for _, s := range r.Sets {
+		names = append(names, s.Set)
+	}
+	return strings.Join(names, "\n")
+}
+
+// MatchIP handles GET /api/ip/match requests.
+// Without an ip query parameter the caller's own address is matched.
+func MatchIP(c *gin.Context) {
+	ip := c.Query("ip")
+	if ip == "" {
+		ip = getRealIP(c)
+	}
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		respond(c, http.StatusBadRequest, ErrorResponse(c, "Invalid IP address"))
+		return
+	}
+
+	sets := ipSetMatches(service.GetIPRuleStore().Match(addr.Unmap().WithZone("")))
+	respond(c, http.StatusOK, IPMatchResponse{
+		IP:      addr.String(),
+		Matched: len(sets) > 0,
+		Sets:    sets,
+	})
+}
+
+// ListIPSets handles GET /api/ip/sets requests
+func ListIPSets(c *gin.Context) {
+	response := IPSetListResponse{Sets: []IPSetResponse{}}
+	for _, set := range service.GetIPRuleStore().List() {
+		response.Sets = append(response.Sets, ipSetResponse(set))
+	}
+	respond(c, http.StatusOK, response)
+}
+
+// GetIPSet handles GET /api/ip/sets/:name requests
+func GetIPSet(c *gin.Context) {
+	set, err := service.GetIPRuleStore().Get(c.Param("name"))
+	if err != nil {
+		respond(c, http.StatusNotFound, ErrorResponse(c, err.Error()))
+		return
+	}
+	respond(c, http.StatusOK, ipSetResponse(set))
+}
+
+// PutIPSet handles PUT /api/ip/sets/:name requests
+func PutIPSet(c *gin.Context) {
+	if !authorizeScope(c, "write:ip") {
+		return
+	}
+
+	var req IPSetRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		respond(c, http.StatusBadRequest, ErrorResponse(c, "Invalid JSON body: "+err.Error()))
+		return
+	}
+
+	set, err := service.GetIPRuleStore().Put(service.IPRuleSet{
+		Name:        c.Param("name"),
+		Description: req.Description,
+		Prefixes:    req.Prefixes,
+	})
+	if err != nil {
+		respond(c, http.StatusBadRequest, ErrorResponse(c, err.Error()))
+		return
+	}
+	respond(c, http.StatusOK, ipSetResponse(set))
+}
+
+// DeleteIPSet handles DELETE /api/ip/sets/:name requests
+func DeleteIPSet(c *gin.Context) {
+	if !authorizeScope(c, "write:ip") {
+		return
+	}
+
+	err := service.GetIPRuleStore().Delete(c.Param("name"))
+	switch {
+	case errors.Is(err, service.ErrIPSetNotFound):
+		respond(c, http.StatusNotFound, ErrorResponse(c, err.Error()))
+	case err != nil:
+		respond(c, http.StatusInternalServerError, ErrorResponse(c, err.Error()))
+	default:
+		c.Status(http.StatusNoContent)
+	}
+}
+
+// IPAccessControl returns a middleware that rejects callers whose address is
+// in any deny set, or, if allow sets are given, in none of them. The address
+// is the connection peer, or the forwarded client when the peer is a trusted
+// proxy, so forged headers cannot get a caller past it. Requests for the
+// exempt paths, such as health checks, are let through.
+func IPAccessControl(allow, deny []string, exempt ...string) gin.HandlerFunc {
+	return func(c *gin.Context) {
+		for _, path := range exempt {
+			if c.Request.URL.Path == path {
+				c.Next()
+				return
+			}
+		}
+
+		addr, err := netip.ParseAddr(getRealIP(c))
+		if err != nil {
+			c.AbortWithStatusJSON(http.StatusForbidden, ErrorResponse(c, "Access denied"))
+			return
+		}
+		addr = addr.Unmap().WithZone("")
+
+		store := service.GetIPRuleStore()
+		if len(deny) > 0 && store.InSets(addr, deny...) {
+			c.AbortWithStatusJSON(http.StatusForbidden, ErrorResponse(c, "Access denied"))
+			return
+		}
+		if len(allow) > 0 && !store.InSets(addr, allow...) {
+			c.AbortWithStatusJSON(http.StatusForbidden, ErrorResponse(c, "Access denied"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// ipSetMatches converts service matches to their response form
+func ipSetMatches(matches []service.IPSetMatch) []IPSetMatchInfo {
+	out := make([]IPSetMatchInfo, 0, len(matches))
+	for _, m := range matches {
+		out = append(out, IPSetMatchInfo{Set: m.Set, Prefix: m.Prefix})
+	}
+	return out
+}
+
+// ipSetResponse converts a rule set to its response form
+func ipSetResponse(set service.IPRuleSet) IPSetResponse {
+	return IPSetResponse{
+		Name:        set.Name,
+		Description: set.Description,
+		Prefixes:    set.Prefixes,
+	}
+}
@@ -0,0 +1,203 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lRoccoon/utils-helper/internal/service"
+	"github.com/stretchr/testify/assert"
+)
+
+// useTestIPSets installs a rule store with office and blocked sets
+func useTestIPSets(t *testing.T) *service.IPRuleStore {
+	t.Helper()
+	store, err := service.NewIPRuleStore(filepath.Join(t.TempDir(), "ipsets.json"))
+	assert.NoError(t, err)
+	_, err = store.Put(service.IPRuleSet{Name: "office", Prefixes: []string{"10.1.0.0/16", "2001:db8:10::/48"}})
+	assert.NoError(t, err)
+	_, err = store.Put(service.IPRuleSet{Name: "blocked", Prefixes: []string{"198.51.100.0/24"}})
+	assert.NoError(t, err)
+
+	service.SetIPRuleStore(store)
+	t.Cleanup(func() { service.SetIPRuleStore(nil) })
+	return store
+}
+
+func TestMatchIP(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestIPSets(t)
+
+	tests := []struct {
+		name           string
+		url            string
+		xff            string
+		expectedStatus int
+		wantSets       []string
+	}{
+		{"Explicit address", "/api/ip/match?ip=10.1.2.3", "", http.StatusOK, []string{"office"}},
+		{"Caller address", "/api/ip/match", "198.51.100.7", http.StatusOK, []string{"blocked"}},
+		{"No match", "/api/ip/match?ip=192.0.2.1", "", http.StatusOK, []string{}},
+		{"Invalid address", "/api/ip/match?ip=nope", "", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			c.Request = req
+
+			MatchIP(c)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response IPMatchResponse
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			names := []string{}
+			for _, s := range response.Sets {
+				names = append(names, s.Set)
+			}
+			assert.Equal(t, tt.wantSets, names)
+			assert.Equal(t, len(tt.wantSets) > 0, response.Matched)
+		})
+	}
+}
+
+func TestGetIPInfoMatchedSets(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestIPSets(t)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/ip", nil)
+	c.Request.Header.Set("X-Forwarded-For", "2001:db8:10::5")
+
+	GetIPInfo(c)
+
+	var response IPInfoResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	if assert.Len(t, response.MatchedSets, 1) {
+		assert.Equal(t, "office", response.MatchedSets[0].Set)
+		assert.Equal(t, "2001:db8:10::/48", response.MatchedSets[0].Prefix)
+	}
+}
+
+func TestIPSetChanges(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestIPSets(t)
+
+	// Stand in for api.Authenticate with two fixed keys
+	keys := map[string]service.APIKey{
+		"writer": {ID: "a1b2c3d4e5f6", Scopes: []string{"write:ip"}},
+		"reader": {ID: "b2c3d4e5f6a1", Scopes: []string{"read:ip"}},
+	}
+	r := gin.New()
+	r.Use(func(c *gin.Context) {
+		if key, ok := keys[strings.TrimPrefix(c.GetHeader("Authorization"), "Bearer ")]; ok {
+			SetAPIKey(c, key)
+		}
+	})
+	r.GET("/api/ip/sets", ListIPSets)
+	r.GET("/api/ip/sets/:name", GetIPSet)
+	r.PUT("/api/ip/sets/:name", PutIPSet)
+	r.DELETE("/api/ip/sets/:name", DeleteIPSet)
+
+	do := func(method, path, token, body string) *httptest.ResponseRecorder {
+		w := httptest.NewRecorder()
+		req := httptest.NewRequest(method, path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		if token != "" {
+			req.Header.Set("Authorization", "Bearer "+token)
+		}
+		r.ServeHTTP(w, req)
+		return w
+	}
+
+	// Changes need a key with the write:ip scope
+	w := do(http.MethodPut, "/api/ip/sets/vpn", "", `{"prefixes": ["10.8.0.0/16"]}`)
+	assert.Equal(t, http.StatusUnauthorized, w.Code)
+	w = do(http.MethodPut, "/api/ip/sets/vpn", "reader", `{"prefixes": ["10.8.0.0/16"]}`)
+	assert.Equal(t, http.StatusForbidden, w.Code)
+	w = do(http.MethodDelete, "/api/ip/sets/office", "reader", "")
+	assert.Equal(t, http.StatusForbidden, w.Code)
+
+	w = do(http.MethodPut, "/api/ip/sets/vpn", "writer", `{"description": "WireGuard", "prefixes": ["10.8.0.1/16"]}`)
+	assert.Equal(t, http.StatusOK, w.Code)
+	var set IPSetResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &set))
+	assert.Equal(t, []string{"10.8.0.0/16"}, set.Prefixes)
+
+	w = do(http.MethodPut, "/api/ip/sets/vpn", "writer", `{"prefixes": ["10.8.0.0/99"]}`)
+	assert.Equal(t, http.StatusBadRequest, w.Code)
+
+	w = do(http.MethodGet, "/api/ip/sets", "", "")
+	var list IPSetListResponse
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &list))
+	assert.Len(t, list.Sets, 3)
+
+	w = do(http.MethodDelete, "/api/ip/sets/vpn", "writer", "")
+	assert.Equal(t, http.StatusNoContent, w.Code)
+	w = do(http.MethodGet, "/api/ip/sets/vpn", "", "")
+	assert.Equal(t, http.StatusNotFound, w.Code)
+}
+
+func TestIPAccessControl(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	useTestIPSets(t)
+
+	r := gin.New()
+	r.GET("/internal", IPAccessControl([]string{"office"}, []string{"blocked"}, "/health"), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/public", IPAccessControl(nil, []string{"blocked"}), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/health", IPAccessControl([]string{"office"}, nil, "/health"), func(c *gin.Context) {
+		c.String(http.StatusOK, "ok")
+	})
+
+	tests := []struct {
+		name string
+		path string
+		peer string
+		xff  string
+		want int
+	}{
+		{"forwarded office address", "/internal", "192.0.2.1", "10.1.0.9", http.StatusOK},
+		{"forwarded other address", "/internal", "192.0.2.1", "203.0.113.1", http.StatusForbidden},
+		{"forwarded unblocked address", "/public", "192.0.2.1", "203.0.113.1", http.StatusOK},
+		{"forwarded blocked address", "/public", "192.0.2.1", "198.51.100.1", http.StatusForbidden},
+		{"office peer", "/internal", "10.1.0.9", "", http.StatusOK},
+		{"fake header from an untrusted peer", "/internal", "203.0.113.1", "10.1.0.9", http.StatusForbidden},
+		{"blocked peer hiding behind a fake header", "/public", "198.51.100.1", "203.0.113.1", http.StatusForbidden},
+		{"unparsable forwarded address", "/public", "192.0.2.1", "unknown", http.StatusForbidden},
+		{"exempt path", "/health", "203.0.113.1", "", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			req.RemoteAddr = tt.peer + ":1234"
+			if tt.xff != "" {
+				req.Header.Set("X-Forwarded-For", tt.xff)
+			}
+			r.ServeHTTP(w, req)
+
+			assert.Equal(t, tt.want, w.Code)
+		})
+	}
+}
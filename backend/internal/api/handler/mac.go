@@ -0,0 +1,127 @@
+package handler
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lRoccoon/utils-helper/internal/service"
+)
+
+// MACResponse represents the MAC address lookup response
+type MACResponse struct {
+	Address             string     `json:"address"`
+	Formats             MACFormats `json:"formats"`
+	Type                string     `json:"type"` // "unicast", "multicast" or "broadcast"
+	Multicast           bool       `json:"multicast"`
+	LocallyAdministered bool       `json:"locally_administered"`
+	Special             string     `json:"special,omitempty"`
+	Vendor              *MACVendor `json:"vendor,omitempty"`
+	EUI64               MACEUI64   `json:"eui64"`
+}
+
+// MACFormats represents a MAC address in common notations
+type MACFormats struct {
+	Colon string `json:"colon"`
+	Dash  string `json:"dash"`
+	Cisco string `json:"cisco"`
+	Bare  string `json:"bare"`
+}
+
+// MACVendor represents the IEEE assignment containing a MAC address
+type MACVendor struct {
+	Name     string `json:"name"`
+	Prefix   string `json:"prefix"`
+	Registry string `json:"registry"`
+}
+
+// MACEUI64 represents the IPv6 addresses derived from a MAC with EUI-64
+type MACEUI64 struct {
+	InterfaceID string `json:"interface_id"`
+	LinkLocal   string `json:"link_local"`
+}
+
+// IPMACInfo represents the MAC address embedded in an EUI-64 IPv6 address
+type IPMACInfo struct {
+	Address string `json:"address"`
+	Vendor  string `json:"vendor,omitempty"`
+}
+
+// PlainText returns the normalized address and vendor
+func (r MACResponse) PlainText() string {
+	if r.Vendor != nil {
+		return r.Address + " " + r.Vendor.Name
+	}
+	return r.Address
+}
+
+// LookupMAC handles GET /api/mac/:address requests
+func LookupMAC(c *gin.Context) {
+	info, err := service.LookupMAC(c.Param("address"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, ErrorResponse(c, err.Error()))
+		return
+	}
+
+	mac := info.Address
+	bare := strings.ReplaceAll(mac.String(), ":", "")
+	response := MACResponse{
+		Address: mac.String(),
+		Formats: MACFormats{
+			Colon: mac.String(),
+			Dash:  strings.ToUpper(strings.ReplaceAll(mac.String(), ":", "-")),
+			Cisco: bare[0:4] + "." + bare[4:8] + "." + bare[8:12],
+			Bare:  bare,
+		},
+		Type:                "unicast",
+		Multicast:           info.Multicast,
+		LocallyAdministered: info.Local,
+		Special:             info.Special,
+		EUI64: MACEUI64{
+			InterfaceID: formatInterfaceID(info.InterfaceID),
+			LinkLocal:   info.LinkLocal.String(),
+		},
+	}
+	switch {
+	case info.Broadcast:
+		response.Type = "broadcast"
+	case info.Multicast:
+		response.Type = "multicast"
+	}
+	if info.Vendor != "" {
+		response.Vendor = &MACVendor{
+			Name:     info.Vendor,
+			Prefix:   info.VendorPrefix,
+			Registry: info.VendorRegistry,
+		}
+	}
+
+	respond(c, http.StatusOK, response)
+}
+
+// eui64MAC returns the MAC address embedded in an EUI-64 IPv6 address, if any
+func eui64MAC(ip string) *IPMACInfo {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return nil
+	}
+	mac, ok := service.MACFromEUI64(addr.WithZone(""))
+	if !ok {
+		return nil
+	}
+
+	out := &IPMACInfo{Address: mac.String()}
+	if info, err := service.LookupMAC(mac.String()); err == nil {
+		out.Vendor = info.Vendor
+	}
+	return out
+}
+
+// formatInterfaceID writes an interface identifier as four IPv6 groups
+func formatInterfaceID(id [8]byte) string {
+	return fmt.Sprintf("%x:%x:%x:%x",
+		uint16(id[0])<<8|uint16(id[1]), uint16(id[2])<<8|uint16(id[3]),
+		uint16(id[4])<<8|uint16(id[5]), uint16(id[6])<<8|uint16(id[7]))
+}
@@ -0,0 +1,97 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLookupMAC(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		address        string
+		expectedStatus int
+		wantType       string
+		wantVendor     string
+	}{
+		{"Cisco dot notation", "001b.6384.45e6", http.StatusOK, "unicast", "Apple, Inc."},
+		{"Multicast", "01-00-5E-00-00-FB", http.StatusOK, "multicast", "ICANN, IANA Department"},
+		{"Broadcast", "ffffffffffff", http.StatusOK, "broadcast", ""},
+		{"Invalid", "00:1b:63", http.StatusBadRequest, "", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, "/api/mac/"+tt.address, nil)
+			c.Params = gin.Params{{Key: "address", Value: tt.address}}
+
+			LookupMAC(c)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response MACResponse
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantType, response.Type)
+			if tt.wantVendor == "" {
+				assert.Nil(t, response.Vendor)
+			} else if assert.NotNil(t, response.Vendor) {
+				assert.Equal(t, tt.wantVendor, response.Vendor.Name)
+			}
+		})
+	}
+}
+
+func TestLookupMACFormats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/mac/00-1B-63-84-45-E6", nil)
+	c.Params = gin.Params{{Key: "address", Value: "00-1B-63-84-45-E6"}}
+
+	LookupMAC(c)
+
+	var response MACResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, MACFormats{
+		Colon: "00:1b:63:84:45:e6",
+		Dash:  "00-1B-63-84-45-E6",
+		Cisco: "001b.6384.45e6",
+		Bare:  "001b638445e6",
+	}, response.Formats)
+	assert.Equal(t, "21b:63ff:fe84:45e6", response.EUI64.InterfaceID)
+	assert.Equal(t, "fe80::21b:63ff:fe84:45e6", response.EUI64.LinkLocal)
+	assert.Equal(t, "00:1b:63:84:45:e6 Apple, Inc.", response.PlainText())
+}
+
+func TestGetIPInfoEUI64(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/ip", nil)
+	c.Request.Header.Set("X-Forwarded-For", "2001:db8:1:2:250:56ff:fe12:3456")
+
+	GetIPInfo(c)
+
+	var response IPInfoResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	if assert.NotNil(t, response.MAC) {
+		assert.Equal(t, "00:50:56:12:34:56", response.MAC.Address)
+		assert.Equal(t, "VMware, Inc.", response.MAC.Vendor)
+	}
+}
@@ -0,0 +1,161 @@
+package handler
+
+import (
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lRoccoon/utils-helper/internal/service"
+)
+
+// Limits of GET /api/ports search results
+const (
+	defaultPortSearchLimit = 50
+	maxPortSearchLimit     = 500
+)
+
+// PortAssignmentInfo represents one service on a port or port range
+type PortAssignmentInfo struct {
+	Service     string `json:"service"`
+	Port        string `json:"port"`
+	Protocol    string `json:"protocol"`
+	Description string `json:"description,omitempty"`
+	Reference   string `json:"reference,omitempty"`
+	Official    bool   `json:"official"`
+}
+
+// PortResponse represents the port lookup response
+type PortResponse struct {
+	Port        int                  `json:"port"`
+	Protocol    string               `json:"protocol,omitempty"`
+	Range       string               `json:"range"`
+	Assignments []PortAssignmentInfo `json:"assignments"`
+}
+
+// PortSearchResponse represents the port search response
+type PortSearchResponse struct {
+	Query    string               `json:"query"`
+	Protocol string               `json:"protocol,omitempty"`
+	Total    int                  `json:"total"`
+	Results  []PortAssignmentInfo `json:"results"`
+}
+
+// PlainText returns one "port/protocol service" line per assignment
+func (r PortResponse) PlainText() string {
+	return portAssignmentLines(r.Assignments)
+}
+
+// PlainText returns one "port/protocol service" line per result
+func (r PortSearchResponse) PlainText() string {
+	return portAssignmentLines(r.Results)
+}
+
+// GetPort handles GET /api/ports/:port and /api/ports/:port/:protocol
+// requests; the protocol may also be given as ?protocol=tcp|udp|sctp|dccp
+func GetPort(c *gin.Context) {
+	protocol := c.Param("protocol")
+	if protocol == "" {
+		protocol = c.Query("protocol")
+	}
+	protocol, ok := portProtocol(c, protocol)
+	if !ok {
+		return
+	}
+
+	port, err := service.ParsePort(c.Param("port"))
+	if err != nil {
+		respond(c, http.StatusBadRequest, ErrorResponse(c, err.Error()))
+		return
+	}
+
+	respond(c, http.StatusOK, PortResponse{
+		Port:        port,
+		Protocol:    protocol,
+		Range:       service.PortRange(port),
+		Assignments: portAssignments(service.LookupPort(port, protocol)),
+	})
+}
+
+// SearchPorts handles GET /api/ports?q= requests, matching service names
+// and descriptions
+func SearchPorts(c *gin.Context) {
+	query := strings.TrimSpace(c.Query("q"))
+	if query == "" {
+		respond(c, http.StatusBadRequest, ErrorResponse(c, "Missing q parameter"))
+		return
+	}
+	protocol, ok := portProtocol(c, c.Query("protocol"))
+	if !ok {
+		return
+	}
+
+	limit := defaultPortSearchLimit
+	if v := c.Query("limit"); v != "" {
+		n, err := strconv.Atoi(v)
+		if err != nil || n < 1 || n > maxPortSearchLimit {
+			respond(c, http.StatusBadRequest, ErrorResponse(c, "limit must be between 1 and "+strconv.Itoa(maxPortSearchLimit)))
+			return
+		}
+		limit = n
+	}
+
+	results := service.SearchPorts(query, protocol)
+	total := len(results)
+	if len(results) > limit {
+		results = results[:limit]
+	}
+
+	respond(c, http.StatusOK, PortSearchResponse{
+		Query:    query,
+		Protocol: protocol,
+		Total:    total,
+		Results:  portAssignments(results),
+	})
+}
+
+// portProtocol validates a protocol filter, responding with an error if it
+// is not one of the registry's protocols
+func portProtocol(c *gin.Context, protocol string) (string, bool) {
+	protocol = strings.ToLower(strings.TrimSpace(protocol))
+	if protocol == "" {
+		return "", true
+	}
+	for _, p := range service.PortProtocols {
+		if p == protocol {
+			return protocol, true
+		}
+	}
+	respond(c, http.StatusBadRequest, ErrorResponse(c, "Invalid protocol, use one of "+strings.Join(service.PortProtocols, ", ")))
+	return "", false
+}
+
+// portAssignments converts service assignments to their response form
+func portAssignments(entries []service.PortAssignment) []PortAssignmentInfo {
+	out := make([]PortAssignmentInfo, 0, len(entries))
+	for _, e := range entries {
+		out = append(out, PortAssignmentInfo{
+			Service:     e.Service,
+			Port:        e.Ports(),
+			Protocol:    e.Protocol,
+			Description: e.Description,
+			Reference:   e.Reference,
+			Official:    e.Official,
+		})
+	}
+	return out
+}
+
+// portAssignmentLines formats assignments like /etc/services, marking
+// unofficial ones
+func portAssignmentLines(entries []PortAssignmentInfo) string {
+	lines := make([]string, 0, len(entries))
+	for _, e := range entries {
+		line := e.Port + "/" + e.Protocol + " " + e.Service
+		if !e.Official {
+			line += " (unofficial)"
+		}
+		lines = append(lines, line)
+	}
+	return strings.Join(lines, "\n")
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGetPort(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	r.GET("/api/ports/:port", GetPort)
+	r.GET("/api/ports/:port/:protocol", GetPort)
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+		wantServices   []string
+	}{
+		{"All protocols", "/api/ports/443", http.StatusOK, []string{"https", "https", "https"}},
+		{"Protocol in path", "/api/ports/8443/udp", http.StatusOK, []string{"pcsync-https", "https-alt"}},
+		{"Protocol query", "/api/ports/3868?protocol=SCTP", http.StatusOK, []string{"diameter"}},
+		{"Unassigned", "/api/ports/40000", http.StatusOK, []string{}},
+		{"Invalid port", "/api/ports/70000", http.StatusBadRequest, nil},
+		{"Invalid protocol", "/api/ports/80/icmp", http.StatusBadRequest, nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, tt.url, nil))
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response PortResponse
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			services := []string{}
+			for _, a := range response.Assignments {
+				services = append(services, a.Service)
+			}
+			assert.Equal(t, tt.wantServices, services)
+		})
+	}
+}
+
+func TestGetPortPlainText(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/ports/8443/udp?format=text", nil)
+	c.Params = gin.Params{{Key: "port", Value: "8443"}, {Key: "protocol", Value: "udp"}}
+
+	GetPort(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "8443/udp pcsync-https\n8443/udp https-alt (unofficial)\n", w.Body.String())
+}
+
+func TestSearchPorts(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	tests := []struct {
+		name           string
+		url            string
+		expectedStatus int
+		wantTotal      int
+		wantFirst      string
+	}{
+		{"By name", "/api/ports?q=ssh", http.StatusOK, 3, "ssh"},
+		{"With protocol and limit", "/api/ports?q=mqtt&protocol=tcp&limit=1", http.StatusOK, 2, "mqtt"},
+		{"No match", "/api/ports?q=gopher", http.StatusOK, 0, ""},
+		{"Missing query", "/api/ports", http.StatusBadRequest, 0, ""},
+		{"Invalid limit", "/api/ports?q=ssh&limit=0", http.StatusBadRequest, 0, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Request = httptest.NewRequest(http.MethodGet, tt.url, nil)
+
+			SearchPorts(c)
+
+			assert.Equal(t, tt.expectedStatus, w.Code)
+			if tt.expectedStatus != http.StatusOK {
+				return
+			}
+
+			var response PortSearchResponse
+			err := json.Unmarshal(w.Body.Bytes(), &response)
+			assert.NoError(t, err)
+			assert.Equal(t, tt.wantTotal, response.Total)
+			if tt.wantFirst != "" && assert.NotEmpty(t, response.Results) {
+				assert.Equal(t, tt.wantFirst, response.Results[0].Service)
+			}
+		})
+	}
+}
@@ -0,0 +1,390 @@
+package handler
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"net/http"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"gopkg.in/yaml.v3"
+)
+
+// Response formats supported by respond
+const (
+	formatJSON  = "json"
+	formatText  = "text"
+	formatKV    = "kv"
+	formatYAML  = "yaml"
+	formatXML   = "xml"
+	formatJSONP = "jsonp"
+)
+
+// formatAliases maps format query values to response formats
+var formatAliases = map[string]string{
+	"json":  formatJSON,
+	"text":  formatText,
+	"txt":   formatText,
+	"plain": formatText,
+	"kv":    formatKV,
+	"env":   formatKV,
+	"yaml":  formatYAML,
+	"yml":   formatYAML,
+	"xml":   formatXML,
+	"jsonp": formatJSONP,
+}
+
+// mediaFormats maps Accept media types to response formats
+var mediaFormats = map[string]string{
+	"application/json":       formatJSON,
+	"text/plain":             formatText,
+	"application/yaml":       formatYAML,
+	"application/x-yaml":     formatYAML,
+	"text/yaml":              formatYAML,
+	"application/xml":        formatXML,
+	"text/xml":               formatXML,
+	"application/javascript": formatJSONP,
+	"text/javascript":        formatJSONP,
+}
+
+// jsonpCallback restricts JSONP callbacks to dotted JavaScript identifiers
+var jsonpCallback = regexp.MustCompile(`^[A-Za-z_$][A-Za-z0-9_$]*(\.[A-Za-z_$][A-Za-z0-9_$]*)*$`)
+
+// PlainTexter is implemented by responses with a short text/plain form,
+// such as the bare address for IP lookups
+type PlainTexter interface {
+	PlainText() string
+}
+
+// respond writes obj in the format negotiated from the format query
+// parameter, a JSONP callback parameter, or the Accept header.
+// JSON is used when nothing more specific is requested.
+func respond(c *gin.Context, status int, obj interface{}) {
+	format, err := negotiateFormat(c, formatJSON)
+	if err != nil {
+		c.JSON(http.StatusBadRequest, ErrorResponse(c, err.Error()))
+		return
+	}
+	render(c, status, obj, format)
+}
+
+// negotiateFormat picks the response format for a request
+func negotiateFormat(c *gin.Context, fallback string) (string, error) {
+	if f := c.Query("format"); f != "" {
+		format, ok := formatAliases[strings.ToLower(f)]
+		if !ok {
+			return "", fmt.Errorf("unsupported format %q, use json, text, kv, yaml, xml or jsonp", f)
+		}
+		return format, nil
+	}
+	if c.Query("callback") != "" {
+		return formatJSONP, nil
+	}
+
+	accept := c.GetHeader("Accept")
+	if accept == "" {
+		return fallback, nil
+	}
+	return negotiateAccept(accept, fallback), nil
+}
+
+// negotiateAccept returns the supported format with the highest quality in
+// an Accept header. Browsers navigating to the API (text/html) get JSON.
+func negotiateAccept(accept, fallback string) string {
+	type candidate struct {
+		format string
+		q      float64
+	}
+
+	var candidates []candidate
+	for _, part := range strings.Split(accept, ",") {
+		mediaType, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		mediaType = strings.ToLower(strings.TrimSpace(mediaType))
+
+		q := 1.0
+		for _, param := range strings.Split(params, ";") {
+			if k, v, ok := strings.Cut(strings.TrimSpace(param), "="); ok && strings.EqualFold(k, "q") {
+				if parsed, err := strconv.ParseFloat(v, 64); err == nil {
+					q = parsed
+				}
+			}
+		}
+		if q <= 0 {
+			continue
+		}
+
+		switch {
+		case mediaType == "text/html":
+			return formatJSON
+		case mediaType == "*/*", mediaType == "application/*":
+			candidates = append(candidates, candidate{fallback, q - 0.0001})
+		case mediaFormats[mediaType] != "":
+			candidates = append(candidates, candidate{mediaFormats[mediaType], q})
+		}
+	}
+
+	if len(candidates) == 0 {
+		return fallback
+	}
+	sort.SliceStable(candidates, func(i, j int) bool {
+		return candidates[i].q > candidates[j].q
+	})
+	return candidates[0].format
+}
+
+// render writes obj in the given format
+func render(c *gin.Context, status int, obj interface{}, format string) {
+	switch format {
+	case formatText:
+		if pt, ok := obj.(PlainTexter); ok {
+			c.String(status, "%s\n", pt.PlainText())
+			return
+		}
+		renderKV(c, status, obj)
+	case formatKV:
+		renderKV(c, status, obj)
+	case formatYAML:
+		renderYAML(c, status, obj)
+	case formatXML:
+		renderXML(c, status, obj)
+	case formatJSONP:
+		renderJSONP(c, status, obj)
+	default:
+		c.JSON(status, obj)
+	}
+}
+
+// renderKV writes obj as key=value lines with nested keys joined by dots
+func renderKV(c *gin.Context, status int, obj interface{}) {
+	value, err := toOrdered(obj)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "error=%s\n", err)
+		return
+	}
+
+	var buf bytes.Buffer
+	writeKV(&buf, "", value)
+	c.Data(status, "text/plain; charset=utf-8", buf.Bytes())
+}
+
+// renderYAML writes obj as YAML using its JSON field names and order
+func renderYAML(c *gin.Context, status int, obj interface{}) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "error: %s\n", err)
+		return
+	}
+
+	// JSON is valid YAML; decoding it into a node keeps the field order
+	var node yaml.Node
+	if err := yaml.Unmarshal(data, &node); err != nil {
+		c.String(http.StatusInternalServerError, "error: %s\n", err)
+		return
+	}
+	clearYAMLStyle(&node)
+
+	out, err := yaml.Marshal(&node)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "error: %s\n", err)
+		return
+	}
+	c.Data(status, "application/yaml; charset=utf-8", out)
+}
+
+// renderXML writes obj as XML under a <response> root element
+func renderXML(c *gin.Context, status int, obj interface{}) {
+	value, err := toOrdered(obj)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "<error>%s</error>\n", err)
+		return
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(xml.Header)
+	enc := xml.NewEncoder(&buf)
+	enc.Indent("", "  ")
+	if err := writeXML(enc, "response", value); err != nil {
+		c.String(http.StatusInternalServerError, "<error>%s</error>\n", err)
+		return
+	}
+	enc.Flush()
+	buf.WriteByte('\n')
+	c.Data(status, "application/xml; charset=utf-8", buf.Bytes())
+}
+
+// renderJSONP wraps the JSON response in a callback, defaulting to "callback"
+func renderJSONP(c *gin.Context, status int, obj interface{}) {
+	callback := c.DefaultQuery("callback", "callback")
+	if !jsonpCallback.MatchString(callback) {
+		c.JSON(http.StatusBadRequest, ErrorResponse(c, "Invalid callback name"))
+		return
+	}
+
+	data, err := json.Marshal(obj)
+	if err != nil {
+		c.String(http.StatusInternalServerError, "error: %s\n", err)
+		return
+	}
+
+	// The leading comment guards against content sniffing attacks (Rosetta Flash)
+	body := fmt.Sprintf("/**/%s(%s);", callback, data)
+	c.Data(status, "application/javascript; charset=utf-8", []byte(body))
+}
+
+// orderedField is one member of a JSON object, kept in encoding order
+type orderedField struct {
+	key   string
+	value interface{}
+}
+
+// toOrdered converts obj to its JSON representation as nested []orderedField,
+// []interface{} and scalar values, keeping the field order of the encoding
+func toOrdered(obj interface{}) (interface{}, error) {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return nil, err
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(data))
+	dec.UseNumber()
+	return decodeOrdered(dec)
+}
+
+func decodeOrdered(dec *json.Decoder) (interface{}, error) {
+	tok, err := dec.Token()
+	if err != nil {
+		return nil, err
+	}
+
+	switch tok {
+	case json.Delim('{'):
+		fields := []orderedField{}
+		for dec.More() {
+			key, err := dec.Token()
+			if err != nil {
+				return nil, err
+			}
+			value, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			fields = append(fields, orderedField{key: key.(string), value: value})
+		}
+		_, err := dec.Token()
+		return fields, err
+	case json.Delim('['):
+		items := []interface{}{}
+		for dec.More() {
+			item, err := decodeOrdered(dec)
+			if err != nil {
+				return nil, err
+			}
+			items = append(items, item)
+		}
+		_, err := dec.Token()
+		return items, err
+	}
+	return tok, nil
+}
+
+// writeKV writes a flattened key=value listing
+func writeKV(buf *bytes.Buffer, prefix string, value interface{}) {
+	join := func(key string) string {
+		if prefix == "" {
+			return key
+		}
+		return prefix + "." + key
+	}
+
+	switch v := value.(type) {
+	case []orderedField:
+		for _, f := range v {
+			writeKV(buf, join(f.key), f.value)
+		}
+	case []interface{}:
+		for i, item := range v {
+			writeKV(buf, join(strconv.Itoa(i)), item)
+		}
+	default:
+		if prefix == "" {
+			prefix = "value"
+		}
+		fmt.Fprintf(buf, "%s=%s\n", prefix, kvScalar(v))
+	}
+}
+
+// kvScalar formats a scalar, quoting strings that a shell would split
+func kvScalar(v interface{}) string {
+	switch v := v.(type) {
+	case nil:
+		return ""
+	case string:
+		if strings.ContainsAny(v, " \t\r\n\"'\\$`;&|<>()") {
+			return strconv.Quote(v)
+		}
+		return v
+	}
+	return fmt.Sprint(v)
+}
+
+// writeXML writes a value as an element with the given name
+func writeXML(enc *xml.Encoder, name string, value interface{}) error {
+	start := xml.StartElement{Name: xml.Name{Local: xmlName(name)}}
+	if err := enc.EncodeToken(start); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case []orderedField:
+		for _, f := range v {
+			if err := writeXML(enc, f.key, f.value); err != nil {
+				return err
+			}
+		}
+	case []interface{}:
+		for _, item := range v {
+			if err := writeXML(enc, "item", item); err != nil {
+				return err
+			}
+		}
+	case nil:
+	default:
+		if err := enc.EncodeToken(xml.CharData(fmt.Sprint(v))); err != nil {
+			return err
+		}
+	}
+	return enc.EncodeToken(start.End())
+}
+
+// xmlName turns a JSON key into a valid XML element name
+func xmlName(key string) string {
+	var sb strings.Builder
+	for i, r := range key {
+		valid := r == '_' || r == '-' || r == '.' ||
+			(r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9')
+		if !valid {
+			r = '_'
+		}
+		if i == 0 && (r == '-' || r == '.' || (r >= '0' && r <= '9')) {
+			sb.WriteByte('_')
+		}
+		sb.WriteRune(r)
+	}
+	if sb.Len() == 0 {
+		return "_"
+	}
+	return sb.String()
+}
+
+// clearYAMLStyle resets the flow and quoting styles inherited from JSON input
+func clearYAMLStyle(node *yaml.Node) {
+	node.Style = 0
+	for _, child := range node.Content {
+		clearYAMLStyle(child)
+	}
+}
@@ -0,0 +1,183 @@
+package handler
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+// renderTestResponse exercises nested objects, arrays and quoting
+type renderTestResponse struct {
+	Name    string            `json:"name"`
+	Count   int               `json:"count"`
+	Tags    []string          `json:"tags"`
+	Details map[string]string `json:"details"`
+	Empty   *string           `json:"empty"`
+}
+
+func TestNegotiateAccept(t *testing.T) {
+	tests := []struct {
+		accept   string
+		fallback string
+		want     string
+	}{
+		{"application/json", formatText, formatJSON},
+		{"text/plain", formatJSON, formatText},
+		{"application/yaml", formatJSON, formatYAML},
+		{"application/x-yaml", formatJSON, formatYAML},
+		{"text/xml", formatJSON, formatXML},
+		{"*/*", formatJSON, formatJSON},
+		{"*/*", formatText, formatText},
+		{"text/plain;q=0.5, application/xml", formatJSON, formatXML},
+		{"application/xml;q=0.1, text/plain;q=0.9", formatJSON, formatText},
+		{"text/plain, */*;q=0.1", formatJSON, formatText},
+		{"application/json;q=0, text/plain", formatJSON, formatText},
+		{"image/png", formatJSON, formatJSON},
+		{"text/html,application/xhtml+xml,application/xml;q=0.9,*/*;q=0.8", formatJSON, formatJSON},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.accept, func(t *testing.T) {
+			assert.Equal(t, tt.want, negotiateAccept(tt.accept, tt.fallback))
+		})
+	}
+}
+
+func TestRespondFormats(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	obj := renderTestResponse{
+		Name:    "two words",
+		Count:   3,
+		Tags:    []string{"a", "b"},
+		Details: map[string]string{"1st": "x"},
+	}
+
+	tests := []struct {
+		name        string
+		url         string
+		accept      string
+		status      int
+		contentType string
+		body        string
+	}{
+		{
+			name:        "Default JSON",
+			url:         "/",
+			status:      http.StatusOK,
+			contentType: "application/json; charset=utf-8",
+			body:        `{"name":"two words","count":3,"tags":["a","b"],"details":{"1st":"x"},"empty":null}`,
+		},
+		{
+			name:        "Key value via Accept",
+			url:         "/",
+			accept:      "text/plain",
+			status:      http.StatusOK,
+			contentType: "text/plain; charset=utf-8",
+			body:        "name=\"two words\"\ncount=3\ntags.0=a\ntags.1=b\ndetails.1st=x\nempty=\n",
+		},
+		{
+			name:        "YAML via format",
+			url:         "/?format=yml",
+			status:      http.StatusOK,
+			contentType: "application/yaml; charset=utf-8",
+			body:        "name: two words\ncount: 3\ntags:\n    - a\n    - b\ndetails:\n    1st: x\nempty: null\n",
+		},
+		{
+			name:        "XML via format",
+			url:         "/?format=xml",
+			status:      http.StatusOK,
+			contentType: "application/xml; charset=utf-8",
+			body: `<?xml version="1.0" encoding="UTF-8"?>
+<response>
+  <name>two words</name>
+  <count>3</count>
+  <tags>
+    <item>a</item>
+    <item>b</item>
+  </tags>
+  <details>
+    <_1st>x</_1st>
+  </details>
+  <empty></empty>
+</response>
+`,
+		},
+		{
+			name:        "JSONP via callback",
+			url:         "/?callback=app.handle",
+			status:      http.StatusOK,
+			contentType: "application/javascript; charset=utf-8",
+			body:        `/**/app.handle({"name":"two words","count":3,"tags":["a","b"],"details":{"1st":"x"},"empty":null});`,
+		},
+		{
+			name:   "Unsafe JSONP callback",
+			url:    "/?callback=alert(1)//",
+			status: http.StatusBadRequest,
+		},
+		{
+			name:   "Unknown format",
+			url:    "/?format=csv",
+			status: http.StatusBadRequest,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+
+			req := httptest.NewRequest(http.MethodGet, tt.url, nil)
+			if tt.accept != "" {
+				req.Header.Set("Accept", tt.accept)
+			}
+			c.Request = req
+
+			respond(c, http.StatusOK, obj)
+
+			assert.Equal(t, tt.status, w.Code)
+			if tt.status != http.StatusOK {
+				assert.Contains(t, w.Body.String(), "error")
+				return
+			}
+			assert.Equal(t, tt.contentType, w.Header().Get("Content-Type"))
+			assert.Equal(t, tt.body, w.Body.String())
+		})
+	}
+}
+
+func TestRespondPlainText(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/?format=text", nil)
+
+	respond(c, http.StatusOK, IPInfoResponse{IP: "203.0.113.7", Version: "IPv4"})
+
+	assert.Equal(t, "203.0.113.7\n", w.Body.String())
+
+	// kv lists every field even when a plain-text form exists
+	w = httptest.NewRecorder()
+	c, _ = gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/?format=kv", nil)
+
+	respond(c, http.StatusOK, IPInfoResponse{IP: "203.0.113.7", Version: "IPv4"})
+
+	assert.Equal(t, "ip=203.0.113.7\nversion=IPv4\n", w.Body.String())
+}
+
+func TestYAMLQuotesAmbiguousStrings(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+	c.Request = httptest.NewRequest(http.MethodGet, "/?format=yaml", nil)
+
+	respond(c, http.StatusOK, gin.H{"addresses": "1024", "flag": "true"})
+
+	assert.Equal(t, "addresses: \"1024\"\nflag: \"true\"\n", w.Body.String())
+}
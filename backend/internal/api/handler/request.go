@@ -0,0 +1,149 @@
+package handler
+
+import (
+	"crypto/tls"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lRoccoon/utils-helper/internal/config"
+)
+
+// maxEchoBodySize bounds how much of a request body /api/request will read
+const maxEchoBodySize = 10 << 20
+
+// redactedValue replaces the values of redacted headers
+const redactedValue = "[REDACTED]"
+
+var (
+	redactedHeaders   = canonicalHeaderSet(config.RedactHeaders)
+	redactedHeadersMu sync.RWMutex
+)
+
+// RequestEchoResponse represents everything the server saw about a request
+type RequestEchoResponse struct {
+	Method        string              `json:"method"`
+	URL           string              `json:"url"`
+	Protocol      string              `json:"protocol"`
+	Host          string              `json:"host"`
+	Headers       map[string][]string `json:"headers"`
+	ClientIP      string              `json:"client_ip"`
+	ClientIPTrail []IPResolutionStep  `json:"client_ip_trail"`
+	RemoteIP      string              `json:"remote_ip"`
+	RemotePort    string              `json:"remote_port"`
+	TLS           *TLSInfo            `json:"tls,omitempty"`
+	ContentLength int64               `json:"content_length"`
+	BodySize      int64               `json:"body_size"`
+	BodyTruncated bool                `json:"body_truncated,omitempty"`
+}
+
+// TLSInfo represents the TLS parameters of a connection
+type TLSInfo struct {
+	Version           string `json:"version"`
+	CipherSuite       string `json:"cipher_suite"`
+	ServerName        string `json:"server_name,omitempty"`
+	ALPN              string `json:"alpn,omitempty"`
+	Resumed           bool   `json:"resumed"`
+	ClientCertificate string `json:"client_certificate,omitempty"`
+}
+
+// SetRedactedHeaders replaces the list of headers hidden by /api/request
+func SetRedactedHeaders(headers []string) {
+	set := canonicalHeaderSet(headers)
+
+	redactedHeadersMu.Lock()
+	redactedHeaders = set
+	redactedHeadersMu.Unlock()
+}
+
+// EchoRequest handles /api/request for any method
+func EchoRequest(c *gin.Context) {
+	req := c.Request
+	clientIP, trail := resolveRealIP(c)
+
+	response := RequestEchoResponse{
+		Method:        req.Method,
+		URL:           requestURL(req),
+		Protocol:      req.Proto,
+		Host:          req.Host,
+		Headers:       redactHeaders(req.Header),
+		ClientIP:      clientIP,
+		ClientIPTrail: trail,
+		ContentLength: req.ContentLength,
+		TLS:           tlsInfo(req.TLS),
+	}
+
+	response.RemoteIP, response.RemotePort, _ = net.SplitHostPort(req.RemoteAddr)
+	if response.RemoteIP == "" {
+		response.RemoteIP = req.RemoteAddr
+	}
+
+	if req.Body != nil {
+		n, _ := io.Copy(io.Discard, io.LimitReader(req.Body, maxEchoBodySize+1))
+		if n > maxEchoBodySize {
+			n = maxEchoBodySize
+			response.BodyTruncated = true
+		}
+		response.BodySize = n
+	}
+
+	respond(c, http.StatusOK, response)
+}
+
+// requestURL rebuilds the absolute URL the client requested
+func requestURL(req *http.Request) string {
+	scheme := "http"
+	if req.TLS != nil {
+		scheme = "https"
+	}
+	return scheme + "://" + req.Host + req.URL.RequestURI()
+}
+
+// redactHeaders copies headers, hiding the values of redacted ones
+func redactHeaders(header http.Header) map[string][]string {
+	redactedHeadersMu.RLock()
+	defer redactedHeadersMu.RUnlock()
+
+	headers := make(map[string][]string, len(header))
+	for name, values := range header {
+		if redactedHeaders[http.CanonicalHeaderKey(name)] {
+			headers[name] = []string{redactedValue}
+			continue
+		}
+		headers[name] = append([]string(nil), values...)
+	}
+	return headers
+}
+
+// tlsInfo describes a TLS connection state, or returns nil for plain HTTP
+func tlsInfo(state *tls.ConnectionState) *TLSInfo {
+	if state == nil {
+		return nil
+	}
+
+	info := &TLSInfo{
+		Version:     tls.VersionName(state.Version),
+		CipherSuite: tls.CipherSuiteName(state.CipherSuite),
+		ServerName:  state.ServerName,
+		ALPN:        state.NegotiatedProtocol,
+		Resumed:     state.DidResume,
+	}
+	if len(state.PeerCertificates) > 0 {
+		info.ClientCertificate = state.PeerCertificates[0].Subject.String()
+	}
+	return info
+}
+
+// canonicalHeaderSet builds a lookup set of canonical header names
+func canonicalHeaderSet(headers []string) map[string]bool {
+	set := make(map[string]bool, len(headers))
+	for _, h := range headers {
+		if h = strings.TrimSpace(h); h != "" {
+			set[http.CanonicalHeaderKey(h)] = true
+		}
+	}
+	return set
+}
@@ -0,0 +1,109 @@
+package handler
+
+import (
+	"crypto/tls"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lRoccoon/utils-helper/internal/config"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEchoRequest(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	SetTrustedProxies([]netip.Prefix{netip.MustParsePrefix("192.0.2.10/32"), netip.MustParsePrefix("10.0.0.0/8")})
+	defer SetTrustedProxies(testProxies)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	req := httptest.NewRequest(http.MethodPost, "/api/request?debug=1", strings.NewReader("hello world"))
+	req.RemoteAddr = "192.0.2.10:54321"
+	req.Header.Set("X-Forwarded-For", "203.0.113.5, 10.0.0.1")
+	req.Header.Set("Authorization", "Bearer secret-token")
+	req.Header.Set("Cookie", "session=abc")
+	req.Header.Set("User-Agent", "curl/8.5.0")
+	c.Request = req
+
+	EchoRequest(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	var response RequestEchoResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "POST", response.Method)
+	assert.Equal(t, "http://example.com/api/request?debug=1", response.URL)
+	assert.Equal(t, "HTTP/1.1", response.Protocol)
+	assert.Equal(t, "192.0.2.10", response.RemoteIP)
+	assert.Equal(t, "54321", response.RemotePort)
+	assert.Equal(t, int64(11), response.BodySize)
+	assert.Nil(t, response.TLS)
+
+	// Sensitive headers are redacted, others are echoed
+	assert.Equal(t, []string{"[REDACTED]"}, response.Headers["Authorization"])
+	assert.Equal(t, []string{"[REDACTED]"}, response.Headers["Cookie"])
+	assert.Equal(t, []string{"curl/8.5.0"}, response.Headers["User-Agent"])
+	assert.NotContains(t, w.Body.String(), "secret-token")
+
+	// The trail shows X-Forwarded-For won
+	assert.Equal(t, "203.0.113.5", response.ClientIP)
+	assert.Len(t, response.ClientIPTrail, 1)
+	assert.Equal(t, "X-Forwarded-For", response.ClientIPTrail[0].Source)
+	assert.True(t, response.ClientIPTrail[0].Used)
+}
+
+func TestEchoRequestTLS(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/request", nil)
+	req.Host = "tools.example.com"
+	req.TLS = &tls.ConnectionState{
+		Version:            tls.VersionTLS13,
+		CipherSuite:        tls.TLS_AES_128_GCM_SHA256,
+		ServerName:         "tools.example.com",
+		NegotiatedProtocol: "h2",
+	}
+	c.Request = req
+
+	EchoRequest(c)
+
+	var response RequestEchoResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "https://tools.example.com/api/request", response.URL)
+	if assert.NotNil(t, response.TLS) {
+		assert.Equal(t, "TLS 1.3", response.TLS.Version)
+		assert.Equal(t, "TLS_AES_128_GCM_SHA256", response.TLS.CipherSuite)
+		assert.Equal(t, "tools.example.com", response.TLS.ServerName)
+		assert.Equal(t, "h2", response.TLS.ALPN)
+	}
+
+	// Without proxy headers the trail falls back to the connection address
+	assert.Len(t, response.ClientIPTrail, 3)
+	assert.Equal(t, "RemoteAddr", response.ClientIPTrail[2].Source)
+	assert.False(t, response.ClientIPTrail[0].Used)
+}
+
+func TestSetRedactedHeaders(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	defer SetRedactedHeaders(config.RedactHeaders)
+
+	SetRedactedHeaders([]string{"x-internal-token", " "})
+
+	headers := redactHeaders(http.Header{
+		"X-Internal-Token": {"abc"},
+		"Authorization":    {"Bearer xyz"},
+	})
+
+	assert.Equal(t, []string{"[REDACTED]"}, headers["X-Internal-Token"])
+	assert.Equal(t, []string{"Bearer xyz"}, headers["Authorization"])
+}
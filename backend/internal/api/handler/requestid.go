@@ -0,0 +1,54 @@
+package handler
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"regexp"
+
+	"github.com/gin-gonic/gin"
+)
+
+// RequestIDHeader carries the request ID in requests and responses
+const RequestIDHeader = "X-Request-ID"
+
+// requestIDKey stores the request ID in the Gin context
+const requestIDKey = "requestID"
+
+// validRequestID accepts IDs from clients and proxies that are safe to log
+// and echo: UUIDs, ULIDs, trace IDs and the like
+var validRequestID = regexp.MustCompile(`^[A-Za-z0-9._:+/=-]{1,128}$`)
+
+// RequestID takes the request ID from X-Request-ID, or generates one when
+// it is missing or malformed, and returns it in the response header
+func RequestID(c *gin.Context) {
+	id := c.GetHeader(RequestIDHeader)
+	if !validRequestID.MatchString(id) {
+		id = newRequestID()
+	}
+	c.Set(requestIDKey, id)
+	c.Header(RequestIDHeader, id)
+	c.Next()
+}
+
+// GetRequestID returns the ID of the current request, or "" outside the
+// RequestID middleware
+func GetRequestID(c *gin.Context) string {
+	return c.GetString(requestIDKey)
+}
+
+// ErrorResponse builds an error body carrying the request ID, so a failed
+// call reported by a user can be found in the logs
+func ErrorResponse(c *gin.Context, msg string) gin.H {
+	body := gin.H{"error": msg}
+	if id := GetRequestID(c); id != "" {
+		body["request_id"] = id
+	}
+	return body
+}
+
+// newRequestID returns 16 random bytes in hex, like a trace ID
+func newRequestID() string {
+	var b [16]byte
+	_, _ = rand.Read(b[:])
+	return hex.EncodeToString(b[:])
+}
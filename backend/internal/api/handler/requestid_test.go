@@ -0,0 +1,75 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func requestIDRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(RequestID)
+	r.GET("/api/holiday/:date", GetHolidayByDate)
+	return r
+}
+
+func TestRequestID(t *testing.T) {
+	r := requestIDRouter()
+
+	tests := []struct {
+		name string
+		id   string
+		keep bool
+	}{
+		{"uuid", "3f2b8c1e-7a4d-4e8b-9c2f-1a2b3c4d5e6f", true},
+		{"trace id", "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"missing", "", false},
+		{"log injection", "abc\ninjected=1", false},
+		{"too long", strings.Repeat("a", 129), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/api/holiday/2024-10-01", nil)
+			if tt.id != "" {
+				req.Header.Set(RequestIDHeader, tt.id)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+
+			got := w.Header().Get(RequestIDHeader)
+			if tt.keep {
+				assert.Equal(t, tt.id, got)
+			} else {
+				assert.Regexp(t, `^[0-9a-f]{32}$`, got)
+			}
+		})
+	}
+}
+
+func TestErrorResponsesCarryRequestID(t *testing.T) {
+	r := requestIDRouter()
+
+	for _, format := range []string{"json", "yaml", "kv"} {
+		req := httptest.NewRequest(http.MethodGet, "/api/holiday/not-a-date?format="+format, nil)
+		req.Header.Set(RequestIDHeader, "req-123")
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, req)
+
+		assert.Equal(t, http.StatusBadRequest, w.Code)
+		assert.Contains(t, w.Body.String(), "req-123", format)
+	}
+
+	req := httptest.NewRequest(http.MethodGet, "/api/holiday/2024-10-01", nil)
+	req.Header.Set(RequestIDHeader, "req-456")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	var body map[string]interface{}
+	assert.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.NotContains(t, body, "request_id", "successful responses are unchanged")
+}
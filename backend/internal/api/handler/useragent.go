@@ -0,0 +1,193 @@
+package handler
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lRoccoon/utils-helper/internal/service"
+)
+
+// acceptClientHints lists the high-entropy hints requested from Chromium
+// browsers so that follow-up requests to /api/ua carry them
+const acceptClientHints = "Sec-CH-UA-Full-Version-List, Sec-CH-UA-Platform-Version, Sec-CH-UA-Model, Sec-CH-UA-Arch, Sec-CH-UA-Bitness"
+
+// UserAgentResponse represents the parsed User-Agent response
+type UserAgentResponse struct {
+	UserAgent    string         `json:"user_agent"`
+	Browser      UABrowser      `json:"browser"`
+	Engine       UAComponent    `json:"engine"`
+	OS           UAComponent    `json:"os"`
+	Device       UADevice       `json:"device"`
+	IsBot        bool           `json:"is_bot"`
+	Bot          *UABot         `json:"bot,omitempty"`
+	ClientHints  *UAClientHints `json:"client_hints,omitempty"`
+	RulesVersion string         `json:"rules_version"`
+}
+
+// UABrowser represents the browser detected in a User-Agent
+type UABrowser struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+	Major   string `json:"major,omitempty"`
+}
+
+// UAComponent represents a named, versioned part of a User-Agent
+type UAComponent struct {
+	Name    string `json:"name,omitempty"`
+	Version string `json:"version,omitempty"`
+}
+
+// UADevice represents the device detected in a User-Agent
+type UADevice struct {
+	Type   string `json:"type,omitempty"`
+	Vendor string `json:"vendor,omitempty"`
+	Model  string `json:"model,omitempty"`
+}
+
+// UABot represents the bot or crawler detected in a User-Agent
+type UABot struct {
+	Name     string `json:"name"`
+	Category string `json:"category"`
+}
+
+// UAClientHints represents the Sec-CH-UA* headers sent by the caller
+type UAClientHints struct {
+	Brands          []UABrand `json:"brands,omitempty"`
+	FullVersionList []UABrand `json:"full_version_list,omitempty"`
+	Mobile          *bool     `json:"mobile,omitempty"`
+	Platform        string    `json:"platform,omitempty"`
+	PlatformVersion string    `json:"platform_version,omitempty"`
+	Model           string    `json:"model,omitempty"`
+	Architecture    string    `json:"architecture,omitempty"`
+	Bitness         string    `json:"bitness,omitempty"`
+}
+
+// UABrand represents one brand/version pair of Sec-CH-UA
+type UABrand struct {
+	Brand   string `json:"brand"`
+	Version string `json:"version"`
+}
+
+// PlainText summarizes the User-Agent on one line
+func (r UserAgentResponse) PlainText() string {
+	if r.IsBot && r.Bot != nil {
+		return r.Bot.Name + " (" + r.Bot.Category + ")"
+	}
+
+	var parts []string
+	if r.Browser.Name != "" {
+		parts = append(parts, strings.TrimSpace(r.Browser.Name+" "+r.Browser.Major))
+	}
+	if r.OS.Name != "" {
+		parts = append(parts, "on "+strings.TrimSpace(r.OS.Name+" "+r.OS.Version))
+	}
+	if r.Device.Type != "" {
+		parts = append(parts, "("+r.Device.Type+")")
+	}
+	if len(parts) == 0 {
+		return "unknown"
+	}
+	return strings.Join(parts, " ")
+}
+
+// ParseUserAgent handles GET /api/ua requests. It parses the ua query
+// parameter if given, otherwise the caller's own User-Agent and Client Hints.
+func ParseUserAgent(c *gin.Context) {
+	ua, supplied := c.GetQuery("ua")
+	if !supplied {
+		ua = c.GetHeader("User-Agent")
+	}
+
+	info := service.ParseUserAgent(ua)
+
+	// Client Hints describe the caller, not a supplied string
+	var hints *service.ClientHints
+	if !supplied {
+		hints = clientHintsFromRequest(c.Request)
+		if hints != nil {
+			service.ApplyClientHints(&info, *hints)
+		}
+		c.Header("Accept-CH", acceptClientHints)
+		c.Header("Vary", "User-Agent, Sec-CH-UA, Sec-CH-UA-Mobile, Sec-CH-UA-Platform")
+	}
+
+	respond(c, http.StatusOK, buildUserAgentResponse(info, hints))
+}
+
+// clientHintsFromRequest reads the Sec-CH-UA* headers, or returns nil if the
+// request carries none
+func clientHintsFromRequest(req *http.Request) *service.ClientHints {
+	h := req.Header
+	hints := service.ClientHints{
+		Brands:          service.ParseClientHintBrands(h.Get("Sec-CH-UA")),
+		FullVersionList: service.ParseClientHintBrands(h.Get("Sec-CH-UA-Full-Version-List")),
+		Mobile:          service.ParseClientHintBool(h.Get("Sec-CH-UA-Mobile")),
+		Platform:        service.ParseClientHintString(h.Get("Sec-CH-UA-Platform")),
+		PlatformVersion: service.ParseClientHintString(h.Get("Sec-CH-UA-Platform-Version")),
+		Model:           service.ParseClientHintString(h.Get("Sec-CH-UA-Model")),
+		Architecture:    service.ParseClientHintString(h.Get("Sec-CH-UA-Arch")),
+		Bitness:         service.ParseClientHintString(h.Get("Sec-CH-UA-Bitness")),
+	}
+
+	if len(hints.Brands) == 0 && len(hints.FullVersionList) == 0 && hints.Mobile == nil &&
+		hints.Platform == "" && hints.PlatformVersion == "" && hints.Model == "" &&
+		hints.Architecture == "" && hints.Bitness == "" {
+		return nil
+	}
+	return &hints
+}
+
+// buildUserAgentResponse converts parsed User-Agent details to the response
+func buildUserAgentResponse(info service.UserAgentInfo, hints *service.ClientHints) UserAgentResponse {
+	major, _, _ := strings.Cut(info.BrowserVersion, ".")
+
+	response := UserAgentResponse{
+		UserAgent: info.UserAgent,
+		Browser: UABrowser{
+			Name:    info.BrowserName,
+			Version: info.BrowserVersion,
+			Major:   major,
+		},
+		Engine: UAComponent{Name: info.EngineName, Version: info.EngineVersion},
+		OS:     UAComponent{Name: info.OSName, Version: info.OSVersion},
+		Device: UADevice{
+			Type:   info.DeviceType,
+			Vendor: info.DeviceVendor,
+			Model:  info.DeviceModel,
+		},
+		IsBot:        info.IsBot,
+		RulesVersion: service.GetUserAgentRulesVersion(),
+	}
+
+	if info.IsBot {
+		response.Bot = &UABot{Name: info.BotName, Category: info.BotCategory}
+	}
+
+	if hints != nil {
+		response.ClientHints = &UAClientHints{
+			Brands:          uaBrands(hints.Brands),
+			FullVersionList: uaBrands(hints.FullVersionList),
+			Mobile:          hints.Mobile,
+			Platform:        hints.Platform,
+			PlatformVersion: hints.PlatformVersion,
+			Model:           hints.Model,
+			Architecture:    hints.Architecture,
+			Bitness:         hints.Bitness,
+		}
+	}
+
+	return response
+}
+
+// uaBrands converts Client Hints brands to their response form
+func uaBrands(brands []service.ClientHintBrand) []UABrand {
+	if len(brands) == 0 {
+		return nil
+	}
+	out := make([]UABrand, 0, len(brands))
+	for _, b := range brands {
+		out = append(out, UABrand{Brand: b.Brand, Version: b.Version})
+	}
+	return out
+}
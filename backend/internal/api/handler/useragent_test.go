@@ -0,0 +1,95 @@
+package handler
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/ua", nil)
+	req.Header.Set("User-Agent", "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36")
+	req.Header.Set("Sec-CH-UA", `"Chromium";v="124", "Google Chrome";v="124", "Not-A.Brand";v="99"`)
+	req.Header.Set("Sec-CH-UA-Mobile", "?0")
+	req.Header.Set("Sec-CH-UA-Platform", `"Windows"`)
+	req.Header.Set("Sec-CH-UA-Platform-Version", `"15.0.0"`)
+	c.Request = req
+
+	ParseUserAgent(c)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Contains(t, w.Header().Get("Accept-CH"), "Sec-CH-UA-Platform-Version")
+
+	var response UserAgentResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, "Chrome", response.Browser.Name)
+	assert.Equal(t, "124", response.Browser.Major)
+	assert.Equal(t, "Blink", response.Engine.Name)
+	assert.Equal(t, "Windows", response.OS.Name)
+	assert.Equal(t, "11", response.OS.Version)
+	assert.Equal(t, "desktop", response.Device.Type)
+	assert.False(t, response.IsBot)
+	assert.NotEmpty(t, response.RulesVersion)
+	if assert.NotNil(t, response.ClientHints) {
+		assert.Len(t, response.ClientHints.Brands, 3)
+		assert.Equal(t, "Windows", response.ClientHints.Platform)
+		if assert.NotNil(t, response.ClientHints.Mobile) {
+			assert.False(t, *response.ClientHints.Mobile)
+		}
+	}
+}
+
+func TestParseUserAgentSupplied(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	ua := "Mozilla/5.0 (compatible; bingbot/2.0; +http://www.bing.com/bingbot.htm)"
+	req := httptest.NewRequest(http.MethodGet, "/api/ua?ua="+url.QueryEscape(ua), nil)
+	req.Header.Set("User-Agent", "curl/8.5.0")
+	req.Header.Set("Sec-CH-UA-Platform", `"macOS"`)
+	c.Request = req
+
+	ParseUserAgent(c)
+
+	var response UserAgentResponse
+	err := json.Unmarshal(w.Body.Bytes(), &response)
+	assert.NoError(t, err)
+	assert.Equal(t, ua, response.UserAgent)
+	assert.True(t, response.IsBot)
+	if assert.NotNil(t, response.Bot) {
+		assert.Equal(t, "Bingbot", response.Bot.Name)
+		assert.Equal(t, "crawler", response.Bot.Category)
+	}
+	assert.Equal(t, "bot", response.Device.Type)
+
+	// The caller's Client Hints do not describe a supplied string
+	assert.Nil(t, response.ClientHints)
+	assert.Empty(t, w.Header().Get("Accept-CH"))
+}
+
+func TestUserAgentPlainText(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	w := httptest.NewRecorder()
+	c, _ := gin.CreateTestContext(w)
+
+	ua := "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Mobile/15E148 Safari/604.1"
+	c.Request = httptest.NewRequest(http.MethodGet, "/api/ua?format=text&ua="+url.QueryEscape(ua), nil)
+
+	ParseUserAgent(c)
+
+	assert.Equal(t, "Safari 17 on iOS 17.4.1 (mobile)\n", w.Body.String())
+}
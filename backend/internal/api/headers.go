@@ -0,0 +1,13 @@
+package api
+
+import "github.com/gin-gonic/gin"
+
+// NoSniff sets X-Content-Type-Options: nosniff on every response, so
+// browsers use the declared Content-Type instead of guessing one from the
+// body; a JSON or text response can then never run as a script or style
+func NoSniff() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		c.Header("X-Content-Type-Options", "nosniff")
+		c.Next()
+	}
+}
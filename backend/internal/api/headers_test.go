@@ -0,0 +1,24 @@
+package api
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNoSniff(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(NoSniff())
+	r.GET("/api/ip", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+	r.NoRoute(func(c *gin.Context) { c.Status(http.StatusNotFound) })
+
+	for _, path := range []string{"/api/ip", "/missing"} {
+		w := httptest.NewRecorder()
+		r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, path, nil))
+		assert.Equal(t, "nosniff", w.Header().Get("X-Content-Type-Options"), path)
+	}
+}
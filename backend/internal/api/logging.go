@@ -0,0 +1,78 @@
+package api
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"runtime/debug"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lRoccoon/utils-helper/internal/api/handler"
+)
+
+// AccessLog logs every request to logger with its request ID, route
+// template, status, latency, client address, user agent and API key ID.
+// Server errors are logged at error level and client errors at warn level.
+// Successful requests to the quiet paths, such as health probes and metric
+// scrapes, are logged at debug level so they do not drown out real traffic.
+func AccessLog(logger *slog.Logger, quietPaths ...string) gin.HandlerFunc {
+	quiet := make(map[string]bool, len(quietPaths))
+	for _, p := range quietPaths {
+		quiet[p] = true
+	}
+
+	return func(c *gin.Context) {
+		start := time.Now()
+		c.Next()
+
+		status := c.Writer.Status()
+		level := slog.LevelInfo
+		switch {
+		case status >= http.StatusInternalServerError:
+			level = slog.LevelError
+		case status >= http.StatusBadRequest:
+			level = slog.LevelWarn
+		case quiet[c.Request.URL.Path]:
+			level = slog.LevelDebug
+		}
+		ctx := context.Background()
+		if !logger.Enabled(ctx, level) {
+			return
+		}
+
+		attrs := []slog.Attr{
+			slog.String("request_id", handler.GetRequestID(c)),
+			slog.String("method", c.Request.Method),
+			slog.String("path", c.Request.URL.Path),
+			slog.String("route", c.FullPath()),
+			slog.Int("status", status),
+			slog.Float64("latency_ms", float64(time.Since(start).Microseconds())/1000),
+			slog.Int("bytes", max(c.Writer.Size(), 0)),
+			slog.String("client_ip", handler.ClientIP(c)),
+			slog.String("user_agent", c.Request.UserAgent()),
+		}
+		if key, ok := handler.GetAPIKey(c); ok {
+			attrs = append(attrs, slog.String("api_key", key.ID))
+		}
+		if len(c.Errors) > 0 {
+			attrs = append(attrs, slog.String("error", c.Errors.String()))
+		}
+		logger.LogAttrs(ctx, level, "Request", attrs...)
+	}
+}
+
+// Recovery turns a panic in a handler into a 500 error response and logs
+// it with the request ID, instead of dropping the connection
+func Recovery(logger *slog.Logger) gin.HandlerFunc {
+	return gin.CustomRecoveryWithWriter(nil, func(c *gin.Context, err any) {
+		logger.Error("Handler panicked",
+			"request_id", handler.GetRequestID(c),
+			"method", c.Request.Method,
+			"path", c.Request.URL.Path,
+			"panic", err,
+			"stack", string(debug.Stack()),
+		)
+		c.AbortWithStatusJSON(http.StatusInternalServerError, handler.ErrorResponse(c, "Internal server error"))
+	})
+}
@@ -0,0 +1,107 @@
+package api
+
+import (
+	"bytes"
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lRoccoon/utils-helper/internal/api/handler"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func loggingRouter(buf *bytes.Buffer, level slog.Level) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	logger := slog.New(slog.NewJSONHandler(buf, &slog.HandlerOptions{Level: level}))
+	r := gin.New()
+	r.Use(handler.RequestID, AccessLog(logger, "/health"), Recovery(logger))
+	r.GET("/health", handler.Health)
+	r.GET("/api/holiday/:date", handler.GetHolidayByDate)
+	r.GET("/panic", func(c *gin.Context) { panic("boom") })
+	return r
+}
+
+func logLines(t *testing.T, buf *bytes.Buffer) []map[string]interface{} {
+	t.Helper()
+	var lines []map[string]interface{}
+	for _, line := range strings.Split(strings.TrimSpace(buf.String()), "\n") {
+		if line == "" {
+			continue
+		}
+		var entry map[string]interface{}
+		require.NoError(t, json.Unmarshal([]byte(line), &entry), line)
+		lines = append(lines, entry)
+	}
+	return lines
+}
+
+func TestAccessLog(t *testing.T) {
+	var buf bytes.Buffer
+	r := loggingRouter(&buf, slog.LevelInfo)
+
+	req := httptest.NewRequest(http.MethodGet, "/api/holiday/2024-10-01", nil)
+	req.Header.Set("X-Request-ID", "req-1")
+	req.Header.Set("User-Agent", "curl/8.0")
+	req.RemoteAddr = "198.51.100.7:4000"
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	req = httptest.NewRequest(http.MethodGet, "/api/holiday/bad", nil)
+	r.ServeHTTP(httptest.NewRecorder(), req)
+
+	// Probes are only logged at debug level
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	lines := logLines(t, &buf)
+	require.Len(t, lines, 2)
+
+	ok := lines[0]
+	assert.Equal(t, "INFO", ok["level"])
+	assert.Equal(t, "Request", ok["msg"])
+	assert.Equal(t, "req-1", ok["request_id"])
+	assert.Equal(t, "/api/holiday/:date", ok["route"])
+	assert.Equal(t, "/api/holiday/2024-10-01", ok["path"])
+	assert.Equal(t, float64(200), ok["status"])
+	assert.Equal(t, "198.51.100.7", ok["client_ip"])
+	assert.Equal(t, "curl/8.0", ok["user_agent"])
+	assert.Contains(t, ok, "latency_ms")
+
+	assert.Equal(t, "WARN", lines[1]["level"])
+	assert.Equal(t, float64(400), lines[1]["status"])
+	assert.NotEmpty(t, lines[1]["request_id"])
+}
+
+func TestAccessLogDebugLevel(t *testing.T) {
+	var buf bytes.Buffer
+	r := loggingRouter(&buf, slog.LevelDebug)
+	r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/health", nil))
+
+	lines := logLines(t, &buf)
+	require.Len(t, lines, 1)
+	assert.Equal(t, "DEBUG", lines[0]["level"])
+}
+
+func TestRecovery(t *testing.T) {
+	var buf bytes.Buffer
+	r := loggingRouter(&buf, slog.LevelInfo)
+
+	req := httptest.NewRequest(http.MethodGet, "/panic", nil)
+	req.Header.Set("X-Request-ID", "req-panic")
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusInternalServerError, w.Code)
+	assert.JSONEq(t, `{"error":"Internal server error","request_id":"req-panic"}`, w.Body.String())
+
+	lines := logLines(t, &buf)
+	require.Len(t, lines, 2)
+	assert.Equal(t, "Handler panicked", lines[0]["msg"])
+	assert.Equal(t, "boom", lines[0]["panic"])
+	assert.Equal(t, "req-panic", lines[0]["request_id"])
+	assert.Equal(t, "ERROR", lines[1]["level"])
+	assert.Equal(t, float64(500), lines[1]["status"])
+}
@@ -0,0 +1,168 @@
+package api
+
+import (
+	"math"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lRoccoon/utils-helper/internal/api/handler"
+	"github.com/lRoccoon/utils-helper/internal/ratelimit"
+)
+
+// RateLimitKey is the context key under which middleware earlier in the
+// chain, such as API key authentication, stores an identity to count
+// requests by instead of the client address
+const RateLimitKey = "rateLimitKey"
+
+// RateLimitOptions configure the RateLimit middleware
+type RateLimitOptions struct {
+	// Limit applies to requests under Paths outside all groups
+	Limit ratelimit.Limit
+	// Paths are the path prefixes limited by default, e.g. /api; other
+	// paths such as frontend files are not limited
+	Paths []string
+	// Groups have limits and buckets of their own
+	Groups []RateLimitGroup
+	// IdleTimeout is how long a client's bucket is kept after its last
+	// request
+	IdleTimeout time.Duration
+}
+
+// RateLimitGroup sets the limit for routes under a path prefix
+type RateLimitGroup struct {
+	Prefix string
+	Limit  ratelimit.Limit
+}
+
+// RateLimit returns a middleware that limits each client with a token
+// bucket per route group. Every limited response carries RateLimit-Limit,
+// RateLimit-Remaining, RateLimit-Reset and RateLimit-Policy headers;
+// rejected requests get 429 with Retry-After.
+func RateLimit(opts RateLimitOptions) gin.HandlerFunc {
+	type group struct {
+		prefix  string
+		limiter *ratelimit.Limiter
+		policy  string
+	}
+	compile := func(prefix string, limit ratelimit.Limit) group {
+		return group{strings.TrimSuffix(prefix, "/"), ratelimit.New(limit, opts.IdleTimeout), rateLimitPolicy(limit)}
+	}
+
+	def := compile("", opts.Limit)
+	groups := make([]group, 0, len(opts.Groups))
+	for _, g := range opts.Groups {
+		groups = append(groups, compile(g.Prefix, g.Limit))
+	}
+	paths := make([]string, 0, len(opts.Paths))
+	for _, p := range opts.Paths {
+		paths = append(paths, strings.TrimSuffix(p, "/"))
+	}
+
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		var g *group
+		longest := -1
+		for i := range groups {
+			if len(groups[i].prefix) > longest && underPrefix(path, groups[i].prefix) {
+				g, longest = &groups[i], len(groups[i].prefix)
+			}
+		}
+		if g == nil {
+			for _, p := range paths {
+				if underPrefix(path, p) {
+					g = &def
+					break
+				}
+			}
+		}
+		if g == nil || c.Request.Method == http.MethodOptions {
+			c.Next()
+			return
+		}
+
+		d := g.limiter.Allow(rateLimitClient(c))
+		h := c.Writer.Header()
+		h.Set("RateLimit-Limit", strconv.Itoa(d.Limit))
+		h.Set("RateLimit-Remaining", strconv.Itoa(d.Remaining))
+		h.Set("RateLimit-Reset", strconv.Itoa(ceilSeconds(d.Reset)))
+		h.Set("RateLimit-Policy", g.policy)
+		if !d.Allowed {
+			h.Set("Retry-After", strconv.Itoa(max(ceilSeconds(d.RetryAfter), 1)))
+			c.AbortWithStatusJSON(http.StatusTooManyRequests, handler.ErrorResponse(c, "Rate limit exceeded"))
+			return
+		}
+		c.Next()
+	}
+}
+
+// ConcurrencyLimit caps the requests in progress under the given path
+// prefixes, which share one pool of limit slots. Requests beyond it get 503
+// at once rather than queueing behind slow lookups.
+func ConcurrencyLimit(limit int, prefixes ...string) gin.HandlerFunc {
+	sem := ratelimit.NewSemaphore(limit)
+	return func(c *gin.Context) {
+		path := c.Request.URL.Path
+		limited := false
+		for _, p := range prefixes {
+			if underPrefix(path, strings.TrimSuffix(p, "/")) {
+				limited = true
+				break
+			}
+		}
+		if !limited {
+			c.Next()
+			return
+		}
+
+		if !sem.TryAcquire() {
+			c.Header("Retry-After", "1")
+			c.AbortWithStatusJSON(http.StatusServiceUnavailable, handler.ErrorResponse(c, "Server busy, retry shortly"))
+			return
+		}
+		defer sem.Release()
+		c.Next()
+	}
+}
+
+// rateLimitClient returns the bucket key of a request: the identity set
+// under RateLimitKey, or else the client address. That is the connection
+// peer unless it is a trusted proxy, so clients cannot pick new buckets
+// with forged headers. IPv6 clients are counted per /64, which a single
+// subscriber usually holds whole.
+func rateLimitClient(c *gin.Context) string {
+	if id := c.GetString(RateLimitKey); id != "" {
+		return "key:" + id
+	}
+	addr, err := netip.ParseAddr(handler.ClientIP(c))
+	if err != nil {
+		// A proxy forwarded something other than an address
+		addr, err = netip.ParseAddr(c.RemoteIP())
+	}
+	if err != nil {
+		return "ip:" + c.RemoteIP()
+	}
+	addr = addr.Unmap().WithZone("")
+	if addr.Is6() {
+		prefix, _ := addr.Prefix(64)
+		return "ip:" + prefix.String()
+	}
+	return "ip:" + addr.String()
+}
+
+// rateLimitPolicy formats a limit as a RateLimit-Policy value: the quota
+// and its window in seconds, plus the burst
+func rateLimitPolicy(l ratelimit.Limit) string {
+	policy := strconv.Itoa(l.Requests) + ";w=" + strconv.Itoa(ceilSeconds(l.Period))
+	if l.Burst > 0 {
+		policy += ";burst=" + strconv.Itoa(l.Burst)
+	}
+	return policy
+}
+
+func ceilSeconds(d time.Duration) int {
+	return int(math.Ceil(d.Seconds()))
+}
@@ -0,0 +1,163 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lRoccoon/utils-helper/internal/api/handler"
+	"github.com/lRoccoon/utils-helper/internal/ratelimit"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func rateLimitRouter(opts RateLimitOptions) *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(handler.RequestID)
+	r.Use(func(c *gin.Context) {
+		if key := c.GetHeader("X-Test-Key"); key != "" {
+			c.Set(RateLimitKey, key)
+		}
+	})
+	r.Use(RateLimit(opts))
+	ok := func(c *gin.Context) { c.String(http.StatusOK, "ok") }
+	r.GET("/api/holiday/:date", ok)
+	r.GET("/api/net/cidr/aggregate", ok)
+	r.GET("/", ok)
+	return r
+}
+
+func rateLimitRequest(r *gin.Engine, path, remoteAddr string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(http.MethodGet, path, nil)
+	req.RemoteAddr = remoteAddr
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestRateLimit(t *testing.T) {
+	r := rateLimitRouter(RateLimitOptions{
+		Limit:       ratelimit.Limit{Requests: 60, Period: time.Minute, Burst: 2},
+		Paths:       []string{"/api"},
+		IdleTimeout: time.Minute,
+		Groups: []RateLimitGroup{
+			{Prefix: "/api/net/cidr/aggregate", Limit: ratelimit.Limit{Requests: 1, Period: time.Minute}},
+		},
+	})
+	const client = "203.0.113.5:1000"
+
+	w := rateLimitRequest(r, "/api/holiday/2024-10-01", client, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "2", w.Header().Get("RateLimit-Limit"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "1", w.Header().Get("RateLimit-Reset"))
+	assert.Equal(t, "60;w=60;burst=2", w.Header().Get("RateLimit-Policy"))
+	assert.Empty(t, w.Header().Get("Retry-After"))
+
+	assert.Equal(t, http.StatusOK, rateLimitRequest(r, "/api/holiday/2024-10-02", client, nil).Code)
+	w = rateLimitRequest(r, "/api/holiday/2024-10-03", client, map[string]string{"X-Request-ID": "req-limited"})
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "0", w.Header().Get("RateLimit-Remaining"))
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+	var body map[string]string
+	require.NoError(t, json.Unmarshal(w.Body.Bytes(), &body))
+	assert.Equal(t, "Rate limit exceeded", body["error"])
+	assert.Equal(t, "req-limited", body["request_id"])
+
+	// The batch group has a stricter limit and a bucket of its own
+	w = rateLimitRequest(r, "/api/net/cidr/aggregate", client, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "1;w=60", w.Header().Get("RateLimit-Policy"))
+	w = rateLimitRequest(r, "/api/net/cidr/aggregate", client, nil)
+	assert.Equal(t, http.StatusTooManyRequests, w.Code)
+	assert.Equal(t, "60", w.Header().Get("Retry-After"))
+
+	// Paths outside the limited prefixes are not counted
+	w = rateLimitRequest(r, "/", client, nil)
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Empty(t, w.Header().Get("RateLimit-Limit"))
+
+	// Other clients and identified callers are counted separately
+	assert.Equal(t, http.StatusOK, rateLimitRequest(r, "/api/holiday/2024-10-01", "203.0.113.6:1000", nil).Code)
+	assert.Equal(t, http.StatusOK, rateLimitRequest(r, "/api/holiday/2024-10-01", client, map[string]string{"X-Test-Key": "k1"}).Code)
+}
+
+func TestRateLimitGroupsIPv6By64(t *testing.T) {
+	r := rateLimitRouter(RateLimitOptions{
+		Limit:       ratelimit.Limit{Requests: 1, Period: time.Minute},
+		Paths:       []string{"/api"},
+		IdleTimeout: time.Minute,
+	})
+
+	assert.Equal(t, http.StatusOK, rateLimitRequest(r, "/api/holiday/2024-10-01", "[2001:db8:1:2::1]:1000", nil).Code)
+	assert.Equal(t, http.StatusTooManyRequests, rateLimitRequest(r, "/api/holiday/2024-10-01", "[2001:db8:1:2::ffff]:1000", nil).Code)
+	assert.Equal(t, http.StatusOK, rateLimitRequest(r, "/api/holiday/2024-10-01", "[2001:db8:1:3::1]:1000", nil).Code)
+}
+
+func TestRateLimitIgnoresForgedHeaders(t *testing.T) {
+	r := rateLimitRouter(RateLimitOptions{
+		Limit:       ratelimit.Limit{Requests: 1, Period: time.Minute},
+		Paths:       []string{"/api"},
+		IdleTimeout: time.Minute,
+	})
+	handler.SetTrustedProxies([]netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})
+	defer handler.SetTrustedProxies(nil)
+
+	// A new X-Forwarded-For on each request does not buy a new bucket
+	assert.Equal(t, http.StatusOK, rateLimitRequest(r, "/api/holiday/2024-10-01", "203.0.113.5:1000", map[string]string{"X-Forwarded-For": "198.51.100.1"}).Code)
+	assert.Equal(t, http.StatusTooManyRequests, rateLimitRequest(r, "/api/holiday/2024-10-01", "203.0.113.5:1000", map[string]string{"X-Forwarded-For": "198.51.100.2"}).Code)
+
+	// Behind a trusted proxy clients are told apart by the forwarded address
+	proxy := "10.0.0.2:1000"
+	assert.Equal(t, http.StatusOK, rateLimitRequest(r, "/api/holiday/2024-10-01", proxy, map[string]string{"X-Forwarded-For": "198.51.100.1"}).Code)
+	assert.Equal(t, http.StatusOK, rateLimitRequest(r, "/api/holiday/2024-10-01", proxy, map[string]string{"X-Forwarded-For": "198.51.100.2"}).Code)
+	assert.Equal(t, http.StatusTooManyRequests, rateLimitRequest(r, "/api/holiday/2024-10-01", proxy, map[string]string{"X-Forwarded-For": "198.51.100.2"}).Code)
+
+	// Values that are no address fall back to the proxy's own bucket
+	assert.Equal(t, http.StatusOK, rateLimitRequest(r, "/api/holiday/2024-10-01", proxy, map[string]string{"X-Forwarded-For": "garbage-1"}).Code)
+	assert.Equal(t, http.StatusTooManyRequests, rateLimitRequest(r, "/api/holiday/2024-10-01", proxy, map[string]string{"X-Forwarded-For": "garbage-2"}).Code)
+}
+
+func TestConcurrencyLimit(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(ConcurrencyLimit(1, "/api/dns"))
+
+	started, release := make(chan struct{}), make(chan struct{})
+	var once sync.Once
+	r.GET("/api/dns", func(c *gin.Context) {
+		once.Do(func() { close(started) })
+		<-release
+		c.String(http.StatusOK, "ok")
+	})
+	r.GET("/api/holiday", func(c *gin.Context) { c.String(http.StatusOK, "ok") })
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		rateLimitRequest(r, "/api/dns", "192.0.2.1:1", nil)
+	}()
+	<-started
+
+	w := rateLimitRequest(r, "/api/dns", "192.0.2.2:1", nil)
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+	assert.Equal(t, "1", w.Header().Get("Retry-After"))
+
+	// Routes outside the capped prefixes are not affected
+	assert.Equal(t, http.StatusOK, rateLimitRequest(r, "/api/holiday", "192.0.2.2:1", nil).Code)
+
+	close(release)
+	wg.Wait()
+	// The slot is free again once the slow request has finished
+	assert.Equal(t, http.StatusOK, rateLimitRequest(r, "/api/dns", "192.0.2.2:1", nil).Code)
+}
@@ -3,22 +3,95 @@ package api
 import (
 	"github.com/gin-gonic/gin"
 	"github.com/lRoccoon/utils-helper/internal/api/handler"
+	"github.com/lRoccoon/utils-helper/internal/service"
 )
 
-// RegisterRoutes registers all API routes
-func RegisterRoutes(r *gin.Engine) {
+// RegisterRoutes registers the API routes of the given modules, or of every
+// module when none are given
+func RegisterRoutes(r *gin.Engine, modules ...string) {
+	enabled := func(name string) bool {
+		if len(modules) == 0 {
+			return true
+		}
+		for _, m := range modules {
+			if m == name {
+				return true
+			}
+		}
+		return false
+	}
+
 	api := r.Group("/api")
 	{
+		// Modules are readable anonymously unless locked down, in which case
+		// their routes need a key with the read:<module> scope
+		module := func(name string) *gin.RouterGroup {
+			return api.Group("", handler.RequireRead(name))
+		}
+
 		// IP address routes
-		api.GET("/ip", handler.GetIPInfo)
+		if enabled("ip") {
+			ip := module("ip")
+			ip.GET("/ip", handler.GetIPInfo)
+			ip.GET("/ip/geo/stats", handler.GetGeoStats)
+			ip.GET("/ip/meta", handler.GetGeoMeta)
+			ip.GET("/ip/match", handler.MatchIP)
+			// The rule sets back the access control, so reading them needs
+			// its own scope and changing them an API key with write:ip
+			sets := api.Group("/ip/sets", handler.RequireScope("read:ipsets"))
+			sets.GET("", handler.ListIPSets)
+			sets.GET("/:name", handler.GetIPSet)
+			api.PUT("/ip/sets/:name", handler.PutIPSet)
+			api.DELETE("/ip/sets/:name", handler.DeleteIPSet)
+		}
+		if enabled("request") {
+			module("request").Any("/request", handler.EchoRequest)
+		}
+		if enabled("ua") {
+			module("ua").GET("/ua", handler.ParseUserAgent)
+		}
+		if enabled("dns") {
+			module("dns").GET("/dns", handler.LookupDNS)
+		}
+		if enabled("mac") {
+			module("mac").GET("/mac/:address", handler.LookupMAC)
+		}
+		if enabled("ports") {
+			ports := module("ports")
+			ports.GET("/ports", handler.SearchPorts)
+			ports.GET("/ports/:port", handler.GetPort)
+			ports.GET("/ports/:port/:protocol", handler.GetPort)
+		}
+
+		// Network calculator routes
+		if enabled("net") {
+			net := module("net")
+			net.GET("/net/cidr", handler.GetCIDRInfo)
+			net.GET("/net/cidr/split", handler.SplitCIDR)
+			net.GET("/net/cidr/contains", handler.CheckCIDRContains)
+			net.GET("/net/cidr/aggregate", handler.AggregateCIDRs)
+			net.POST("/net/cidr/aggregate", handler.AggregateCIDRs)
+			net.GET("/net/convert", handler.ConvertIP)
+		}
 
 		// Holiday routes
-		api.GET("/holiday", handler.GetHolidayInfo)
-		api.GET("/holiday/:date", handler.GetHolidayByDate)
+		if enabled("holiday") {
+			holiday := module("holiday")
+			holiday.GET("/holiday", handler.GetHolidayInfo)
+			holiday.GET("/holiday/:date", handler.GetHolidayByDate)
+		}
+
+		// API key usage, for administrators
+		api.GET("/auth/keys", handler.RequireScope(service.ScopeAdmin), handler.ListAPIKeys)
 	}
 
-	// Health check
-	r.GET("/health", func(c *gin.Context) {
-		c.JSON(200, gin.H{"status": "ok"})
-	})
+	// Liveness and readiness checks
+	r.GET("/health", handler.Health)
+	r.GET("/ready", handler.Ready)
+
+	// Plain-text /ip alias for curl and wget. It is a middleware rather than a
+	// route so browser navigations still fall through to the frontend page.
+	if enabled("ip") {
+		r.Use(handler.IPAlias)
+	}
 }
@@ -6,6 +6,7 @@ import (
 	"testing"
 
 	"github.com/gin-gonic/gin"
+	"github.com/lRoccoon/utils-helper/internal/api/handler"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -33,6 +34,84 @@ func TestRegisterRoutes(t *testing.T) {
 			path:           "/api/ip",
 			expectedStatus: http.StatusOK,
 		},
+		{
+			name:           "Geo stats endpoint exists",
+			method:         http.MethodGet,
+			path:           "/api/ip/geo/stats",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Geo database metadata endpoint exists",
+			method:         http.MethodGet,
+			path:           "/api/ip/meta",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "MAC lookup endpoint exists",
+			method:         http.MethodGet,
+			path:           "/api/mac/00:1b:63:84:45:e6",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Port lookup endpoint exists",
+			method:         http.MethodGet,
+			path:           "/api/ports/8443/udp",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "Port search endpoint exists",
+			method:         http.MethodGet,
+			path:           "/api/ports?q=ssh",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "IP match endpoint exists",
+			method:         http.MethodGet,
+			path:           "/api/ip/match?ip=192.0.2.1",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "IP rule sets endpoint needs a key",
+			method:         http.MethodGet,
+			path:           "/api/ip/sets",
+			expectedStatus: http.StatusUnauthorized,
+		},
+		{
+			name:           "Request echo endpoint exists",
+			method:         http.MethodPost,
+			path:           "/api/request",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "User-Agent endpoint exists",
+			method:         http.MethodGet,
+			path:           "/api/ua",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "DNS endpoint exists",
+			method:         http.MethodGet,
+			path:           "/api/dns",
+			expectedStatus: http.StatusBadRequest,
+		},
+		{
+			name:           "CIDR calculator endpoint exists",
+			method:         http.MethodGet,
+			path:           "/api/net/cidr?prefix=10.0.0.0/22",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "CIDR aggregate endpoint exists",
+			method:         http.MethodGet,
+			path:           "/api/net/cidr/aggregate?prefixes=10.0.0.0/25,10.0.0.128/25",
+			expectedStatus: http.StatusOK,
+		},
+		{
+			name:           "IP converter endpoint exists",
+			method:         http.MethodGet,
+			path:           "/api/net/convert?ip=10.0.0.1",
+			expectedStatus: http.StatusOK,
+		},
 		{
 			name:           "Holiday info endpoint exists",
 			method:         http.MethodGet,
@@ -71,3 +150,69 @@ func TestHealthCheck(t *testing.T) {
 	assert.Equal(t, http.StatusOK, w.Code)
 	assert.Contains(t, w.Body.String(), "ok")
 }
+
+func TestReadinessCheck(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	RegisterRoutes(r)
+
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+
+	handler.SetReady(false)
+	defer handler.SetReady(true)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/ready", nil))
+	assert.Equal(t, http.StatusServiceUnavailable, w.Code)
+
+	w = httptest.NewRecorder()
+	r.ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/health", nil))
+	assert.Equal(t, http.StatusOK, w.Code, "liveness must keep passing while draining")
+}
+
+func TestIPAliasRoute(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	RegisterRoutes(r)
+
+	w := httptest.NewRecorder()
+	req := httptest.NewRequest(http.MethodGet, "/ip", nil)
+	req.Header.Set("User-Agent", "curl/8.5.0")
+	// Forwarding headers from an untrusted peer are ignored
+	req.Header.Set("X-Forwarded-For", "203.0.113.1")
+	r.ServeHTTP(w, req)
+
+	assert.Equal(t, http.StatusOK, w.Code)
+	assert.Equal(t, "192.0.2.1\n", w.Body.String())
+}
+
+func TestRegisterRoutesModules(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+
+	r := gin.New()
+	RegisterRoutes(r, "net", "holiday")
+
+	tests := []struct {
+		path           string
+		expectedStatus int
+	}{
+		{"/api/net/cidr?prefix=192.0.2.0/24", http.StatusOK},
+		{"/api/holiday?date=2024-01-01", http.StatusOK},
+		{"/api/ip", http.StatusNotFound},
+		{"/api/ports/443", http.StatusNotFound},
+		{"/health", http.StatusOK},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			assert.Equal(t, tt.expectedStatus, w.Code)
+		})
+	}
+}
@@ -0,0 +1,154 @@
+// Package compress negotiates content codings and compresses responses with
+// gzip, brotli and zstd.
+package compress
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"mime"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+// Content codings
+const (
+	Brotli = "br"
+	Gzip   = "gzip"
+	Zstd   = "zstd"
+)
+
+// Encodings are the supported content codings
+var Encodings = []string{Brotli, Gzip, Zstd}
+
+// Negotiate picks the offered coding the client prefers according to an
+// Accept-Encoding header. Offers are in server preference order, which
+// breaks ties between equal q-values. It returns "" when the response should
+// not be encoded.
+func Negotiate(acceptEncoding string, offered []string) string {
+	if acceptEncoding == "" {
+		return ""
+	}
+
+	weights := make(map[string]float64)
+	wildcard := -1.0
+	for _, part := range strings.Split(acceptEncoding, ",") {
+		coding, params, _ := strings.Cut(part, ";")
+		coding = strings.ToLower(strings.TrimSpace(coding))
+		q := 1.0
+		for _, p := range strings.Split(params, ";") {
+			if name, value, ok := strings.Cut(strings.TrimSpace(p), "="); ok && strings.EqualFold(name, "q") {
+				if v, err := strconv.ParseFloat(value, 64); err == nil {
+					q = v
+				}
+			}
+		}
+		if coding == "*" {
+			wildcard = q
+		} else {
+			weights[coding] = q
+		}
+	}
+
+	best, bestQ := "", 0.0
+	for _, coding := range offered {
+		q, ok := weights[coding]
+		if !ok {
+			q = wildcard
+		}
+		if q > bestQ {
+			best, bestQ = coding, q
+		}
+	}
+	return best
+}
+
+// Compressible reports whether a media type benefits from compression.
+// Images, fonts other than TTF/OTF, archives and video already are.
+func Compressible(contentType string) bool {
+	mediaType, _, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return false
+	}
+	if strings.HasPrefix(mediaType, "text/") ||
+		strings.HasSuffix(mediaType, "+json") || strings.HasSuffix(mediaType, "+xml") {
+		return true
+	}
+	switch mediaType {
+	case "application/json", "application/javascript", "application/xml", "application/yaml",
+		"application/x-yaml", "application/x-ndjson", "application/wasm", "application/manifest+json",
+		"image/svg+xml", "image/x-icon", "image/vnd.microsoft.icon", "font/ttf", "font/otf":
+		return true
+	}
+	return false
+}
+
+// Bytes compresses data with the highest level of a coding, for content
+// compressed once and served many times
+func Bytes(coding string, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	var w io.WriteCloser
+	switch coding {
+	case Brotli:
+		w = brotli.NewWriterLevel(&buf, brotli.BestCompression)
+	case Gzip:
+		w, _ = gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	case Zstd:
+		enc, err := zstd.NewWriter(&buf, zstd.WithEncoderLevel(zstd.SpeedBestCompression), zstd.WithEncoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		w = enc
+	default:
+		return nil, fmt.Errorf("unsupported content coding %q", coding)
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// encoder is a streaming compressor that can be reused with Reset
+type encoder interface {
+	io.WriteCloser
+	Flush() error
+	Reset(w io.Writer)
+}
+
+// Streaming encoders are pooled; a fast level suits responses compressed
+// on every request
+var encoderPools = map[string]*sync.Pool{
+	Gzip: {New: func() interface{} {
+		w, _ := gzip.NewWriterLevel(io.Discard, gzip.DefaultCompression)
+		return w
+	}},
+	Zstd: {New: func() interface{} {
+		enc, _ := zstd.NewWriter(io.Discard, zstd.WithEncoderLevel(zstd.SpeedDefault),
+			zstd.WithEncoderConcurrency(1), zstd.WithWindowSize(1<<20))
+		return enc
+	}},
+	Brotli: {New: func() interface{} {
+		return brotli.NewWriterLevel(io.Discard, 4)
+	}},
+}
+
+// getEncoder returns a pooled encoder writing to w
+func getEncoder(coding string, w io.Writer) encoder {
+	enc := encoderPools[coding].Get().(encoder)
+	enc.Reset(w)
+	return enc
+}
+
+// putEncoder returns an encoder to its pool
+func putEncoder(coding string, enc encoder) {
+	enc.Reset(io.Discard)
+	encoderPools[coding].Put(enc)
+}
@@ -0,0 +1,115 @@
+package compress
+
+import (
+	"bytes"
+	"io"
+	"testing"
+
+	"github.com/andybalholm/brotli"
+	"github.com/klauspost/compress/gzip"
+	"github.com/klauspost/compress/zstd"
+)
+
+func TestNegotiate(t *testing.T) {
+	offered := []string{Brotli, Gzip, Zstd}
+	tests := []struct {
+		accept string
+		want   string
+	}{
+		{"", ""},
+		{"identity", ""},
+		{"gzip", Gzip},
+		{"gzip, br", Brotli},
+		{"GZIP;q=0.5, zstd", Zstd},
+		{"br;q=0, gzip", Gzip},
+		{"br;q=0.8, gzip;q=0.8", Brotli},
+		{"*", Brotli},
+		{"*;q=0.5, gzip", Gzip},
+		{"*;q=0", ""},
+		{"deflate", ""},
+		{"gzip;q=invalid", Gzip},
+	}
+	for _, tt := range tests {
+		if got := Negotiate(tt.accept, offered); got != tt.want {
+			t.Errorf("Negotiate(%q) = %q, want %q", tt.accept, got, tt.want)
+		}
+	}
+
+	// The server order breaks ties
+	if got := Negotiate("gzip, zstd", []string{Zstd, Gzip}); got != Zstd {
+		t.Errorf("Negotiate with zstd first = %q, want zstd", got)
+	}
+}
+
+func TestCompressible(t *testing.T) {
+	tests := map[string]bool{
+		"application/json; charset=utf-8": true,
+		"text/html; charset=utf-8":        true,
+		"text/javascript":                 true,
+		"application/problem+json":        true,
+		"image/svg+xml":                   true,
+		"application/wasm":                true,
+		"image/png":                       false,
+		"font/woff2":                      false,
+		"application/octet-stream":        false,
+		"application/gzip":                false,
+		"":                                false,
+	}
+	for contentType, want := range tests {
+		if got := Compressible(contentType); got != want {
+			t.Errorf("Compressible(%q) = %v, want %v", contentType, got, want)
+		}
+	}
+}
+
+func TestBytesRoundTrip(t *testing.T) {
+	data := bytes.Repeat([]byte("utils-helper "), 200)
+	for _, coding := range Encodings {
+		t.Run(coding, func(t *testing.T) {
+			compressed, err := Bytes(coding, data)
+			if err != nil {
+				t.Fatalf("Bytes: %v", err)
+			}
+			if len(compressed) >= len(data) {
+				t.Errorf("compressed %d bytes into %d", len(data), len(compressed))
+			}
+			if got := decode(t, coding, compressed); !bytes.Equal(got, data) {
+				t.Error("round trip changed the data")
+			}
+		})
+	}
+
+	if _, err := Bytes("deflate", data); err == nil {
+		t.Error("expected an error for an unsupported coding")
+	}
+}
+
+// decode decompresses data in the given coding
+func decode(t *testing.T, coding string, data []byte) []byte {
+	t.Helper()
+	var r io.Reader
+	switch coding {
+	case Brotli:
+		r = brotli.NewReader(bytes.NewReader(data))
+	case Gzip:
+		gr, err := gzip.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("gzip: %v", err)
+		}
+		r = gr
+	case Zstd:
+		zr, err := zstd.NewReader(bytes.NewReader(data))
+		if err != nil {
+			t.Fatalf("zstd: %v", err)
+		}
+		defer zr.Close()
+		r = zr
+	default:
+		return data
+	}
+	out, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("decode %s: %v", coding, err)
+	}
+	return out
+}
@@ -0,0 +1,164 @@
+package compress
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// Middleware defaults
+const (
+	DefaultMinLength = 1024
+)
+
+// DefaultMiddlewareEncodings are the codings compressed on the fly, in
+// server preference order. Brotli compresses better but is too slow at useful
+// levels to run on every response.
+var DefaultMiddlewareEncodings = []string{Zstd, Gzip}
+
+// Options configure the compression middleware
+type Options struct {
+	// Encodings are the offered codings in preference order
+	Encodings []string
+	// MinLength is the smallest body compressed; smaller bodies gain little
+	// and cost a buffer and an encoder
+	MinLength int
+}
+
+// Middleware compresses responses with the coding the client prefers. The
+// body is buffered until MinLength bytes are written or the handler returns,
+// so the decision can depend on the size; after that it streams through a
+// pooled encoder. Responses that already have a Content-Encoding, partial
+// content, empty statuses, HEAD requests and media types that do not
+// compress are passed through unchanged.
+func Middleware(opts Options) gin.HandlerFunc {
+	if len(opts.Encodings) == 0 {
+		opts.Encodings = DefaultMiddlewareEncodings
+	}
+	if opts.MinLength <= 0 {
+		opts.MinLength = DefaultMinLength
+	}
+
+	return func(c *gin.Context) {
+		if c.Request.Method == http.MethodHead {
+			c.Next()
+			return
+		}
+
+		w := &compressWriter{
+			ResponseWriter: c.Writer,
+			coding:         Negotiate(c.GetHeader("Accept-Encoding"), opts.Encodings),
+			minLength:      opts.MinLength,
+		}
+		c.Writer = w
+		c.Next()
+		w.close()
+		c.Writer = w.ResponseWriter
+	}
+}
+
+// compressWriter buffers the start of a response until it can decide whether
+// to compress, then writes through an encoder or directly
+type compressWriter struct {
+	gin.ResponseWriter
+	coding    string
+	minLength int
+
+	buf     []byte
+	decided bool
+	enc     encoder
+}
+
+func (w *compressWriter) Write(p []byte) (int, error) {
+	if !w.decided {
+		w.buf = append(w.buf, p...)
+		if len(w.buf) < w.minLength {
+			return len(p), nil
+		}
+		if err := w.decide(true); err != nil {
+			return 0, err
+		}
+		return len(p), nil
+	}
+	if w.enc != nil {
+		return w.enc.Write(p)
+	}
+	return w.ResponseWriter.Write(p)
+}
+
+func (w *compressWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// Flush sends what is buffered; a handler that flushes is streaming, so the
+// response is compressed regardless of its size so far
+func (w *compressWriter) Flush() {
+	if !w.decided {
+		if err := w.decide(true); err != nil {
+			return
+		}
+	}
+	if w.enc != nil {
+		_ = w.enc.Flush()
+	}
+	w.ResponseWriter.Flush()
+}
+
+// decide sets the headers for the response and writes out the buffer. large
+// reports whether the body is big enough to be worth compressing.
+func (w *compressWriter) decide(large bool) error {
+	w.decided = true
+	h := w.Header()
+
+	if w.compressible() {
+		h.Add("Vary", "Accept-Encoding")
+		if w.coding != "" && large {
+			h.Set("Content-Encoding", w.coding)
+			h.Del("Content-Length")
+			// The compressed body is a different representation, so a strong
+			// validator of the identity body no longer holds
+			if etag := h.Get("ETag"); strings.HasPrefix(etag, `"`) {
+				h.Set("ETag", "W/"+etag)
+			}
+			w.enc = getEncoder(w.coding, w.ResponseWriter)
+		}
+	}
+
+	buf := w.buf
+	w.buf = nil
+	if len(buf) == 0 {
+		return nil
+	}
+	var err error
+	if w.enc != nil {
+		_, err = w.enc.Write(buf)
+	} else {
+		_, err = w.ResponseWriter.Write(buf)
+	}
+	return err
+}
+
+// compressible reports whether the response may be compressed at all
+func (w *compressWriter) compressible() bool {
+	h := w.Header()
+	switch w.Status() {
+	case http.StatusNoContent, http.StatusNotModified, http.StatusPartialContent:
+		return false
+	}
+	return h.Get("Content-Encoding") == "" && h.Get("Content-Range") == "" &&
+		Compressible(h.Get("Content-Type"))
+}
+
+// close writes out a response shorter than the minimum length and finishes
+// the compressed stream
+func (w *compressWriter) close() {
+	if !w.decided && len(w.buf) > 0 {
+		_ = w.decide(false)
+	}
+	if w.enc != nil {
+		_ = w.enc.Close()
+		putEncoder(w.coding, w.enc)
+		w.enc = nil
+	}
+}
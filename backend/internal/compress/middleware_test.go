@@ -0,0 +1,107 @@
+package compress
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/stretchr/testify/assert"
+)
+
+var largeJSON = `{"data":"` + strings.Repeat("a", 4096) + `"}`
+
+func compressRouter() *gin.Engine {
+	gin.SetMode(gin.TestMode)
+	r := gin.New()
+	r.Use(Middleware(Options{}))
+	r.GET("/large", func(c *gin.Context) {
+		c.Header("ETag", `"v1"`)
+		c.Data(http.StatusOK, "application/json; charset=utf-8", []byte(largeJSON))
+	})
+	r.GET("/small", func(c *gin.Context) {
+		c.JSON(http.StatusOK, gin.H{"ok": true})
+	})
+	r.GET("/image", func(c *gin.Context) {
+		c.Data(http.StatusOK, "image/png", make([]byte, 4096))
+	})
+	r.GET("/encoded", func(c *gin.Context) {
+		c.Header("Content-Encoding", Gzip)
+		c.Data(http.StatusOK, "text/plain", make([]byte, 4096))
+	})
+	r.GET("/stream", func(c *gin.Context) {
+		c.Header("Content-Type", "text/event-stream")
+		for i := 0; i < 3; i++ {
+			c.Writer.WriteString("data: tick\n\n")
+			c.Writer.Flush()
+		}
+	})
+	return r
+}
+
+func compressRequest(r *gin.Engine, method, path, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	if accept != "" {
+		req.Header.Set("Accept-Encoding", accept)
+	}
+	w := httptest.NewRecorder()
+	r.ServeHTTP(w, req)
+	return w
+}
+
+func TestMiddlewareCompresses(t *testing.T) {
+	r := compressRouter()
+
+	for _, coding := range []string{Gzip, Zstd} {
+		t.Run(coding, func(t *testing.T) {
+			w := compressRequest(r, http.MethodGet, "/large", coding)
+			assert.Equal(t, http.StatusOK, w.Code)
+			assert.Equal(t, coding, w.Header().Get("Content-Encoding"))
+			assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+			assert.Equal(t, `W/"v1"`, w.Header().Get("ETag"))
+			assert.Empty(t, w.Header().Get("Content-Length"))
+			assert.Less(t, w.Body.Len(), len(largeJSON))
+			assert.Equal(t, largeJSON, string(decode(t, coding, w.Body.Bytes())))
+		})
+	}
+
+	// zstd is preferred when the client accepts both equally
+	w := compressRequest(r, http.MethodGet, "/large", "gzip, deflate, br, zstd")
+	assert.Equal(t, Zstd, w.Header().Get("Content-Encoding"))
+}
+
+func TestMiddlewarePassesThrough(t *testing.T) {
+	r := compressRouter()
+
+	w := compressRequest(r, http.MethodGet, "/large", "")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	assert.Equal(t, largeJSON, w.Body.String())
+
+	w = compressRequest(r, http.MethodGet, "/small", "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, "Accept-Encoding", w.Header().Get("Vary"))
+	assert.JSONEq(t, `{"ok":true}`, w.Body.String())
+
+	w = compressRequest(r, http.MethodGet, "/image", "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+	assert.Empty(t, w.Header().Get("Vary"))
+	assert.Equal(t, 4096, w.Body.Len())
+
+	w = compressRequest(r, http.MethodGet, "/encoded", "zstd")
+	assert.Equal(t, Gzip, w.Header().Get("Content-Encoding"))
+	assert.Equal(t, 4096, w.Body.Len())
+
+	w = compressRequest(r, http.MethodHead, "/large", "gzip")
+	assert.Empty(t, w.Header().Get("Content-Encoding"))
+}
+
+func TestMiddlewareStreams(t *testing.T) {
+	r := compressRouter()
+
+	w := compressRequest(r, http.MethodGet, "/stream", "gzip")
+	assert.Equal(t, Gzip, w.Header().Get("Content-Encoding"))
+	assert.True(t, w.Flushed)
+	assert.Equal(t, strings.Repeat("data: tick\n\n", 3), string(decode(t, Gzip, w.Body.Bytes())))
+}
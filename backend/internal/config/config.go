@@ -0,0 +1,659 @@
+// Package config loads the server configuration from a YAML or TOML file,
+// environment variables and command-line flags.
+package config
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Modules are the features that can be enabled, all of them by default
+var Modules = []string{"ip", "request", "ua", "dns", "mac", "ports", "net", "holiday", "frontend"}
+
+// RedactHeaders are the headers hidden by /api/request unless configured
+// otherwise
+var RedactHeaders = []string{"Authorization", "Proxy-Authorization", "Cookie", "X-API-Key", "X-Auth-Token"}
+
+// Config is the server configuration. Values are applied in order of
+// precedence: built-in defaults, the config file, environment variables,
+// then command-line flags. Fields with an env tag read that variable; every
+// field can be set with a flag named after its file key, e.g. -server.listen.
+type Config struct {
+	Server      Server      `yaml:"server" toml:"server"`
+	TLS         TLS         `yaml:"tls" toml:"tls"`
+	CORS        CORS        `yaml:"cors" toml:"cors"`
+	Log         Log         `yaml:"log" toml:"log"`
+	Compression Compression `yaml:"compression" toml:"compression"`
+	Frontend    Frontend    `yaml:"frontend" toml:"frontend"`
+	Metrics     Metrics     `yaml:"metrics" toml:"metrics"`
+	RateLimit   RateLimit   `yaml:"rate_limit" toml:"rate_limit"`
+	Auth        Auth        `yaml:"auth" toml:"auth"`
+	Modules     []string    `yaml:"modules" toml:"modules" env:"MODULES" help:"enabled modules"`
+	Data        Data        `yaml:"data" toml:"data"`
+	GeoIP       GeoIP       `yaml:"geoip" toml:"geoip"`
+	DNS         DNS         `yaml:"dns" toml:"dns"`
+	IPSets      IPSets      `yaml:"ipsets" toml:"ipsets"`
+	Echo        Echo        `yaml:"echo" toml:"echo"`
+}
+
+// Server configures the listener and request handling
+type Server struct {
+	Listen         string   `yaml:"listen" toml:"listen" env:"LISTEN_ADDR" help:"address to listen on"`
+	Mode           string   `yaml:"mode" toml:"mode" env:"GIN_MODE" help:"gin mode: debug, release or test"`
+	TrustedProxies []string `yaml:"trusted_proxies" toml:"trusted_proxies" env:"TRUSTED_PROXIES" help:"proxy addresses or CIDRs whose forwarding headers are trusted"`
+
+	ReadHeaderTimeout Duration `yaml:"read_header_timeout" toml:"read_header_timeout" env:"HTTP_READ_HEADER_TIMEOUT" help:"time to read request headers"`
+	ReadTimeout       Duration `yaml:"read_timeout" toml:"read_timeout" env:"HTTP_READ_TIMEOUT" help:"time to read the whole request, 0 for none"`
+	WriteTimeout      Duration `yaml:"write_timeout" toml:"write_timeout" env:"HTTP_WRITE_TIMEOUT" help:"time to write the response, 0 for none"`
+	IdleTimeout       Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"HTTP_IDLE_TIMEOUT" help:"how long idle keep-alive connections stay open"`
+	MaxHeaderBytes    int      `yaml:"max_header_bytes" toml:"max_header_bytes" env:"HTTP_MAX_HEADER_BYTES" help:"maximum size of request headers"`
+	H2C               bool     `yaml:"h2c" toml:"h2c" env:"HTTP_H2C" help:"accept HTTP/2 without TLS from trusted proxies"`
+
+	// On SIGINT or SIGTERM /ready fails for ShutdownDelay so load balancers
+	// take the instance out, then in-flight requests get ShutdownTimeout
+	ShutdownDelay   Duration `yaml:"shutdown_delay" toml:"shutdown_delay" env:"SHUTDOWN_DELAY" help:"time between failing readiness and closing the listener"`
+	ShutdownTimeout Duration `yaml:"shutdown_timeout" toml:"shutdown_timeout" env:"SHUTDOWN_TIMEOUT" help:"time in-flight requests get to finish"`
+}
+
+// TLS configures the HTTPS listener. It is enabled by setting listen; the
+// plain listener on server.listen keeps serving alongside, or redirects to
+// HTTPS with redirect_http.
+type TLS struct {
+	Listen         string   `yaml:"listen" toml:"listen" env:"TLS_LISTEN" help:"HTTPS address, empty to serve plain HTTP only"`
+	CertFile       string   `yaml:"cert_file" toml:"cert_file" env:"TLS_CERT_FILE" help:"certificate chain in PEM"`
+	KeyFile        string   `yaml:"key_file" toml:"key_file" env:"TLS_KEY_FILE" help:"private key in PEM"`
+	ReloadInterval Duration `yaml:"reload_interval" toml:"reload_interval" env:"TLS_RELOAD_INTERVAL" help:"how often the certificate files are checked for changes"`
+	HTTP2          bool     `yaml:"http2" toml:"http2" env:"TLS_HTTP2" help:"offer HTTP/2 over TLS"`
+	MinVersion     string   `yaml:"min_version" toml:"min_version" env:"TLS_MIN_VERSION" help:"minimum TLS version: 1.2 or 1.3"`
+	CipherSuites   []string `yaml:"cipher_suites" toml:"cipher_suites" env:"TLS_CIPHER_SUITES" help:"TLS 1.2 cipher suites, empty for Go's defaults"`
+
+	// A client CA bundle turns on mutual TLS
+	ClientCAFile string `yaml:"client_ca_file" toml:"client_ca_file" env:"TLS_CLIENT_CA_FILE" help:"CA bundle verifying client certificates, empty to not ask for them"`
+	ClientAuth   string `yaml:"client_auth" toml:"client_auth" env:"TLS_CLIENT_AUTH" help:"with a client CA bundle: require or verify_if_given"`
+
+	RedirectHTTP bool `yaml:"redirect_http" toml:"redirect_http" env:"TLS_REDIRECT_HTTP" help:"redirect requests on server.listen to HTTPS, except health checks"`
+	RedirectPort int  `yaml:"redirect_port" toml:"redirect_port" env:"TLS_REDIRECT_PORT" help:"public HTTPS port redirects point to, 0 for the port of tls.listen"`
+}
+
+// Enabled reports whether the HTTPS listener is configured
+func (t TLS) Enabled() bool {
+	return t.Listen != ""
+}
+
+// CORS configures cross-origin requests to the API
+type CORS struct {
+	AllowOrigins     []string `yaml:"allow_origins" toml:"allow_origins" env:"CORS_ALLOW_ORIGINS" help:"allowed origins: exact, https://*.example.com for subdomains, or * for any"`
+	AllowCredentials bool     `yaml:"allow_credentials" toml:"allow_credentials" env:"CORS_ALLOW_CREDENTIALS" help:"allow cookies and authorization headers, requires listed origins"`
+	AllowMethods     []string `yaml:"allow_methods" toml:"allow_methods" env:"CORS_ALLOW_METHODS" help:"allowed methods"`
+	AllowHeaders     []string `yaml:"allow_headers" toml:"allow_headers" env:"CORS_ALLOW_HEADERS" help:"allowed request headers, * for any"`
+	ExposeHeaders    []string `yaml:"expose_headers" toml:"expose_headers" env:"CORS_EXPOSE_HEADERS" help:"response headers readable by scripts"`
+	MaxAge           Duration `yaml:"max_age" toml:"max_age" env:"CORS_MAX_AGE" help:"how long browsers may cache preflight responses"`
+
+	// Groups override the policy for routes under a path prefix such as
+	// /api/ip/sets. They can only be set in the config file.
+	Groups map[string]CORSGroup `yaml:"groups" toml:"groups"`
+}
+
+// CORSGroup overrides the CORS policy of a route group; keys left out keep
+// the top-level values
+type CORSGroup struct {
+	AllowOrigins     []string  `yaml:"allow_origins,omitempty" toml:"allow_origins,omitempty"`
+	AllowCredentials *bool     `yaml:"allow_credentials,omitempty" toml:"allow_credentials,omitempty"`
+	AllowMethods     []string  `yaml:"allow_methods,omitempty" toml:"allow_methods,omitempty"`
+	AllowHeaders     []string  `yaml:"allow_headers,omitempty" toml:"allow_headers,omitempty"`
+	ExposeHeaders    []string  `yaml:"expose_headers,omitempty" toml:"expose_headers,omitempty"`
+	MaxAge           *Duration `yaml:"max_age,omitempty" toml:"max_age,omitempty"`
+}
+
+// Group returns the top-level policy with a group's overrides applied
+func (c CORS) Group(prefix string) CORS {
+	g := c.Groups[prefix]
+	out := c
+	out.Groups = nil
+	if g.AllowOrigins != nil {
+		out.AllowOrigins = g.AllowOrigins
+	}
+	if g.AllowCredentials != nil {
+		out.AllowCredentials = *g.AllowCredentials
+	}
+	if g.AllowMethods != nil {
+		out.AllowMethods = g.AllowMethods
+	}
+	if g.AllowHeaders != nil {
+		out.AllowHeaders = g.AllowHeaders
+	}
+	if g.ExposeHeaders != nil {
+		out.ExposeHeaders = g.ExposeHeaders
+	}
+	if g.MaxAge != nil {
+		out.MaxAge = *g.MaxAge
+	}
+	return out
+}
+
+// Log configures logging
+type Log struct {
+	Level  string `yaml:"level" toml:"level" env:"LOG_LEVEL" help:"debug, info, warn or error"`
+	Format string `yaml:"format" toml:"format" env:"LOG_FORMAT" help:"text or json"`
+	Access bool   `yaml:"access" toml:"access" env:"LOG_ACCESS" help:"log every request"`
+}
+
+// Compression configures response compression
+type Compression struct {
+	Enabled     bool     `yaml:"enabled" toml:"enabled" env:"COMPRESSION_ENABLED" help:"compress responses"`
+	MinLength   int      `yaml:"min_length" toml:"min_length" env:"COMPRESSION_MIN_LENGTH" help:"smallest response body compressed on the fly, in bytes"`
+	Encodings   []string `yaml:"encodings" toml:"encodings" env:"COMPRESSION_ENCODINGS" help:"codings of on-the-fly compression in preference order"`
+	Precompress []string `yaml:"precompress" toml:"precompress" env:"COMPRESSION_PRECOMPRESS" help:"codings the frontend files are compressed into at startup"`
+}
+
+// Frontend configures the embedded frontend
+type Frontend struct {
+	// MIMETypes map file extensions such as ".wasm" to media types,
+	// overriding the built-in ones; they can only be set in the config file
+	MIMETypes map[string]string `yaml:"mime_types,omitempty" toml:"mime_types,omitempty"`
+}
+
+// Metrics configures the Prometheus metrics endpoint
+type Metrics struct {
+	Enabled bool   `yaml:"enabled" toml:"enabled" env:"METRICS_ENABLED" help:"expose Prometheus metrics"`
+	Path    string `yaml:"path" toml:"path" env:"METRICS_PATH" help:"path of the metrics endpoint"`
+	Listen  string `yaml:"listen" toml:"listen" env:"METRICS_LISTEN" help:"separate admin address for metrics, empty to serve them on server.listen"`
+}
+
+// RateLimit configures per-client rate limits and the concurrency cap
+type RateLimit struct {
+	Enabled     bool     `yaml:"enabled" toml:"enabled" env:"RATE_LIMIT_ENABLED" help:"limit requests per client"`
+	Requests    int      `yaml:"requests" toml:"requests" env:"RATE_LIMIT_REQUESTS" help:"requests a client may make per period"`
+	Period      Duration `yaml:"period" toml:"period" env:"RATE_LIMIT_PERIOD" help:"period of the request quota"`
+	Burst       int      `yaml:"burst" toml:"burst" env:"RATE_LIMIT_BURST" help:"requests allowed at once, 0 for the whole quota"`
+	Paths       []string `yaml:"paths" toml:"paths" env:"RATE_LIMIT_PATHS" help:"path prefixes that are limited"`
+	IdleTimeout Duration `yaml:"idle_timeout" toml:"idle_timeout" env:"RATE_LIMIT_IDLE_TIMEOUT" help:"how long an idle client is remembered"`
+
+	MaxConcurrent    int      `yaml:"max_concurrent" toml:"max_concurrent" env:"RATE_LIMIT_MAX_CONCURRENT" help:"requests in progress allowed across the concurrency paths, 0 for no cap"`
+	ConcurrencyPaths []string `yaml:"concurrency_paths" toml:"concurrency_paths" env:"RATE_LIMIT_CONCURRENCY_PATHS" help:"path prefixes of expensive routes sharing the concurrency cap"`
+
+	// Groups give routes under a path prefix a limit and buckets of their
+	// own, e.g. a stricter one for batch endpoints. They can only be set in
+	// the config file.
+	Groups map[string]RateLimitGroup `yaml:"groups" toml:"groups"`
+}
+
+// RateLimitGroup overrides the limit of a route group; keys left out keep
+// the top-level values
+type RateLimitGroup struct {
+	Requests int       `yaml:"requests,omitempty" toml:"requests,omitempty"`
+	Period   *Duration `yaml:"period,omitempty" toml:"period,omitempty"`
+	Burst    *int      `yaml:"burst,omitempty" toml:"burst,omitempty"`
+}
+
+// Group returns the top-level limit with a group's overrides applied
+func (r RateLimit) Group(prefix string) RateLimit {
+	g := r.Groups[prefix]
+	out := r
+	out.Groups = nil
+	if g.Requests != 0 {
+		out.Requests = g.Requests
+	}
+	if g.Period != nil {
+		out.Period = *g.Period
+	}
+	if g.Burst != nil {
+		out.Burst = *g.Burst
+	}
+	return out
+}
+
+// Data configures the files replacing built-in reference data
+type Data struct {
+	UARulesPath       string   `yaml:"ua_rules_path" toml:"ua_rules_path" env:"UA_RULES_PATH" help:"User-Agent rules file"`
+	OUIRegistryPaths  []string `yaml:"oui_registry_paths" toml:"oui_registry_paths" env:"OUI_REGISTRY_PATH" help:"IEEE OUI registry CSV files"`
+	PortsRegistryPath string   `yaml:"ports_registry_path" toml:"ports_registry_path" env:"PORTS_REGISTRY_PATH" help:"IANA port registry CSV file"`
+}
+
+// GeoIP configures the geolocation provider chain
+type GeoIP struct {
+	Providers      []string `yaml:"providers" toml:"providers" env:"GEOIP_PROVIDERS" help:"lookup order of static, mmdb, csv and http"`
+	StaticPath     string   `yaml:"static_path" toml:"static_path" env:"GEOIP_STATIC_PATH" help:"JSON file of CIDR overrides"`
+	MMDBPath       string   `yaml:"mmdb_path" toml:"mmdb_path" env:"GEOIP_MMDB_PATH" help:"MaxMind DB file"`
+	CSVPath        string   `yaml:"csv_path" toml:"csv_path" env:"GEOIP_CSV_PATH" help:"CSV range file"`
+	HTTPProvider   string   `yaml:"http_provider" toml:"http_provider" env:"GEOIP_HTTP_PROVIDER" help:"remote lookup service, ip-api or ipinfo"`
+	HTTPURL        string   `yaml:"http_url" toml:"http_url" env:"GEOIP_HTTP_URL" help:"remote service endpoint"`
+	HTTPToken      string   `yaml:"http_token" toml:"http_token" env:"GEOIP_HTTP_TOKEN" secret:"true" help:"remote service token"`
+	ReloadInterval Duration `yaml:"reload_interval" toml:"reload_interval" env:"GEOIP_RELOAD_INTERVAL" help:"how often database files are checked for changes, 0 to disable"`
+	ProbeIPs       []string `yaml:"probe_ips" toml:"probe_ips" env:"GEOIP_PROBE_IPS" help:"addresses new database versions must resolve"`
+}
+
+// DNS configures the resolver of /api/dns
+type DNS struct {
+	Upstream string   `yaml:"upstream" toml:"upstream" env:"DNS_UPSTREAM" help:"resolver address or URL"`
+	Timeout  Duration `yaml:"timeout" toml:"timeout" env:"DNS_TIMEOUT" help:"timeout of each lookup"`
+}
+
+// IPSets configures the named CIDR rule sets and the access control
+// based on them
+type IPSets struct {
+	Path  string   `yaml:"path" toml:"path" env:"IPSETS_PATH" help:"rule set file"`
+	Allow []string `yaml:"allow" toml:"allow" env:"IPSETS_ALLOW" help:"rule sets clients must be in, empty for any client"`
+	Deny  []string `yaml:"deny" toml:"deny" env:"IPSETS_DENY" help:"rule sets whose clients are rejected"`
+}
+
+// AccessControl reports whether requests are checked against rule sets
+func (s IPSets) AccessControl() bool {
+	return len(s.Allow) > 0 || len(s.Deny) > 0
+}
+
+// Auth configures API keys. Keys come from the key file managed with the
+// keys command and from the config file, and carry scopes such as
+// read:holiday, write:ip or admin.
+type Auth struct {
+	KeyFile   string   `yaml:"key_file" toml:"key_file" env:"AUTH_KEY_FILE" help:"API key file managed with the keys command"`
+	Anonymous []string `yaml:"anonymous" toml:"anonymous" env:"AUTH_ANONYMOUS" help:"modules readable without an API key"`
+
+	// Keys are given by ID with the hash and scopes printed by "keys create
+	// -print". They can only be set in the config file.
+	Keys map[string]AuthKey `yaml:"keys" toml:"keys"`
+}
+
+// AuthKey is an API key stored in the config file
+type AuthKey struct {
+	Name   string   `yaml:"name,omitempty" toml:"name,omitempty"`
+	Hash   string   `yaml:"hash" toml:"hash"`
+	Scopes []string `yaml:"scopes" toml:"scopes"`
+}
+
+// Echo configures /api/request
+type Echo struct {
+	RedactHeaders []string `yaml:"redact_headers" toml:"redact_headers" env:"ECHO_REDACT_HEADERS" help:"headers hidden from the echo"`
+}
+
+// Duration is a time.Duration written as "30s" or "5m" in files and flags
+type Duration struct {
+	time.Duration
+}
+
+// UnmarshalText parses a Go duration string
+func (d *Duration) UnmarshalText(text []byte) error {
+	v, err := time.ParseDuration(string(text))
+	if err != nil {
+		return err
+	}
+	d.Duration = v
+	return nil
+}
+
+// MarshalText writes the duration as a Go duration string
+func (d Duration) MarshalText() ([]byte, error) {
+	return []byte(d.String()), nil
+}
+
+// Default returns the built-in configuration
+func Default() *Config {
+	return &Config{
+		Server: Server{
+			Listen: ":8080",
+			Mode:   "release",
+
+			ReadHeaderTimeout: Duration{5 * time.Second},
+			ReadTimeout:       Duration{30 * time.Second},
+			WriteTimeout:      Duration{30 * time.Second},
+			IdleTimeout:       Duration{2 * time.Minute},
+			MaxHeaderBytes:    1 << 20,
+			ShutdownDelay:     Duration{5 * time.Second},
+			ShutdownTimeout:   Duration{25 * time.Second},
+		},
+		TLS: TLS{
+			ReloadInterval: Duration{30 * time.Second},
+			HTTP2:          true,
+			MinVersion:     "1.2",
+			ClientAuth:     "require",
+		},
+		CORS: CORS{
+			AllowOrigins: []string{"*"},
+			AllowMethods: []string{"GET", "POST", "OPTIONS"},
+			AllowHeaders: []string{"Content-Type", "Authorization", "X-API-Key"},
+			// Lets browser code report the ID of a failed call
+			ExposeHeaders: []string{"X-Request-ID"},
+			MaxAge:        Duration{10 * time.Minute},
+		},
+		Log: Log{
+			Level:  "info",
+			Format: "text",
+			Access: true,
+		},
+		Compression: Compression{
+			Enabled:     true,
+			MinLength:   1024,
+			Encodings:   []string{"zstd", "gzip"},
+			Precompress: []string{"br", "gzip"},
+		},
+		// Metrics reveal traffic and runtime details, so they are opt-in
+		Metrics: Metrics{
+			Path: "/metrics",
+		},
+		RateLimit: RateLimit{
+			Requests:         120,
+			Period:           Duration{time.Minute},
+			Paths:            []string{"/api", "/ip"},
+			IdleTimeout:      Duration{10 * time.Minute},
+			ConcurrencyPaths: []string{"/api/dns", "/api/net/cidr/aggregate", "/api/net/cidr/split"},
+		},
+		Auth: Auth{
+			Anonymous: apiModules(),
+		},
+		Modules: append([]string(nil), Modules...),
+		GeoIP: GeoIP{
+			ReloadInterval: Duration{time.Minute},
+		},
+		DNS: DNS{
+			Timeout: Duration{5 * time.Second},
+		},
+		Echo: Echo{
+			RedactHeaders: append([]string(nil), RedactHeaders...),
+		},
+	}
+}
+
+// ModuleEnabled reports whether a module is enabled
+func (c *Config) ModuleEnabled(name string) bool {
+	for _, m := range c.Modules {
+		if m == name {
+			return true
+		}
+	}
+	return false
+}
+
+// TrustedProxyPrefixes returns the trusted proxies as prefixes; single
+// addresses become host prefixes
+func (c *Config) TrustedProxyPrefixes() ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, p := range c.Server.TrustedProxies {
+		prefix, err := parsePrefix(p)
+		if err != nil {
+			return nil, err
+		}
+		prefixes = append(prefixes, prefix)
+	}
+	return prefixes, nil
+}
+
+// Validate checks every setting and reports all problems at once. Values
+// defined by other packages, such as codings, MIME types, API keys and TLS
+// parameters, are left to the server, which checks them against those
+// packages.
+func (c *Config) Validate() error {
+	var errs []error
+	fail := func(key, format string, args ...interface{}) {
+		errs = append(errs, fmt.Errorf("%s: %s", key, fmt.Sprintf(format, args...)))
+	}
+
+	// With HTTPS the plain listener may be turned off
+	if c.Server.Listen != "" || !c.TLS.Enabled() {
+		if _, port, err := net.SplitHostPort(c.Server.Listen); err != nil || port == "" {
+			fail("server.listen", "invalid address %q, expected host:port or :port", c.Server.Listen)
+		}
+	}
+	if c.Server.H2C && len(c.Server.TrustedProxies) == 0 {
+		fail("server.h2c", "needs server.trusted_proxies, h2c is only accepted from them")
+	}
+	c.validateTLS(fail)
+	if !oneOf(c.Server.Mode, "debug", "release", "test") {
+		fail("server.mode", "must be debug, release or test, got %q", c.Server.Mode)
+	}
+	for _, p := range c.Server.TrustedProxies {
+		if _, err := parsePrefix(p); err != nil {
+			fail("server.trusted_proxies", "%v", err)
+		}
+	}
+	for _, t := range []struct {
+		key string
+		d   Duration
+	}{
+		{"server.read_timeout", c.Server.ReadTimeout},
+		{"server.write_timeout", c.Server.WriteTimeout},
+		{"server.idle_timeout", c.Server.IdleTimeout},
+		{"server.shutdown_delay", c.Server.ShutdownDelay},
+	} {
+		if t.d.Duration < 0 {
+			fail(t.key, "must not be negative")
+		}
+	}
+	if c.Server.ReadHeaderTimeout.Duration <= 0 {
+		fail("server.read_header_timeout", "must be positive, slow clients could otherwise hold connections open")
+	}
+	if c.Server.ShutdownTimeout.Duration <= 0 {
+		fail("server.shutdown_timeout", "must be positive")
+	}
+	if c.Server.MaxHeaderBytes <= 0 {
+		fail("server.max_header_bytes", "must be positive")
+	}
+
+	validateCORS("cors", c.CORS, fail)
+	prefixes := make([]string, 0, len(c.CORS.Groups))
+	for prefix := range c.CORS.Groups {
+		prefixes = append(prefixes, prefix)
+	}
+	sort.Strings(prefixes)
+	for _, prefix := range prefixes {
+		if !strings.HasPrefix(prefix, "/") {
+			fail("cors.groups", "route prefix %q must start with /", prefix)
+		}
+		validateCORS("cors.groups."+prefix, c.CORS.Group(prefix), fail)
+	}
+
+	if !oneOf(c.Log.Level, "debug", "info", "warn", "error") {
+		fail("log.level", "must be debug, info, warn or error, got %q", c.Log.Level)
+	}
+	if !oneOf(c.Log.Format, "text", "json") {
+		fail("log.format", "must be text or json, got %q", c.Log.Format)
+	}
+
+	if c.Compression.MinLength < 0 {
+		fail("compression.min_length", "must not be negative")
+	}
+
+	if c.RateLimit.Enabled {
+		validateRateLimit("rate_limit", c.RateLimit, fail)
+		for _, p := range append(append([]string(nil), c.RateLimit.Paths...), c.RateLimit.ConcurrencyPaths...) {
+			if !strings.HasPrefix(p, "/") {
+				fail("rate_limit", "path prefix %q must start with /", p)
+			}
+		}
+		if c.RateLimit.IdleTimeout.Duration <= 0 {
+			fail("rate_limit.idle_timeout", "must be positive")
+		}
+		if c.RateLimit.MaxConcurrent < 0 {
+			fail("rate_limit.max_concurrent", "must not be negative")
+		}
+		groups := make([]string, 0, len(c.RateLimit.Groups))
+		for prefix := range c.RateLimit.Groups {
+			groups = append(groups, prefix)
+		}
+		sort.Strings(groups)
+		for _, prefix := range groups {
+			if !strings.HasPrefix(prefix, "/") {
+				fail("rate_limit.groups", "route prefix %q must start with /", prefix)
+			}
+			validateRateLimit("rate_limit.groups."+prefix, c.RateLimit.Group(prefix), fail)
+		}
+	}
+
+	for _, m := range c.Auth.Anonymous {
+		if !oneOf(m, apiModules()...) {
+			fail("auth.anonymous", "unknown module %q, expected some of %s", m, strings.Join(apiModules(), ", "))
+		}
+	}
+
+	if c.Metrics.Enabled {
+		if !strings.HasPrefix(c.Metrics.Path, "/") || strings.HasPrefix(c.Metrics.Path, "/api/") {
+			fail("metrics.path", "must start with / and not be under /api/, got %q", c.Metrics.Path)
+		}
+		if c.Metrics.Listen != "" {
+			if _, port, err := net.SplitHostPort(c.Metrics.Listen); err != nil || port == "" {
+				fail("metrics.listen", "invalid address %q, expected host:port or :port", c.Metrics.Listen)
+			} else if c.Metrics.Listen == c.Server.Listen || c.Metrics.Listen == c.TLS.Listen {
+				fail("metrics.listen", "must differ from server.listen and tls.listen, leave it empty to share the listener")
+			}
+		}
+	}
+
+	for _, m := range c.Modules {
+		if !oneOf(m, Modules...) {
+			fail("modules", "unknown module %q, expected some of %s", m, strings.Join(Modules, ", "))
+		}
+	}
+
+	for _, p := range c.GeoIP.Providers {
+		if !oneOf(p, "static", "mmdb", "csv", "http") {
+			fail("geoip.providers", "unknown provider %q", p)
+		}
+	}
+	if c.GeoIP.HTTPProvider != "" && !oneOf(c.GeoIP.HTTPProvider, "ip-api", "ipinfo") {
+		fail("geoip.http_provider", "must be ip-api or ipinfo, got %q", c.GeoIP.HTTPProvider)
+	}
+	if c.GeoIP.ReloadInterval.Duration < 0 {
+		fail("geoip.reload_interval", "must not be negative")
+	}
+	for _, ip := range c.GeoIP.ProbeIPs {
+		if _, err := netip.ParseAddr(ip); err != nil {
+			fail("geoip.probe_ips", "invalid address %q", ip)
+		}
+	}
+
+	if c.DNS.Timeout.Duration <= 0 {
+		fail("dns.timeout", "must be positive")
+	}
+
+	return errors.Join(errs...)
+}
+
+// validateCORS checks a CORS policy; key prefixes the reported errors
+func validateCORS(key string, cors CORS, fail func(key, format string, args ...interface{})) {
+	for _, origin := range cors.AllowOrigins {
+		if err := validateOrigin(origin); err != nil {
+			fail(key+".allow_origins", "%v", err)
+		}
+		if origin == "*" && cors.AllowCredentials {
+			fail(key+".allow_origins", "* cannot be combined with allow_credentials, list the origins instead")
+		}
+	}
+	for _, method := range cors.AllowMethods {
+		if method == "" || strings.ToUpper(method) != method || strings.ContainsAny(method, " ,") {
+			fail(key+".allow_methods", "invalid method %q, use upper-case names such as GET", method)
+		}
+	}
+	if cors.MaxAge.Duration < 0 {
+		fail(key+".max_age", "must not be negative")
+	}
+}
+
+// validateOrigin accepts "*", an origin such as https://portal.example.com
+// or a wildcard subdomain origin such as https://*.example.com
+func validateOrigin(origin string) error {
+	if origin == "*" {
+		return nil
+	}
+	u, err := url.Parse(strings.Replace(origin, "://*.", "://wildcard.", 1))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" || strings.Contains(u.Host, "*") ||
+		u.Path != "" || u.RawQuery != "" || u.Fragment != "" || u.User != nil {
+		return fmt.Errorf("invalid origin %q, expected scheme://host[:port] or scheme://*.domain", origin)
+	}
+	return nil
+}
+
+// parsePrefix parses a CIDR or a single address
+func parsePrefix(s string) (netip.Prefix, error) {
+	if strings.Contains(s, "/") {
+		prefix, err := netip.ParsePrefix(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid CIDR %q", s)
+		}
+		return prefix.Masked(), nil
+	}
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid address %q", s)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
+
+// validateTLS checks the HTTPS listener settings
+func (c *Config) validateTLS(fail func(key, format string, args ...interface{})) {
+	t := c.TLS
+	if !t.Enabled() {
+		if t.RedirectHTTP {
+			fail("tls.redirect_http", "needs tls.listen")
+		}
+		return
+	}
+
+	if _, port, err := net.SplitHostPort(t.Listen); err != nil || port == "" {
+		fail("tls.listen", "invalid address %q, expected host:port or :port", t.Listen)
+	} else if t.Listen == c.Server.Listen {
+		fail("tls.listen", "must differ from server.listen")
+	}
+	if t.CertFile == "" || t.KeyFile == "" {
+		fail("tls", "cert_file and key_file are required with listen")
+	}
+	if t.ReloadInterval.Duration <= 0 {
+		fail("tls.reload_interval", "must be positive")
+	}
+	// The version, suite names and client_auth are checked by the
+	// tlsconfig package when the listener is set up
+	if len(t.CipherSuites) > 0 && t.MinVersion == "1.3" {
+		fail("tls.cipher_suites", "has no effect with min_version 1.3")
+	} else if len(t.CipherSuites) > 0 && t.HTTP2 &&
+		!oneOf("TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", t.CipherSuites...) &&
+		!oneOf("TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256", t.CipherSuites...) {
+		// RFC 7540 requires one of them and net/http refuses to serve without
+		fail("tls.cipher_suites", "HTTP/2 needs TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 or TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256")
+	}
+	if t.RedirectHTTP && c.Server.Listen == "" {
+		fail("tls.redirect_http", "needs server.listen")
+	}
+	if t.RedirectPort < 0 || t.RedirectPort > 65535 {
+		fail("tls.redirect_port", "must be a port number, got %d", t.RedirectPort)
+	}
+}
+
+// apiModules returns the modules serving API routes, i.e. all but the
+// frontend
+func apiModules() []string {
+	modules := make([]string, 0, len(Modules))
+	for _, m := range Modules {
+		if m != "frontend" {
+			modules = append(modules, m)
+		}
+	}
+	return modules
+}
+
+// validateRateLimit checks a request quota
+func validateRateLimit(key string, r RateLimit, fail func(key, format string, args ...interface{})) {
+	if r.Requests <= 0 {
+		fail(key+".requests", "must be positive")
+	}
+	if r.Period.Duration <= 0 {
+		fail(key+".period", "must be positive")
+	}
+	if r.Burst < 0 {
+		fail(key+".burst", "must not be negative")
+	}
+}
+
+// oneOf reports whether s is one of the options
+func oneOf(s string, options ...string) bool {
+	for _, o := range options {
+		if s == o {
+			return true
+		}
+	}
+	return false
+}
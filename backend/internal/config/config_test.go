@@ -0,0 +1,417 @@
+package config
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// env returns a lookup function over a fixed environment
+func env(vars map[string]string) func(string) (string, bool) {
+	return func(name string) (string, bool) {
+		v, ok := vars[name]
+		return v, ok
+	}
+}
+
+func writeFile(t *testing.T, name, content string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), name)
+	if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestDefaultIsValid(t *testing.T) {
+	if err := Default().Validate(); err != nil {
+		t.Errorf("Default().Validate() = %v", err)
+	}
+}
+
+func TestLoadPrecedence(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+server:
+  listen: ":7000"
+  trusted_proxies: [10.0.0.0/8]
+log:
+  level: debug
+  format: json
+dns:
+  timeout: 2s
+modules: [ip, net]
+`)
+
+	cfg, err := load([]string{"-config", path, "-log.level", "warn"}, env(map[string]string{
+		"LISTEN_ADDR": "127.0.0.1:7100",
+		"LOG_LEVEL":   "error",
+		"DNS_TIMEOUT": "3s",
+	}), io.Discard)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+
+	if cfg.Server.Listen != "127.0.0.1:7100" {
+		t.Errorf("Listen = %q, env should override the file", cfg.Server.Listen)
+	}
+	if cfg.Log.Level != "warn" {
+		t.Errorf("Level = %q, flags should override env", cfg.Log.Level)
+	}
+	if cfg.Log.Format != "json" {
+		t.Errorf("Format = %q, file should override defaults", cfg.Log.Format)
+	}
+	if cfg.DNS.Timeout.Duration != 3*time.Second {
+		t.Errorf("DNS timeout = %v", cfg.DNS.Timeout)
+	}
+	if len(cfg.Modules) != 2 || !cfg.ModuleEnabled("net") || cfg.ModuleEnabled("dns") {
+		t.Errorf("Modules = %v", cfg.Modules)
+	}
+	if len(cfg.Server.TrustedProxies) != 1 {
+		t.Errorf("TrustedProxies = %v", cfg.Server.TrustedProxies)
+	}
+	if len(cfg.CORS.AllowOrigins) != 1 || cfg.CORS.AllowOrigins[0] != "*" {
+		t.Errorf("AllowOrigins = %v, unset keys should keep defaults", cfg.CORS.AllowOrigins)
+	}
+}
+
+func TestLoadTOML(t *testing.T) {
+	path := writeFile(t, "config.toml", `
+modules = ["holiday"]
+
+[server]
+listen = ":9000"
+
+[geoip]
+reload_interval = "30s"
+probe_ips = ["192.0.2.1"]
+`)
+
+	cfg, err := load(nil, env(map[string]string{"CONFIG_FILE": path}), io.Discard)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Server.Listen != ":9000" {
+		t.Errorf("Listen = %q", cfg.Server.Listen)
+	}
+	if cfg.GeoIP.ReloadInterval.Duration != 30*time.Second {
+		t.Errorf("ReloadInterval = %v", cfg.GeoIP.ReloadInterval)
+	}
+	if len(cfg.Modules) != 1 || cfg.Modules[0] != "holiday" {
+		t.Errorf("Modules = %v", cfg.Modules)
+	}
+}
+
+func TestLoadLegacyPort(t *testing.T) {
+	cfg, err := load(nil, env(map[string]string{"PORT": "9999"}), io.Discard)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Server.Listen != ":9999" {
+		t.Errorf("Listen = %q, want :9999", cfg.Server.Listen)
+	}
+
+	cfg, err = load(nil, env(map[string]string{"PORT": "9999", "LISTEN_ADDR": ":8181"}), io.Discard)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if cfg.Server.Listen != ":8181" {
+		t.Errorf("Listen = %q, LISTEN_ADDR should win over PORT", cfg.Server.Listen)
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name    string
+		args    []string
+		env     map[string]string
+		file    string
+		content string
+		want    string
+	}{
+		{name: "unknown yaml key", file: "c.yaml", content: "server:\n  lisen: \":80\"\n", want: "lisen"},
+		{name: "unknown toml key", file: "c.toml", content: "[server]\nlisen = \":80\"\n", want: "lisen"},
+		{name: "unsupported file type", file: "c.json", content: "{}", want: "unsupported config file type"},
+		{name: "invalid env duration", env: map[string]string{"DNS_TIMEOUT": "soon"}, want: "DNS_TIMEOUT"},
+		{name: "invalid flag boolean", args: []string{"-log.access=maybe"}, want: "log.access"},
+		{name: "unknown module", args: []string{"-modules", "ip,weather"}, want: `unknown module "weather"`},
+		{name: "invalid listen", args: []string{"-server.listen", "8080"}, want: "server.listen"},
+		{name: "invalid proxy", env: map[string]string{"TRUSTED_PROXIES": "10.0.0.0/33"}, want: "server.trusted_proxies"},
+		{name: "invalid origin", env: map[string]string{"CORS_ALLOW_ORIGINS": "example.com"}, want: "cors.allow_origins"},
+		{name: "negative write timeout", env: map[string]string{"HTTP_WRITE_TIMEOUT": "-1s"}, want: "server.write_timeout"},
+		{name: "no header timeout", args: []string{"-server.read-header-timeout", "0s"}, want: "server.read_header_timeout"},
+		{name: "metrics under api", env: map[string]string{"METRICS_ENABLED": "true", "METRICS_PATH": "/api/metrics"}, want: "metrics.path"},
+		{name: "metrics on main listener", args: []string{"-metrics.enabled", "-metrics.listen", ":8080"}, want: "metrics.listen: must differ from server.listen"},
+		{name: "rate limit without quota", env: map[string]string{"RATE_LIMIT_ENABLED": "true", "RATE_LIMIT_REQUESTS": "0"}, want: "rate_limit.requests: must be positive"},
+		{name: "unexpected argument", args: []string{"serve"}, want: `unexpected argument "serve"`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			args := tt.args
+			if tt.file != "" {
+				args = append([]string{"-config", writeFile(t, tt.file, tt.content)}, args...)
+			}
+			_, err := load(args, env(tt.env), io.Discard)
+			if err == nil {
+				t.Fatal("expected an error")
+			}
+			if !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("error = %q, want it to mention %q", err, tt.want)
+			}
+		})
+	}
+}
+
+func TestCORSGroups(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+cors:
+  allow_origins: ["*"]
+  groups:
+    /api/ip/sets:
+      allow_origins: [https://admin.example.com, https://*.corp.example.com]
+      allow_credentials: true
+      max_age: 1h
+`)
+
+	cfg, err := load([]string{"-config", path}, env(nil), io.Discard)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	group := cfg.CORS.Group("/api/ip/sets")
+	if !group.AllowCredentials || len(group.AllowOrigins) != 2 || group.MaxAge.Duration != time.Hour {
+		t.Errorf("group policy = %+v", group)
+	}
+	if strings.Join(group.AllowMethods, ",") != "GET,POST,OPTIONS" {
+		t.Errorf("AllowMethods = %v, should inherit the top-level value", group.AllowMethods)
+	}
+	if cfg.CORS.AllowCredentials {
+		t.Error("group override leaked into the top-level policy")
+	}
+
+	path = writeFile(t, "invalid.yaml", `
+cors:
+  groups:
+    /api/ip/sets:
+      allow_credentials: true
+    api/holiday:
+      allow_origins: ["https://*.*.example.com"]
+`)
+	_, err = load([]string{"-config", path}, env(nil), io.Discard)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{
+		"cors.groups./api/ip/sets.allow_origins: * cannot be combined with allow_credentials",
+		`route prefix "api/holiday" must start with /`,
+		`invalid origin "https://*.*.example.com"`,
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestRateLimitGroups(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+rate_limit:
+  enabled: true
+  requests: 600
+  period: 1m
+  groups:
+    /api/net/cidr/aggregate:
+      requests: 10
+      burst: 2
+    /api/dns:
+      period: 10m
+`)
+	cfg, err := load([]string{"-config", path, "-rate-limit.burst", "50"}, env(nil), io.Discard)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	batch := cfg.RateLimit.Group("/api/net/cidr/aggregate")
+	if batch.Requests != 10 || batch.Burst != 2 || batch.Period.Duration != time.Minute {
+		t.Errorf("batch group = %d per %s, burst %d", batch.Requests, batch.Period, batch.Burst)
+	}
+	dns := cfg.RateLimit.Group("/api/dns")
+	if dns.Requests != 600 || dns.Burst != 50 || dns.Period.Duration != 10*time.Minute {
+		t.Errorf("dns group = %d per %s, burst %d, should inherit the rest", dns.Requests, dns.Period, dns.Burst)
+	}
+
+	path = writeFile(t, "invalid.yaml", `
+rate_limit:
+  enabled: true
+  groups:
+    api/dns:
+      period: 0s
+`)
+	_, err = load([]string{"-config", path}, env(nil), io.Discard)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{
+		`route prefix "api/dns" must start with /`,
+		"rate_limit.groups.api/dns.period: must be positive",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+}
+
+func TestAuthKeys(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+auth:
+  anonymous: [ip, holiday]
+  keys:
+    a1b2c3d4e5f6:
+      name: ci
+      hash: sha256:`+strings.Repeat("ab", 32)+`
+      scopes: [read:dns, write:ip]
+`)
+	cfg, err := load([]string{"-config", path}, env(map[string]string{"AUTH_KEY_FILE": "/var/lib/utils-helper/apikeys.json"}), io.Discard)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if k := cfg.Auth.Keys["a1b2c3d4e5f6"]; len(cfg.Auth.Keys) != 1 || k.Name != "ci" || len(k.Scopes) != 2 {
+		t.Errorf("Keys = %+v", cfg.Auth.Keys)
+	}
+	if cfg.Auth.KeyFile != "/var/lib/utils-helper/apikeys.json" || len(cfg.Auth.Anonymous) != 2 {
+		t.Errorf("auth = %+v", cfg.Auth)
+	}
+
+	_, err = load([]string{"-auth.anonymous", "ip,frontend"}, env(nil), io.Discard)
+	if err == nil || !strings.Contains(err.Error(), `auth.anonymous: unknown module "frontend"`) {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestTLS(t *testing.T) {
+	path := writeFile(t, "config.yaml", `
+server:
+  listen: ""
+tls:
+  listen: ":8443"
+  cert_file: /etc/tls/tls.crt
+  key_file: /etc/tls/tls.key
+  min_version: "1.2"
+  cipher_suites: [TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256]
+  client_ca_file: /etc/tls/ca.crt
+  client_auth: verify_if_given
+`)
+	cfg, err := load([]string{"-config", path, "-tls.http2=false"}, env(nil), io.Discard)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if !cfg.TLS.Enabled() || cfg.TLS.HTTP2 || len(cfg.TLS.CipherSuites) != 1 ||
+		cfg.TLS.ClientAuth != "verify_if_given" || cfg.TLS.ClientCAFile != "/etc/tls/ca.crt" {
+		t.Errorf("TLS = %+v", cfg.TLS)
+	}
+
+	path = writeFile(t, "invalid.yaml", `
+server:
+  listen: ":8443"
+  h2c: true
+tls:
+  listen: ":8443"
+  min_version: "1.3"
+  cipher_suites: [TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256]
+  redirect_http: true
+`)
+	_, err = load([]string{"-config", path}, env(nil), io.Discard)
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, want := range []string{
+		"server.h2c: needs server.trusted_proxies",
+		"tls.listen: must differ from server.listen",
+		"tls: cert_file and key_file are required",
+		"tls.cipher_suites: has no effect with min_version 1.3",
+	} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("error %q does not mention %q", err, want)
+		}
+	}
+
+	// Without HTTPS the plain listener is required and cannot redirect
+	_, err = load([]string{"-server.listen", "", "-tls.redirect-http"}, env(nil), io.Discard)
+	if err == nil || !strings.Contains(err.Error(), "server.listen: invalid address") || !strings.Contains(err.Error(), "tls.redirect_http: needs tls.listen") {
+		t.Errorf("error = %v", err)
+	}
+}
+
+func TestFrontendMIMETypes(t *testing.T) {
+	path := writeFile(t, "config.toml", `
+[frontend.mime_types]
+".wasm" = "application/wasm"
+".dat" = "application/octet-stream"
+`)
+	cfg, err := load(nil, env(map[string]string{"CONFIG_FILE": path}), io.Discard)
+	if err != nil {
+		t.Fatalf("load: %v", err)
+	}
+	if len(cfg.Frontend.MIMETypes) != 2 || cfg.Frontend.MIMETypes[".dat"] != "application/octet-stream" {
+		t.Errorf("MIMETypes = %v", cfg.Frontend.MIMETypes)
+	}
+}
+
+func TestValidateReportsEveryError(t *testing.T) {
+	cfg := Default()
+	cfg.Log.Level = "loud"
+	cfg.Log.Format = "xml"
+	cfg.DNS.Timeout = Duration{}
+
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected an error")
+	}
+	for _, key := range []string{"log.level", "log.format", "dns.timeout"} {
+		if !strings.Contains(err.Error(), key) {
+			t.Errorf("error %q does not mention %s", err, key)
+		}
+	}
+}
+
+func TestTrustedProxyPrefixes(t *testing.T) {
+	cfg := Default()
+	cfg.Server.TrustedProxies = []string{"10.0.0.0/8", "192.0.2.1", "::ffff:198.51.100.1"}
+
+	prefixes, err := cfg.TrustedProxyPrefixes()
+	if err != nil {
+		t.Fatalf("TrustedProxyPrefixes: %v", err)
+	}
+	want := []string{"10.0.0.0/8", "192.0.2.1/32", "198.51.100.1/32"}
+	for i, p := range prefixes {
+		if p.String() != want[i] {
+			t.Errorf("prefix %d = %s, want %s", i, p, want[i])
+		}
+	}
+}
+
+func TestPrintRedactsSecrets(t *testing.T) {
+	cfg := Default()
+	cfg.GeoIP.HTTPToken = "t0ken"
+
+	var out strings.Builder
+	if err := cfg.Print(&out); err != nil {
+		t.Fatalf("Print: %v", err)
+	}
+	if strings.Contains(out.String(), "t0ken") {
+		t.Errorf("secrets printed:\n%s", out.String())
+	}
+	if cfg.GeoIP.HTTPToken != "t0ken" {
+		t.Error("Print modified the configuration")
+	}
+
+	// The printed configuration loads back as a config file
+	path := writeFile(t, "printed.yaml", out.String())
+	loaded, err := load([]string{"-config", path}, env(nil), io.Discard)
+	if err != nil {
+		t.Fatalf("load printed config: %v", err)
+	}
+	if loaded.DNS.Timeout != cfg.DNS.Timeout || loaded.Server.Listen != cfg.Server.Listen {
+		t.Errorf("round trip changed the configuration: %+v", loaded)
+	}
+}
@@ -0,0 +1,270 @@
+package config
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"github.com/pelletier/go-toml/v2"
+	"gopkg.in/yaml.v3"
+)
+
+// Load builds the configuration from the defaults, the config file,
+// environment variables and the command-line flags in args, then validates
+// it. The file is named by the -config flag or the CONFIG_FILE variable.
+func Load(args []string) (*Config, error) {
+	return load(args, os.LookupEnv, os.Stderr)
+}
+
+// load is Load with the environment and flag output injected for tests
+func load(args []string, lookupEnv func(string) (string, bool), output io.Writer) (*Config, error) {
+	cfg := Default()
+
+	fs, configPath, flags := newFlagSet(cfg, output)
+	if err := fs.Parse(args); err != nil {
+		return nil, err
+	}
+	if fs.NArg() > 0 {
+		return nil, fmt.Errorf("unexpected argument %q", fs.Arg(0))
+	}
+
+	path := *configPath
+	if path == "" {
+		path, _ = lookupEnv("CONFIG_FILE")
+	}
+	if path != "" {
+		if err := cfg.loadFile(path); err != nil {
+			return nil, err
+		}
+	}
+
+	if err := cfg.loadEnv(lookupEnv); err != nil {
+		return nil, err
+	}
+
+	var errs []error
+	for _, f := range flags {
+		if f.value != nil {
+			if err := setField(f.field, *f.value); err != nil {
+				errs = append(errs, fmt.Errorf("-%s: %w", f.name, err))
+			}
+		}
+	}
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	return cfg, nil
+}
+
+// loadFile overlays a YAML (.yaml, .yml) or TOML (.toml) file. Keys the
+// configuration does not know are errors, so typos do not go unnoticed.
+func (c *Config) loadFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	switch ext := strings.ToLower(filepath.Ext(path)); ext {
+	case ".yaml", ".yml":
+		dec := yaml.NewDecoder(f)
+		dec.KnownFields(true)
+		if err := dec.Decode(c); err != nil && !errors.Is(err, io.EOF) {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	case ".toml":
+		dec := toml.NewDecoder(f)
+		dec.DisallowUnknownFields()
+		if err := dec.Decode(c); err != nil {
+			var strict *toml.StrictMissingError
+			if errors.As(err, &strict) {
+				return fmt.Errorf("%s: %s", path, strict.String())
+			}
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	default:
+		return fmt.Errorf("%s: unsupported config file type %q, use .yaml, .yml or .toml", path, ext)
+	}
+	return nil
+}
+
+// loadEnv overlays the variables named by env tags. PORT is accepted as
+// the listen port when LISTEN_ADDR is not set.
+func (c *Config) loadEnv(lookupEnv func(string) (string, bool)) error {
+	if _, ok := lookupEnv("LISTEN_ADDR"); !ok {
+		if port, ok := lookupEnv("PORT"); ok && port != "" {
+			c.Server.Listen = ":" + port
+		}
+	}
+
+	var errs []error
+	walkFields(reflect.ValueOf(c).Elem(), "", func(field reflect.Value, key string, tag reflect.StructTag) {
+		name := tag.Get("env")
+		if name == "" {
+			return
+		}
+		if v, ok := lookupEnv(name); ok {
+			if err := setField(field, v); err != nil {
+				errs = append(errs, fmt.Errorf("%s: %w", name, err))
+			}
+		}
+	})
+	return errors.Join(errs...)
+}
+
+// configFlag is a flag for one configuration field; value is set when the
+// flag is given so it can be applied after the file and environment
+type configFlag struct {
+	name  string
+	field reflect.Value
+	def   string
+	value *string
+}
+
+func (f *configFlag) String() string {
+	if f == nil {
+		return ""
+	}
+	return f.def
+}
+
+func (f *configFlag) Set(v string) error {
+	f.value = &v
+	return nil
+}
+
+// IsBoolFlag lets boolean fields be given as -log.access without a value
+func (f *configFlag) IsBoolFlag() bool {
+	return f.field.Kind() == reflect.Bool
+}
+
+// newFlagSet defines -config and one flag per field, named after its file
+// key with underscores as dashes, e.g. -geoip.reload-interval
+func newFlagSet(cfg *Config, output io.Writer) (*flag.FlagSet, *string, []*configFlag) {
+	fs := flag.NewFlagSet("utils-helper", flag.ContinueOnError)
+	fs.SetOutput(output)
+	configPath := fs.String("config", "", "YAML or TOML config file (env CONFIG_FILE)")
+
+	var flags []*configFlag
+	walkFields(reflect.ValueOf(cfg).Elem(), "", func(field reflect.Value, key string, tag reflect.StructTag) {
+		f := &configFlag{
+			name:  strings.ReplaceAll(key, "_", "-"),
+			field: field,
+			def:   formatField(field),
+		}
+		usage := tag.Get("help")
+		if env := tag.Get("env"); env != "" {
+			usage += " (env " + env + ")"
+		}
+		fs.Var(f, f.name, usage)
+		flags = append(flags, f)
+	})
+	return fs, configPath, flags
+}
+
+// walkFields calls fn for every settable field below v with its dotted file
+// key, e.g. "server.listen". Maps such as cors.groups are file-only and
+// skipped.
+func walkFields(v reflect.Value, prefix string, fn func(field reflect.Value, key string, tag reflect.StructTag)) {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		key := strings.Split(sf.Tag.Get("yaml"), ",")[0]
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		field := v.Field(i)
+		if field.Kind() == reflect.Map {
+			continue
+		}
+		if field.Kind() == reflect.Struct && field.Type() != reflect.TypeOf(Duration{}) {
+			walkFields(field, key, fn)
+			continue
+		}
+		fn(field, key, sf.Tag)
+	}
+}
+
+// setField parses s into a field. Lists are comma-separated; an empty
+// string clears them.
+func setField(field reflect.Value, s string) error {
+	switch field.Interface().(type) {
+	case string:
+		field.SetString(s)
+	case []string:
+		var list []string
+		for _, item := range strings.Split(s, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		field.Set(reflect.ValueOf(list))
+	case bool:
+		b, err := strconv.ParseBool(s)
+		if err != nil {
+			return fmt.Errorf("invalid boolean %q", s)
+		}
+		field.SetBool(b)
+	case int:
+		n, err := strconv.Atoi(s)
+		if err != nil {
+			return fmt.Errorf("invalid number %q", s)
+		}
+		field.SetInt(int64(n))
+	case Duration:
+		var d Duration
+		if err := d.UnmarshalText([]byte(s)); err != nil {
+			return fmt.Errorf("invalid duration %q", s)
+		}
+		field.Set(reflect.ValueOf(d))
+	default:
+		return fmt.Errorf("unsupported field type %s", field.Type())
+	}
+	return nil
+}
+
+// formatField writes a field in the form setField parses
+func formatField(field reflect.Value) string {
+	switch v := field.Interface().(type) {
+	case []string:
+		return strings.Join(v, ",")
+	case Duration:
+		if v.Duration == 0 {
+			return ""
+		}
+		return v.String()
+	case bool:
+		if !v {
+			return ""
+		}
+	}
+	return fmt.Sprint(field.Interface())
+}
+
+// Print writes the configuration as YAML, the format accepted by the
+// config file. Secrets are replaced by a placeholder.
+func (c *Config) Print(w io.Writer) error {
+	redacted := *c
+	walkFields(reflect.ValueOf(&redacted).Elem(), "", func(field reflect.Value, key string, tag reflect.StructTag) {
+		if tag.Get("secret") == "true" && field.String() != "" {
+			field.SetString("<redacted>")
+		}
+	})
+
+	enc := yaml.NewEncoder(w)
+	enc.SetIndent(2)
+	if err := enc.Encode(&redacted); err != nil {
+		return err
+	}
+	return enc.Close()
+}
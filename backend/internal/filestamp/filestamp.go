@@ -0,0 +1,29 @@
+// Package filestamp tells versions of a file apart, so files watched for
+// changes are only reloaded when they were replaced or rewritten.
+package filestamp
+
+import (
+	"os"
+	"time"
+)
+
+// Stamp identifies a version of a file by modification time and size. The
+// zero Stamp stands for a missing file.
+type Stamp struct {
+	ModTime time.Time
+	Size    int64
+}
+
+// Stat returns the stamp of the file at path
+func Stat(path string) (Stamp, error) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return Stamp{}, err
+	}
+	return Stamp{ModTime: info.ModTime(), Size: info.Size()}, nil
+}
+
+// Equal reports whether two stamps identify the same version
+func (s Stamp) Equal(o Stamp) bool {
+	return s.ModTime.Equal(o.ModTime) && s.Size == o.Size
+}
@@ -0,0 +1,38 @@
+package filestamp
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestStat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "data.csv")
+	if s, err := Stat(path); err == nil || s != (Stamp{}) {
+		t.Errorf("Stat(missing) = %+v, %v, want the zero stamp and an error", s, err)
+	}
+
+	if err := os.WriteFile(path, []byte("v1"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	first, err := Stat(path)
+	if err != nil || first.Size != 2 {
+		t.Fatalf("Stat() = %+v, %v", first, err)
+	}
+	if again, _ := Stat(path); !again.Equal(first) {
+		t.Errorf("Stat() of an unchanged file = %+v, want %+v", again, first)
+	}
+
+	// A rewrite is noticed by its time even when the size stays the same
+	if err := os.WriteFile(path, []byte("v2"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := first.ModTime.Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	if changed, _ := Stat(path); changed.Equal(first) {
+		t.Error("Stat() of a rewritten file equals the old stamp")
+	}
+}
@@ -0,0 +1,124 @@
+package metrics
+
+import (
+	"strconv"
+
+	"github.com/lRoccoon/utils-helper/internal/service"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// datasetCollector reads the holiday, geolocation and key file statistics
+// the services keep anyway at scrape time, so lookups carry no extra cost
+type datasetCollector struct {
+	holidayEntries  *prometheus.Desc
+	holidayLastDate *prometheus.Desc
+
+	geoLookups       *prometheus.Desc
+	geoCacheRequests *prometheus.Desc
+	geoCacheEntries  *prometheus.Desc
+	geoDBBuildTime   *prometheus.Desc
+	geoDBRecords     *prometheus.Desc
+	geoDBReloads     *prometheus.Desc
+
+	apiKeyReloadFailures *prometheus.Desc
+}
+
+func newDatasetCollector() *datasetCollector {
+	desc := func(name, help string, labels ...string) *prometheus.Desc {
+		return prometheus.NewDesc(prometheus.BuildFQName(namespace, "", name), help, labels, nil)
+	}
+	return &datasetCollector{
+		holidayEntries:  desc("holiday_dataset_entries", "Holidays and compensatory workdays in the dataset by year.", "year"),
+		holidayLastDate: desc("holiday_dataset_last_date_timestamp_seconds", "Latest date in the holiday dataset; later dates are answered from the weekday alone."),
+
+		geoLookups:       desc("geo_lookups_total", "Geolocation lookups by provider and result (hit, miss or error).", "provider", "result"),
+		geoCacheRequests: desc("geo_cache_requests_total", "Geolocation cache lookups by result (hit or miss).", "result"),
+		geoCacheEntries:  desc("geo_cache_entries", "Entries in the geolocation cache."),
+		geoDBBuildTime:   desc("geo_database_build_timestamp_seconds", "Build time of the loaded geolocation databases.", "provider", "type"),
+		geoDBRecords:     desc("geo_database_records", "Records in the loaded geolocation databases.", "provider"),
+		geoDBReloads:     desc("geo_database_reloads_total", "Reloads of the geolocation databases since startup.", "provider"),
+
+		apiKeyReloadFailures: desc("api_key_reload_failures_total", "Failed reloads of the API key file; the previous keys stay in use."),
+	}
+}
+
+func (d *datasetCollector) Describe(ch chan<- *prometheus.Desc) {
+	for _, desc := range []*prometheus.Desc{
+		d.holidayEntries, d.holidayLastDate,
+		d.geoLookups, d.geoCacheRequests, d.geoCacheEntries,
+		d.geoDBBuildTime, d.geoDBRecords, d.geoDBReloads,
+		d.apiKeyReloadFailures,
+	} {
+		ch <- desc
+	}
+}
+
+func (d *datasetCollector) Collect(ch chan<- prometheus.Metric) {
+	coverage := service.GetHolidayCoverage()
+	for year, n := range coverage.EntriesByYear {
+		ch <- prometheus.MustNewConstMetric(d.holidayEntries, prometheus.GaugeValue, float64(n), strconv.Itoa(year))
+	}
+	if !coverage.Last.IsZero() {
+		ch <- prometheus.MustNewConstMetric(d.holidayLastDate, prometheus.GaugeValue, float64(coverage.Last.Unix()))
+	}
+
+	stats := service.GetGeoStats()
+	// Providers are keyed by name, which a chain may in principle repeat
+	lookups := make(map[string][3]uint64)
+	var order []string
+	for _, p := range stats.Providers {
+		counts, seen := lookups[p.Name]
+		if !seen {
+			order = append(order, p.Name)
+		}
+		lookups[p.Name] = [3]uint64{counts[0] + p.Hits, counts[1] + p.Misses, counts[2] + p.Errors}
+	}
+	for _, name := range order {
+		counts := lookups[name]
+		for i, result := range []string{"hit", "miss", "error"} {
+			ch <- prometheus.MustNewConstMetric(d.geoLookups, prometheus.CounterValue, float64(counts[i]), name, result)
+		}
+	}
+	ch <- prometheus.MustNewConstMetric(d.geoCacheRequests, prometheus.CounterValue, float64(stats.CacheHits), "hit")
+	ch <- prometheus.MustNewConstMetric(d.geoCacheRequests, prometheus.CounterValue, float64(stats.CacheMisses), "miss")
+	ch <- prometheus.MustNewConstMetric(d.geoCacheEntries, prometheus.GaugeValue, float64(stats.CacheSize))
+
+	seen := make(map[string]bool)
+	for _, db := range service.GetGeoDatabases() {
+		if seen[db.Provider] {
+			continue
+		}
+		seen[db.Provider] = true
+		if !db.BuildTime.IsZero() {
+			ch <- prometheus.MustNewConstMetric(d.geoDBBuildTime, prometheus.GaugeValue, float64(db.BuildTime.Unix()), db.Provider, db.Type)
+		}
+		ch <- prometheus.MustNewConstMetric(d.geoDBRecords, prometheus.GaugeValue, float64(db.Records), db.Provider)
+		ch <- prometheus.MustNewConstMetric(d.geoDBReloads, prometheus.CounterValue, float64(db.Reloads), db.Provider)
+	}
+
+	ch <- prometheus.MustNewConstMetric(d.apiKeyReloadFailures, prometheus.CounterValue, float64(service.GetAPIKeyStore().ReloadFailures()))
+}
+
+// apiKeyCollector reads the request counts of the API keys. They reveal
+// which keys exist and how much they are used, so they are only served on
+// the admin listener.
+type apiKeyCollector struct {
+	requests *prometheus.Desc
+}
+
+func newAPIKeyCollector() *apiKeyCollector {
+	return &apiKeyCollector{
+		requests: prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "api_key_requests_total"),
+			"Requests authenticated with each API key, by key ID.", []string{"key"}, nil),
+	}
+}
+
+func (k *apiKeyCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- k.requests
+}
+
+func (k *apiKeyCollector) Collect(ch chan<- prometheus.Metric) {
+	for _, u := range service.GetAPIKeyStore().Usage() {
+		ch <- prometheus.MustNewConstMetric(k.requests, prometheus.CounterValue, float64(u.Requests), u.ID)
+	}
+}
@@ -0,0 +1,115 @@
+// Package metrics exposes request, runtime and dataset metrics in the
+// Prometheus text format.
+package metrics
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "utils_helper"
+
+// unmatchedRoute labels requests that matched no route template, e.g. the
+// frontend files, so raw paths never become label values
+const unmatchedRoute = "unmatched"
+
+// Metrics holds the collectors of a server and the registries exposing
+// them: registry for every scrape, admin for the admin listener only
+type Metrics struct {
+	registry *prometheus.Registry
+	admin    *prometheus.Registry
+
+	requests     *prometheus.CounterVec
+	duration     *prometheus.HistogramVec
+	responseSize *prometheus.HistogramVec
+	inFlight     prometheus.Gauge
+	static       *prometheus.CounterVec
+}
+
+// New creates the collectors and registers them together with the Go
+// runtime, process and dataset collectors
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		admin:    prometheus.NewRegistry(),
+		requests: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "http_requests_total",
+			Help:      "HTTP requests by method, route template and status code.",
+		}, []string{"method", "route", "code"}),
+		duration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_request_duration_seconds",
+			Help:      "Time to handle HTTP requests by method and route template.",
+			Buckets:   prometheus.DefBuckets,
+		}, []string{"method", "route"}),
+		responseSize: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "http_response_size_bytes",
+			Help:      "Size of HTTP response bodies as sent, after compression.",
+			Buckets:   prometheus.ExponentialBuckets(256, 4, 8),
+		}, []string{"method", "route"}),
+		inFlight: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Name:      "http_requests_in_flight",
+			Help:      "HTTP requests being handled.",
+		}),
+		static: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "static_requests_total",
+			Help:      "Requests for embedded frontend files by status code.",
+		}, []string{"code"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.requests, m.duration, m.responseSize, m.inFlight, m.static,
+		newDatasetCollector(),
+	)
+	m.admin.MustRegister(newAPIKeyCollector())
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{})
+}
+
+// AdminHandler serves the metrics together with the per-key request
+// counts, for a listener kept off the public network
+func (m *Metrics) AdminHandler() http.Handler {
+	return promhttp.HandlerFor(prometheus.Gatherers{m.registry, m.admin}, promhttp.HandlerOpts{})
+}
+
+// Middleware records the count, latency and response size of every request
+// under its route template, e.g. /api/holiday/:date
+func (m *Metrics) Middleware() gin.HandlerFunc {
+	return func(c *gin.Context) {
+		start := time.Now()
+		m.inFlight.Inc()
+		defer m.inFlight.Dec()
+
+		c.Next()
+
+		route := c.FullPath()
+		if route == "" {
+			route = unmatchedRoute
+		}
+		method := c.Request.Method
+		m.requests.WithLabelValues(method, route, strconv.Itoa(c.Writer.Status())).Inc()
+		m.duration.WithLabelValues(method, route).Observe(time.Since(start).Seconds())
+		m.responseSize.WithLabelValues(method, route).Observe(float64(max(c.Writer.Size(), 0)))
+	}
+}
+
+// ObserveStatic counts a request served from the embedded frontend
+func (m *Metrics) ObserveStatic(code int) {
+	m.static.WithLabelValues(strconv.Itoa(code)).Inc()
+}
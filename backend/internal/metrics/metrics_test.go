@@ -0,0 +1,83 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+	"github.com/lRoccoon/utils-helper/internal/service"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func scrape(t *testing.T, m *Metrics) string {
+	t.Helper()
+	w := httptest.NewRecorder()
+	m.Handler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	assert.Equal(t, http.StatusOK, w.Code)
+	return w.Body.String()
+}
+
+func TestMiddlewareUsesRouteTemplates(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	m := New()
+	r := gin.New()
+	r.Use(m.Middleware())
+	r.GET("/api/holiday/:date", func(c *gin.Context) { c.String(http.StatusOK, "holiday") })
+	r.NoRoute(func(c *gin.Context) {
+		c.String(http.StatusNotFound, "missing")
+		m.ObserveStatic(c.Writer.Status())
+	})
+
+	for _, path := range []string{"/api/holiday/2024-01-01", "/api/holiday/2024-10-01", "/tools/subnet"} {
+		r.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, path, nil))
+	}
+
+	body := scrape(t, m)
+	for _, want := range []string{
+		`utils_helper_http_requests_total{code="200",method="GET",route="/api/holiday/:date"} 2`,
+		`utils_helper_http_requests_total{code="404",method="GET",route="unmatched"} 1`,
+		`utils_helper_http_request_duration_seconds_count{method="GET",route="/api/holiday/:date"} 2`,
+		`utils_helper_http_response_size_bytes_sum{method="GET",route="/api/holiday/:date"} 14`,
+		`utils_helper_http_requests_in_flight 0`,
+		`utils_helper_static_requests_total{code="404"} 1`,
+	} {
+		assert.Contains(t, body, want)
+	}
+	assert.NotContains(t, body, "2024-01-01", "raw paths must not become labels")
+}
+
+func TestRuntimeAndDatasetMetrics(t *testing.T) {
+	secret, key, err := service.GenerateAPIKey("ci", []string{"read:holiday"})
+	require.NoError(t, err)
+	store, err := service.NewAPIKeyStore("", []service.APIKey{key})
+	require.NoError(t, err)
+	service.SetAPIKeyStore(store)
+	t.Cleanup(func() { service.SetAPIKeyStore(nil) })
+	store.Authenticate(secret)
+
+	m := New()
+	body := scrape(t, m)
+	for _, want := range []string{
+		"go_goroutines ",
+		"go_memstats_heap_alloc_bytes ",
+		`utils_helper_holiday_dataset_entries{year="2024"} `,
+		"utils_helper_holiday_dataset_last_date_timestamp_seconds ",
+		`utils_helper_geo_cache_requests_total{result="hit"} `,
+		"utils_helper_geo_cache_entries ",
+		"utils_helper_api_key_reload_failures_total 0",
+	} {
+		assert.True(t, strings.Contains(body, want), "missing %q", want)
+	}
+
+	// Per-key counts are left to the admin listener, by ID only
+	assert.NotContains(t, body, "utils_helper_api_key_requests_total")
+	w := httptest.NewRecorder()
+	m.AdminHandler().ServeHTTP(w, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+	admin := w.Body.String()
+	assert.Contains(t, admin, `utils_helper_api_key_requests_total{key="`+key.ID+`"} 1`)
+	assert.Contains(t, admin, "go_goroutines ")
+	assert.NotContains(t, admin, `name="ci"`)
+}
@@ -0,0 +1,151 @@
+// Package ratelimit implements per-client token buckets and a concurrency
+// cap for the HTTP server.
+package ratelimit
+
+import (
+	"math"
+	"sync"
+	"time"
+)
+
+// Limit allows Requests per Period on average, with bursts of up to Burst
+// requests; a Burst of zero allows a whole period's requests at once
+type Limit struct {
+	Requests int
+	Period   time.Duration
+	Burst    int
+}
+
+// rate returns the refill rate in tokens per second
+func (l Limit) rate() float64 {
+	return float64(l.Requests) / l.Period.Seconds()
+}
+
+// burst returns the bucket size
+func (l Limit) burst() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+// Decision is the outcome of a request against a bucket, with the values of
+// the RateLimit response headers
+type Decision struct {
+	Allowed bool
+	// Limit is the bucket size
+	Limit int
+	// Remaining is the number of requests that may follow immediately
+	Remaining int
+	// Reset is the time until the bucket is full again
+	Reset time.Duration
+	// RetryAfter is the time until the next request is allowed, zero when
+	// this one was
+	RetryAfter time.Duration
+}
+
+type bucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// Limiter keeps a token bucket per key. Buckets that have been idle long
+// enough to be full again are dropped, since a new bucket starts full too.
+type Limiter struct {
+	limit       Limit
+	idleTimeout time.Duration
+	now         func() time.Time
+
+	mu        sync.Mutex
+	buckets   map[string]*bucket
+	lastSweep time.Time
+}
+
+// New creates a limiter. Buckets untouched for idleTimeout are evicted once
+// they have refilled.
+func New(limit Limit, idleTimeout time.Duration) *Limiter {
+	return &Limiter{
+		limit:       limit,
+		idleTimeout: idleTimeout,
+		now:         time.Now,
+		buckets:     make(map[string]*bucket),
+	}
+}
+
+// Allow takes a token from the bucket of key if one is left
+func (l *Limiter) Allow(key string) Decision {
+	rate, size := l.limit.rate(), float64(l.limit.burst())
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := l.now()
+	l.sweep(now, rate, size)
+
+	b, ok := l.buckets[key]
+	if !ok {
+		b = &bucket{tokens: size, last: now}
+		l.buckets[key] = b
+	}
+	b.tokens = math.Min(size, b.tokens+now.Sub(b.last).Seconds()*rate)
+	b.last = now
+
+	d := Decision{Limit: int(size)}
+	if b.tokens >= 1 {
+		b.tokens--
+		d.Allowed = true
+	} else {
+		d.RetryAfter = seconds((1 - b.tokens) / rate)
+	}
+	d.Remaining = int(b.tokens)
+	d.Reset = seconds((size - b.tokens) / rate)
+	return d
+}
+
+// Len returns the number of buckets held
+func (l *Limiter) Len() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.buckets)
+}
+
+// sweep evicts idle, full buckets at most once per idle timeout
+func (l *Limiter) sweep(now time.Time, rate, size float64) {
+	if now.Sub(l.lastSweep) < l.idleTimeout {
+		return
+	}
+	l.lastSweep = now
+	for key, b := range l.buckets {
+		idle := now.Sub(b.last)
+		if idle >= l.idleTimeout && b.tokens+idle.Seconds()*rate >= size {
+			delete(l.buckets, key)
+		}
+	}
+}
+
+func seconds(s float64) time.Duration {
+	return time.Duration(s * float64(time.Second))
+}
+
+// Semaphore caps the number of requests in progress
+type Semaphore chan struct{}
+
+// NewSemaphore allows up to n concurrent holders
+func NewSemaphore(n int) Semaphore {
+	return make(Semaphore, n)
+}
+
+// TryAcquire takes a slot without waiting, reporting whether one was free
+func (s Semaphore) TryAcquire() bool {
+	select {
+	case s <- struct{}{}:
+		return true
+	default:
+		return false
+	}
+}
+
+// Release frees a slot taken with TryAcquire
+func (s Semaphore) Release() {
+	<-s
+}
@@ -0,0 +1,101 @@
+package ratelimit
+
+import (
+	"testing"
+	"time"
+)
+
+// fakeClock is a manually advanced time source
+type fakeClock struct{ t time.Time }
+
+func (c *fakeClock) now() time.Time          { return c.t }
+func (c *fakeClock) advance(d time.Duration) { c.t = c.t.Add(d) }
+
+func newTestLimiter(limit Limit, idle time.Duration) (*Limiter, *fakeClock) {
+	clock := &fakeClock{t: time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)}
+	l := New(limit, idle)
+	l.now = clock.now
+	return l, clock
+}
+
+func TestLimiterTokenBucket(t *testing.T) {
+	// 60 per minute is one per second, with bursts of 3
+	l, clock := newTestLimiter(Limit{Requests: 60, Period: time.Minute, Burst: 3}, time.Hour)
+
+	for i := 2; i >= 0; i-- {
+		d := l.Allow("a")
+		if !d.Allowed || d.Remaining != i || d.Limit != 3 {
+			t.Fatalf("burst request = %+v, want allowed with %d remaining", d, i)
+		}
+	}
+	d := l.Allow("a")
+	if d.Allowed || d.RetryAfter != time.Second || d.Reset != 3*time.Second {
+		t.Errorf("over limit = %+v, want rejected, retry after 1s, reset in 3s", d)
+	}
+
+	// Other keys have their own bucket
+	if d := l.Allow("b"); !d.Allowed {
+		t.Error("second client was limited by the first")
+	}
+
+	clock.advance(1500 * time.Millisecond)
+	if d := l.Allow("a"); !d.Allowed || d.Remaining != 0 {
+		t.Errorf("after refill = %+v, want allowed with 0 remaining", d)
+	}
+	if d := l.Allow("a"); d.Allowed || d.RetryAfter != 500*time.Millisecond {
+		t.Errorf("half a token later = %+v, want retry after 500ms", d)
+	}
+
+	// The bucket never holds more than the burst
+	clock.advance(time.Hour)
+	if d := l.Allow("a"); d.Remaining != 2 {
+		t.Errorf("after an hour Remaining = %d, want 2", d.Remaining)
+	}
+}
+
+func TestLimiterDefaultBurst(t *testing.T) {
+	l, _ := newTestLimiter(Limit{Requests: 5, Period: time.Minute}, time.Hour)
+	allowed := 0
+	for i := 0; i < 10; i++ {
+		if l.Allow("a").Allowed {
+			allowed++
+		}
+	}
+	if allowed != 5 {
+		t.Errorf("allowed %d requests at once, want the whole quota of 5", allowed)
+	}
+}
+
+func TestLimiterEvictsIdleBuckets(t *testing.T) {
+	l, clock := newTestLimiter(Limit{Requests: 10, Period: time.Minute}, 5*time.Minute)
+	for i := 0; i < 10; i++ {
+		l.Allow("busy")
+	}
+	l.Allow("idle")
+	clock.advance(4 * time.Minute)
+	l.Allow("recent")
+
+	if l.Len() != 3 {
+		t.Fatalf("Len() = %d, want 3", l.Len())
+	}
+
+	clock.advance(2 * time.Minute)
+	l.Allow("recent")
+	if l.Len() != 1 {
+		t.Errorf("Len() = %d after the idle timeout, want only the recent client", l.Len())
+	}
+}
+
+func TestSemaphore(t *testing.T) {
+	s := NewSemaphore(2)
+	if !s.TryAcquire() || !s.TryAcquire() {
+		t.Fatal("could not take free slots")
+	}
+	if s.TryAcquire() {
+		t.Error("took a third slot of two")
+	}
+	s.Release()
+	if !s.TryAcquire() {
+		t.Error("released slot is not free")
+	}
+}
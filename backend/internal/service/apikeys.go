@@ -0,0 +1,410 @@
+package service
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// APIKeyPrefix starts every API key, so keys are recognisable in logs and
+// secret scanners and are not mistaken for other bearer tokens
+const APIKeyPrefix = "uh_"
+
+// ScopeAdmin grants every other scope
+const ScopeAdmin = "admin"
+
+// apiKeyReloadInterval is how often the key file is checked for changes
+// made by the keys command while the server runs
+const apiKeyReloadInterval = 5 * time.Second
+
+// ErrAPIKeyNotFound is returned for key IDs that do not exist
+var ErrAPIKeyNotFound = errors.New("API key not found")
+
+var (
+	apiKeyIDPattern   = regexp.MustCompile(`^[0-9a-f]{12}$`)
+	apiKeyHashPattern = regexp.MustCompile(`^sha256:[0-9a-f]{64}$`)
+	scopePattern      = regexp.MustCompile(`^(admin|(read|write):[a-z][a-z0-9_-]*)$`)
+)
+
+// APIKey describes a key without its secret. Only a SHA-256 hash of the key
+// is kept; keys carry 256 random bits, so a fast hash is enough.
+type APIKey struct {
+	ID      string    `json:"id" yaml:"id" toml:"id"`
+	Name    string    `json:"name" yaml:"name" toml:"name"`
+	Hash    string    `json:"hash" yaml:"hash" toml:"hash"`
+	Scopes  []string  `json:"scopes" yaml:"scopes" toml:"scopes"`
+	Created time.Time `json:"created,omitempty" yaml:"created,omitempty" toml:"created,omitempty"`
+}
+
+// HasScope reports whether the key grants scope. Admin keys grant every
+// scope and write:<module> implies read:<module>.
+func (k APIKey) HasScope(scope string) bool {
+	read, isRead := strings.CutPrefix(scope, "read:")
+	for _, s := range k.Scopes {
+		if s == scope || s == ScopeAdmin || (isRead && s == "write:"+read) {
+			return true
+		}
+	}
+	return false
+}
+
+// APIKeyUsage is an API key together with its usage since startup
+type APIKeyUsage struct {
+	APIKey
+	Requests uint64
+	LastUsed time.Time
+}
+
+type apiKeyCounter struct {
+	requests atomic.Uint64
+	lastUsed atomic.Int64
+}
+
+// ValidateScope checks a scope is admin, read:<module> or write:<module>
+func ValidateScope(scope string) error {
+	if !scopePattern.MatchString(scope) {
+		return fmt.Errorf("invalid scope %q, expected admin, read:<module> or write:<module>", scope)
+	}
+	return nil
+}
+
+// ValidateAPIKey checks the ID, hash and scopes of a stored key
+func ValidateAPIKey(key APIKey) error {
+	if !apiKeyIDPattern.MatchString(key.ID) {
+		return fmt.Errorf("invalid API key ID %q, expected 12 hexadecimal digits", key.ID)
+	}
+	if !apiKeyHashPattern.MatchString(key.Hash) {
+		return fmt.Errorf("API key %s: invalid hash, expected sha256:<64 hexadecimal digits>", key.ID)
+	}
+	if len(key.Scopes) == 0 {
+		return fmt.Errorf("API key %s: no scopes", key.ID)
+	}
+	for _, s := range key.Scopes {
+		if err := ValidateScope(s); err != nil {
+			return fmt.Errorf("API key %s: %w", key.ID, err)
+		}
+	}
+	return nil
+}
+
+// GenerateAPIKey creates a key with a random ID and secret. The returned
+// secret is the only copy of the key; the APIKey holds its hash.
+func GenerateAPIKey(name string, scopes []string) (string, APIKey, error) {
+	for _, s := range scopes {
+		if err := ValidateScope(s); err != nil {
+			return "", APIKey{}, err
+		}
+	}
+	if len(scopes) == 0 {
+		return "", APIKey{}, errors.New("an API key needs at least one scope")
+	}
+
+	id := make([]byte, 6)
+	secret := make([]byte, 32)
+	if _, err := rand.Read(id); err != nil {
+		return "", APIKey{}, err
+	}
+	if _, err := rand.Read(secret); err != nil {
+		return "", APIKey{}, err
+	}
+
+	key := APIKeyPrefix + hex.EncodeToString(id) + "_" + base64.RawURLEncoding.EncodeToString(secret)
+	return key, APIKey{
+		ID:      hex.EncodeToString(id),
+		Name:    name,
+		Hash:    HashAPIKey(key),
+		Scopes:  scopes,
+		Created: time.Now().UTC().Truncate(time.Second),
+	}, nil
+}
+
+// HashAPIKey returns the stored form of a key
+func HashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// apiKeyID extracts the ID from a key of the form uh_<id>_<secret>
+func apiKeyID(key string) (string, bool) {
+	rest, ok := strings.CutPrefix(key, APIKeyPrefix)
+	if !ok {
+		return "", false
+	}
+	id, _, ok := strings.Cut(rest, "_")
+	return id, ok && apiKeyIDPattern.MatchString(id)
+}
+
+// ReadAPIKeyFile reads the keys of a key file. A missing file holds no keys.
+func ReadAPIKeyFile(path string) ([]APIKey, error) {
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var file struct {
+		Keys []APIKey `json:"keys"`
+	}
+	if err := json.Unmarshal(data, &file); err != nil {
+		return nil, fmt.Errorf("invalid API key file %s: %w", path, err)
+	}
+	for _, key := range file.Keys {
+		if err := ValidateAPIKey(key); err != nil {
+			return nil, fmt.Errorf("API key file %s: %w", path, err)
+		}
+	}
+	return file.Keys, nil
+}
+
+// WriteAPIKeyFile writes the key file atomically through a temporary file,
+// readable by the owner only
+func WriteAPIKeyFile(path string, keys []APIKey) error {
+	sort.Slice(keys, func(i, j int) bool { return keys[i].ID < keys[j].ID })
+	data, err := json.MarshalIndent(struct {
+		Keys []APIKey `json:"keys"`
+	}{keys}, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".apikeys-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// RevokeAPIKey removes the key with the given ID from the key file
+func RevokeAPIKey(path, id string) error {
+	keys, err := ReadAPIKeyFile(path)
+	if err != nil {
+		return err
+	}
+	for i, key := range keys {
+		if key.ID == id {
+			return WriteAPIKeyFile(path, append(keys[:i], keys[i+1:]...))
+		}
+	}
+	return ErrAPIKeyNotFound
+}
+
+// APIKeyStore authenticates API keys from the configuration and a key file
+// and counts the requests made with each. Changes to the key file, such as
+// keys revoked with the keys command, are picked up within a few seconds.
+type APIKeyStore struct {
+	static []APIKey
+	path   string
+	now    func() time.Time
+
+	mu        sync.RWMutex
+	keys      map[string]APIKey
+	counters  map[string]*apiKeyCounter
+	modTime   time.Time
+	lastCheck time.Time
+
+	reloadFailures atomic.Uint64
+}
+
+// NewAPIKeyStore creates a store of the static keys and those in the key
+// file at path, if any. Key IDs must be unique across both.
+func NewAPIKeyStore(path string, static []APIKey) (*APIKeyStore, error) {
+	s := &APIKeyStore{
+		static:   static,
+		path:     path,
+		now:      time.Now,
+		counters: make(map[string]*apiKeyCounter),
+	}
+	if err := s.load(); err != nil {
+		return nil, err
+	}
+	s.lastCheck = s.now()
+	return s, nil
+}
+
+// load reads the key file and swaps in the keys; usage counters of keys
+// that remain are kept
+func (s *APIKeyStore) load() error {
+	var fileKeys []APIKey
+	var modTime time.Time
+	if s.path != "" {
+		if info, err := os.Stat(s.path); err == nil {
+			modTime = info.ModTime()
+		}
+		var err error
+		if fileKeys, err = ReadAPIKeyFile(s.path); err != nil {
+			return err
+		}
+	}
+
+	keys := make(map[string]APIKey, len(s.static)+len(fileKeys))
+	for _, key := range append(append([]APIKey(nil), s.static...), fileKeys...) {
+		if err := ValidateAPIKey(key); err != nil {
+			return err
+		}
+		if _, dup := keys[key.ID]; dup {
+			return fmt.Errorf("duplicate API key ID %s", key.ID)
+		}
+		keys[key.ID] = key
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	for id := range keys {
+		if s.counters[id] == nil {
+			s.counters[id] = &apiKeyCounter{}
+		}
+	}
+	for id := range s.counters {
+		if _, ok := keys[id]; !ok {
+			delete(s.counters, id)
+		}
+	}
+	s.keys = keys
+	s.modTime = modTime
+	return nil
+}
+
+// reloadIfChanged reloads the key file when its modification time changed,
+// checking at most once per reload interval. A file that fails to load
+// keeps the previous keys and is retried at the next check.
+func (s *APIKeyStore) reloadIfChanged() {
+	if s.path == "" {
+		return
+	}
+	now := s.now()
+	s.mu.Lock()
+	if now.Sub(s.lastCheck) < apiKeyReloadInterval {
+		s.mu.Unlock()
+		return
+	}
+	s.lastCheck = now
+	modTime := s.modTime
+	s.mu.Unlock()
+
+	var current time.Time
+	if info, err := os.Stat(s.path); err == nil {
+		current = info.ModTime()
+	}
+	if !current.Equal(modTime) {
+		if err := s.load(); err != nil {
+			s.reloadFailures.Add(1)
+			slog.Error("Failed to reload API key file, keeping the previous keys", "path", s.path, "error", err)
+		}
+	}
+}
+
+// Authenticate returns the key matching a presented key and counts the
+// request against it
+func (s *APIKeyStore) Authenticate(key string) (APIKey, bool) {
+	id, ok := apiKeyID(key)
+	if !ok {
+		return APIKey{}, false
+	}
+	s.reloadIfChanged()
+
+	s.mu.RLock()
+	stored, ok := s.keys[id]
+	counter := s.counters[id]
+	s.mu.RUnlock()
+	if !ok || subtle.ConstantTimeCompare([]byte(stored.Hash), []byte(HashAPIKey(key))) != 1 {
+		return APIKey{}, false
+	}
+
+	counter.requests.Add(1)
+	counter.lastUsed.Store(s.now().UnixNano())
+	return stored, true
+}
+
+// Len returns the number of keys
+func (s *APIKeyStore) Len() int {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	return len(s.keys)
+}
+
+// ReloadFailures returns how often reloading the key file failed
+func (s *APIKeyStore) ReloadFailures() uint64 {
+	return s.reloadFailures.Load()
+}
+
+// Usage returns every key with its usage, sorted by ID
+func (s *APIKeyStore) Usage() []APIKeyUsage {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	usage := make([]APIKeyUsage, 0, len(s.keys))
+	for id, key := range s.keys {
+		u := APIKeyUsage{APIKey: key, Requests: s.counters[id].requests.Load()}
+		if ns := s.counters[id].lastUsed.Load(); ns != 0 {
+			u.LastUsed = time.Unix(0, ns).UTC()
+		}
+		usage = append(usage, u)
+	}
+	sort.Slice(usage, func(i, j int) bool { return usage[i].ID < usage[j].ID })
+	return usage
+}
+
+var (
+	apiKeyStore   *APIKeyStore
+	apiKeyStoreMu sync.RWMutex
+)
+
+// ConfigureAPIKeys loads the keys used by GetAPIKeyStore
+func ConfigureAPIKeys(path string, static []APIKey) error {
+	store, err := NewAPIKeyStore(path, static)
+	if err != nil {
+		return err
+	}
+	SetAPIKeyStore(store)
+	return nil
+}
+
+// SetAPIKeyStore replaces the key store returned by GetAPIKeyStore
+func SetAPIKeyStore(store *APIKeyStore) {
+	apiKeyStoreMu.Lock()
+	apiKeyStore = store
+	apiKeyStoreMu.Unlock()
+}
+
+// GetAPIKeyStore returns the configured key store, or an empty store if
+// none was configured
+func GetAPIKeyStore() *APIKeyStore {
+	apiKeyStoreMu.RLock()
+	store := apiKeyStore
+	apiKeyStoreMu.RUnlock()
+	if store != nil {
+		return store
+	}
+
+	apiKeyStoreMu.Lock()
+	defer apiKeyStoreMu.Unlock()
+	if apiKeyStore == nil {
+		apiKeyStore, _ = NewAPIKeyStore("", nil)
+	}
+	return apiKeyStore
+}
@@ -0,0 +1,172 @@
+package service
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAPIKeyHasScope(t *testing.T) {
+	key := APIKey{Scopes: []string{"read:holiday", "write:ip"}}
+	tests := []struct {
+		scope string
+		want  bool
+	}{
+		{"read:holiday", true},
+		{"write:holiday", false},
+		{"write:ip", true},
+		{"read:ip", true},
+		{"read:dns", false},
+		{"admin", false},
+	}
+	for _, tt := range tests {
+		if got := key.HasScope(tt.scope); got != tt.want {
+			t.Errorf("HasScope(%q) = %v, want %v", tt.scope, got, tt.want)
+		}
+	}
+
+	admin := APIKey{Scopes: []string{ScopeAdmin}}
+	if !admin.HasScope("write:calendar") {
+		t.Error("admin HasScope(write:calendar) = false, want true")
+	}
+}
+
+func TestValidateScope(t *testing.T) {
+	for _, scope := range []string{"admin", "read:holiday", "write:calendar", "read:ip-sets"} {
+		if err := ValidateScope(scope); err != nil {
+			t.Errorf("ValidateScope(%q) error = %v", scope, err)
+		}
+	}
+	for _, scope := range []string{"", "root", "read", "read:", "delete:ip", "Read:holiday"} {
+		if err := ValidateScope(scope); err == nil {
+			t.Errorf("ValidateScope(%q) error = nil, want error", scope)
+		}
+	}
+}
+
+func TestAPIKeyStoreAuthenticate(t *testing.T) {
+	secret, key, err := GenerateAPIKey("ci", []string{"read:holiday"})
+	if err != nil {
+		t.Fatalf("GenerateAPIKey() error = %v", err)
+	}
+	if err := ValidateAPIKey(key); err != nil {
+		t.Fatalf("generated key invalid: %v", err)
+	}
+	if HashAPIKey(secret) != key.Hash {
+		t.Fatal("generated key hash does not match the secret")
+	}
+
+	store, err := NewAPIKeyStore("", []APIKey{key})
+	if err != nil {
+		t.Fatalf("NewAPIKeyStore() error = %v", err)
+	}
+
+	got, ok := store.Authenticate(secret)
+	if !ok || got.ID != key.ID || got.Name != "ci" {
+		t.Fatalf("Authenticate() = %+v, %v", got, ok)
+	}
+	for _, bad := range []string{"", "uh_", secret + "x", "uh_" + key.ID + "_wrong", "uh_000000000000_" + secret[16:]} {
+		if _, ok := store.Authenticate(bad); ok {
+			t.Errorf("Authenticate(%q) = true, want false", bad)
+		}
+	}
+
+	usage := store.Usage()
+	if len(usage) != 1 || usage[0].Requests != 1 || usage[0].LastUsed.IsZero() {
+		t.Errorf("Usage() = %+v, want one request", usage)
+	}
+}
+
+func TestAPIKeyStoreRejectsDuplicates(t *testing.T) {
+	_, key, err := GenerateAPIKey("a", []string{"admin"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewAPIKeyStore("", []APIKey{key, key}); err == nil {
+		t.Error("NewAPIKeyStore() with duplicate IDs error = nil, want error")
+	}
+}
+
+func TestAPIKeyFileReload(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apikeys.json")
+	secret, key, err := GenerateAPIKey("deploy", []string{"write:ip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteAPIKeyFile(path, []APIKey{key}); err != nil {
+		t.Fatalf("WriteAPIKeyFile() error = %v", err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Mode().Perm() != 0o600 {
+		t.Errorf("key file mode = %v, %v, want 0600", info.Mode().Perm(), err)
+	}
+
+	store, err := NewAPIKeyStore(path, nil)
+	if err != nil {
+		t.Fatalf("NewAPIKeyStore() error = %v", err)
+	}
+	now := time.Now()
+	store.now = func() time.Time { return now }
+	if _, ok := store.Authenticate(secret); !ok {
+		t.Fatal("Authenticate() with a key from the file = false")
+	}
+
+	if err := RevokeAPIKey(path, key.ID); err != nil {
+		t.Fatalf("RevokeAPIKey() error = %v", err)
+	}
+	if err := RevokeAPIKey(path, key.ID); !errors.Is(err, ErrAPIKeyNotFound) {
+		t.Errorf("second RevokeAPIKey() error = %v, want ErrAPIKeyNotFound", err)
+	}
+	// Make the change visible even on file systems with coarse timestamps
+	later := now.Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+
+	// The file is only checked once per reload interval
+	if _, ok := store.Authenticate(secret); !ok {
+		t.Error("Authenticate() before the reload interval = false, want true")
+	}
+	now = now.Add(apiKeyReloadInterval)
+	if _, ok := store.Authenticate(secret); ok {
+		t.Error("Authenticate() with a revoked key = true, want false")
+	}
+	if store.Len() != 0 {
+		t.Errorf("Len() = %d, want 0", store.Len())
+	}
+}
+
+func TestAPIKeyFileReloadFailure(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "apikeys.json")
+	secret, key, err := GenerateAPIKey("deploy", []string{"read:ip"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := WriteAPIKeyFile(path, []APIKey{key}); err != nil {
+		t.Fatal(err)
+	}
+	store, err := NewAPIKeyStore(path, nil)
+	if err != nil {
+		t.Fatalf("NewAPIKeyStore() error = %v", err)
+	}
+	now := time.Now()
+	store.now = func() time.Time { return now }
+
+	if err := os.WriteFile(path, []byte("{not json"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	later := now.Add(time.Minute)
+	if err := os.Chtimes(path, later, later); err != nil {
+		t.Fatal(err)
+	}
+	now = now.Add(apiKeyReloadInterval)
+
+	// A broken file keeps the previous keys and is counted
+	if _, ok := store.Authenticate(secret); !ok {
+		t.Error("Authenticate() after a failed reload = false, want true")
+	}
+	if n := store.ReloadFailures(); n != 1 {
+		t.Errorf("ReloadFailures() = %d, want 1", n)
+	}
+}
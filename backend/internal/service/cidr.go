@@ -0,0 +1,206 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"strings"
+)
+
+// maxSplitBits limits SplitCIDR to 2^12 (4096) subnets per call
+const maxSplitBits = 12
+
+// CIDRInfo represents the calculated details of a network prefix
+type CIDRInfo struct {
+	Prefix       string
+	Version      string
+	PrefixLength int
+	Network      string
+	Broadcast    string // IPv4 only
+	Netmask      string
+	Wildcard     string
+	FirstHost    string
+	LastHost     string
+	Addresses    *big.Int
+	UsableHosts  *big.Int
+}
+
+// ParseCIDR parses a prefix such as "10.0.0.0/22" or "2001:db8::/48".
+// A bare address is treated as a single-host prefix and host bits are masked off.
+func ParseCIDR(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return netip.Prefix{}, errors.New("prefix is required")
+	}
+
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid prefix %q", s)
+		}
+		addr = addr.Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid prefix %q", s)
+	}
+	if prefix.Addr().Is4In6() {
+		bits := prefix.Bits() - 96
+		if bits < 0 {
+			return netip.Prefix{}, fmt.Errorf("invalid prefix %q", s)
+		}
+		prefix = netip.PrefixFrom(prefix.Addr().Unmap(), bits)
+	}
+	return prefix.Masked(), nil
+}
+
+// GetCIDRInfo returns network details for a prefix
+func GetCIDRInfo(s string) (*CIDRInfo, error) {
+	prefix, err := ParseCIDR(s)
+	if err != nil {
+		return nil, err
+	}
+
+	network := prefix.Addr()
+	last := lastAddr(prefix)
+	bits := network.BitLen()
+	hostBits := bits - prefix.Bits()
+
+	info := &CIDRInfo{
+		Prefix:       prefix.String(),
+		PrefixLength: prefix.Bits(),
+		Network:      network.String(),
+		Netmask:      maskAddr(prefix.Bits(), bits).String(),
+		Wildcard:     hostMaskAddr(prefix.Bits(), bits).String(),
+		Addresses:    new(big.Int).Lsh(big.NewInt(1), uint(hostBits)),
+	}
+
+	if network.Is4() {
+		info.Version = "IPv4"
+		info.Broadcast = last.String()
+
+		switch {
+		case hostBits == 0:
+			// Single host route
+			info.FirstHost = network.String()
+			info.LastHost = network.String()
+			info.UsableHosts = big.NewInt(1)
+		case hostBits == 1:
+			// Point-to-point link (RFC 3021), both addresses are usable
+			info.FirstHost = network.String()
+			info.LastHost = last.String()
+			info.UsableHosts = big.NewInt(2)
+		default:
+			info.FirstHost = network.Next().String()
+			info.LastHost = last.Prev().String()
+			info.UsableHosts = new(big.Int).Sub(info.Addresses, big.NewInt(2))
+		}
+		return info, nil
+	}
+
+	// IPv6 has no broadcast address, every address in the prefix is assignable
+	info.Version = "IPv6"
+	info.FirstHost = network.String()
+	info.LastHost = last.String()
+	info.UsableHosts = new(big.Int).Set(info.Addresses)
+	return info, nil
+}
+
+// SplitCIDR splits a prefix into subnets of the given prefix length
+func SplitCIDR(s string, newBits int) ([]string, error) {
+	prefix, err := ParseCIDR(s)
+	if err != nil {
+		return nil, err
+	}
+
+	if newBits < prefix.Bits() || newBits > prefix.Addr().BitLen() {
+		return nil, fmt.Errorf("new prefix length must be between %d and %d", prefix.Bits(), prefix.Addr().BitLen())
+	}
+
+	diff := newBits - prefix.Bits()
+	if diff > maxSplitBits {
+		return nil, fmt.Errorf("splitting %s into /%d would exceed %d subnets", prefix, newBits, 1<<maxSplitBits)
+	}
+
+	subnets := make([]string, 0, 1<<diff)
+	addr := prefix.Addr()
+	for i := 0; i < 1<<diff; i++ {
+		subnet := netip.PrefixFrom(addr, newBits)
+		subnets = append(subnets, subnet.String())
+		addr = lastAddr(subnet).Next()
+	}
+	return subnets, nil
+}
+
+// CIDRContains reports whether the address is inside the prefix
+func CIDRContains(prefixStr, ipStr string) (bool, error) {
+	prefix, err := ParseCIDR(prefixStr)
+	if err != nil {
+		return false, err
+	}
+
+	addr, err := netip.ParseAddr(strings.TrimSpace(ipStr))
+	if err != nil {
+		return false, fmt.Errorf("invalid IP address %q", ipStr)
+	}
+
+	return prefix.Contains(addr.Unmap()), nil
+}
+
+// lastAddr returns the highest address in a prefix
+func lastAddr(prefix netip.Prefix) netip.Addr {
+	addr := prefix.Masked().Addr()
+	if addr.Is4() {
+		b := addr.As4()
+		setHostBits(b[:], prefix.Bits())
+		return netip.AddrFrom4(b)
+	}
+	b := addr.As16()
+	setHostBits(b[:], prefix.Bits())
+	return netip.AddrFrom16(b)
+}
+
+// maskAddr returns the netmask for a prefix length as an address
+func maskAddr(ones, bits int) netip.Addr {
+	b := make([]byte, bits/8)
+	for i := range b {
+		switch {
+		case ones >= 8:
+			b[i] = 0xff
+			ones -= 8
+		case ones > 0:
+			b[i] = ^byte(0xff >> ones)
+			ones = 0
+		}
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// hostMaskAddr returns the inverse of the netmask (the wildcard mask)
+func hostMaskAddr(ones, bits int) netip.Addr {
+	b := maskAddr(ones, bits).AsSlice()
+	for i := range b {
+		b[i] = ^b[i]
+	}
+	addr, _ := netip.AddrFromSlice(b)
+	return addr
+}
+
+// setHostBits sets every bit after the first ones bits
+func setHostBits(b []byte, ones int) {
+	for i := range b {
+		switch {
+		case ones >= 8:
+			ones -= 8
+		case ones > 0:
+			b[i] |= 0xff >> ones
+			ones = 0
+		default:
+			b[i] = 0xff
+		}
+	}
+}
@@ -0,0 +1,235 @@
+package service
+
+import (
+	"fmt"
+	"math/big"
+	"net/netip"
+	"sort"
+	"strings"
+)
+
+// MaxIPSetEntries limits how many entries NewIPSet accepts in one call
+const MaxIPSetEntries = 100000
+
+// addrRange is an inclusive range of addresses of one family
+type addrRange struct {
+	from, to netip.Addr
+}
+
+// IPSet is a set of IPv4 and IPv6 addresses, stored as sorted,
+// non-overlapping and non-adjacent ranges
+type IPSet struct {
+	ranges []addrRange
+}
+
+// NewIPSet builds a set from addresses, prefixes such as "10.0.0.0/8" and
+// ranges such as "10.0.0.1-10.0.0.9". Duplicate and overlapping entries are merged.
+func NewIPSet(entries []string) (*IPSet, error) {
+	if len(entries) > MaxIPSetEntries {
+		return nil, fmt.Errorf("too many entries (%d), the limit is %d", len(entries), MaxIPSetEntries)
+	}
+
+	ranges := make([]addrRange, 0, len(entries))
+	for _, entry := range entries {
+		r, err := parseAddrRange(entry)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, r)
+	}
+	return &IPSet{ranges: mergeRanges(ranges)}, nil
+}
+
+// ParseIPSetList splits a list of entries separated by newlines, commas or
+// spaces, ignoring blank lines and # comments, as found in vendor range files
+func ParseIPSetList(text string) []string {
+	var entries []string
+	for _, line := range strings.Split(text, "\n") {
+		if i := strings.IndexByte(line, '#'); i >= 0 {
+			line = line[:i]
+		}
+		entries = append(entries, strings.FieldsFunc(line, func(r rune) bool {
+			return r == ',' || r == ' ' || r == '\t' || r == '\r' || r == ';'
+		})...)
+	}
+	return entries
+}
+
+// Union returns the addresses in s or o
+func (s *IPSet) Union(o *IPSet) *IPSet {
+	ranges := make([]addrRange, 0, len(s.ranges)+len(o.ranges))
+	ranges = append(ranges, s.ranges...)
+	ranges = append(ranges, o.ranges...)
+	return &IPSet{ranges: mergeRanges(ranges)}
+}
+
+// Intersect returns the addresses in both s and o
+func (s *IPSet) Intersect(o *IPSet) *IPSet {
+	var out []addrRange
+	i, j := 0, 0
+	for i < len(s.ranges) && j < len(o.ranges) {
+		a, b := s.ranges[i], o.ranges[j]
+		from := maxAddr(a.from, b.from)
+		to := minAddr(a.to, b.to)
+		if from.Compare(to) <= 0 {
+			out = append(out, addrRange{from, to})
+		}
+		if a.to.Compare(b.to) < 0 {
+			i++
+		} else {
+			j++
+		}
+	}
+	return &IPSet{ranges: out}
+}
+
+// Subtract returns the addresses in s that are not in o
+func (s *IPSet) Subtract(o *IPSet) *IPSet {
+	var out []addrRange
+	j := 0
+	for _, r := range s.ranges {
+		// Skip exclusions entirely below this range
+		for j < len(o.ranges) && o.ranges[j].to.Compare(r.from) < 0 {
+			j++
+		}
+
+		cur, done := r.from, false
+		for k := j; k < len(o.ranges) && o.ranges[k].from.Compare(r.to) <= 0; k++ {
+			ex := o.ranges[k]
+			if ex.from.Compare(cur) > 0 {
+				out = append(out, addrRange{cur, ex.from.Prev()})
+			}
+			if ex.to.Compare(r.to) >= 0 {
+				done = true
+				break
+			}
+			if next := ex.to.Next(); next.Compare(cur) > 0 {
+				cur = next
+			}
+		}
+		if !done {
+			out = append(out, addrRange{cur, r.to})
+		}
+	}
+	return &IPSet{ranges: out}
+}
+
+// Prefixes returns the smallest list of prefixes covering exactly the set,
+// IPv4 first, each family in address order
+func (s *IPSet) Prefixes() []netip.Prefix {
+	var prefixes []netip.Prefix
+	for _, r := range s.ranges {
+		prefixes = append(prefixes, rangePrefixes(r)...)
+	}
+	return prefixes
+}
+
+// AddressCounts returns the number of IPv4 and IPv6 addresses in the set
+func (s *IPSet) AddressCounts() (v4, v6 *big.Int) {
+	v4, v6 = new(big.Int), new(big.Int)
+	for _, r := range s.ranges {
+		size := new(big.Int).Sub(addrBig(r.to), addrBig(r.from))
+		size.Add(size, big.NewInt(1))
+		if r.from.Is4() {
+			v4.Add(v4, size)
+		} else {
+			v6.Add(v6, size)
+		}
+	}
+	return v4, v6
+}
+
+// IsEmpty reports whether the set contains no addresses
+func (s *IPSet) IsEmpty() bool {
+	return len(s.ranges) == 0
+}
+
+// parseAddrRange parses an address, prefix or "from-to" range
+func parseAddrRange(entry string) (addrRange, error) {
+	entry = strings.TrimSpace(entry)
+	if fromStr, toStr, ok := strings.Cut(entry, "-"); ok {
+		from, err1 := netip.ParseAddr(strings.TrimSpace(fromStr))
+		to, err2 := netip.ParseAddr(strings.TrimSpace(toStr))
+		if err1 != nil || err2 != nil {
+			return addrRange{}, fmt.Errorf("invalid range %q", entry)
+		}
+		from, to = from.Unmap(), to.Unmap()
+		if from.Is4() != to.Is4() || from.Compare(to) > 0 {
+			return addrRange{}, fmt.Errorf("invalid range %q", entry)
+		}
+		return addrRange{from.WithZone(""), to.WithZone("")}, nil
+	}
+
+	prefix, err := ParseCIDR(entry)
+	if err != nil {
+		return addrRange{}, err
+	}
+	return addrRange{prefix.Addr(), lastAddr(prefix)}, nil
+}
+
+// mergeRanges sorts ranges and merges overlapping or adjacent ones
+func mergeRanges(ranges []addrRange) []addrRange {
+	if len(ranges) == 0 {
+		return nil
+	}
+	sort.Slice(ranges, func(i, j int) bool {
+		return ranges[i].from.Less(ranges[j].from)
+	})
+
+	out := []addrRange{ranges[0]}
+	for _, r := range ranges[1:] {
+		last := &out[len(out)-1]
+		if r.from.Compare(last.to) <= 0 || r.from == last.to.Next() {
+			if r.to.Compare(last.to) > 0 {
+				last.to = r.to
+			}
+			continue
+		}
+		out = append(out, r)
+	}
+	return out
+}
+
+// rangePrefixes splits a range into the fewest aligned prefixes
+func rangePrefixes(r addrRange) []netip.Prefix {
+	var prefixes []netip.Prefix
+	from := r.from
+	for {
+		// The shortest prefix starting at from that ends within the range
+		bits := from.BitLen()
+		for b := 0; b <= from.BitLen(); b++ {
+			p := netip.PrefixFrom(from, b)
+			if p.Masked().Addr() == from && lastAddr(p).Compare(r.to) <= 0 {
+				bits = b
+				break
+			}
+		}
+		p := netip.PrefixFrom(from, bits)
+		prefixes = append(prefixes, p)
+
+		last := lastAddr(p)
+		if last.Compare(r.to) >= 0 {
+			return prefixes
+		}
+		from = last.Next()
+	}
+}
+
+// addrBig returns an address as an unsigned integer
+func addrBig(addr netip.Addr) *big.Int {
+	return new(big.Int).SetBytes(addr.AsSlice())
+}
+
+func minAddr(a, b netip.Addr) netip.Addr {
+	if a.Compare(b) <= 0 {
+		return a
+	}
+	return b
+}
+
+func maxAddr(a, b netip.Addr) netip.Addr {
+	if a.Compare(b) >= 0 {
+		return a
+	}
+	return b
+}
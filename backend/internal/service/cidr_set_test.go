@@ -0,0 +1,171 @@
+package service
+
+import (
+	"net/netip"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+func prefixStrings(prefixes []netip.Prefix) []string {
+	out := make([]string, len(prefixes))
+	for i, p := range prefixes {
+		out[i] = p.String()
+	}
+	return out
+}
+
+func mustIPSet(t *testing.T, entries ...string) *IPSet {
+	t.Helper()
+	s, err := NewIPSet(entries)
+	if err != nil {
+		t.Fatalf("NewIPSet(%v) error = %v", entries, err)
+	}
+	return s
+}
+
+func TestIPSetAggregate(t *testing.T) {
+	tests := []struct {
+		name    string
+		entries []string
+		want    []string
+	}{
+		{
+			name:    "Adjacent prefixes merge",
+			entries: []string{"10.0.0.0/25", "10.0.0.128/25", "10.0.1.0/24"},
+			want:    []string{"10.0.0.0/23"},
+		},
+		{
+			name:    "Duplicates and covered prefixes are dropped",
+			entries: []string{"192.168.0.0/16", "192.168.1.0/24", "192.168.0.0/16", "192.168.5.5"},
+			want:    []string{"192.168.0.0/16"},
+		},
+		{
+			name:    "Unaligned range",
+			entries: []string{"10.0.0.1-10.0.0.6"},
+			want:    []string{"10.0.0.1/32", "10.0.0.2/31", "10.0.0.4/31", "10.0.0.6/32"},
+		},
+		{
+			name:    "Mixed families sorted IPv4 first",
+			entries: []string{"2001:db8:0:1::/64", "10.0.0.0/8", "2001:db8::/64", "::ffff:172.16.0.0/108"},
+			want:    []string{"10.0.0.0/8", "172.16.0.0/12", "2001:db8::/63"},
+		},
+		{
+			name:    "Adjacent across byte boundary",
+			entries: []string{"10.0.0.255", "10.0.1.0"},
+			want:    []string{"10.0.0.255/32", "10.0.1.0/32"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := prefixStrings(mustIPSet(t, tt.entries...).Prefixes())
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Prefixes() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPSetSubtract(t *testing.T) {
+	tests := []struct {
+		name    string
+		base    []string
+		exclude []string
+		want    []string
+	}{
+		{
+			name:    "Hole in the middle",
+			base:    []string{"10.0.0.0/24"},
+			exclude: []string{"10.0.0.128/26"},
+			want:    []string{"10.0.0.0/25", "10.0.0.192/26"},
+		},
+		{
+			name:    "Private ranges minus one host",
+			base:    []string{"192.168.0.0/30"},
+			exclude: []string{"192.168.0.1"},
+			want:    []string{"192.168.0.0/32", "192.168.0.2/31"},
+		},
+		{
+			name:    "Exclusion spans several ranges",
+			base:    []string{"10.0.0.0/24", "10.0.2.0/24", "2001:db8::/32"},
+			exclude: []string{"10.0.0.128-10.0.2.127"},
+			want:    []string{"10.0.0.0/25", "10.0.2.128/25", "2001:db8::/32"},
+		},
+		{
+			name:    "Exclusion covers everything",
+			base:    []string{"10.0.0.0/24"},
+			exclude: []string{"0.0.0.0/0"},
+			want:    []string{},
+		},
+		{
+			name:    "Top of the address space",
+			base:    []string{"255.255.255.0/24"},
+			exclude: []string{"255.255.255.255"},
+			want:    []string{"255.255.255.0/25", "255.255.255.128/26", "255.255.255.192/27", "255.255.255.224/28", "255.255.255.240/29", "255.255.255.248/30", "255.255.255.252/31", "255.255.255.254/32"},
+		},
+		{
+			name:    "Other family is untouched",
+			base:    []string{"10.0.0.0/8"},
+			exclude: []string{"::/0"},
+			want:    []string{"10.0.0.0/8"},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := prefixStrings(mustIPSet(t, tt.base...).Subtract(mustIPSet(t, tt.exclude...)).Prefixes())
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Subtract() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestIPSetIntersectAndUnion(t *testing.T) {
+	a := mustIPSet(t, "10.0.0.0/16", "2001:db8::/32")
+	b := mustIPSet(t, "10.0.255.0/24", "10.1.0.0/16", "2001:db8:ffff::/48", "192.0.2.0/24")
+
+	got := prefixStrings(a.Intersect(b).Prefixes())
+	want := []string{"10.0.255.0/24", "2001:db8:ffff::/48"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Intersect() = %v, want %v", got, want)
+	}
+
+	got = prefixStrings(a.Union(b).Prefixes())
+	want = []string{"10.0.0.0/15", "192.0.2.0/24", "2001:db8::/32"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("Union() = %v, want %v", got, want)
+	}
+}
+
+func TestIPSetAddressCounts(t *testing.T) {
+	v4, v6 := mustIPSet(t, "10.0.0.0/24", "10.0.0.0/25", "192.0.2.1", "2001:db8::/64").AddressCounts()
+	if v4.String() != "257" {
+		t.Errorf("IPv4 count = %s, want 257", v4)
+	}
+	if v6.String() != "18446744073709551616" {
+		t.Errorf("IPv6 count = %s, want 2^64", v6)
+	}
+}
+
+func TestNewIPSetErrors(t *testing.T) {
+	for _, entry := range []string{"10.0.0.0/33", "not-an-ip", "10.0.0.9-10.0.0.1", "10.0.0.1-2001:db8::1"} {
+		if _, err := NewIPSet([]string{entry}); err == nil {
+			t.Errorf("NewIPSet(%q) should fail", entry)
+		}
+	}
+}
+
+func TestParseIPSetList(t *testing.T) {
+	text := "# AWS ranges\n10.0.0.0/8, 172.16.0.0/12\n\n192.168.0.0/16 # office\r\n2001:db8::/32\t198.51.100.0/24\n"
+	got := ParseIPSetList(text)
+	want := []string{"10.0.0.0/8", "172.16.0.0/12", "192.168.0.0/16", "2001:db8::/32", "198.51.100.0/24"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("ParseIPSetList() = %v, want %v", got, want)
+	}
+
+	if got := ParseIPSetList(strings.Repeat(" \n", 3)); len(got) != 0 {
+		t.Errorf("ParseIPSetList(blank) = %v, want empty", got)
+	}
+}
@@ -0,0 +1,194 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestGetCIDRInfo(t *testing.T) {
+	tests := []struct {
+		name        string
+		prefix      string
+		wantErr     bool
+		network     string
+		broadcast   string
+		netmask     string
+		wildcard    string
+		firstHost   string
+		lastHost    string
+		addresses   string
+		usableHosts string
+	}{
+		{
+			name:        "IPv4 /22",
+			prefix:      "10.0.0.0/22",
+			network:     "10.0.0.0",
+			broadcast:   "10.0.3.255",
+			netmask:     "255.255.252.0",
+			wildcard:    "0.0.3.255",
+			firstHost:   "10.0.0.1",
+			lastHost:    "10.0.3.254",
+			addresses:   "1024",
+			usableHosts: "1022",
+		},
+		{
+			name:        "IPv4 host bits are masked",
+			prefix:      "192.168.1.77/24",
+			network:     "192.168.1.0",
+			broadcast:   "192.168.1.255",
+			netmask:     "255.255.255.0",
+			wildcard:    "0.0.0.255",
+			firstHost:   "192.168.1.1",
+			lastHost:    "192.168.1.254",
+			addresses:   "256",
+			usableHosts: "254",
+		},
+		{
+			name:        "IPv4 /31 point-to-point",
+			prefix:      "203.0.113.0/31",
+			network:     "203.0.113.0",
+			broadcast:   "203.0.113.1",
+			netmask:     "255.255.255.254",
+			wildcard:    "0.0.0.1",
+			firstHost:   "203.0.113.0",
+			lastHost:    "203.0.113.1",
+			addresses:   "2",
+			usableHosts: "2",
+		},
+		{
+			name:        "Bare IPv4 address",
+			prefix:      "8.8.8.8",
+			network:     "8.8.8.8",
+			broadcast:   "8.8.8.8",
+			netmask:     "255.255.255.255",
+			wildcard:    "0.0.0.0",
+			firstHost:   "8.8.8.8",
+			lastHost:    "8.8.8.8",
+			addresses:   "1",
+			usableHosts: "1",
+		},
+		{
+			name:        "IPv6 /64",
+			prefix:      "2001:db8:abcd:12::/64",
+			network:     "2001:db8:abcd:12::",
+			netmask:     "ffff:ffff:ffff:ffff::",
+			wildcard:    "::ffff:ffff:ffff:ffff",
+			firstHost:   "2001:db8:abcd:12::",
+			lastHost:    "2001:db8:abcd:12:ffff:ffff:ffff:ffff",
+			addresses:   "18446744073709551616",
+			usableHosts: "18446744073709551616",
+		},
+		{
+			name:        "IPv6 /0",
+			prefix:      "::/0",
+			network:     "::",
+			netmask:     "::",
+			wildcard:    "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+			firstHost:   "::",
+			lastHost:    "ffff:ffff:ffff:ffff:ffff:ffff:ffff:ffff",
+			addresses:   "340282366920938463463374607431768211456",
+			usableHosts: "340282366920938463463374607431768211456",
+		},
+		{
+			name:    "Invalid prefix",
+			prefix:  "10.0.0.0/33",
+			wantErr: true,
+		},
+		{
+			name:    "Empty prefix",
+			prefix:  "",
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := GetCIDRInfo(tt.prefix)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("GetCIDRInfo(%q) error = %v, wantErr %v", tt.prefix, err, tt.wantErr)
+			}
+			if tt.wantErr {
+				return
+			}
+
+			checks := []struct {
+				field, got, want string
+			}{
+				{"Network", got.Network, tt.network},
+				{"Broadcast", got.Broadcast, tt.broadcast},
+				{"Netmask", got.Netmask, tt.netmask},
+				{"Wildcard", got.Wildcard, tt.wildcard},
+				{"FirstHost", got.FirstHost, tt.firstHost},
+				{"LastHost", got.LastHost, tt.lastHost},
+				{"Addresses", got.Addresses.String(), tt.addresses},
+				{"UsableHosts", got.UsableHosts.String(), tt.usableHosts},
+			}
+			for _, c := range checks {
+				if c.got != c.want {
+					t.Errorf("GetCIDRInfo(%q).%s = %q, want %q", tt.prefix, c.field, c.got, c.want)
+				}
+			}
+		})
+	}
+}
+
+func TestSplitCIDR(t *testing.T) {
+	got, err := SplitCIDR("10.0.0.0/22", 24)
+	if err != nil {
+		t.Fatalf("SplitCIDR() returned error: %v", err)
+	}
+
+	want := []string{"10.0.0.0/24", "10.0.1.0/24", "10.0.2.0/24", "10.0.3.0/24"}
+	if len(got) != len(want) {
+		t.Fatalf("SplitCIDR() returned %d subnets, want %d", len(got), len(want))
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("SplitCIDR()[%d] = %s, want %s", i, got[i], want[i])
+		}
+	}
+
+	got, err = SplitCIDR("2001:db8::/47", 48)
+	if err != nil {
+		t.Fatalf("SplitCIDR() returned error: %v", err)
+	}
+	if len(got) != 2 || got[1] != "2001:db8:1::/48" {
+		t.Errorf("SplitCIDR() IPv6 = %v", got)
+	}
+
+	// Shorter prefix than the source, and too many subnets
+	if _, err := SplitCIDR("10.0.0.0/22", 16); err == nil {
+		t.Error("SplitCIDR() should reject a shorter prefix length")
+	}
+	if _, err := SplitCIDR("10.0.0.0/8", 32); err == nil {
+		t.Error("SplitCIDR() should reject splits over the subnet limit")
+	}
+}
+
+func TestCIDRContains(t *testing.T) {
+	tests := []struct {
+		prefix  string
+		ip      string
+		want    bool
+		wantErr bool
+	}{
+		{"10.0.0.0/22", "10.0.3.7", true, false},
+		{"10.0.0.0/22", "10.0.4.1", false, false},
+		{"2001:db8::/32", "2001:db8:ffff::1", true, false},
+		{"2001:db8::/32", "10.0.0.1", false, false},
+		{"10.0.0.0/8", "::ffff:10.1.2.3", true, false},
+		{"10.0.0.0/22", "not-an-ip", false, true},
+		{"bad", "10.0.0.1", false, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.prefix+" "+tt.ip, func(t *testing.T) {
+			got, err := CIDRContains(tt.prefix, tt.ip)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("CIDRContains() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got != tt.want {
+				t.Errorf("CIDRContains(%q, %q) = %v, want %v", tt.prefix, tt.ip, got, tt.want)
+			}
+		})
+	}
+}
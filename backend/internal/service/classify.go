@@ -0,0 +1,236 @@
+package service
+
+import (
+	"net/netip"
+)
+
+// Address categories reported by ClassifyIP
+const (
+	CategoryPublic        = "public"
+	CategoryUnspecified   = "unspecified"
+	CategoryThisNetwork   = "this-network"
+	CategoryLoopback      = "loopback"
+	CategoryPrivate       = "private"
+	CategoryShared        = "shared"
+	CategoryLinkLocal     = "link-local"
+	CategoryUniqueLocal   = "unique-local"
+	CategoryDocumentation = "documentation"
+	CategoryBenchmarking  = "benchmarking"
+	CategoryMulticast     = "multicast"
+	CategoryBroadcast     = "broadcast"
+	CategoryDiscard       = "discard-only"
+	CategoryTranslation   = "translation"
+	CategoryTunnel        = "tunnel"
+	CategoryAnycast       = "anycast"
+	CategoryProtocol      = "protocol-assignment"
+	CategoryReserved      = "reserved"
+)
+
+// AddressClass describes where an address falls in the IANA special-purpose registries
+type AddressClass struct {
+	Category       string
+	Name           string
+	Prefix         string
+	RFC            string
+	Forwardable    bool
+	Global         bool
+	MulticastScope string // multicast addresses only
+}
+
+// IsLocal reports whether the address only has meaning inside a private network or host
+func (a AddressClass) IsLocal() bool {
+	switch a.Category {
+	case CategoryLoopback, CategoryPrivate, CategoryShared, CategoryLinkLocal, CategoryUniqueLocal:
+		return true
+	}
+	return false
+}
+
+// specialBlock is one entry of an IANA special-purpose address registry
+type specialBlock struct {
+	prefix      netip.Prefix
+	category    string
+	name        string
+	rfc         string
+	forwardable bool
+	global      bool
+}
+
+func block(prefix, category, name, rfc string, forwardable, global bool) specialBlock {
+	return specialBlock{
+		prefix:      netip.MustParsePrefix(prefix),
+		category:    category,
+		name:        name,
+		rfc:         rfc,
+		forwardable: forwardable,
+		global:      global,
+	}
+}
+
+// specialBlocks mirrors the IANA IPv4 and IPv6 Special-Purpose Address Registries,
+// plus the multicast ranges that are allocated outside of them.
+// The most specific matching prefix wins.
+var specialBlocks = []specialBlock{
+	// IPv4 Special-Purpose Address Registry
+	block("0.0.0.0/8", CategoryThisNetwork, "This network", "RFC 791", false, false),
+	block("0.0.0.0/32", CategoryUnspecified, "This host on this network", "RFC 1122", false, false),
+	block("10.0.0.0/8", CategoryPrivate, "Private-Use", "RFC 1918", true, false),
+	block("100.64.0.0/10", CategoryShared, "Shared Address Space", "RFC 6598", true, false),
+	block("127.0.0.0/8", CategoryLoopback, "Loopback", "RFC 1122", false, false),
+	block("169.254.0.0/16", CategoryLinkLocal, "Link Local", "RFC 3927", false, false),
+	block("172.16.0.0/12", CategoryPrivate, "Private-Use", "RFC 1918", true, false),
+	block("192.0.0.0/24", CategoryProtocol, "IETF Protocol Assignments", "RFC 6890", false, false),
+	block("192.0.0.0/29", CategoryTranslation, "IPv4 Service Continuity Prefix", "RFC 7335", true, false),
+	block("192.0.0.8/32", CategoryProtocol, "IPv4 dummy address", "RFC 7600", false, false),
+	block("192.0.0.9/32", CategoryAnycast, "Port Control Protocol Anycast", "RFC 7723", true, true),
+	block("192.0.0.10/32", CategoryAnycast, "Traversal Using Relays around NAT Anycast", "RFC 8155", true, true),
+	block("192.0.0.170/32", CategoryTranslation, "NAT64/DNS64 Discovery", "RFC 8880", false, false),
+	block("192.0.0.171/32", CategoryTranslation, "NAT64/DNS64 Discovery", "RFC 8880", false, false),
+	block("192.0.2.0/24", CategoryDocumentation, "Documentation (TEST-NET-1)", "RFC 5737", false, false),
+	block("192.31.196.0/24", CategoryAnycast, "AS112-v4", "RFC 7535", true, true),
+	block("192.52.193.0/24", CategoryAnycast, "AMT", "RFC 7450", true, true),
+	block("192.88.99.0/24", CategoryReserved, "Deprecated (6to4 Relay Anycast)", "RFC 7526", false, false),
+	block("192.168.0.0/16", CategoryPrivate, "Private-Use", "RFC 1918", true, false),
+	block("192.175.48.0/24", CategoryAnycast, "Direct Delegation AS112 Service", "RFC 7534", true, true),
+	block("198.18.0.0/15", CategoryBenchmarking, "Benchmarking", "RFC 2544", true, false),
+	block("198.51.100.0/24", CategoryDocumentation, "Documentation (TEST-NET-2)", "RFC 5737", false, false),
+	block("203.0.113.0/24", CategoryDocumentation, "Documentation (TEST-NET-3)", "RFC 5737", false, false),
+	block("240.0.0.0/4", CategoryReserved, "Reserved", "RFC 1112", false, false),
+	block("255.255.255.255/32", CategoryBroadcast, "Limited Broadcast", "RFC 919", false, false),
+
+	// IPv4 Multicast Address Space Registry
+	block("224.0.0.0/4", CategoryMulticast, "Multicast", "RFC 5771", true, true),
+	block("224.0.0.0/24", CategoryMulticast, "Local Network Control Block", "RFC 5771", false, false),
+	block("224.0.1.0/24", CategoryMulticast, "Internetwork Control Block", "RFC 5771", true, true),
+	block("232.0.0.0/8", CategoryMulticast, "Source-Specific Multicast Block", "RFC 4607", true, true),
+	block("233.0.0.0/8", CategoryMulticast, "GLOP Block", "RFC 3180", true, true),
+	block("239.0.0.0/8", CategoryMulticast, "Administratively Scoped Block", "RFC 2365", true, false),
+
+	// IPv6 Special-Purpose Address Registry
+	block("::1/128", CategoryLoopback, "Loopback Address", "RFC 4291", false, false),
+	block("::/128", CategoryUnspecified, "Unspecified Address", "RFC 4291", false, false),
+	block("::ffff:0:0/96", CategoryTranslation, "IPv4-mapped Address", "RFC 4291", false, false),
+	block("64:ff9b::/96", CategoryTranslation, "IPv4-IPv6 Translation", "RFC 6052", true, true),
+	block("64:ff9b:1::/48", CategoryTranslation, "IPv4-IPv6 Translation", "RFC 8215", true, false),
+	block("100::/64", CategoryDiscard, "Discard-Only Address Block", "RFC 6666", true, false),
+	block("100:0:0:1::/64", CategoryReserved, "Dummy IPv6 Prefix", "RFC 9780", false, false),
+	block("2001::/23", CategoryProtocol, "IETF Protocol Assignments", "RFC 2928", false, false),
+	block("2001::/32", CategoryTunnel, "TEREDO", "RFC 4380", true, true),
+	block("2001:1::1/128", CategoryAnycast, "Port Control Protocol Anycast", "RFC 7723", true, true),
+	block("2001:1::2/128", CategoryAnycast, "Traversal Using Relays around NAT Anycast", "RFC 8155", true, true),
+	block("2001:1::3/128", CategoryAnycast, "DNS-SD Service Registration Protocol Anycast", "RFC 9665", true, true),
+	block("2001:2::/48", CategoryBenchmarking, "Benchmarking", "RFC 5180", true, false),
+	block("2001:3::/32", CategoryAnycast, "AMT", "RFC 7450", true, true),
+	block("2001:4:112::/48", CategoryAnycast, "AS112-v6", "RFC 7535", true, true),
+	block("2001:10::/28", CategoryReserved, "Deprecated (previously ORCHID)", "RFC 4843", false, false),
+	block("2001:20::/28", CategoryProtocol, "ORCHIDv2", "RFC 7343", true, true),
+	block("2001:30::/28", CategoryProtocol, "Drone Remote ID Protocol Entity Tags (DETs) Prefix", "RFC 9374", true, true),
+	block("2001:db8::/32", CategoryDocumentation, "Documentation", "RFC 3849", false, false),
+	block("2002::/16", CategoryTunnel, "6to4", "RFC 3056", true, true),
+	block("2620:4f:8000::/48", CategoryAnycast, "Direct Delegation AS112 Service", "RFC 7534", true, true),
+	block("3fff::/20", CategoryDocumentation, "Documentation", "RFC 9637", false, false),
+	block("5f00::/16", CategoryProtocol, "Segment Routing (SRv6) SIDs", "RFC 9602", true, false),
+	block("fc00::/7", CategoryUniqueLocal, "Unique-Local", "RFC 4193", true, false),
+	block("fe80::/10", CategoryLinkLocal, "Link-Local Unicast", "RFC 4291", false, false),
+
+	// IPv6 Multicast Address Space Registry
+	block("ff00::/8", CategoryMulticast, "Multicast", "RFC 4291", true, false),
+}
+
+// ipv6GlobalUnicast is the only IPv6 range currently allocated for global unicast
+var ipv6GlobalUnicast = netip.MustParsePrefix("2000::/3")
+
+// ipv6MulticastScopes maps the scope nibble of an IPv6 multicast address to its name (RFC 7346)
+var ipv6MulticastScopes = map[byte]string{
+	0x1: "interface-local",
+	0x2: "link-local",
+	0x3: "realm-local",
+	0x4: "admin-local",
+	0x5: "site-local",
+	0x8: "organization-local",
+	0xe: "global",
+}
+
+// ClassifyIP classifies an address against the IANA special-purpose registries.
+// It returns false if the address cannot be parsed.
+func ClassifyIP(ip string) (AddressClass, bool) {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return AddressClass{}, false
+	}
+	return ClassifyAddr(addr), true
+}
+
+// ClassifyAddr classifies a parsed address against the IANA special-purpose registries
+func ClassifyAddr(addr netip.Addr) AddressClass {
+	addr = addr.WithZone("")
+	if addr.Is4In6() {
+		addr = addr.Unmap()
+	}
+
+	var match *specialBlock
+	for i := range specialBlocks {
+		b := &specialBlocks[i]
+		if b.prefix.Contains(addr) && (match == nil || b.prefix.Bits() > match.prefix.Bits()) {
+			match = b
+		}
+	}
+
+	if match == nil {
+		if addr.Is6() && !ipv6GlobalUnicast.Contains(addr) {
+			return AddressClass{
+				Category: CategoryReserved,
+				Name:     "Reserved by IETF",
+				Prefix:   "::/0",
+				RFC:      "RFC 4291",
+			}
+		}
+		return AddressClass{
+			Category:    CategoryPublic,
+			Name:        "Global Unicast",
+			Forwardable: true,
+			Global:      true,
+		}
+	}
+
+	class := AddressClass{
+		Category:    match.category,
+		Name:        match.name,
+		Prefix:      match.prefix.String(),
+		RFC:         match.rfc,
+		Forwardable: match.forwardable,
+		Global:      match.global,
+	}
+
+	if match.category == CategoryMulticast {
+		class.MulticastScope = multicastScope(addr)
+		if addr.Is6() {
+			class.Global = class.MulticastScope == "global"
+		}
+	}
+	return class
+}
+
+// multicastScope returns the scope of a multicast address
+func multicastScope(addr netip.Addr) string {
+	if addr.Is6() {
+		scope, ok := ipv6MulticastScopes[addr.As16()[1]&0x0f]
+		if !ok {
+			return "reserved"
+		}
+		return scope
+	}
+
+	b := addr.As4()
+	switch {
+	case b[0] == 224 && b[1] == 0 && b[2] == 0:
+		return "link-local"
+	case b[0] == 239 && b[1] == 255:
+		return "site-local"
+	case b[0] == 239 && b[1] >= 192 && b[1] <= 195:
+		return "organization-local"
+	case b[0] == 239:
+		return "admin-local"
+	}
+	return "global"
+}
@@ -0,0 +1,112 @@
+package service
+
+import (
+	"testing"
+)
+
+func TestClassifyIP(t *testing.T) {
+	tests := []struct {
+		ip       string
+		category string
+		name     string
+		global   bool
+	}{
+		{"8.8.8.8", CategoryPublic, "Global Unicast", true},
+		{"0.0.0.0", CategoryUnspecified, "This host on this network", false},
+		{"0.1.2.3", CategoryThisNetwork, "This network", false},
+		{"10.1.2.3", CategoryPrivate, "Private-Use", false},
+		{"100.64.0.1", CategoryShared, "Shared Address Space", false},
+		{"100.127.255.254", CategoryShared, "Shared Address Space", false},
+		{"100.128.0.1", CategoryPublic, "Global Unicast", true},
+		{"127.0.0.1", CategoryLoopback, "Loopback", false},
+		{"169.254.10.20", CategoryLinkLocal, "Link Local", false},
+		{"192.0.0.9", CategoryAnycast, "Port Control Protocol Anycast", true},
+		{"192.0.0.100", CategoryProtocol, "IETF Protocol Assignments", false},
+		{"192.0.2.1", CategoryDocumentation, "Documentation (TEST-NET-1)", false},
+		{"198.19.0.1", CategoryBenchmarking, "Benchmarking", false},
+		{"203.0.113.1", CategoryDocumentation, "Documentation (TEST-NET-3)", false},
+		{"240.0.0.1", CategoryReserved, "Reserved", false},
+		{"255.255.255.255", CategoryBroadcast, "Limited Broadcast", false},
+		{"2001:4860:4860::8888", CategoryPublic, "Global Unicast", true},
+		{"::1", CategoryLoopback, "Loopback Address", false},
+		{"::", CategoryUnspecified, "Unspecified Address", false},
+		{"::ffff:192.168.1.1", CategoryPrivate, "Private-Use", false},
+		{"64:ff9b::808:808", CategoryTranslation, "IPv4-IPv6 Translation", true},
+		{"100::1", CategoryDiscard, "Discard-Only Address Block", false},
+		{"2001:0:4136:e378::1", CategoryTunnel, "TEREDO", true},
+		{"2001:2::1", CategoryBenchmarking, "Benchmarking", false},
+		{"2001:db8::1", CategoryDocumentation, "Documentation", false},
+		{"3fff::1", CategoryDocumentation, "Documentation", false},
+		{"2002:c000:221::1", CategoryTunnel, "6to4", true},
+		{"fd12:3456:789a::1", CategoryUniqueLocal, "Unique-Local", false},
+		{"fe80::1%eth0", CategoryLinkLocal, "Link-Local Unicast", false},
+		{"4000::1", CategoryReserved, "Reserved by IETF", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			got, ok := ClassifyIP(tt.ip)
+			if !ok {
+				t.Fatalf("ClassifyIP(%q) failed to parse", tt.ip)
+			}
+			if got.Category != tt.category || got.Name != tt.name || got.Global != tt.global {
+				t.Errorf("ClassifyIP(%q) = %s/%q (global %v), want %s/%q (global %v)",
+					tt.ip, got.Category, got.Name, got.Global, tt.category, tt.name, tt.global)
+			}
+		})
+	}
+}
+
+func TestClassifyMulticastScope(t *testing.T) {
+	tests := []struct {
+		ip     string
+		scope  string
+		global bool
+	}{
+		{"224.0.0.251", "link-local", false},
+		{"224.0.1.1", "global", true},
+		{"232.1.1.1", "global", true},
+		{"239.255.255.250", "site-local", false},
+		{"239.192.0.1", "organization-local", false},
+		{"ff02::1", "link-local", false},
+		{"ff05::1:3", "site-local", false},
+		{"ff0e::101", "global", true},
+		{"ff01::1", "interface-local", false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			got, _ := ClassifyIP(tt.ip)
+			if got.Category != CategoryMulticast {
+				t.Fatalf("ClassifyIP(%q).Category = %s, want multicast", tt.ip, got.Category)
+			}
+			if got.MulticastScope != tt.scope || got.Global != tt.global {
+				t.Errorf("ClassifyIP(%q) scope = %s (global %v), want %s (global %v)",
+					tt.ip, got.MulticastScope, got.Global, tt.scope, tt.global)
+			}
+		})
+	}
+}
+
+func TestClassifyIPInvalid(t *testing.T) {
+	if _, ok := ClassifyIP("not-an-ip"); ok {
+		t.Error("ClassifyIP() should fail for invalid input")
+	}
+}
+
+func TestAddressClassIsLocal(t *testing.T) {
+	for ip, want := range map[string]bool{
+		"10.0.0.1":    true,
+		"100.64.0.1":  true,
+		"fd00::1":     true,
+		"fe80::1":     true,
+		"192.0.2.1":   false,
+		"8.8.8.8":     false,
+		"224.0.0.251": false,
+	} {
+		got, _ := ClassifyIP(ip)
+		if got.IsLocal() != want {
+			t.Errorf("ClassifyIP(%q).IsLocal() = %v, want %v", ip, got.IsLocal(), want)
+		}
+	}
+}
@@ -0,0 +1,249 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"math/big"
+	"net/netip"
+	"strconv"
+	"strings"
+)
+
+var (
+	// nat64Prefix is the well-known NAT64 prefix (RFC 6052)
+	nat64Prefix = netip.MustParsePrefix("64:ff9b::/96")
+	// sixToFourPrefix is the 6to4 relay prefix (RFC 3056)
+	sixToFourPrefix = netip.MustParsePrefix("2002::/16")
+
+	maxIPv4 = new(big.Int).SetUint64(1<<32 - 1)
+	maxIPv6 = new(big.Int).Sub(new(big.Int).Lsh(big.NewInt(1), 128), big.NewInt(1))
+)
+
+// IPConversion represents an address in all of its common notations
+type IPConversion struct {
+	IP           string
+	Version      string
+	Expanded     string
+	Decimal      string
+	Hex          string
+	Binary       string
+	IPv4Mapped   string // IPv4 only
+	SixToFour    string // IPv4 only
+	NAT64        string // IPv4 only
+	PTR          string
+	EmbeddedIPv4 string // IPv6 only
+	Embedding    string // "ipv4-mapped", "6to4" or "nat64"
+}
+
+// ParseIPAny parses an address written in any notation supported by ConvertIP:
+// dotted or colon notation, decimal or 0x-prefixed hex integers, 0b-prefixed,
+// dotted or colon-grouped binary, and in-addr.arpa / ip6.arpa reverse DNS names.
+func ParseIPAny(s string) (netip.Addr, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return netip.Addr{}, errors.New("ip is required")
+	}
+
+	lower := strings.ToLower(strings.TrimSuffix(s, "."))
+	switch {
+	case strings.HasSuffix(lower, ".in-addr.arpa"), strings.HasSuffix(lower, ".ip6.arpa"):
+		return parsePTR(lower)
+	case strings.HasPrefix(lower, "0x"):
+		return parseIPInteger(lower[2:], 16, s)
+	case strings.HasPrefix(lower, "0b"):
+		return parseIPInteger(lower[2:], 2, s)
+	case isBinaryGroups(lower, ".", 4, 8):
+		return parseIPInteger(strings.ReplaceAll(lower, ".", ""), 2, s)
+	case isBinaryGroups(lower, ":", 8, 16):
+		return parseIPInteger(strings.ReplaceAll(lower, ":", ""), 2, s)
+	case isDigits(lower):
+		return parseIPInteger(lower, 10, s)
+	}
+
+	addr, err := netip.ParseAddr(s)
+	if err != nil {
+		return netip.Addr{}, fmt.Errorf("invalid IP address %q", s)
+	}
+	return addr.WithZone(""), nil
+}
+
+// ConvertIP returns every supported representation of an address
+func ConvertIP(s string) (*IPConversion, error) {
+	addr, err := ParseIPAny(s)
+	if err != nil {
+		return nil, err
+	}
+
+	value := new(big.Int).SetBytes(addr.AsSlice())
+	conv := &IPConversion{
+		IP:      addr.String(),
+		Decimal: value.String(),
+		PTR:     ReversePTR(addr),
+	}
+
+	if addr.Is4() {
+		b := addr.As4()
+		conv.Version = "IPv4"
+		conv.Hex = fmt.Sprintf("0x%08x", value)
+		conv.Binary = binaryGroups(b[:], 1, ".")
+		conv.IPv4Mapped = netip.AddrFrom16(addr.As16()).String()
+		conv.SixToFour = netip.PrefixFrom(netip.AddrFrom16([16]byte{0x20, 0x02, b[0], b[1], b[2], b[3]}), 48).String()
+
+		nat := nat64Prefix.Addr().As16()
+		copy(nat[12:], b[:])
+		conv.NAT64 = netip.AddrFrom16(nat).String()
+		return conv, nil
+	}
+
+	b := addr.As16()
+	conv.Version = "IPv6"
+	conv.Expanded = addr.StringExpanded()
+	conv.Hex = fmt.Sprintf("0x%032x", value)
+	conv.Binary = binaryGroups(b[:], 2, ":")
+
+	switch {
+	case addr.Is4In6():
+		conv.EmbeddedIPv4 = addr.Unmap().String()
+		conv.Embedding = "ipv4-mapped"
+	case sixToFourPrefix.Contains(addr):
+		conv.EmbeddedIPv4 = netip.AddrFrom4([4]byte{b[2], b[3], b[4], b[5]}).String()
+		conv.Embedding = "6to4"
+	case nat64Prefix.Contains(addr):
+		conv.EmbeddedIPv4 = netip.AddrFrom4([4]byte{b[12], b[13], b[14], b[15]}).String()
+		conv.Embedding = "nat64"
+	}
+	return conv, nil
+}
+
+// ReversePTR returns the reverse DNS name for an address
+func ReversePTR(addr netip.Addr) string {
+	var sb strings.Builder
+	if addr.Is4() {
+		b := addr.As4()
+		for i := len(b) - 1; i >= 0; i-- {
+			sb.WriteString(strconv.Itoa(int(b[i])))
+			sb.WriteByte('.')
+		}
+		sb.WriteString("in-addr.arpa")
+		return sb.String()
+	}
+
+	const hexDigits = "0123456789abcdef"
+	b := addr.As16()
+	for i := len(b) - 1; i >= 0; i-- {
+		sb.WriteByte(hexDigits[b[i]&0x0f])
+		sb.WriteByte('.')
+		sb.WriteByte(hexDigits[b[i]>>4])
+		sb.WriteByte('.')
+	}
+	sb.WriteString("ip6.arpa")
+	return sb.String()
+}
+
+// parsePTR parses an in-addr.arpa or ip6.arpa name back into an address
+func parsePTR(name string) (netip.Addr, error) {
+	if strings.HasSuffix(name, ".in-addr.arpa") {
+		labels := strings.Split(strings.TrimSuffix(name, ".in-addr.arpa"), ".")
+		if len(labels) != 4 {
+			return netip.Addr{}, fmt.Errorf("invalid in-addr.arpa name %q", name)
+		}
+		var b [4]byte
+		for i, label := range labels {
+			n, err := strconv.ParseUint(label, 10, 8)
+			if err != nil {
+				return netip.Addr{}, fmt.Errorf("invalid in-addr.arpa name %q", name)
+			}
+			b[3-i] = byte(n)
+		}
+		return netip.AddrFrom4(b), nil
+	}
+
+	labels := strings.Split(strings.TrimSuffix(name, ".ip6.arpa"), ".")
+	if len(labels) != 32 {
+		return netip.Addr{}, fmt.Errorf("invalid ip6.arpa name %q", name)
+	}
+	var b [16]byte
+	for i, label := range labels {
+		n, err := strconv.ParseUint(label, 16, 4)
+		if err != nil || len(label) != 1 {
+			return netip.Addr{}, fmt.Errorf("invalid ip6.arpa name %q", name)
+		}
+		pos := 31 - i
+		if pos%2 == 0 {
+			b[pos/2] |= byte(n) << 4
+		} else {
+			b[pos/2] |= byte(n)
+		}
+	}
+	return netip.AddrFrom16(b), nil
+}
+
+// parseIPInteger converts an integer in the given base into an address.
+// Hex and binary numbers are IPv6 when written with more digits than an
+// IPv4 address has, so the zero-padded output of ConvertIP parses back into
+// the same family. Decimal numbers carry no width: values that fit in 32 bits
+// are IPv4, larger values up to 128 bits are IPv6.
+func parseIPInteger(digits string, base int, input string) (netip.Addr, error) {
+	value, ok := new(big.Int).SetString(digits, base)
+	if !ok || value.Sign() < 0 || value.Cmp(maxIPv6) > 0 {
+		return netip.Addr{}, fmt.Errorf("invalid IP address %q", input)
+	}
+
+	is4 := value.Cmp(maxIPv4) <= 0
+	switch base {
+	case 16:
+		is4 = len(digits) <= 8
+	case 2:
+		is4 = len(digits) <= 32
+	}
+	if is4 {
+		var b [4]byte
+		value.FillBytes(b[:])
+		return netip.AddrFrom4(b), nil
+	}
+
+	var b [16]byte
+	value.FillBytes(b[:])
+	return netip.AddrFrom16(b), nil
+}
+
+// binaryGroups formats bytes as binary digits, joining every size bytes with sep
+func binaryGroups(b []byte, size int, sep string) string {
+	groups := make([]string, 0, len(b)/size)
+	for i := 0; i < len(b); i += size {
+		var sb strings.Builder
+		for _, v := range b[i : i+size] {
+			fmt.Fprintf(&sb, "%08b", v)
+		}
+		groups = append(groups, sb.String())
+	}
+	return strings.Join(groups, sep)
+}
+
+// isBinaryGroups reports whether s is n sep-separated groups of size binary
+// digits, the form binaryGroups writes
+func isBinaryGroups(s, sep string, n, size int) bool {
+	parts := strings.Split(s, sep)
+	if len(parts) != n {
+		return false
+	}
+	for _, p := range parts {
+		if len(p) != size || strings.Trim(p, "01") != "" {
+			return false
+		}
+	}
+	return true
+}
+
+// isDigits reports whether s consists only of decimal digits
+func isDigits(s string) bool {
+	if s == "" {
+		return false
+	}
+	for _, r := range s {
+		if r < '0' || r > '9' {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,169 @@
+package service
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestParseIPAny(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		want    string
+		wantErr bool
+	}{
+		{"Dotted IPv4", "10.0.0.1", "10.0.0.1", false},
+		{"Compressed IPv6", "2001:db8::1", "2001:db8::1", false},
+		{"Expanded IPv6", "2001:0db8:0000:0000:0000:0000:0000:0001", "2001:db8::1", false},
+		{"Decimal IPv4", "167772161", "10.0.0.1", false},
+		{"Decimal IPv6", "42540766411282592856903984951653826561", "2001:db8::1", false},
+		{"Hex IPv4", "0x0A000001", "10.0.0.1", false},
+		{"Hex IPv6", "0x20010db8000000000000000000000001", "2001:db8::1", false},
+		{"Prefixed binary", "0b00001010000000000000000000000001", "10.0.0.1", false},
+		{"Dotted binary", "00001010.00000000.00000000.00000001", "10.0.0.1", false},
+		{"Padded hex IPv6", "0x00000000000000000000000000000001", "::1", false},
+		{"Short hex IPv4", "0x1", "0.0.0.1", false},
+		{"Grouped binary IPv6", "0000000000000000:0000000000000000:0000000000000000:0000000000000000:0000000000000000:0000000000000000:0000000000000000:0000000000000001", "::1", false},
+		{"IPv4-mapped IPv6", "::ffff:10.0.0.1", "::ffff:10.0.0.1", false},
+		{"in-addr.arpa", "1.0.0.10.in-addr.arpa.", "10.0.0.1", false},
+		{"ip6.arpa", "1.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.0.8.b.d.0.1.0.0.2.ip6.arpa", "2001:db8::1", false},
+		{"Zone is dropped", "fe80::1%eth0", "fe80::1", false},
+		{"Empty", "", "", true},
+		{"Garbage", "not-an-ip", "", true},
+		{"Integer too large", "0x1" + "00000000000000000000000000000000", "", true},
+		{"Short in-addr.arpa", "0.10.in-addr.arpa", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := ParseIPAny(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseIPAny(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if !tt.wantErr && got.String() != tt.want {
+				t.Errorf("ParseIPAny(%q) = %s, want %s", tt.input, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestConvertIPv4(t *testing.T) {
+	got, err := ConvertIP("192.0.2.33")
+	if err != nil {
+		t.Fatalf("ConvertIP() returned error: %v", err)
+	}
+
+	checks := map[string][2]string{
+		"Version":    {got.Version, "IPv4"},
+		"Decimal":    {got.Decimal, "3221226017"},
+		"Hex":        {got.Hex, "0xc0000221"},
+		"Binary":     {got.Binary, "11000000.00000000.00000010.00100001"},
+		"IPv4Mapped": {got.IPv4Mapped, "::ffff:192.0.2.33"},
+		"SixToFour":  {got.SixToFour, "2002:c000:221::/48"},
+		"NAT64":      {got.NAT64, "64:ff9b::c000:221"},
+		"PTR":        {got.PTR, "33.2.0.192.in-addr.arpa"},
+	}
+	for field, c := range checks {
+		if c[0] != c[1] {
+			t.Errorf("ConvertIP().%s = %q, want %q", field, c[0], c[1])
+		}
+	}
+}
+
+func TestConvertIPv6(t *testing.T) {
+	got, err := ConvertIP("2001:db8::1")
+	if err != nil {
+		t.Fatalf("ConvertIP() returned error: %v", err)
+	}
+
+	if got.Expanded != "2001:0db8:0000:0000:0000:0000:0000:0001" {
+		t.Errorf("ConvertIP().Expanded = %q", got.Expanded)
+	}
+	if got.Hex != "0x20010db8000000000000000000000001" {
+		t.Errorf("ConvertIP().Hex = %q", got.Hex)
+	}
+	if got.Binary[:19] != "0010000000000001:00" {
+		t.Errorf("ConvertIP().Binary = %q", got.Binary)
+	}
+	if got.IPv4Mapped != "" || got.Embedding != "" {
+		t.Errorf("ConvertIP() should not report IPv4 fields for a plain IPv6 address")
+	}
+
+	// PTR names round-trip
+	addr, err := ParseIPAny(got.PTR)
+	if err != nil || addr != netip.MustParseAddr("2001:db8::1") {
+		t.Errorf("ParseIPAny(%q) = %v, %v", got.PTR, addr, err)
+	}
+}
+
+func TestConvertEmbeddedIPv4(t *testing.T) {
+	tests := []struct {
+		input     string
+		embedded  string
+		embedding string
+	}{
+		{"::ffff:10.1.2.3", "10.1.2.3", "ipv4-mapped"},
+		{"2002:c000:221::1", "192.0.2.33", "6to4"},
+		{"64:ff9b::c000:221", "192.0.2.33", "nat64"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ConvertIP(tt.input)
+			if err != nil {
+				t.Fatalf("ConvertIP() returned error: %v", err)
+			}
+			if got.EmbeddedIPv4 != tt.embedded || got.Embedding != tt.embedding {
+				t.Errorf("ConvertIP(%q) embedded = %s (%s), want %s (%s)",
+					tt.input, got.EmbeddedIPv4, got.Embedding, tt.embedded, tt.embedding)
+			}
+		})
+	}
+}
+
+func TestConvertIPRoundTrip(t *testing.T) {
+	// IPv6 addresses in ::/96 have decimal values that read as IPv4
+	low := netip.MustParsePrefix("::/96")
+	for _, input := range []string{"192.0.2.33", "0.0.0.1", "2001:db8::1", "::1", "::ffff:10.1.2.3", "fe80::1:2"} {
+		t.Run(input, func(t *testing.T) {
+			want := netip.MustParseAddr(input)
+			got, err := ConvertIP(input)
+			if err != nil {
+				t.Fatalf("ConvertIP() returned error: %v", err)
+			}
+
+			fields := map[string]string{
+				"IP":     got.IP,
+				"Hex":    got.Hex,
+				"Binary": got.Binary,
+				"PTR":    got.PTR,
+			}
+			if got.Expanded != "" {
+				fields["Expanded"] = got.Expanded
+			}
+			if want.Is4() || !low.Contains(want) {
+				fields["Decimal"] = got.Decimal
+			}
+			for field, value := range fields {
+				addr, err := ParseIPAny(value)
+				if err != nil || addr != want {
+					t.Errorf("ParseIPAny(%s %q) = %v, %v, want %s", field, value, addr, err, want)
+				}
+			}
+
+			// The IPv6 embeddings of an IPv4 address lead back to it
+			for field, value := range map[string]string{"IPv4Mapped": got.IPv4Mapped, "NAT64": got.NAT64} {
+				if value == "" {
+					continue
+				}
+				embedded, err := ConvertIP(value)
+				if err != nil {
+					t.Fatalf("ConvertIP(%s %q) returned error: %v", field, value, err)
+				}
+				if embedded.EmbeddedIPv4 != want.String() {
+					t.Errorf("ConvertIP(%s %q) embeds %q, want %s", field, value, embedded.EmbeddedIPv4, want)
+				}
+			}
+		})
+	}
+}
@@ -0,0 +1,661 @@
+package service
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// Supported DNS transports
+const (
+	DNSProtocolUDP = "udp"
+	DNSProtocolTCP = "tcp"
+	DNSProtocolDoT = "dot"
+	DNSProtocolDoH = "doh"
+)
+
+// DefaultDNSTimeout bounds a single DNS lookup, including a TCP retry
+const DefaultDNSTimeout = 5 * time.Second
+
+const (
+	// dnsUDPPayloadSize is the EDNS0 buffer size recommended by DNS Flag Day 2020
+	dnsUDPPayloadSize = 1232
+	// fallbackDNSServer is used when /etc/resolv.conf lists no nameserver
+	fallbackDNSServer = "1.1.1.1:53"
+	// typeCAA is not defined by dnsmessage
+	typeCAA dnsmessage.Type = 257
+)
+
+// ErrInvalidDNSQuery is returned for names or record types that cannot be queried
+var ErrInvalidDNSQuery = errors.New("invalid DNS query")
+
+// dnsQueryTypes maps the record types /api/dns accepts to their wire values
+var dnsQueryTypes = map[string]dnsmessage.Type{
+	"A":     dnsmessage.TypeA,
+	"AAAA":  dnsmessage.TypeAAAA,
+	"CNAME": dnsmessage.TypeCNAME,
+	"MX":    dnsmessage.TypeMX,
+	"TXT":   dnsmessage.TypeTXT,
+	"NS":    dnsmessage.TypeNS,
+	"SOA":   dnsmessage.TypeSOA,
+	"SRV":   dnsmessage.TypeSRV,
+	"CAA":   typeCAA,
+	"PTR":   dnsmessage.TypePTR,
+}
+
+// dnsRCodeNames are the conventional names of response codes, as printed by dig
+var dnsRCodeNames = map[dnsmessage.RCode]string{
+	dnsmessage.RCodeSuccess:        "NOERROR",
+	dnsmessage.RCodeFormatError:    "FORMERR",
+	dnsmessage.RCodeServerFailure:  "SERVFAIL",
+	dnsmessage.RCodeNameError:      "NXDOMAIN",
+	dnsmessage.RCodeNotImplemented: "NOTIMP",
+	dnsmessage.RCodeRefused:        "REFUSED",
+}
+
+// DNSUpstream is a resolver address and the transport used to reach it
+type DNSUpstream struct {
+	Protocol string
+	Address  string // host:port, or the DoH URL
+}
+
+// String returns the upstream in the form accepted by ParseDNSUpstream
+func (u DNSUpstream) String() string {
+	switch u.Protocol {
+	case DNSProtocolDoH:
+		return u.Address
+	case DNSProtocolDoT:
+		return "tls://" + u.Address
+	default:
+		return u.Protocol + "://" + u.Address
+	}
+}
+
+// ParseDNSUpstream parses a resolver address such as "1.1.1.1",
+// "tcp://1.1.1.1:53", "tls://dns.google" or "https://dns.google/dns-query".
+// Bare addresses use UDP; ports default to 53, or 853 for DNS over TLS.
+func ParseDNSUpstream(s string) (DNSUpstream, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return DNSUpstream{}, errors.New("empty DNS upstream")
+	}
+
+	scheme, rest, found := strings.Cut(s, "://")
+	if !found {
+		scheme, rest = DNSProtocolUDP, s
+	}
+
+	var u DNSUpstream
+	port := "53"
+	switch strings.ToLower(scheme) {
+	case "udp":
+		u.Protocol = DNSProtocolUDP
+	case "tcp":
+		u.Protocol = DNSProtocolTCP
+	case "tls", "dot":
+		u.Protocol = DNSProtocolDoT
+		port = "853"
+	case "https":
+		parsed, err := url.Parse(s)
+		if err != nil || parsed.Host == "" {
+			return DNSUpstream{}, fmt.Errorf("invalid DNS over HTTPS URL %q", s)
+		}
+		return DNSUpstream{Protocol: DNSProtocolDoH, Address: parsed.String()}, nil
+	default:
+		return DNSUpstream{}, fmt.Errorf("unsupported DNS upstream scheme %q", scheme)
+	}
+
+	rest = strings.TrimSuffix(rest, "/")
+	if rest == "" {
+		return DNSUpstream{}, fmt.Errorf("missing host in DNS upstream %q", s)
+	}
+	if _, _, err := net.SplitHostPort(rest); err != nil {
+		// Bare host or IPv6 literal without a port
+		rest = net.JoinHostPort(strings.Trim(rest, "[]"), port)
+	}
+	u.Address = rest
+	return u, nil
+}
+
+// DNSRecord is one resource record from a DNS response. Data holds the
+// record in zone-file presentation form; the typed fields are set for the
+// record types they apply to.
+type DNSRecord struct {
+	Name string
+	Type string
+	TTL  uint32
+	Data string
+
+	Address    string   // A, AAAA
+	Target     string   // CNAME, NS, PTR, MX, SRV
+	Preference uint16   // MX
+	Priority   uint16   // SRV
+	Weight     uint16   // SRV
+	Port       uint16   // SRV
+	Text       []string // TXT
+	SOA        *DNSSOA
+	CAA        *DNSCAA
+}
+
+// DNSSOA holds the fields of an SOA record
+type DNSSOA struct {
+	NS      string
+	Mbox    string
+	Serial  uint32
+	Refresh uint32
+	Retry   uint32
+	Expire  uint32
+	MinTTL  uint32
+}
+
+// DNSCAA holds the fields of a CAA record
+type DNSCAA struct {
+	Flag  uint8
+	Tag   string
+	Value string
+}
+
+// DNSResult is the outcome of a DNS lookup
+type DNSResult struct {
+	Name               string
+	Type               string
+	Server             string
+	Protocol           string
+	RCode              string
+	Authoritative      bool
+	RecursionAvailable bool
+	Truncated          bool
+	Answers            []DNSRecord
+	Authority          []DNSRecord
+	Duration           time.Duration
+}
+
+// DNSResolver sends queries to a single upstream resolver
+type DNSResolver struct {
+	upstream   DNSUpstream
+	timeout    time.Duration
+	tlsConfig  *tls.Config
+	httpClient *http.Client
+}
+
+// NewDNSResolver creates a resolver for an upstream accepted by ParseDNSUpstream
+func NewDNSResolver(upstream string, timeout time.Duration) (*DNSResolver, error) {
+	u, err := ParseDNSUpstream(upstream)
+	if err != nil {
+		return nil, err
+	}
+	if timeout <= 0 {
+		timeout = DefaultDNSTimeout
+	}
+	return &DNSResolver{
+		upstream:   u,
+		timeout:    timeout,
+		httpClient: &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Upstream returns the resolver's upstream
+func (r *DNSResolver) Upstream() DNSUpstream {
+	return r.upstream
+}
+
+// Lookup queries name for records of qtype. For PTR lookups an IP address
+// may be given instead of a reverse name. A response with an error rcode
+// such as NXDOMAIN is a result, not an error.
+func (r *DNSResolver) Lookup(ctx context.Context, name, qtype string) (*DNSResult, error) {
+	qtype = strings.ToUpper(strings.TrimSpace(qtype))
+	if qtype == "" {
+		qtype = "A"
+	}
+	t, ok := dnsQueryTypes[qtype]
+	if !ok {
+		return nil, fmt.Errorf("%w: unsupported record type %q", ErrInvalidDNSQuery, qtype)
+	}
+
+	name = strings.TrimSpace(name)
+	if t == dnsmessage.TypePTR {
+		if addr, err := netip.ParseAddr(name); err == nil {
+			name = ReversePTR(addr.Unmap())
+		}
+	}
+	qname, err := dnsQueryName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, r.timeout)
+	defer cancel()
+
+	protocol := r.upstream.Protocol
+	query, err := buildDNSQuery(qname, t, protocol != DNSProtocolDoH)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	resp, err := r.exchange(ctx, protocol, query)
+	if err != nil {
+		return nil, err
+	}
+	header, err := checkDNSResponse(query, resp)
+	if err != nil {
+		return nil, err
+	}
+	// Retry truncated UDP answers over TCP, as stub resolvers do
+	if header.Truncated && protocol == DNSProtocolUDP {
+		protocol = DNSProtocolTCP
+		if resp, err = r.exchange(ctx, protocol, query); err != nil {
+			return nil, err
+		}
+		if _, err = checkDNSResponse(query, resp); err != nil {
+			return nil, err
+		}
+	}
+	elapsed := time.Since(start)
+
+	result, err := parseDNSResponse(resp)
+	if err != nil {
+		return nil, err
+	}
+	result.Name = qname.String()
+	result.Type = qtype
+	result.Server = r.upstream.Address
+	result.Protocol = protocol
+	result.Duration = elapsed
+	return result, nil
+}
+
+// exchange sends a packed query over protocol and returns the raw response
+func (r *DNSResolver) exchange(ctx context.Context, protocol string, query []byte) ([]byte, error) {
+	switch protocol {
+	case DNSProtocolUDP:
+		return r.exchangeUDP(ctx, query)
+	case DNSProtocolTCP, DNSProtocolDoT:
+		return r.exchangeStream(ctx, protocol, query)
+	case DNSProtocolDoH:
+		return r.exchangeHTTPS(ctx, query)
+	default:
+		return nil, fmt.Errorf("unsupported DNS protocol %q", protocol)
+	}
+}
+
+// exchangeUDP sends a query in a single datagram
+func (r *DNSResolver) exchangeUDP(ctx context.Context, query []byte) ([]byte, error) {
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "udp", r.upstream.Address)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if _, err := conn.Write(query); err != nil {
+		return nil, err
+	}
+
+	buf := make([]byte, 65535)
+	for {
+		n, err := conn.Read(buf)
+		if err != nil {
+			return nil, err
+		}
+		// Ignore stray datagrams that do not answer this query
+		if n >= 2 && buf[0] == query[0] && buf[1] == query[1] {
+			return buf[:n], nil
+		}
+	}
+}
+
+// exchangeStream sends a length-prefixed query over TCP or TLS (RFC 7766, RFC 7858)
+func (r *DNSResolver) exchangeStream(ctx context.Context, protocol string, query []byte) ([]byte, error) {
+	var (
+		conn net.Conn
+		err  error
+	)
+	if protocol == DNSProtocolDoT {
+		d := tls.Dialer{Config: r.dotConfig()}
+		conn, err = d.DialContext(ctx, "tcp", r.upstream.Address)
+	} else {
+		var d net.Dialer
+		conn, err = d.DialContext(ctx, "tcp", r.upstream.Address)
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	msg := make([]byte, 2+len(query))
+	binary.BigEndian.PutUint16(msg, uint16(len(query)))
+	copy(msg[2:], query)
+	if _, err := conn.Write(msg); err != nil {
+		return nil, err
+	}
+
+	br := bufio.NewReader(conn)
+	var length uint16
+	if err := binary.Read(br, binary.BigEndian, &length); err != nil {
+		return nil, err
+	}
+	resp := make([]byte, length)
+	if _, err := io.ReadFull(br, resp); err != nil {
+		return nil, err
+	}
+	return resp, nil
+}
+
+// exchangeHTTPS POSTs a query to a DNS over HTTPS endpoint (RFC 8484)
+func (r *DNSResolver) exchangeHTTPS(ctx context.Context, query []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.upstream.Address, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DNS over HTTPS upstream returned %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, 65535))
+}
+
+// dotConfig returns the TLS settings for DNS over TLS, verifying the
+// upstream host name
+func (r *DNSResolver) dotConfig() *tls.Config {
+	if r.tlsConfig != nil {
+		return r.tlsConfig
+	}
+	host, _, _ := net.SplitHostPort(r.upstream.Address)
+	return &tls.Config{ServerName: host, MinVersion: tls.VersionTLS12}
+}
+
+// dnsQueryName validates a domain name and returns it in fully qualified form
+func dnsQueryName(name string) (dnsmessage.Name, error) {
+	if name == "" {
+		return dnsmessage.Name{}, fmt.Errorf("%w: name is required", ErrInvalidDNSQuery)
+	}
+	if !strings.HasSuffix(name, ".") {
+		name += "."
+	}
+	if len(name) > 254 {
+		return dnsmessage.Name{}, fmt.Errorf("%w: name is longer than 253 characters", ErrInvalidDNSQuery)
+	}
+	if name != "." {
+		for _, label := range strings.Split(strings.TrimSuffix(name, "."), ".") {
+			if label == "" || len(label) > 63 {
+				return dnsmessage.Name{}, fmt.Errorf("%w: invalid label in %q", ErrInvalidDNSQuery, name)
+			}
+		}
+	}
+
+	n, err := dnsmessage.NewName(name)
+	if err != nil {
+		return dnsmessage.Name{}, fmt.Errorf("%w: %v", ErrInvalidDNSQuery, err)
+	}
+	return n, nil
+}
+
+// buildDNSQuery packs a recursive query, advertising a larger UDP payload
+// via EDNS0. DNS over HTTPS queries use ID 0 so responses stay cacheable.
+func buildDNSQuery(name dnsmessage.Name, t dnsmessage.Type, randomID bool) ([]byte, error) {
+	var id uint16
+	if randomID {
+		var b [2]byte
+		if _, err := rand.Read(b[:]); err != nil {
+			return nil, err
+		}
+		id = binary.BigEndian.Uint16(b[:])
+	}
+
+	b := dnsmessage.NewBuilder(nil, dnsmessage.Header{ID: id, RecursionDesired: true})
+	b.EnableCompression()
+	if err := b.StartQuestions(); err != nil {
+		return nil, err
+	}
+	if err := b.Question(dnsmessage.Question{Name: name, Type: t, Class: dnsmessage.ClassINET}); err != nil {
+		return nil, err
+	}
+	if err := b.StartAdditionals(); err != nil {
+		return nil, err
+	}
+	var opt dnsmessage.ResourceHeader
+	if err := opt.SetEDNS0(dnsUDPPayloadSize, dnsmessage.RCodeSuccess, false); err != nil {
+		return nil, err
+	}
+	if err := b.OPTResource(opt, dnsmessage.OPTResource{}); err != nil {
+		return nil, err
+	}
+	return b.Finish()
+}
+
+// checkDNSResponse verifies that resp answers query
+func checkDNSResponse(query, resp []byte) (dnsmessage.Header, error) {
+	var p dnsmessage.Parser
+	header, err := p.Start(resp)
+	if err != nil {
+		return header, fmt.Errorf("malformed DNS response: %w", err)
+	}
+	if !header.Response || len(resp) < 2 || resp[0] != query[0] || resp[1] != query[1] {
+		return header, errors.New("DNS response does not match the query")
+	}
+	return header, nil
+}
+
+// parseDNSResponse decodes the header, answer and authority sections
+func parseDNSResponse(resp []byte) (*DNSResult, error) {
+	var p dnsmessage.Parser
+	header, err := p.Start(resp)
+	if err != nil {
+		return nil, fmt.Errorf("malformed DNS response: %w", err)
+	}
+	if err := p.SkipAllQuestions(); err != nil {
+		return nil, fmt.Errorf("malformed DNS response: %w", err)
+	}
+	answers, err := p.AllAnswers()
+	if err != nil {
+		return nil, fmt.Errorf("malformed DNS response: %w", err)
+	}
+	authority, err := p.AllAuthorities()
+	if err != nil {
+		return nil, fmt.Errorf("malformed DNS response: %w", err)
+	}
+
+	rcode, ok := dnsRCodeNames[header.RCode]
+	if !ok {
+		rcode = "RCODE" + strconv.Itoa(int(header.RCode))
+	}
+
+	return &DNSResult{
+		RCode:              rcode,
+		Authoritative:      header.Authoritative,
+		RecursionAvailable: header.RecursionAvailable,
+		Truncated:          header.Truncated,
+		Answers:            dnsRecords(answers),
+		Authority:          dnsRecords(authority),
+	}, nil
+}
+
+// dnsRecords converts parsed resources to records
+func dnsRecords(resources []dnsmessage.Resource) []DNSRecord {
+	records := make([]DNSRecord, 0, len(resources))
+	for _, res := range resources {
+		records = append(records, dnsRecord(res))
+	}
+	return records
+}
+
+// dnsRecord converts one parsed resource to a record
+func dnsRecord(res dnsmessage.Resource) DNSRecord {
+	rec := DNSRecord{
+		Name: res.Header.Name.String(),
+		Type: dnsTypeName(res.Header.Type),
+		TTL:  res.Header.TTL,
+	}
+
+	switch body := res.Body.(type) {
+	case *dnsmessage.AResource:
+		rec.Address = netip.AddrFrom4(body.A).String()
+		rec.Data = rec.Address
+	case *dnsmessage.AAAAResource:
+		rec.Address = netip.AddrFrom16(body.AAAA).String()
+		rec.Data = rec.Address
+	case *dnsmessage.CNAMEResource:
+		rec.Target = body.CNAME.String()
+		rec.Data = rec.Target
+	case *dnsmessage.NSResource:
+		rec.Target = body.NS.String()
+		rec.Data = rec.Target
+	case *dnsmessage.PTRResource:
+		rec.Target = body.PTR.String()
+		rec.Data = rec.Target
+	case *dnsmessage.MXResource:
+		rec.Preference = body.Pref
+		rec.Target = body.MX.String()
+		rec.Data = fmt.Sprintf("%d %s", body.Pref, rec.Target)
+	case *dnsmessage.SRVResource:
+		rec.Priority = body.Priority
+		rec.Weight = body.Weight
+		rec.Port = body.Port
+		rec.Target = body.Target.String()
+		rec.Data = fmt.Sprintf("%d %d %d %s", body.Priority, body.Weight, body.Port, rec.Target)
+	case *dnsmessage.TXTResource:
+		rec.Text = append([]string(nil), body.TXT...)
+		quoted := make([]string, len(body.TXT))
+		for i, s := range body.TXT {
+			quoted[i] = strconv.Quote(s)
+		}
+		rec.Data = strings.Join(quoted, " ")
+	case *dnsmessage.SOAResource:
+		rec.SOA = &DNSSOA{
+			NS:      body.NS.String(),
+			Mbox:    body.MBox.String(),
+			Serial:  body.Serial,
+			Refresh: body.Refresh,
+			Retry:   body.Retry,
+			Expire:  body.Expire,
+			MinTTL:  body.MinTTL,
+		}
+		rec.Data = fmt.Sprintf("%s %s %d %d %d %d %d", rec.SOA.NS, rec.SOA.Mbox,
+			body.Serial, body.Refresh, body.Retry, body.Expire, body.MinTTL)
+	case *dnsmessage.UnknownResource:
+		if body.Type == typeCAA {
+			if caa, ok := parseCAA(body.Data); ok {
+				rec.CAA = caa
+				rec.Data = fmt.Sprintf("%d %s %s", caa.Flag, caa.Tag, strconv.Quote(caa.Value))
+				break
+			}
+		}
+		// RFC 3597 generic presentation format
+		rec.Data = fmt.Sprintf(`\# %d %x`, len(body.Data), body.Data)
+	}
+	return rec
+}
+
+// parseCAA decodes the RDATA of a CAA record (RFC 8659)
+func parseCAA(data []byte) (*DNSCAA, bool) {
+	if len(data) < 2 {
+		return nil, false
+	}
+	tagLen := int(data[1])
+	if tagLen == 0 || 2+tagLen > len(data) {
+		return nil, false
+	}
+	return &DNSCAA{
+		Flag:  data[0],
+		Tag:   string(data[2 : 2+tagLen]),
+		Value: string(data[2+tagLen:]),
+	}, true
+}
+
+// dnsTypeName returns the mnemonic for a record type
+func dnsTypeName(t dnsmessage.Type) string {
+	if t == typeCAA {
+		return "CAA"
+	}
+	return strings.TrimPrefix(t.String(), "Type")
+}
+
+var (
+	dnsResolver   *DNSResolver
+	dnsResolverMu sync.RWMutex
+)
+
+// ConfigureDNS sets the upstream used by LookupDNS. An empty upstream uses
+// the first nameserver in /etc/resolv.conf.
+func ConfigureDNS(upstream string, timeout time.Duration) error {
+	if upstream == "" {
+		upstream = systemDNSServer("/etc/resolv.conf")
+	}
+	resolver, err := NewDNSResolver(upstream, timeout)
+	if err != nil {
+		return err
+	}
+	SetDNSResolver(resolver)
+	return nil
+}
+
+// SetDNSResolver replaces the resolver used by LookupDNS
+func SetDNSResolver(resolver *DNSResolver) {
+	dnsResolverMu.Lock()
+	dnsResolver = resolver
+	dnsResolverMu.Unlock()
+}
+
+// LookupDNS queries the configured upstream resolver
+func LookupDNS(ctx context.Context, name, qtype string) (*DNSResult, error) {
+	dnsResolverMu.RLock()
+	resolver := dnsResolver
+	dnsResolverMu.RUnlock()
+
+	if resolver == nil {
+		if err := ConfigureDNS("", 0); err != nil {
+			return nil, err
+		}
+		return LookupDNS(ctx, name, qtype)
+	}
+	return resolver.Lookup(ctx, name, qtype)
+}
+
+// systemDNSServer returns the first nameserver listed in a resolv.conf file
+func systemDNSServer(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return fallbackDNSServer
+	}
+	for _, line := range strings.Split(string(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) >= 2 && fields[0] == "nameserver" {
+			if addr, err := netip.ParseAddr(fields[1]); err == nil {
+				return net.JoinHostPort(addr.String(), "53")
+			}
+		}
+	}
+	return fallbackDNSServer
+}
@@ -0,0 +1,407 @@
+package service
+
+import (
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/binary"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// stubDNS answers queries from a fixed zone for tests. Over UDP it sets the
+// TC bit for big.example.com so the client has to retry over TCP.
+func stubDNS(query []byte, overUDP bool) []byte {
+	var p dnsmessage.Parser
+	h, err := p.Start(query)
+	if err != nil {
+		return nil
+	}
+	q, err := p.Question()
+	if err != nil {
+		return nil
+	}
+
+	msg := dnsmessage.Message{
+		Header: dnsmessage.Header{
+			ID:                 h.ID,
+			Response:           true,
+			RecursionDesired:   h.RecursionDesired,
+			RecursionAvailable: true,
+		},
+		Questions: []dnsmessage.Question{q},
+	}
+	rh := func(t dnsmessage.Type, ttl uint32) dnsmessage.ResourceHeader {
+		return dnsmessage.ResourceHeader{Name: q.Name, Type: t, Class: dnsmessage.ClassINET, TTL: ttl}
+	}
+	name := func(s string) dnsmessage.Name { return dnsmessage.MustNewName(s) }
+	soa := dnsmessage.Resource{
+		Header: dnsmessage.ResourceHeader{Name: name("example.com."), Type: dnsmessage.TypeSOA, Class: dnsmessage.ClassINET, TTL: 3600},
+		Body: &dnsmessage.SOAResource{
+			NS: name("ns1.example.com."), MBox: name("hostmaster.example.com."),
+			Serial: 2026101901, Refresh: 7200, Retry: 900, Expire: 1209600, MinTTL: 300,
+		},
+	}
+
+	switch {
+	case q.Name.String() == "big.example.com." && overUDP:
+		msg.Header.Truncated = true
+	case q.Name.String() == "big.example.com.":
+		for i := 1; i <= 3; i++ {
+			msg.Answers = append(msg.Answers, dnsmessage.Resource{
+				Header: rh(dnsmessage.TypeA, 60),
+				Body:   &dnsmessage.AResource{A: [4]byte{192, 0, 2, byte(i)}},
+			})
+		}
+	case q.Name.String() == "1.2.0.192.in-addr.arpa." && q.Type == dnsmessage.TypePTR:
+		msg.Answers = append(msg.Answers, dnsmessage.Resource{
+			Header: rh(dnsmessage.TypePTR, 86400),
+			Body:   &dnsmessage.PTRResource{PTR: name("host.example.com.")},
+		})
+	case q.Name.String() != "example.com.":
+		msg.Header.RCode = dnsmessage.RCodeNameError
+		msg.Authorities = append(msg.Authorities, soa)
+	case q.Type == dnsmessage.TypeA:
+		msg.Answers = append(msg.Answers, dnsmessage.Resource{
+			Header: rh(dnsmessage.TypeA, 300),
+			Body:   &dnsmessage.AResource{A: [4]byte{93, 184, 216, 34}},
+		})
+	case q.Type == dnsmessage.TypeMX:
+		msg.Answers = append(msg.Answers, dnsmessage.Resource{
+			Header: rh(dnsmessage.TypeMX, 3600),
+			Body:   &dnsmessage.MXResource{Pref: 10, MX: name("mail.example.com.")},
+		})
+	case q.Type == dnsmessage.TypeTXT:
+		msg.Answers = append(msg.Answers, dnsmessage.Resource{
+			Header: rh(dnsmessage.TypeTXT, 3600),
+			Body:   &dnsmessage.TXTResource{TXT: []string{"v=spf1 -all"}},
+		})
+	case q.Type == dnsmessage.TypeSOA:
+		msg.Answers = append(msg.Answers, soa)
+	case q.Type == typeCAA:
+		msg.Answers = append(msg.Answers, dnsmessage.Resource{
+			Header: rh(typeCAA, 3600),
+			Body:   &dnsmessage.UnknownResource{Type: typeCAA, Data: append([]byte{0, 5}, "issueletsencrypt.org"...)},
+		})
+	}
+
+	resp, err := msg.Pack()
+	if err != nil {
+		return nil
+	}
+	return resp
+}
+
+// startStubUDP serves stubDNS on a loopback UDP socket
+func startStubUDP(t *testing.T) string {
+	t.Helper()
+	addr := startStubUDPAt(t, "127.0.0.1:0")
+	if addr == "" {
+		t.Fatal("could not listen on UDP")
+	}
+	return addr
+}
+
+// serveStubStream serves stubDNS with RFC 7766 length framing on ln
+func serveStubStream(t *testing.T, ln net.Listener) string {
+	t.Helper()
+	t.Cleanup(func() { ln.Close() })
+
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				var length uint16
+				if err := binary.Read(conn, binary.BigEndian, &length); err != nil {
+					return
+				}
+				query := make([]byte, length)
+				if _, err := io.ReadFull(conn, query); err != nil {
+					return
+				}
+				resp := stubDNS(query, false)
+				out := make([]byte, 2+len(resp))
+				binary.BigEndian.PutUint16(out, uint16(len(resp)))
+				copy(out[2:], resp)
+				conn.Write(out)
+			}(conn)
+		}
+	}()
+	return ln.Addr().String()
+}
+
+// startStubServers starts UDP and TCP stubs on the same loopback port
+func startStubServers(t *testing.T) string {
+	t.Helper()
+	for i := 0; i < 10; i++ {
+		ln, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if startStubUDPAt(t, ln.Addr().String()) != "" {
+			return serveStubStream(t, ln)
+		}
+		ln.Close()
+	}
+	t.Fatal("could not bind UDP and TCP to the same port")
+	return ""
+}
+
+// startStubUDPAt serves stubDNS on a specific UDP address
+func startStubUDPAt(t *testing.T, addr string) string {
+	t.Helper()
+	pc, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return ""
+	}
+	t.Cleanup(func() { pc.Close() })
+
+	go func() {
+		buf := make([]byte, 65535)
+		for {
+			n, from, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			if resp := stubDNS(buf[:n], true); resp != nil {
+				pc.WriteTo(resp, from)
+			}
+		}
+	}()
+	return pc.LocalAddr().String()
+}
+
+func TestParseDNSUpstream(t *testing.T) {
+	tests := []struct {
+		input    string
+		protocol string
+		address  string
+		wantErr  bool
+	}{
+		{"1.1.1.1", DNSProtocolUDP, "1.1.1.1:53", false},
+		{"udp://9.9.9.9:5353", DNSProtocolUDP, "9.9.9.9:5353", false},
+		{"tcp://[2606:4700:4700::1111]", DNSProtocolTCP, "[2606:4700:4700::1111]:53", false},
+		{"2606:4700:4700::1111", DNSProtocolUDP, "[2606:4700:4700::1111]:53", false},
+		{"tls://dns.google", DNSProtocolDoT, "dns.google:853", false},
+		{"https://dns.google/dns-query", DNSProtocolDoH, "https://dns.google/dns-query", false},
+		{"quic://dns.adguard.com", "", "", true},
+		{"tcp://", "", "", true},
+		{"", "", "", true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got, err := ParseDNSUpstream(tt.input)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("ParseDNSUpstream(%q) error = %v, wantErr %v", tt.input, err, tt.wantErr)
+			}
+			if got.Protocol != tt.protocol || got.Address != tt.address {
+				t.Errorf("ParseDNSUpstream(%q) = %s %s, want %s %s", tt.input, got.Protocol, got.Address, tt.protocol, tt.address)
+			}
+		})
+	}
+}
+
+func TestDNSResolverUDP(t *testing.T) {
+	addr := startStubUDP(t)
+	r, err := NewDNSResolver("udp://"+addr, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		qtype string
+		data  string
+		check func(t *testing.T, rec DNSRecord)
+	}{
+		{"A", "93.184.216.34", func(t *testing.T, rec DNSRecord) {
+			if rec.Address != "93.184.216.34" || rec.TTL != 300 {
+				t.Errorf("A record = %+v", rec)
+			}
+		}},
+		{"mx", "10 mail.example.com.", func(t *testing.T, rec DNSRecord) {
+			if rec.Preference != 10 || rec.Target != "mail.example.com." {
+				t.Errorf("MX record = %+v", rec)
+			}
+		}},
+		{"TXT", `"v=spf1 -all"`, func(t *testing.T, rec DNSRecord) {
+			if len(rec.Text) != 1 || rec.Text[0] != "v=spf1 -all" {
+				t.Errorf("TXT record = %+v", rec)
+			}
+		}},
+		{"SOA", "ns1.example.com. hostmaster.example.com. 2026101901 7200 900 1209600 300", func(t *testing.T, rec DNSRecord) {
+			if rec.SOA == nil || rec.SOA.Serial != 2026101901 {
+				t.Errorf("SOA record = %+v", rec)
+			}
+		}},
+		{"CAA", `0 issue "letsencrypt.org"`, func(t *testing.T, rec DNSRecord) {
+			if rec.CAA == nil || rec.CAA.Tag != "issue" || rec.CAA.Value != "letsencrypt.org" {
+				t.Errorf("CAA record = %+v", rec)
+			}
+		}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.qtype, func(t *testing.T) {
+			result, err := r.Lookup(context.Background(), "example.com", tt.qtype)
+			if err != nil {
+				t.Fatalf("Lookup() error = %v", err)
+			}
+			if result.RCode != "NOERROR" || result.Protocol != DNSProtocolUDP || result.Server != addr {
+				t.Errorf("result = %s via %s %s", result.RCode, result.Protocol, result.Server)
+			}
+			if result.Name != "example.com." || result.Type != strings.ToUpper(tt.qtype) {
+				t.Errorf("question = %s %s", result.Name, result.Type)
+			}
+			if len(result.Answers) != 1 {
+				t.Fatalf("got %d answers, want 1", len(result.Answers))
+			}
+			if result.Answers[0].Data != tt.data {
+				t.Errorf("Data = %q, want %q", result.Answers[0].Data, tt.data)
+			}
+			tt.check(t, result.Answers[0])
+		})
+	}
+}
+
+func TestDNSResolverNXDomainAndPTR(t *testing.T) {
+	addr := startStubUDP(t)
+	r, err := NewDNSResolver(addr, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := r.Lookup(context.Background(), "missing.example.net", "A")
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if result.RCode != "NXDOMAIN" || len(result.Answers) != 0 || len(result.Authority) != 1 {
+		t.Errorf("NXDOMAIN result = %+v", result)
+	}
+
+	// PTR lookups accept an address in place of the reverse name
+	result, err = r.Lookup(context.Background(), "192.0.2.1", "PTR")
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if result.Name != "1.2.0.192.in-addr.arpa." || len(result.Answers) != 1 || result.Answers[0].Target != "host.example.com." {
+		t.Errorf("PTR result = %+v", result)
+	}
+}
+
+func TestDNSResolverTruncatedRetry(t *testing.T) {
+	addr := startStubServers(t)
+	r, err := NewDNSResolver(addr, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	result, err := r.Lookup(context.Background(), "big.example.com", "A")
+	if err != nil {
+		t.Fatalf("Lookup() error = %v", err)
+	}
+	if result.Protocol != DNSProtocolTCP || len(result.Answers) != 3 || result.Truncated {
+		t.Errorf("truncated retry result = %s, %d answers, truncated %v", result.Protocol, len(result.Answers), result.Truncated)
+	}
+}
+
+func TestDNSResolverDoTAndDoH(t *testing.T) {
+	doh := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/dns-message" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		query, _ := io.ReadAll(r.Body)
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(stubDNS(query, false))
+	}))
+	defer doh.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(doh.Certificate())
+
+	t.Run("DoH", func(t *testing.T) {
+		r, err := NewDNSResolver(doh.URL+"/dns-query", 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.httpClient = doh.Client()
+
+		result, err := r.Lookup(context.Background(), "example.com", "A")
+		if err != nil {
+			t.Fatalf("Lookup() error = %v", err)
+		}
+		if result.Protocol != DNSProtocolDoH || len(result.Answers) != 1 || result.Answers[0].Address != "93.184.216.34" {
+			t.Errorf("DoH result = %+v", result)
+		}
+	})
+
+	t.Run("DoT", func(t *testing.T) {
+		ln, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: doh.TLS.Certificates})
+		if err != nil {
+			t.Fatal(err)
+		}
+		addr := serveStubStream(t, ln)
+
+		r, err := NewDNSResolver("tls://"+addr, 0)
+		if err != nil {
+			t.Fatal(err)
+		}
+		r.tlsConfig = &tls.Config{RootCAs: pool, ServerName: "example.com"}
+
+		result, err := r.Lookup(context.Background(), "example.com", "MX")
+		if err != nil {
+			t.Fatalf("Lookup() error = %v", err)
+		}
+		if result.Protocol != DNSProtocolDoT || len(result.Answers) != 1 || result.Answers[0].Target != "mail.example.com." {
+			t.Errorf("DoT result = %+v", result)
+		}
+	})
+}
+
+func TestDNSResolverInvalidQuery(t *testing.T) {
+	r, err := NewDNSResolver("127.0.0.1:1", 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct{ name, qtype string }{
+		{"example.com", "AXFR"},
+		{"", "A"},
+		{"bad..example.com", "A"},
+		{strings.Repeat("a", 64) + ".example.com", "A"},
+	} {
+		if _, err := r.Lookup(context.Background(), tt.name, tt.qtype); !errors.Is(err, ErrInvalidDNSQuery) {
+			t.Errorf("Lookup(%q, %q) error = %v, want ErrInvalidDNSQuery", tt.name, tt.qtype, err)
+		}
+	}
+}
+
+func TestSystemDNSServer(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "resolv.conf")
+	conf := "# generated\nsearch example.com\nnameserver fe80::1%eth0\nnameserver 10.0.0.2\n"
+	if err := os.WriteFile(path, []byte(conf), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := systemDNSServer(path); got != "[fe80::1%eth0]:53" {
+		t.Errorf("systemDNSServer() = %s, want [fe80::1%%eth0]:53", got)
+	}
+	if got := systemDNSServer(filepath.Join(dir, "missing")); got != fallbackDNSServer {
+		t.Errorf("systemDNSServer() without file = %s, want %s", got, fallbackDNSServer)
+	}
+}
@@ -0,0 +1,357 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net/netip"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// ErrGeoNotFound is returned by a GeoProvider that has no record for an address
+var ErrGeoNotFound = errors.New("geolocation not found")
+
+// GeoProvider looks up geographic information for an address.
+// Implementations return ErrGeoNotFound when they have no record so that
+// the chain can fall through to the next provider.
+type GeoProvider interface {
+	Name() string
+	Lookup(addr netip.Addr) (*GeoInfo, error)
+}
+
+// GeoConfig configures the geolocation provider chain
+type GeoConfig struct {
+	// Providers are consulted in order, e.g. "static", "mmdb", "csv", "http".
+	// An empty list uses the default order for every configured source.
+	Providers []string
+
+	StaticPath string // JSON file of CIDR overrides
+	MMDBPath   string // MaxMind DB file, e.g. GeoLite2-City.mmdb
+	CSVPath    string // CSV range file
+
+	HTTPProvider string // "ip-api" or "ipinfo"
+	HTTPBaseURL  string // overrides the provider's default endpoint
+	HTTPToken    string
+	HTTPTimeout  time.Duration
+
+	CacheSize   int
+	CacheTTL    time.Duration
+	NegativeTTL time.Duration
+
+	// ReloadInterval is how often the database files are checked for new
+	// versions; zero disables reloading
+	ReloadInterval time.Duration
+	// ProbeIPs must keep resolving in new versions, defaults to DefaultGeoProbeIPs
+	ProbeIPs []string
+}
+
+// Default cache settings used when GeoConfig leaves them unset
+const (
+	DefaultGeoCacheSize   = 10000
+	DefaultGeoCacheTTL    = time.Hour
+	DefaultGeoNegativeTTL = 5 * time.Minute
+)
+
+// GeoProviderStats represents lookup counters for one provider
+type GeoProviderStats struct {
+	Name   string
+	Hits   uint64
+	Misses uint64
+	Errors uint64
+}
+
+// GeoStats represents lookup counters for the provider chain and its cache
+type GeoStats struct {
+	Providers   []GeoProviderStats
+	CacheHits   uint64
+	CacheMisses uint64
+	CacheSize   int
+}
+
+// trackedProvider wraps a provider with hit and miss counters
+type trackedProvider struct {
+	GeoProvider
+	hits   atomic.Uint64
+	misses atomic.Uint64
+	errors atomic.Uint64
+}
+
+// GeoChain consults an ordered list of providers behind an LRU cache
+type GeoChain struct {
+	providers []*trackedProvider
+	cache     *geoCache
+}
+
+// NewGeoChain creates a provider chain with a cache in front of it.
+// A cacheSize of zero disables caching.
+func NewGeoChain(cacheSize int, ttl, negativeTTL time.Duration, providers ...GeoProvider) *GeoChain {
+	chain := &GeoChain{}
+	for _, p := range providers {
+		chain.providers = append(chain.providers, &trackedProvider{GeoProvider: p})
+	}
+	if cacheSize > 0 {
+		chain.cache = newGeoCache(cacheSize, ttl, negativeTTL)
+	}
+	return chain
+}
+
+// Lookup returns the first provider answer for the address, or ErrGeoNotFound
+func (c *GeoChain) Lookup(addr netip.Addr) (*GeoInfo, error) {
+	key := addr.String()
+	if c.cache != nil {
+		if info, ok := c.cache.get(key); ok {
+			if info == nil {
+				return nil, ErrGeoNotFound
+			}
+			return info, nil
+		}
+	}
+
+	var lastErr error
+	for _, p := range c.providers {
+		info, err := p.Lookup(addr)
+		switch {
+		case err == nil && info != nil:
+			p.hits.Add(1)
+			if info.Source == "" {
+				info.Source = p.Name()
+			}
+			if c.cache != nil {
+				c.cache.add(key, info)
+			}
+			return info, nil
+		case err == nil, errors.Is(err, ErrGeoNotFound):
+			p.misses.Add(1)
+		default:
+			p.errors.Add(1)
+			lastErr = err
+		}
+	}
+
+	// Provider errors are usually transient, so only cache clean misses
+	if lastErr != nil {
+		return nil, fmt.Errorf("%w: %v", ErrGeoNotFound, lastErr)
+	}
+	if c.cache != nil {
+		c.cache.add(key, nil)
+	}
+	return nil, ErrGeoNotFound
+}
+
+// Stats returns the lookup counters of the chain
+func (c *GeoChain) Stats() GeoStats {
+	stats := GeoStats{}
+	for _, p := range c.providers {
+		stats.Providers = append(stats.Providers, GeoProviderStats{
+			Name:   p.Name(),
+			Hits:   p.hits.Load(),
+			Misses: p.misses.Load(),
+			Errors: p.errors.Load(),
+		})
+	}
+	if c.cache != nil {
+		stats.CacheHits, stats.CacheMisses, stats.CacheSize = c.cache.stats()
+	}
+	return stats
+}
+
+// Reload checks every file-backed provider for a new version, reporting
+// whether any was swapped in. The cache is purged after a swap so answers
+// from the old version are not served until they expire.
+func (c *GeoChain) Reload() (bool, error) {
+	var (
+		reloaded bool
+		errs     []error
+	)
+	for _, p := range c.providers {
+		r, ok := p.GeoProvider.(interface{ Reload() (bool, error) })
+		if !ok {
+			continue
+		}
+		changed, err := r.Reload()
+		if err != nil {
+			errs = append(errs, err)
+		}
+		reloaded = reloaded || changed
+	}
+	if reloaded && c.cache != nil {
+		c.cache.purge()
+	}
+	return reloaded, errors.Join(errs...)
+}
+
+// Databases describes the database files behind the chain
+func (c *GeoChain) Databases() []GeoDatabaseInfo {
+	var dbs []GeoDatabaseInfo
+	for _, p := range c.providers {
+		if db, ok := p.GeoProvider.(interface{ Info() GeoDatabaseInfo }); ok {
+			dbs = append(dbs, db.Info())
+		}
+	}
+	return dbs
+}
+
+var (
+	geoChain   = NewGeoChain(0, 0, 0)
+	geoChainMu sync.RWMutex
+
+	// stopGeoWatch stops the reload loop of the configured chain
+	stopGeoWatch context.CancelFunc
+)
+
+// ConfigureGeo builds the provider chain used by GetGeoLocation
+func ConfigureGeo(cfg GeoConfig) error {
+	order := cfg.Providers
+	if len(order) == 0 {
+		order = []string{"static", "mmdb", "csv", "http"}
+	}
+
+	probeIPs := cfg.ProbeIPs
+	if probeIPs == nil {
+		probeIPs = DefaultGeoProbeIPs
+	}
+	probes := make([]netip.Addr, 0, len(probeIPs))
+	for _, ip := range probeIPs {
+		addr, err := netip.ParseAddr(ip)
+		if err != nil {
+			return fmt.Errorf("invalid geo probe address %q", ip)
+		}
+		probes = append(probes, addr)
+	}
+
+	var providers []GeoProvider
+	for _, name := range order {
+		var (
+			p   GeoProvider
+			err error
+		)
+		switch name {
+		case "static":
+			if cfg.StaticPath == "" {
+				continue
+			}
+			p, err = OpenReloadableGeoProvider(name, cfg.StaticPath, probes)
+		case "mmdb":
+			if cfg.MMDBPath == "" {
+				continue
+			}
+			p, err = OpenReloadableGeoProvider(name, cfg.MMDBPath, probes)
+		case "csv":
+			if cfg.CSVPath == "" {
+				continue
+			}
+			p, err = OpenReloadableGeoProvider(name, cfg.CSVPath, probes)
+		case "http":
+			if cfg.HTTPProvider == "" {
+				continue
+			}
+			p, err = NewHTTPGeoProvider(cfg.HTTPProvider, cfg.HTTPBaseURL, cfg.HTTPToken, cfg.HTTPTimeout)
+		default:
+			err = fmt.Errorf("unknown geo provider %q", name)
+		}
+		if err != nil {
+			closeGeoProviders(providers)
+			return err
+		}
+		providers = append(providers, p)
+	}
+
+	size, ttl, negativeTTL := cfg.CacheSize, cfg.CacheTTL, cfg.NegativeTTL
+	if size == 0 {
+		size = DefaultGeoCacheSize
+	}
+	if ttl == 0 {
+		ttl = DefaultGeoCacheTTL
+	}
+	if negativeTTL == 0 {
+		negativeTTL = DefaultGeoNegativeTTL
+	}
+
+	chain := NewGeoChain(size, ttl, negativeTTL, providers...)
+	SetGeoChain(chain)
+
+	geoChainMu.Lock()
+	if stopGeoWatch != nil {
+		stopGeoWatch()
+		stopGeoWatch = nil
+	}
+	if cfg.ReloadInterval > 0 {
+		var ctx context.Context
+		ctx, stopGeoWatch = context.WithCancel(context.Background())
+		go watchGeoDatabases(ctx, chain, cfg.ReloadInterval)
+	}
+	geoChainMu.Unlock()
+	return nil
+}
+
+// watchGeoDatabases reloads changed database files until ctx is cancelled
+func watchGeoDatabases(ctx context.Context, chain *GeoChain, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			reloaded, err := chain.Reload()
+			if err != nil {
+				slog.Warn("Failed to reload geolocation databases", "error", err)
+			}
+			if reloaded {
+				slog.Info("Geolocation databases reloaded")
+			}
+		}
+	}
+}
+
+// SetGeoChain replaces the chain used by GetGeoLocation.
+// The old chain is left open since lookups may still be using it.
+func SetGeoChain(chain *GeoChain) {
+	geoChainMu.Lock()
+	geoChain = chain
+	geoChainMu.Unlock()
+}
+
+// GetGeoStats returns the lookup counters of the active provider chain
+func GetGeoStats() GeoStats {
+	geoChainMu.RLock()
+	defer geoChainMu.RUnlock()
+	return geoChain.Stats()
+}
+
+// GetGeoDatabases describes the database files behind the active chain
+func GetGeoDatabases() []GeoDatabaseInfo {
+	geoChainMu.RLock()
+	defer geoChainMu.RUnlock()
+	return geoChain.Databases()
+}
+
+// ReloadGeoDatabases checks the active chain's database files for new
+// versions right away rather than waiting for the reload interval
+func ReloadGeoDatabases() (bool, error) {
+	geoChainMu.RLock()
+	chain := geoChain
+	geoChainMu.RUnlock()
+	return chain.Reload()
+}
+
+// lookupGeoChain consults the active provider chain
+func lookupGeoChain(addr netip.Addr) (*GeoInfo, error) {
+	geoChainMu.RLock()
+	chain := geoChain
+	geoChainMu.RUnlock()
+	return chain.Lookup(addr)
+}
+
+// closeGeoProviders closes providers that hold open files
+func closeGeoProviders(providers []GeoProvider) {
+	for _, p := range providers {
+		if closer, ok := p.(interface{ Close() error }); ok {
+			closer.Close()
+		}
+	}
+}
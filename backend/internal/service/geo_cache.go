@@ -0,0 +1,112 @@
+package service
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// geoCacheEntry is a cached lookup result; a nil info records a negative result
+type geoCacheEntry struct {
+	key     string
+	info    *GeoInfo
+	expires time.Time
+}
+
+// geoCache is an LRU cache with separate TTLs for positive and negative results
+type geoCache struct {
+	mu          sync.Mutex
+	size        int
+	ttl         time.Duration
+	negativeTTL time.Duration
+	items       map[string]*list.Element
+	order       *list.List
+	hits        uint64
+	misses      uint64
+	now         func() time.Time
+}
+
+func newGeoCache(size int, ttl, negativeTTL time.Duration) *geoCache {
+	return &geoCache{
+		size:        size,
+		ttl:         ttl,
+		negativeTTL: negativeTTL,
+		items:       make(map[string]*list.Element),
+		order:       list.New(),
+		now:         time.Now,
+	}
+}
+
+// get returns a copy of the cached info and whether the key was cached.
+// A cached negative result returns (nil, true).
+func (c *geoCache) get(key string) (*GeoInfo, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	elem, ok := c.items[key]
+	if !ok {
+		c.misses++
+		return nil, false
+	}
+
+	entry := elem.Value.(*geoCacheEntry)
+	if c.now().After(entry.expires) {
+		c.order.Remove(elem)
+		delete(c.items, key)
+		c.misses++
+		return nil, false
+	}
+
+	c.order.MoveToFront(elem)
+	c.hits++
+	if entry.info == nil {
+		return nil, true
+	}
+	info := *entry.info
+	return &info, true
+}
+
+// add stores a lookup result, evicting the least recently used entry when full
+func (c *geoCache) add(key string, info *GeoInfo) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	ttl := c.ttl
+	if info == nil {
+		ttl = c.negativeTTL
+	} else {
+		stored := *info
+		info = &stored
+	}
+	expires := c.now().Add(ttl)
+
+	if elem, ok := c.items[key]; ok {
+		entry := elem.Value.(*geoCacheEntry)
+		entry.info = info
+		entry.expires = expires
+		c.order.MoveToFront(elem)
+		return
+	}
+
+	c.items[key] = c.order.PushFront(&geoCacheEntry{key: key, info: info, expires: expires})
+	for c.order.Len() > c.size {
+		oldest := c.order.Back()
+		c.order.Remove(oldest)
+		delete(c.items, oldest.Value.(*geoCacheEntry).key)
+	}
+}
+
+// stats returns the hit and miss counters and the number of cached entries
+func (c *geoCache) stats() (hits, misses uint64, size int) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.hits, c.misses, c.order.Len()
+}
+
+// purge drops every cached entry, keeping the counters
+func (c *geoCache) purge() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.items = make(map[string]*list.Element)
+	c.order.Init()
+}
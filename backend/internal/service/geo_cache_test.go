@@ -0,0 +1,65 @@
+package service
+
+import (
+	"testing"
+	"time"
+)
+
+func TestGeoCacheEviction(t *testing.T) {
+	c := newGeoCache(2, time.Hour, time.Minute)
+
+	c.add("a", &GeoInfo{Country: "A"})
+	c.add("b", &GeoInfo{Country: "B"})
+	c.get("a") // a is now most recently used
+	c.add("c", &GeoInfo{Country: "C"})
+
+	if _, ok := c.get("b"); ok {
+		t.Error("least recently used entry should have been evicted")
+	}
+	if got, ok := c.get("a"); !ok || got.Country != "A" {
+		t.Errorf("get(a) = %v, %v", got, ok)
+	}
+	if got, ok := c.get("c"); !ok || got.Country != "C" {
+		t.Errorf("get(c) = %v, %v", got, ok)
+	}
+}
+
+func TestGeoCacheTTL(t *testing.T) {
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	c := newGeoCache(10, time.Hour, time.Minute)
+	c.now = func() time.Time { return now }
+
+	c.add("positive", &GeoInfo{Country: "A"})
+	c.add("negative", nil)
+
+	if got, ok := c.get("negative"); !ok || got != nil {
+		t.Errorf("get(negative) = %v, %v, want cached negative result", got, ok)
+	}
+
+	now = now.Add(2 * time.Minute)
+	if _, ok := c.get("negative"); ok {
+		t.Error("negative entry should expire after the negative TTL")
+	}
+	if _, ok := c.get("positive"); !ok {
+		t.Error("positive entry should outlive the negative TTL")
+	}
+
+	now = now.Add(time.Hour)
+	if _, ok := c.get("positive"); ok {
+		t.Error("positive entry should expire after the TTL")
+	}
+}
+
+func TestGeoCacheReturnsCopies(t *testing.T) {
+	c := newGeoCache(10, time.Hour, time.Minute)
+	info := &GeoInfo{Country: "A"}
+	c.add("a", info)
+	info.Country = "changed"
+
+	got, _ := c.get("a")
+	got.Country = "mutated"
+
+	if again, _ := c.get("a"); again.Country != "A" {
+		t.Errorf("cached entry was modified to %q", again.Country)
+	}
+}
@@ -0,0 +1,145 @@
+package service
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"net/netip"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// csvGeoRange is one address range of a CSV geolocation file. maxEnd is
+// the highest end of this and all earlier ranges, which bounds the search
+// for ranges enclosing an address.
+type csvGeoRange struct {
+	start  netip.Addr
+	end    netip.Addr
+	maxEnd netip.Addr
+	info   GeoInfo
+}
+
+// CSVGeoProvider answers lookups from an in-memory table of address ranges
+type CSVGeoProvider struct {
+	ranges []csvGeoRange
+	path   string
+}
+
+// LoadCSVGeoProvider loads a CSV range file with the columns
+// start_ip,end_ip,country_code,country,region,city,latitude,longitude
+// as used by DB-IP and IP2Location lite exports. Only the first three
+// columns are required, and a header row or # comments are skipped.
+func LoadCSVGeoProvider(path string) (*CSVGeoProvider, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open geo CSV: %w", err)
+	}
+	defer f.Close()
+
+	p, err := ParseCSVGeo(f)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse geo CSV %s: %w", path, err)
+	}
+	p.path = path
+	return p, nil
+}
+
+// ParseCSVGeo parses CSV range data from a reader
+func ParseCSVGeo(r io.Reader) (*CSVGeoProvider, error) {
+	reader := csv.NewReader(r)
+	reader.FieldsPerRecord = -1
+	reader.Comment = '#'
+	reader.TrimLeadingSpace = true
+
+	p := &CSVGeoProvider{}
+	for line := 1; ; line++ {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) < 3 {
+			return nil, fmt.Errorf("line %d: expected at least 3 columns", line)
+		}
+
+		start, err := netip.ParseAddr(fields[0])
+		if err != nil {
+			if line == 1 {
+				continue // header row
+			}
+			return nil, fmt.Errorf("line %d: invalid start address %q", line, fields[0])
+		}
+		end, err := netip.ParseAddr(fields[1])
+		if err != nil || start.Unmap().BitLen() != end.Unmap().BitLen() || end.Unmap().Less(start.Unmap()) {
+			return nil, fmt.Errorf("line %d: invalid end address %q", line, fields[1])
+		}
+
+		rng := csvGeoRange{
+			start: start.Unmap(),
+			end:   end.Unmap(),
+			info:  GeoInfo{CountryCode: strings.ToUpper(fields[2])},
+		}
+		optional := []*string{&rng.info.Country, &rng.info.Region, &rng.info.City}
+		for i, dst := range optional {
+			if len(fields) > 3+i {
+				*dst = fields[3+i]
+			}
+		}
+		if len(fields) > 7 {
+			rng.info.Latitude, _ = strconv.ParseFloat(fields[6], 64)
+			rng.info.Longitude, _ = strconv.ParseFloat(fields[7], 64)
+		}
+		p.ranges = append(p.ranges, rng)
+	}
+
+	// Merged feeds nest specific ranges inside broader ones. Among ranges
+	// with the same start the broader one sorts first, so the narrowest
+	// range containing an address is always the last one.
+	sort.SliceStable(p.ranges, func(i, j int) bool {
+		if c := p.ranges[i].start.Compare(p.ranges[j].start); c != 0 {
+			return c < 0
+		}
+		return p.ranges[j].end.Less(p.ranges[i].end)
+	})
+	for i := range p.ranges {
+		p.ranges[i].maxEnd = p.ranges[i].end
+		if i > 0 && p.ranges[i].maxEnd.Less(p.ranges[i-1].maxEnd) {
+			p.ranges[i].maxEnd = p.ranges[i-1].maxEnd
+		}
+	}
+	return p, nil
+}
+
+// Name returns the provider name
+func (p *CSVGeoProvider) Name() string {
+	return "csv"
+}
+
+// Info describes the loaded range table
+func (p *CSVGeoProvider) Info() GeoDatabaseInfo {
+	return GeoDatabaseInfo{Provider: p.Name(), Path: p.path, Type: "csv", Records: len(p.ranges)}
+}
+
+// Lookup returns the containing range that starts last, which is the
+// narrowest one where ranges nest
+func (p *CSVGeoProvider) Lookup(addr netip.Addr) (*GeoInfo, error) {
+	addr = addr.Unmap()
+
+	// Walk back from the last range starting at or before the address
+	// until no earlier range reaches it
+	i := sort.Search(len(p.ranges), func(i int) bool {
+		return addr.Less(p.ranges[i].start)
+	}) - 1
+	for ; i >= 0 && !p.ranges[i].maxEnd.Less(addr); i-- {
+		if !p.ranges[i].end.Less(addr) {
+			info := p.ranges[i].info
+			return &info, nil
+		}
+	}
+	return nil, ErrGeoNotFound
+}
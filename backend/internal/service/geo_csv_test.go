@@ -0,0 +1,111 @@
+package service
+
+import (
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestParseCSVGeo(t *testing.T) {
+	data := `start_ip,end_ip,country_code,country,region,city,latitude,longitude
+# comments are ignored
+1.0.0.0,1.0.0.255,au,Australia,Queensland,Brisbane,-27.4679,153.0281
+8.8.8.0,8.8.8.255,US,United States,California,Mountain View,37.386,-122.0838
+2001:4860::,2001:4860:ffff:ffff:ffff:ffff:ffff:ffff,US
+`
+
+	p, err := ParseCSVGeo(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseCSVGeo() returned error: %v", err)
+	}
+
+	tests := []struct {
+		ip      string
+		code    string
+		city    string
+		missing bool
+	}{
+		{ip: "1.0.0.0", code: "AU", city: "Brisbane"},
+		{ip: "1.0.0.255", code: "AU", city: "Brisbane"},
+		{ip: "8.8.8.8", code: "US", city: "Mountain View"},
+		{ip: "2001:4860:4860::8888", code: "US"},
+		{ip: "1.0.1.0", missing: true},
+		{ip: "0.255.255.255", missing: true},
+		{ip: "2001:4861::1", missing: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			got, err := p.Lookup(netip.MustParseAddr(tt.ip))
+			if tt.missing {
+				if err != ErrGeoNotFound {
+					t.Errorf("Lookup(%s) = %+v, %v, want ErrGeoNotFound", tt.ip, got, err)
+				}
+				return
+			}
+			if err != nil || got.CountryCode != tt.code || got.City != tt.city {
+				t.Errorf("Lookup(%s) = %+v, %v", tt.ip, got, err)
+			}
+		})
+	}
+}
+
+func TestCSVGeoNestedRanges(t *testing.T) {
+	// Merged feeds list broad ranges with more specific ones inside
+	data := `10.0.0.0,10.255.255.255,DE
+10.1.0.0,10.1.255.255,FR
+10.1.2.0,10.1.2.255,FR,France,Ile-de-France,Paris
+10.0.0.0,10.0.0.255,NL
+10.200.0.0,11.0.0.255,AT
+`
+	p, err := ParseCSVGeo(strings.NewReader(data))
+	if err != nil {
+		t.Fatalf("ParseCSVGeo() returned error: %v", err)
+	}
+
+	tests := []struct {
+		ip   string
+		code string
+	}{
+		{"10.1.2.3", "FR"},
+		{"10.1.3.0", "FR"},
+		{"10.2.0.0", "DE"},
+		{"10.0.0.5", "NL"},
+		{"10.0.1.0", "DE"},
+		{"10.250.0.0", "AT"},
+		{"11.0.0.1", "AT"},
+		{"11.0.1.0", ""},
+		{"9.255.255.255", ""},
+	}
+	for _, tt := range tests {
+		got, err := p.Lookup(netip.MustParseAddr(tt.ip))
+		if tt.code == "" {
+			if err != ErrGeoNotFound {
+				t.Errorf("Lookup(%s) = %+v, %v, want ErrGeoNotFound", tt.ip, got, err)
+			}
+			continue
+		}
+		if err != nil || got.CountryCode != tt.code {
+			t.Errorf("Lookup(%s) = %+v, %v, want %s", tt.ip, got, err, tt.code)
+		}
+	}
+	if got, _ := p.Lookup(netip.MustParseAddr("10.1.2.3")); got == nil || got.City != "Paris" {
+		t.Errorf("Lookup(10.1.2.3) = %+v, want the innermost range", got)
+	}
+}
+
+func TestParseCSVGeoErrors(t *testing.T) {
+	for name, data := range map[string]string{
+		"Too few columns": "1.0.0.0,1.0.0.255\n",
+		"Bad end address": "1.0.0.0,nope,AU\n",
+		"Mixed families":  "1.0.0.0,::1,AU\n",
+		"Reversed range":  "1.0.0.255,1.0.0.0,AU\n",
+		"Bad later row":   "1.0.0.0,1.0.0.255,AU\nnope,1.0.1.0,AU\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			if _, err := ParseCSVGeo(strings.NewReader(data)); err == nil {
+				t.Error("ParseCSVGeo() should return an error")
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Default endpoints of the supported HTTP geolocation services
+const (
+	ipAPIBaseURL  = "http://ip-api.com"
+	ipInfoBaseURL = "https://ipinfo.io"
+)
+
+// DefaultGeoHTTPTimeout bounds each request to an HTTP geolocation service
+const DefaultGeoHTTPTimeout = 2 * time.Second
+
+// HTTPGeoProvider answers lookups from a remote service such as ip-api.com or ipinfo.io
+type HTTPGeoProvider struct {
+	kind    string
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// NewHTTPGeoProvider creates a provider for "ip-api" or "ipinfo".
+// An empty baseURL uses the service's public endpoint.
+func NewHTTPGeoProvider(kind, baseURL, token string, timeout time.Duration) (*HTTPGeoProvider, error) {
+	if baseURL == "" {
+		switch kind {
+		case "ip-api":
+			baseURL = ipAPIBaseURL
+		case "ipinfo":
+			baseURL = ipInfoBaseURL
+		}
+	}
+	if kind != "ip-api" && kind != "ipinfo" {
+		return nil, fmt.Errorf("unknown HTTP geo provider %q", kind)
+	}
+	if timeout == 0 {
+		timeout = DefaultGeoHTTPTimeout
+	}
+
+	return &HTTPGeoProvider{
+		kind:    kind,
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		client:  &http.Client{Timeout: timeout},
+	}, nil
+}
+
+// Name returns the provider name
+func (p *HTTPGeoProvider) Name() string {
+	return p.kind
+}
+
+// Lookup queries the remote service.
+// Addresses that are not globally reachable are never sent upstream.
+func (p *HTTPGeoProvider) Lookup(addr netip.Addr) (*GeoInfo, error) {
+	if !ClassifyAddr(addr).Global {
+		return nil, ErrGeoNotFound
+	}
+
+	if p.kind == "ipinfo" {
+		return p.lookupIPInfo(addr)
+	}
+	return p.lookupIPAPI(addr)
+}
+
+// lookupIPAPI queries the ip-api.com JSON endpoint
+func (p *HTTPGeoProvider) lookupIPAPI(addr netip.Addr) (*GeoInfo, error) {
+	var result struct {
+		Status      string  `json:"status"`
+		Message     string  `json:"message"`
+		Country     string  `json:"country"`
+		CountryCode string  `json:"countryCode"`
+		RegionName  string  `json:"regionName"`
+		City        string  `json:"city"`
+		Lat         float64 `json:"lat"`
+		Lon         float64 `json:"lon"`
+	}
+
+	query := url.Values{"fields": {"status,message,country,countryCode,regionName,city,lat,lon"}}
+	if p.token != "" {
+		query.Set("key", p.token)
+	}
+	if err := p.get(p.baseURL+"/json/"+addr.String()+"?"+query.Encode(), &result); err != nil {
+		return nil, err
+	}
+	if result.Status != "success" {
+		return nil, ErrGeoNotFound
+	}
+
+	return &GeoInfo{
+		Country:     result.Country,
+		CountryCode: result.CountryCode,
+		Region:      result.RegionName,
+		City:        result.City,
+		Latitude:    result.Lat,
+		Longitude:   result.Lon,
+	}, nil
+}
+
+// lookupIPInfo queries the ipinfo.io JSON endpoint
+func (p *HTTPGeoProvider) lookupIPInfo(addr netip.Addr) (*GeoInfo, error) {
+	var result struct {
+		Bogon   bool   `json:"bogon"`
+		Country string `json:"country"`
+		Region  string `json:"region"`
+		City    string `json:"city"`
+		Loc     string `json:"loc"`
+	}
+
+	endpoint := p.baseURL + "/" + addr.String() + "/json"
+	if p.token != "" {
+		endpoint += "?" + url.Values{"token": {p.token}}.Encode()
+	}
+	if err := p.get(endpoint, &result); err != nil {
+		return nil, err
+	}
+	if result.Bogon || result.Country == "" {
+		return nil, ErrGeoNotFound
+	}
+
+	info := &GeoInfo{
+		CountryCode: result.Country,
+		Region:      result.Region,
+		City:        result.City,
+	}
+	if lat, lon, ok := strings.Cut(result.Loc, ","); ok {
+		info.Latitude, _ = strconv.ParseFloat(lat, 64)
+		info.Longitude, _ = strconv.ParseFloat(lon, 64)
+	}
+	return info, nil
+}
+
+// get fetches a URL and decodes the JSON response
+func (p *HTTPGeoProvider) get(endpoint string, v interface{}) error {
+	resp, err := p.client.Get(endpoint)
+	if err != nil {
+		return fmt.Errorf("%s request failed: %w", p.kind, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return ErrGeoNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("%s returned status %d", p.kind, resp.StatusCode)
+	}
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("%s returned invalid JSON: %w", p.kind, err)
+	}
+	return nil
+}
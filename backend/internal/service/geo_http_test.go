@@ -0,0 +1,104 @@
+package service
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestHTTPGeoProviderIPAPI(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.URL.Path, "/json/") {
+			http.NotFound(w, r)
+			return
+		}
+		if r.URL.Path == "/json/8.8.8.8" {
+			w.Write([]byte(`{"status":"success","country":"United States","countryCode":"US","regionName":"Virginia","city":"Ashburn","lat":39.03,"lon":-77.5}`))
+			return
+		}
+		w.Write([]byte(`{"status":"fail","message":"reserved range"}`))
+	}))
+	defer server.Close()
+
+	p, err := NewHTTPGeoProvider("ip-api", server.URL, "", 0)
+	if err != nil {
+		t.Fatalf("NewHTTPGeoProvider() returned error: %v", err)
+	}
+
+	got, err := p.Lookup(netip.MustParseAddr("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("Lookup() returned error: %v", err)
+	}
+	if got.Country != "United States" || got.Region != "Virginia" || got.City != "Ashburn" || got.Longitude != -77.5 {
+		t.Errorf("Lookup() = %+v", got)
+	}
+
+	if _, err := p.Lookup(netip.MustParseAddr("1.1.1.1")); err != ErrGeoNotFound {
+		t.Errorf("Lookup() error = %v, want ErrGeoNotFound", err)
+	}
+}
+
+func TestHTTPGeoProviderIPInfo(t *testing.T) {
+	var token string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token = r.URL.Query().Get("token")
+		switch r.URL.Path {
+		case "/8.8.8.8/json":
+			w.Write([]byte(`{"ip":"8.8.8.8","city":"Mountain View","region":"California","country":"US","loc":"37.4056,-122.0775"}`))
+		case "/9.9.9.9/json":
+			w.WriteHeader(http.StatusTooManyRequests)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	p, _ := NewHTTPGeoProvider("ipinfo", server.URL, "secret", 0)
+	if p.Name() != "ipinfo" {
+		t.Errorf("Name() = %s, want ipinfo", p.Name())
+	}
+
+	got, err := p.Lookup(netip.MustParseAddr("8.8.8.8"))
+	if err != nil {
+		t.Fatalf("Lookup() returned error: %v", err)
+	}
+	if got.CountryCode != "US" || got.City != "Mountain View" || got.Latitude != 37.4056 || got.Longitude != -122.0775 {
+		t.Errorf("Lookup() = %+v", got)
+	}
+	if token != "secret" {
+		t.Errorf("token = %q, want secret", token)
+	}
+
+	if _, err := p.Lookup(netip.MustParseAddr("9.9.9.9")); err == nil || err == ErrGeoNotFound {
+		t.Errorf("Lookup() error = %v, want upstream error", err)
+	}
+	if _, err := p.Lookup(netip.MustParseAddr("1.1.1.1")); err != ErrGeoNotFound {
+		t.Errorf("Lookup() error = %v, want ErrGeoNotFound", err)
+	}
+}
+
+func TestHTTPGeoProviderSkipsSpecialAddresses(t *testing.T) {
+	called := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		called = true
+	}))
+	defer server.Close()
+
+	p, _ := NewHTTPGeoProvider("ip-api", server.URL, "", 0)
+	for _, ip := range []string{"10.0.0.1", "127.0.0.1", "203.0.113.1", "fd00::1"} {
+		if _, err := p.Lookup(netip.MustParseAddr(ip)); err != ErrGeoNotFound {
+			t.Errorf("Lookup(%s) error = %v, want ErrGeoNotFound", ip, err)
+		}
+	}
+	if called {
+		t.Error("special-purpose addresses should not be sent upstream")
+	}
+}
+
+func TestNewHTTPGeoProviderUnknown(t *testing.T) {
+	if _, err := NewHTTPGeoProvider("geo-magic", "", "", 0); err == nil {
+		t.Error("NewHTTPGeoProvider() should reject unknown providers")
+	}
+}
@@ -0,0 +1,105 @@
+package service
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"time"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+// mmdbCityRecord is the subset of the GeoIP2/GeoLite2 City and Country schema we read
+type mmdbCityRecord struct {
+	Country struct {
+		ISOCode string            `maxminddb:"iso_code"`
+		Names   map[string]string `maxminddb:"names"`
+	} `maxminddb:"country"`
+	Subdivisions []struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"subdivisions"`
+	City struct {
+		Names map[string]string `maxminddb:"names"`
+	} `maxminddb:"city"`
+	Location struct {
+		Latitude  float64 `maxminddb:"latitude"`
+		Longitude float64 `maxminddb:"longitude"`
+	} `maxminddb:"location"`
+}
+
+// MMDBProvider answers lookups from a MaxMind DB file such as GeoLite2-City.mmdb
+type MMDBProvider struct {
+	reader  *maxminddb.Reader
+	path    string
+	records int
+}
+
+// OpenMMDBProvider opens a MaxMind DB file. The networks are counted once on
+// open, which walks the whole search tree but decodes no records.
+func OpenMMDBProvider(path string) (*MMDBProvider, error) {
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open MMDB %s: %w", path, err)
+	}
+
+	p := &MMDBProvider{reader: reader, path: path}
+	networks := reader.Networks(maxminddb.SkipAliasedNetworks)
+	for networks.Next() {
+		p.records++
+	}
+	if err := networks.Err(); err != nil {
+		reader.Close()
+		return nil, fmt.Errorf("failed to read MMDB %s: %w", path, err)
+	}
+	return p, nil
+}
+
+// Name returns the provider name
+func (p *MMDBProvider) Name() string {
+	return "mmdb"
+}
+
+// Lookup returns the database record for the address
+func (p *MMDBProvider) Lookup(addr netip.Addr) (*GeoInfo, error) {
+	var record mmdbCityRecord
+	_, ok, err := p.reader.LookupNetwork(net.IP(addr.Unmap().AsSlice()), &record)
+	if err != nil {
+		return nil, err
+	}
+	if !ok || record.Country.ISOCode == "" {
+		return nil, ErrGeoNotFound
+	}
+
+	info := &GeoInfo{
+		Country:     record.Country.Names["en"],
+		CountryCode: record.Country.ISOCode,
+		City:        record.City.Names["en"],
+		Latitude:    record.Location.Latitude,
+		Longitude:   record.Location.Longitude,
+	}
+	if len(record.Subdivisions) > 0 {
+		info.Region = record.Subdivisions[0].Names["en"]
+	}
+	return info, nil
+}
+
+// Info describes the database from its metadata
+func (p *MMDBProvider) Info() GeoDatabaseInfo {
+	meta := p.reader.Metadata
+	info := GeoDatabaseInfo{
+		Provider:    p.Name(),
+		Path:        p.path,
+		Type:        meta.DatabaseType,
+		Description: meta.Description["en"],
+		Records:     p.records,
+	}
+	if meta.BuildEpoch > 0 {
+		info.BuildTime = time.Unix(int64(meta.BuildEpoch), 0).UTC()
+	}
+	return info
+}
+
+// Close releases the database file
+func (p *MMDBProvider) Close() error {
+	return p.reader.Close()
+}
@@ -0,0 +1,232 @@
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+// mmdbTestNode is a node of the search tree built by writeTestMMDB
+type mmdbTestNode struct {
+	id       int
+	children [2]*mmdbTestNode
+	leaf     bool
+	data     int // offset into the data section for leaves
+}
+
+// writeTestMMDB writes a minimal IPv6 MaxMind DB (24-bit records) mapping
+// each CIDR to a record. IPv4 prefixes are stored in the ::/96 subtree the
+// same way GeoLite2 databases store them.
+func writeTestMMDB(t *testing.T, dir, dbType string, records map[string]map[string]interface{}) string {
+	t.Helper()
+
+	var data bytes.Buffer
+	root := &mmdbTestNode{}
+
+	cidrs := make([]string, 0, len(records))
+	for cidr := range records {
+		cidrs = append(cidrs, cidr)
+	}
+	sort.Strings(cidrs)
+
+	for _, cidr := range cidrs {
+		prefix := netip.MustParsePrefix(cidr)
+		bits := prefix.Bits()
+		if prefix.Addr().Is4() {
+			bits += 96
+		}
+		addr := netip.AddrFrom16(prefix.Addr().As16())
+		if prefix.Addr().Is4() {
+			// ::a.b.c.d rather than ::ffff:a.b.c.d
+			b := addr.As16()
+			b[10], b[11] = 0, 0
+			addr = netip.AddrFrom16(b)
+		}
+
+		offset := data.Len()
+		mmdbEncode(&data, records[cidr])
+
+		b := addr.As16()
+		node := root
+		for i := 0; i < bits; i++ {
+			bit := (b[i/8] >> (7 - i%8)) & 1
+			if node.children[bit] == nil {
+				node.children[bit] = &mmdbTestNode{}
+			}
+			node = node.children[bit]
+		}
+		node.leaf = true
+		node.data = offset
+	}
+
+	// Number the internal nodes breadth first
+	var nodes []*mmdbTestNode
+	queue := []*mmdbTestNode{root}
+	for len(queue) > 0 {
+		n := queue[0]
+		queue = queue[1:]
+		n.id = len(nodes)
+		nodes = append(nodes, n)
+		for _, child := range n.children {
+			if child != nil && !child.leaf {
+				queue = append(queue, child)
+			}
+		}
+	}
+
+	nodeCount := len(nodes)
+	record := func(child *mmdbTestNode) uint32 {
+		switch {
+		case child == nil:
+			return uint32(nodeCount)
+		case child.leaf:
+			return uint32(nodeCount + 16 + child.data)
+		}
+		return uint32(child.id)
+	}
+
+	var out bytes.Buffer
+	for _, n := range nodes {
+		for _, child := range n.children {
+			v := record(child)
+			out.Write([]byte{byte(v >> 16), byte(v >> 8), byte(v)})
+		}
+	}
+	out.Write(make([]byte, 16))
+	out.Write(data.Bytes())
+	out.WriteString("\xab\xcd\xefMaxMind.com")
+	mmdbEncode(&out, map[string]interface{}{
+		"binary_format_major_version": uint16(2),
+		"binary_format_minor_version": uint16(0),
+		"build_epoch":                 uint64(time.Date(2026, 10, 13, 0, 0, 0, 0, time.UTC).Unix()),
+		"database_type":               dbType,
+		"description":                 map[string]interface{}{"en": "test database"},
+		"ip_version":                  uint16(6),
+		"languages":                   []interface{}{"en"},
+		"node_count":                  uint32(nodeCount),
+		"record_size":                 uint16(24),
+	})
+
+	path := filepath.Join(dir, dbType+".mmdb")
+	if err := os.WriteFile(path, out.Bytes(), 0o644); err != nil {
+		t.Fatalf("failed to write test MMDB: %v", err)
+	}
+	return path
+}
+
+// mmdbEncode writes a value in the MaxMind DB data section format.
+// Only the types used by the tests are supported and sizes must be below 29.
+func mmdbEncode(buf *bytes.Buffer, v interface{}) {
+	control := func(typ, size int) {
+		if typ <= 7 {
+			buf.WriteByte(byte(typ<<5 | size))
+			return
+		}
+		buf.WriteByte(byte(size))
+		buf.WriteByte(byte(typ - 7))
+	}
+
+	switch v := v.(type) {
+	case string:
+		control(2, len(v))
+		buf.WriteString(v)
+	case float64:
+		control(3, 8)
+		binary.Write(buf, binary.BigEndian, math.Float64bits(v))
+	case uint16:
+		control(5, 2)
+		binary.Write(buf, binary.BigEndian, v)
+	case uint32:
+		control(6, 4)
+		binary.Write(buf, binary.BigEndian, v)
+	case uint64:
+		control(9, 8)
+		binary.Write(buf, binary.BigEndian, v)
+	case map[string]interface{}:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		control(7, len(v))
+		for _, k := range keys {
+			mmdbEncode(buf, k)
+			mmdbEncode(buf, v[k])
+		}
+	case []interface{}:
+		control(11, len(v))
+		for _, item := range v {
+			mmdbEncode(buf, item)
+		}
+	default:
+		panic("mmdbEncode: unsupported type")
+	}
+}
+
+// testCityRecord builds a GeoLite2-City style record
+func testCityRecord(code, country, region, city string, lat, lon float64) map[string]interface{} {
+	return map[string]interface{}{
+		"country": map[string]interface{}{
+			"iso_code": code,
+			"names":    map[string]interface{}{"en": country},
+		},
+		"subdivisions": []interface{}{
+			map[string]interface{}{"names": map[string]interface{}{"en": region}},
+		},
+		"city":     map[string]interface{}{"names": map[string]interface{}{"en": city}},
+		"location": map[string]interface{}{"latitude": lat, "longitude": lon},
+	}
+}
+
+func TestMMDBProvider(t *testing.T) {
+	path := writeTestMMDB(t, t.TempDir(), "GeoLite2-City", map[string]map[string]interface{}{
+		"8.8.8.0/24":      testCityRecord("US", "United States", "California", "Mountain View", 37.386, -122.0838),
+		"2001:4860::/32":  testCityRecord("US", "United States", "California", "Mountain View", 37.386, -122.0838),
+		"203.208.60.0/24": testCityRecord("CN", "China", "Beijing", "Beijing", 39.9042, 116.4074),
+	})
+
+	p, err := OpenMMDBProvider(path)
+	if err != nil {
+		t.Fatalf("OpenMMDBProvider() returned error: %v", err)
+	}
+	defer p.Close()
+
+	if p.Name() != "mmdb" {
+		t.Errorf("Name() = %s, want mmdb", p.Name())
+	}
+
+	got, err := p.Lookup(netip.MustParseAddr("203.208.60.1"))
+	if err != nil {
+		t.Fatalf("Lookup() returned error: %v", err)
+	}
+	if got.CountryCode != "CN" || got.Country != "China" || got.Region != "Beijing" || got.City != "Beijing" {
+		t.Errorf("Lookup() = %+v", got)
+	}
+	if got.Latitude != 39.9042 || got.Longitude != 116.4074 {
+		t.Errorf("Lookup() location = %v,%v", got.Latitude, got.Longitude)
+	}
+
+	got, err = p.Lookup(netip.MustParseAddr("2001:4860:4860::8888"))
+	if err != nil || got.City != "Mountain View" {
+		t.Errorf("Lookup() IPv6 = %+v, %v", got, err)
+	}
+
+	if _, err := p.Lookup(netip.MustParseAddr("1.1.1.1")); err != ErrGeoNotFound {
+		t.Errorf("Lookup() for unknown address error = %v, want ErrGeoNotFound", err)
+	}
+}
+
+func TestOpenMMDBProviderInvalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "broken.mmdb")
+	os.WriteFile(path, []byte("not a database"), 0o644)
+
+	if _, err := OpenMMDBProvider(path); err == nil {
+		t.Error("OpenMMDBProvider() should fail for an invalid file")
+	}
+}
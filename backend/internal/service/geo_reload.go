@@ -0,0 +1,266 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"net/netip"
+	"sync"
+	"time"
+
+	"github.com/lRoccoon/utils-helper/internal/filestamp"
+)
+
+// DefaultGeoProbeIPs are looked up in every new database version before it
+// replaces the current one
+var DefaultGeoProbeIPs = []string{"8.8.8.8", "1.1.1.1", "2001:4860:4860::8888"}
+
+// DefaultGeoReloadInterval is how often database files are checked for changes
+const DefaultGeoReloadInterval = time.Minute
+
+// GeoDatabaseInfo describes a geolocation database loaded from a file
+type GeoDatabaseInfo struct {
+	Provider    string
+	Path        string
+	Type        string // database_type for MaxMind DBs, "csv" or "static"
+	Description string
+	BuildTime   time.Time // zero for formats without a build date
+	Records     int
+
+	LoadedAt  time.Time
+	ModTime   time.Time
+	Size      int64
+	Reloads   uint64
+	LastCheck time.Time
+	LastError string // why the latest version on disk was rejected
+}
+
+// geoDatabase is a provider loaded from a file that can describe itself
+type geoDatabase interface {
+	GeoProvider
+	Info() GeoDatabaseInfo
+}
+
+// geoDatabaseOpeners opens each file-backed provider kind
+var geoDatabaseOpeners = map[string]func(path string) (geoDatabase, error){
+	"mmdb":   func(path string) (geoDatabase, error) { return OpenMMDBProvider(path) },
+	"csv":    func(path string) (geoDatabase, error) { return LoadCSVGeoProvider(path) },
+	"static": func(path string) (geoDatabase, error) { return LoadStaticGeoProvider(path) },
+}
+
+// geoGeneration is one loaded version of a database file. Lookups hold a
+// reference so a replaced version is only closed after they finish.
+type geoGeneration struct {
+	db       geoDatabase
+	stamp    filestamp.Stamp
+	loadedAt time.Time
+	lookups  sync.WaitGroup
+	closed   chan struct{} // closed once the version is closed
+}
+
+// ReloadableGeoProvider serves lookups from a database file and swaps in a
+// new version when the file changes on disk. Replace the file by renaming a
+// complete copy over it, as geoipupdate does, rather than writing in place.
+type ReloadableGeoProvider struct {
+	kind   string
+	path   string
+	open   func(path string) (geoDatabase, error)
+	probes []netip.Addr
+
+	mu        sync.RWMutex
+	current   *geoGeneration
+	reloads   uint64
+	lastCheck time.Time
+	lastError string
+
+	reloadMu sync.Mutex
+	seen     filestamp.Stamp // version of the last file tried, zero if it was missing
+}
+
+// OpenReloadableGeoProvider opens a "mmdb", "csv" or "static" database file.
+// New versions must still answer every probe address the current one does.
+func OpenReloadableGeoProvider(kind, path string, probes []netip.Addr) (*ReloadableGeoProvider, error) {
+	open, ok := geoDatabaseOpeners[kind]
+	if !ok {
+		return nil, fmt.Errorf("geo provider %q is not file based", kind)
+	}
+
+	p := &ReloadableGeoProvider{kind: kind, path: path, open: open, probes: probes}
+	stamp, err := filestamp.Stat(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open geo database: %w", err)
+	}
+	gen, err := p.load(stamp)
+	if err != nil {
+		return nil, err
+	}
+	p.current = gen
+	p.seen = stamp
+	return p, nil
+}
+
+// Name returns the provider name of the underlying database
+func (p *ReloadableGeoProvider) Name() string {
+	return p.kind
+}
+
+// Lookup answers from the current version of the database
+func (p *ReloadableGeoProvider) Lookup(addr netip.Addr) (*GeoInfo, error) {
+	gen := p.acquire()
+	if gen == nil {
+		return nil, ErrGeoNotFound
+	}
+	defer gen.lookups.Done()
+	return gen.db.Lookup(addr)
+}
+
+// Info describes the current version and the latest reload attempt
+func (p *ReloadableGeoProvider) Info() GeoDatabaseInfo {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	info := GeoDatabaseInfo{Provider: p.kind, Path: p.path}
+	if p.current != nil {
+		info = p.current.db.Info()
+		info.LoadedAt = p.current.loadedAt
+		info.ModTime = p.current.stamp.ModTime
+		info.Size = p.current.stamp.Size
+	}
+	info.Reloads = p.reloads
+	info.LastCheck = p.lastCheck
+	info.LastError = p.lastError
+	return info
+}
+
+// Reload swaps in the file on disk if it changed since the last attempt and
+// passes validation, reporting whether it did. A rejected version is not
+// retried until the file changes again; the current one keeps serving.
+func (p *ReloadableGeoProvider) Reload() (bool, error) {
+	p.reloadMu.Lock()
+	defer p.reloadMu.Unlock()
+
+	stamp, err := filestamp.Stat(p.path)
+	if err != nil {
+		// Report a missing file once, not on every check
+		if p.seen == (filestamp.Stamp{}) {
+			p.recordCheck(nil)
+			return false, nil
+		}
+		p.seen = filestamp.Stamp{}
+		return false, p.recordCheck(err)
+	}
+	if stamp.Equal(p.seen) {
+		p.mu.Lock()
+		p.lastCheck = time.Now()
+		p.mu.Unlock()
+		return false, nil
+	}
+	p.seen = stamp
+
+	gen, err := p.load(stamp)
+	if err == nil {
+		if err = p.validate(gen.db); err != nil {
+			retireGeoGeneration(gen)
+		}
+	}
+	if err != nil {
+		return false, p.recordCheck(err)
+	}
+
+	p.mu.Lock()
+	old := p.current
+	p.current = gen
+	p.reloads++
+	p.lastCheck = time.Now()
+	p.lastError = ""
+	p.mu.Unlock()
+
+	if old != nil {
+		go retireGeoGeneration(old)
+	}
+	return true, nil
+}
+
+// Close closes the current version once in-flight lookups finish
+func (p *ReloadableGeoProvider) Close() error {
+	p.mu.Lock()
+	old := p.current
+	p.current = nil
+	p.mu.Unlock()
+
+	if old != nil {
+		retireGeoGeneration(old)
+	}
+	return nil
+}
+
+// acquire returns the current version with a lookup reference held
+func (p *ReloadableGeoProvider) acquire() *geoGeneration {
+	p.mu.RLock()
+	defer p.mu.RUnlock()
+
+	gen := p.current
+	if gen != nil {
+		gen.lookups.Add(1)
+	}
+	return gen
+}
+
+// load opens the file as it is now
+func (p *ReloadableGeoProvider) load(stamp filestamp.Stamp) (*geoGeneration, error) {
+	db, err := p.open(p.path)
+	if err != nil {
+		return nil, err
+	}
+	return &geoGeneration{db: db, stamp: stamp, loadedAt: time.Now(), closed: make(chan struct{})}, nil
+}
+
+// validate checks a new version against the current one. A version that lost
+// more than half of its records is most likely truncated, and every probe the
+// current version answers must still resolve.
+func (p *ReloadableGeoProvider) validate(db geoDatabase) error {
+	gen := p.acquire()
+	if gen != nil {
+		defer gen.lookups.Done()
+		if old, records := gen.db.Info().Records, db.Info().Records; records < old/2 {
+			return fmt.Errorf("new version has %d records, down from %d", records, old)
+		}
+	}
+
+	for _, addr := range p.probes {
+		_, err := db.Lookup(addr)
+		if err == nil {
+			continue
+		}
+		if !errors.Is(err, ErrGeoNotFound) {
+			return fmt.Errorf("probe lookup of %s failed: %w", addr, err)
+		}
+		if gen != nil {
+			if _, oldErr := gen.db.Lookup(addr); oldErr == nil {
+				return fmt.Errorf("probe %s no longer resolves", addr)
+			}
+		}
+	}
+	return nil
+}
+
+// recordCheck notes a reload attempt and returns its error with the path
+func (p *ReloadableGeoProvider) recordCheck(err error) error {
+	if err != nil {
+		err = fmt.Errorf("reload of %s rejected: %w", p.path, err)
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.lastCheck = time.Now()
+	if err != nil {
+		p.lastError = err.Error()
+	}
+	return err
+}
+
+// retireGeoGeneration closes a replaced version after its lookups finish
+func retireGeoGeneration(gen *geoGeneration) {
+	gen.lookups.Wait()
+	closeGeoProviders([]GeoProvider{gen.db})
+	close(gen.closed)
+}
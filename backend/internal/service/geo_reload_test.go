@@ -0,0 +1,148 @@
+package service
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// replaceTestMMDB writes a new database version and renames it over path
+func replaceTestMMDB(t *testing.T, path string, records map[string]map[string]interface{}, modTime time.Time) {
+	t.Helper()
+	next := writeTestMMDB(t, t.TempDir(), "GeoLite2-City", records)
+	if err := os.Chtimes(next, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Rename(next, path); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReloadableGeoProvider(t *testing.T) {
+	google := testCityRecord("US", "United States", "California", "Mountain View", 37.386, -122.0838)
+	path := writeTestMMDB(t, t.TempDir(), "GeoLite2-City", map[string]map[string]interface{}{
+		"8.8.8.0/24":      google,
+		"203.208.60.0/24": testCityRecord("CN", "China", "Beijing", "Beijing", 39.9042, 116.4074),
+	})
+
+	p, err := OpenReloadableGeoProvider("mmdb", path, []netip.Addr{netip.MustParseAddr("8.8.8.8")})
+	if err != nil {
+		t.Fatalf("OpenReloadableGeoProvider() error = %v", err)
+	}
+	defer p.Close()
+	chain := NewGeoChain(10, time.Hour, time.Hour, p)
+
+	info := p.Info()
+	if info.Type != "GeoLite2-City" || info.Records != 2 || info.Path != path {
+		t.Errorf("Info() = %+v", info)
+	}
+	if !info.BuildTime.Equal(time.Date(2026, 10, 13, 0, 0, 0, 0, time.UTC)) {
+		t.Errorf("Info().BuildTime = %v", info.BuildTime)
+	}
+
+	if reloaded, err := chain.Reload(); reloaded || err != nil {
+		t.Errorf("Reload() of unchanged file = %v, %v", reloaded, err)
+	}
+
+	beijing := netip.MustParseAddr("203.208.60.1")
+	if got, _ := chain.Lookup(beijing); got == nil || got.City != "Beijing" {
+		t.Fatalf("Lookup() = %+v", got)
+	}
+
+	// A lookup in flight keeps the old version open across the swap
+	inFlight := p.acquire()
+	base := time.Now().Add(-time.Hour)
+	replaceTestMMDB(t, path, map[string]map[string]interface{}{
+		"8.8.8.0/24":      google,
+		"203.208.60.0/24": testCityRecord("CN", "China", "Shanghai", "Shanghai", 31.2304, 121.4737),
+	}, base)
+
+	if reloaded, err := chain.Reload(); !reloaded || err != nil {
+		t.Fatalf("Reload() after update = %v, %v", reloaded, err)
+	}
+	if got, _ := chain.Lookup(beijing); got == nil || got.City != "Shanghai" {
+		t.Errorf("Lookup() after reload = %+v, want the new version past the cache", got)
+	}
+	if got, err := inFlight.db.Lookup(beijing); err != nil || got.City != "Beijing" {
+		t.Errorf("in-flight Lookup() = %+v, %v, want the old version still open", got, err)
+	}
+
+	select {
+	case <-inFlight.closed:
+		t.Fatal("old version was closed during a lookup")
+	case <-time.After(20 * time.Millisecond):
+	}
+	inFlight.lookups.Done()
+	select {
+	case <-inFlight.closed:
+	case <-time.After(time.Second):
+		t.Fatal("old version was not closed after its lookups finished")
+	}
+
+	// A version that stopped answering a probe is rejected and not retried
+	replaceTestMMDB(t, path, map[string]map[string]interface{}{
+		"203.208.60.0/24": testCityRecord("CN", "China", "Beijing", "Beijing", 39.9042, 116.4074),
+		"1.0.0.0/24":      testCityRecord("AU", "Australia", "", "", -33.494, 143.2104),
+	}, base.Add(time.Minute))
+
+	if reloaded, err := p.Reload(); reloaded || err == nil || !strings.Contains(err.Error(), "8.8.8.8") {
+		t.Errorf("Reload() of version without probe = %v, %v", reloaded, err)
+	}
+	if reloaded, err := p.Reload(); reloaded || err != nil {
+		t.Errorf("Reload() of rejected version again = %v, %v", reloaded, err)
+	}
+	if info := p.Info(); info.LastError == "" || info.Reloads != 1 {
+		t.Errorf("Info() after rejection = %+v", info)
+	}
+	if got, _ := p.Lookup(beijing); got == nil || got.City != "Shanghai" {
+		t.Errorf("Lookup() after rejection = %+v, want the current version", got)
+	}
+
+	// So is a broken file, while a missing one keeps the current version
+	if err := os.WriteFile(path, []byte("not a database"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if reloaded, err := p.Reload(); reloaded || err == nil {
+		t.Errorf("Reload() of broken file = %v, %v", reloaded, err)
+	}
+	os.Remove(path)
+	if _, err := p.Reload(); err == nil {
+		t.Error("Reload() of missing file should report it")
+	}
+	if _, err := p.Reload(); err != nil {
+		t.Errorf("Reload() of missing file again error = %v", err)
+	}
+	if got, _ := p.Lookup(beijing); got == nil || got.City != "Shanghai" {
+		t.Errorf("Lookup() after missing file = %+v", got)
+	}
+}
+
+func TestReloadableGeoProviderTruncated(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ranges.csv")
+	rows := "1.0.0.0,1.0.0.255,AU\n8.8.8.0,8.8.8.255,US\n9.9.9.0,9.9.9.255,CH\n203.208.60.0,203.208.60.255,CN\n"
+	if err := os.WriteFile(path, []byte(rows), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	p, err := OpenReloadableGeoProvider("csv", path, nil)
+	if err != nil {
+		t.Fatalf("OpenReloadableGeoProvider() error = %v", err)
+	}
+	defer p.Close()
+
+	os.WriteFile(path, []byte(rows[:30]), 0o644)
+	os.Chtimes(path, time.Now().Add(time.Minute), time.Now().Add(time.Minute))
+	if reloaded, err := p.Reload(); reloaded || err == nil {
+		t.Errorf("Reload() of truncated file = %v, %v", reloaded, err)
+	}
+	if info := p.Info(); info.Records != 4 || info.Type != "csv" {
+		t.Errorf("Info() = %+v", info)
+	}
+
+	if _, err := OpenReloadableGeoProvider("http", path, nil); err == nil {
+		t.Error("OpenReloadableGeoProvider(http) should fail")
+	}
+}
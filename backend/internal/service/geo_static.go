@@ -0,0 +1,101 @@
+package service
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/netip"
+	"os"
+	"sort"
+)
+
+// geoRecord represents a geolocation entry in override files
+type geoRecord struct {
+	Country     string  `json:"country"`
+	CountryCode string  `json:"country_code"`
+	Region      string  `json:"region"`
+	City        string  `json:"city"`
+	Latitude    float64 `json:"latitude"`
+	Longitude   float64 `json:"longitude"`
+}
+
+// staticGeoEntry maps a prefix to a fixed location
+type staticGeoEntry struct {
+	prefix netip.Prefix
+	info   GeoInfo
+}
+
+// StaticGeoProvider answers lookups from a fixed table of CIDR overrides,
+// typically used to label office or data center networks
+type StaticGeoProvider struct {
+	entries []staticGeoEntry
+	path    string
+}
+
+// LoadStaticGeoProvider loads overrides from a JSON file keyed by CIDR, e.g.
+// {"10.1.0.0/16": {"country": "China", "country_code": "CN", "city": "Beijing"}}
+func LoadStaticGeoProvider(path string) (*StaticGeoProvider, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read geo overrides: %w", err)
+	}
+
+	var records map[string]geoRecord
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse geo overrides: %w", err)
+	}
+
+	overrides := make(map[netip.Prefix]GeoInfo, len(records))
+	for cidr, r := range records {
+		prefix, err := ParseCIDR(cidr)
+		if err != nil {
+			return nil, fmt.Errorf("geo overrides: %w", err)
+		}
+		overrides[prefix] = GeoInfo{
+			Country:     r.Country,
+			CountryCode: r.CountryCode,
+			Region:      r.Region,
+			City:        r.City,
+			Latitude:    r.Latitude,
+			Longitude:   r.Longitude,
+		}
+	}
+	p := NewStaticGeoProvider(overrides)
+	p.path = path
+	return p, nil
+}
+
+// NewStaticGeoProvider creates a provider from a prefix to location table
+func NewStaticGeoProvider(overrides map[netip.Prefix]GeoInfo) *StaticGeoProvider {
+	p := &StaticGeoProvider{}
+	for prefix, info := range overrides {
+		p.entries = append(p.entries, staticGeoEntry{prefix: prefix.Masked(), info: info})
+	}
+
+	// Longest prefix first so the most specific override wins
+	sort.Slice(p.entries, func(i, j int) bool {
+		return p.entries[i].prefix.Bits() > p.entries[j].prefix.Bits()
+	})
+	return p
+}
+
+// Name returns the provider name
+func (p *StaticGeoProvider) Name() string {
+	return "static"
+}
+
+// Info describes the loaded override table
+func (p *StaticGeoProvider) Info() GeoDatabaseInfo {
+	return GeoDatabaseInfo{Provider: p.Name(), Path: p.path, Type: "static", Records: len(p.entries)}
+}
+
+// Lookup returns the most specific override containing the address
+func (p *StaticGeoProvider) Lookup(addr netip.Addr) (*GeoInfo, error) {
+	addr = addr.Unmap()
+	for _, e := range p.entries {
+		if e.prefix.Contains(addr) {
+			info := e.info
+			return &info, nil
+		}
+	}
+	return nil, ErrGeoNotFound
+}
@@ -0,0 +1,55 @@
+package service
+
+import (
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestStaticGeoProvider(t *testing.T) {
+	p := NewStaticGeoProvider(map[netip.Prefix]GeoInfo{
+		netip.MustParsePrefix("10.0.0.0/8"):  {Country: "Corporate", CountryCode: "CN"},
+		netip.MustParsePrefix("10.1.0.0/16"): {Country: "China", CountryCode: "CN", City: "Beijing"},
+	})
+
+	got, err := p.Lookup(netip.MustParseAddr("10.1.2.3"))
+	if err != nil || got.City != "Beijing" {
+		t.Errorf("Lookup() = %+v, %v, want most specific override", got, err)
+	}
+
+	got, err = p.Lookup(netip.MustParseAddr("::ffff:10.2.0.1"))
+	if err != nil || got.Country != "Corporate" {
+		t.Errorf("Lookup() = %+v, %v, want /8 override", got, err)
+	}
+
+	if _, err := p.Lookup(netip.MustParseAddr("8.8.8.8")); err != ErrGeoNotFound {
+		t.Errorf("Lookup() error = %v, want ErrGeoNotFound", err)
+	}
+}
+
+func TestLoadStaticGeoProvider(t *testing.T) {
+	dir := t.TempDir()
+
+	path := filepath.Join(dir, "overrides.json")
+	os.WriteFile(path, []byte(`{"192.168.10.0/24": {"country": "China", "country_code": "CN", "region": "Beijing", "city": "Beijing", "latitude": 39.9, "longitude": 116.4}}`), 0o644)
+
+	p, err := LoadStaticGeoProvider(path)
+	if err != nil {
+		t.Fatalf("LoadStaticGeoProvider() returned error: %v", err)
+	}
+	got, err := p.Lookup(netip.MustParseAddr("192.168.10.20"))
+	if err != nil || got.Region != "Beijing" || got.Latitude != 39.9 {
+		t.Errorf("Lookup() = %+v, %v", got, err)
+	}
+
+	bad := filepath.Join(dir, "bad.json")
+	os.WriteFile(bad, []byte(`{"not-a-cidr": {}}`), 0o644)
+	if _, err := LoadStaticGeoProvider(bad); err == nil {
+		t.Error("LoadStaticGeoProvider() should reject invalid prefixes")
+	}
+
+	if _, err := LoadStaticGeoProvider(filepath.Join(dir, "missing.json")); err == nil {
+		t.Error("LoadStaticGeoProvider() should fail for a missing file")
+	}
+}
@@ -0,0 +1,160 @@
+package service
+
+import (
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// fakeGeoProvider returns fixed answers and counts lookups
+type fakeGeoProvider struct {
+	name    string
+	answers map[string]*GeoInfo
+	err     error
+	calls   int
+}
+
+func (p *fakeGeoProvider) Name() string {
+	return p.name
+}
+
+func (p *fakeGeoProvider) Lookup(addr netip.Addr) (*GeoInfo, error) {
+	p.calls++
+	if p.err != nil {
+		return nil, p.err
+	}
+	if info, ok := p.answers[addr.String()]; ok {
+		copied := *info
+		return &copied, nil
+	}
+	return nil, ErrGeoNotFound
+}
+
+func TestGeoChainFallback(t *testing.T) {
+	first := &fakeGeoProvider{name: "first", answers: map[string]*GeoInfo{
+		"10.1.1.1": {Country: "Office", CountryCode: "CN"},
+	}}
+	broken := &fakeGeoProvider{name: "broken", err: errors.New("connection refused")}
+	last := &fakeGeoProvider{name: "last", answers: map[string]*GeoInfo{
+		"8.8.8.8": {Country: "United States", CountryCode: "US"},
+	}}
+
+	chain := NewGeoChain(0, 0, 0, first, broken, last)
+
+	got, err := chain.Lookup(netip.MustParseAddr("10.1.1.1"))
+	if err != nil || got.Country != "Office" || got.Source != "first" {
+		t.Errorf("Lookup() = %+v, %v, want first provider answer", got, err)
+	}
+	if broken.calls != 0 {
+		t.Error("Lookup() should stop at the first provider with an answer")
+	}
+
+	got, err = chain.Lookup(netip.MustParseAddr("8.8.8.8"))
+	if err != nil || got.CountryCode != "US" || got.Source != "last" {
+		t.Errorf("Lookup() = %+v, %v, want fallback to last provider", got, err)
+	}
+
+	_, err = chain.Lookup(netip.MustParseAddr("1.1.1.1"))
+	if !errors.Is(err, ErrGeoNotFound) {
+		t.Errorf("Lookup() error = %v, want ErrGeoNotFound", err)
+	}
+
+	stats := chain.Stats()
+	want := []GeoProviderStats{
+		{Name: "first", Hits: 1, Misses: 2},
+		{Name: "broken", Errors: 2},
+		{Name: "last", Hits: 1, Misses: 1},
+	}
+	for i, w := range want {
+		if stats.Providers[i] != w {
+			t.Errorf("Stats().Providers[%d] = %+v, want %+v", i, stats.Providers[i], w)
+		}
+	}
+}
+
+func TestGeoChainCache(t *testing.T) {
+	p := &fakeGeoProvider{name: "fake", answers: map[string]*GeoInfo{
+		"8.8.8.8": {Country: "United States", CountryCode: "US"},
+	}}
+	chain := NewGeoChain(10, time.Hour, time.Minute, p)
+
+	for i := 0; i < 3; i++ {
+		chain.Lookup(netip.MustParseAddr("8.8.8.8"))
+		chain.Lookup(netip.MustParseAddr("1.1.1.1"))
+	}
+
+	// Positive and negative results are each looked up once
+	if p.calls != 2 {
+		t.Errorf("provider called %d times, want 2", p.calls)
+	}
+
+	stats := chain.Stats()
+	if stats.CacheHits != 4 || stats.CacheMisses != 2 || stats.CacheSize != 2 {
+		t.Errorf("Stats() cache = %d hits, %d misses, %d entries", stats.CacheHits, stats.CacheMisses, stats.CacheSize)
+	}
+}
+
+func TestGeoChainDoesNotCacheErrors(t *testing.T) {
+	p := &fakeGeoProvider{name: "flaky", err: errors.New("timeout")}
+	chain := NewGeoChain(10, time.Hour, time.Minute, p)
+
+	chain.Lookup(netip.MustParseAddr("8.8.8.8"))
+	chain.Lookup(netip.MustParseAddr("8.8.8.8"))
+
+	if p.calls != 2 {
+		t.Errorf("provider called %d times, want 2 since errors are not cached", p.calls)
+	}
+}
+
+func TestConfigureGeo(t *testing.T) {
+	defer SetGeoChain(NewGeoChain(0, 0, 0))
+
+	dir := t.TempDir()
+	staticPath := filepath.Join(dir, "overrides.json")
+	os.WriteFile(staticPath, []byte(`{"10.20.0.0/16": {"country": "China", "country_code": "CN", "city": "Shanghai"}}`), 0o644)
+	csvPath := filepath.Join(dir, "ranges.csv")
+	os.WriteFile(csvPath, []byte("8.8.8.0,8.8.8.255,US,United States,California,Mountain View,37.386,-122.0838\n"), 0o644)
+
+	err := ConfigureGeo(GeoConfig{
+		StaticPath: staticPath,
+		CSVPath:    csvPath,
+	})
+	if err != nil {
+		t.Fatalf("ConfigureGeo() returned error: %v", err)
+	}
+
+	got, _ := GetGeoLocation("10.20.3.4")
+	if got.City != "Shanghai" || got.Source != "static" {
+		t.Errorf("GetGeoLocation() override = %+v", got)
+	}
+
+	got, _ = GetGeoLocation("8.8.8.8")
+	if got.City != "Mountain View" || got.Source != "csv" {
+		t.Errorf("GetGeoLocation() CSV = %+v", got)
+	}
+
+	// Private addresses without an override are still reported as local
+	got, _ = GetGeoLocation("10.30.0.1")
+	if got.Country != "Local" {
+		t.Errorf("GetGeoLocation() private = %+v", got)
+	}
+
+	stats := GetGeoStats()
+	if len(stats.Providers) != 2 || stats.Providers[0].Name != "static" || stats.Providers[1].Name != "csv" {
+		t.Errorf("GetGeoStats() providers = %+v", stats.Providers)
+	}
+}
+
+func TestConfigureGeoErrors(t *testing.T) {
+	defer SetGeoChain(NewGeoChain(0, 0, 0))
+
+	if err := ConfigureGeo(GeoConfig{Providers: []string{"carrier-pigeon"}}); err == nil {
+		t.Error("ConfigureGeo() should reject unknown providers")
+	}
+	if err := ConfigureGeo(GeoConfig{MMDBPath: filepath.Join(t.TempDir(), "missing.mmdb")}); err == nil {
+		t.Error("ConfigureGeo() should fail for a missing database")
+	}
+}
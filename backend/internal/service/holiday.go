@@ -3,7 +3,7 @@ package service
 import (
 	"embed"
 	"encoding/json"
-	"log"
+	"log/slog"
 	"sync"
 	"time"
 )
@@ -34,13 +34,13 @@ func loadHolidays() {
 	holidayOnce.Do(func() {
 		data, err := holidaysData.ReadFile("holidays.json")
 		if err != nil {
-			log.Printf("Warning: Failed to load holidays data: %v", err)
+			slog.Warn("Failed to load holidays data", "error", err)
 			chineseHolidays = make(map[string]HolidayNote)
 			return
 		}
 
 		if err := json.Unmarshal(data, &chineseHolidays); err != nil {
-			log.Printf("Warning: Failed to parse holidays data: %v", err)
+			slog.Warn("Failed to parse holidays data", "error", err)
 			chineseHolidays = make(map[string]HolidayNote)
 			return
 		}
@@ -94,3 +94,34 @@ func GetHolidayInfo(date string) HolidayInfo {
 		Type:      "weekday",
 	}
 }
+
+// HolidayCoverage describes which years the holiday dataset covers
+type HolidayCoverage struct {
+	// EntriesByYear counts the holidays and compensatory workdays per year
+	EntriesByYear map[int]int
+	// First and Last are the earliest and latest dates in the dataset
+	First time.Time
+	Last  time.Time
+}
+
+// GetHolidayCoverage returns the coverage of the holiday dataset; dates of
+// years it does not cover are answered from the weekday alone
+func GetHolidayCoverage() HolidayCoverage {
+	loadHolidays()
+
+	coverage := HolidayCoverage{EntriesByYear: make(map[int]int)}
+	for date := range chineseHolidays {
+		t, err := time.Parse("2006-01-02", date)
+		if err != nil {
+			continue
+		}
+		coverage.EntriesByYear[t.Year()]++
+		if coverage.First.IsZero() || t.Before(coverage.First) {
+			coverage.First = t
+		}
+		if t.After(coverage.Last) {
+			coverage.Last = t
+		}
+	}
+	return coverage
+}
@@ -146,3 +146,24 @@ func TestLoadHolidaysErrorPath(t *testing.T) {
 		t.Error("chineseHolidays should not be nil")
 	}
 }
+
+func TestGetHolidayCoverage(t *testing.T) {
+	coverage := GetHolidayCoverage()
+	if coverage.EntriesByYear[2024] == 0 {
+		t.Fatalf("EntriesByYear = %v, want entries for 2024", coverage.EntriesByYear)
+	}
+
+	total := 0
+	for _, n := range coverage.EntriesByYear {
+		total += n
+	}
+	if total != len(chineseHolidays) {
+		t.Errorf("counted %d entries, dataset has %d", total, len(chineseHolidays))
+	}
+	if coverage.First.IsZero() || coverage.Last.Before(coverage.First) {
+		t.Errorf("First = %v, Last = %v", coverage.First, coverage.Last)
+	}
+	if _, ok := chineseHolidays[coverage.Last.Format("2006-01-02")]; !ok {
+		t.Errorf("Last = %v is not in the dataset", coverage.Last)
+	}
+}
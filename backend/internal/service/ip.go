@@ -1,7 +1,7 @@
 package service
 
 import (
-	"net"
+	"net/netip"
 )
 
 // GeoInfo represents geographic information
@@ -12,34 +12,42 @@ type GeoInfo struct {
 	City        string
 	Latitude    float64
 	Longitude   float64
+	Source      string // name of the provider that answered
 }
 
-// GetGeoLocation returns geographic information for an IP address
-// This is a placeholder implementation. In production, you would use
-// a service like MaxMind GeoIP2 or ip-api.com
+// GetGeoLocation returns geographic information for an IP address.
+// The configured provider chain is consulted first (see ConfigureGeo), so
+// overrides can label private networks. Addresses no provider knows about
+// are reported as Local, Reserved or Unknown based on their classification.
 func GetGeoLocation(ip string) (*GeoInfo, error) {
 	// Parse IP to validate
-	parsedIP := net.ParseIP(ip)
-	if parsedIP == nil {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
 		return nil, nil
 	}
+	addr = addr.WithZone("").Unmap()
 
-	// For localhost/private IPs, return empty info
-	if parsedIP.IsLoopback() || parsedIP.IsPrivate() {
+	if info, err := lookupGeoChain(addr); err == nil {
+		return info, nil
+	}
+
+	// Special-purpose addresses have no geographic location
+	class := ClassifyAddr(addr)
+	if class.IsLocal() {
 		return &GeoInfo{
 			Country:     "Local",
 			CountryCode: "LOCAL",
 		}, nil
 	}
+	if !class.Global {
+		return &GeoInfo{
+			Country:     "Reserved",
+			CountryCode: "RESERVED",
+		}, nil
+	}
 
-	// Placeholder - in production, integrate with GeoIP database
-	// For now, return basic info
 	return &GeoInfo{
 		Country:     "Unknown",
 		CountryCode: "XX",
-		Region:      "",
-		City:        "",
-		Latitude:    0,
-		Longitude:   0,
 	}, nil
 }
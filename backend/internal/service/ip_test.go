@@ -70,3 +70,28 @@ func TestGetGeoLocationNil(t *testing.T) {
 		t.Errorf("GetGeoLocation() should return nil for invalid IP, got: %v", got)
 	}
 }
+
+func TestGetGeoLocationSpecialPurpose(t *testing.T) {
+	tests := []struct {
+		ip          string
+		countryCode string
+	}{
+		{"100.64.1.1", "LOCAL"},
+		{"fd00::1", "LOCAL"},
+		{"203.0.113.1", "RESERVED"},
+		{"2001:db8::1", "RESERVED"},
+		{"198.18.0.1", "RESERVED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			got, err := GetGeoLocation(tt.ip)
+			if err != nil || got == nil {
+				t.Fatalf("GetGeoLocation(%s) = %v, %v", tt.ip, got, err)
+			}
+			if got.CountryCode != tt.countryCode {
+				t.Errorf("GetGeoLocation(%s).CountryCode = %s, want %s", tt.ip, got.CountryCode, tt.countryCode)
+			}
+		})
+	}
+}
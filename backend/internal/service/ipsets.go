@@ -0,0 +1,293 @@
+package service
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrIPSetNotFound is returned for rule sets that do not exist
+var ErrIPSetNotFound = errors.New("rule set not found")
+
+// ipSetNamePattern restricts rule set names to URL- and file-safe characters
+var ipSetNamePattern = regexp.MustCompile(`^[a-z0-9][a-z0-9_-]{0,63}$`)
+
+// IPRuleSet is a named list of prefixes such as "office" or "blocked"
+type IPRuleSet struct {
+	Name        string   `json:"-"`
+	Description string   `json:"description,omitempty"`
+	Prefixes    []string `json:"prefixes"`
+}
+
+// IPSetMatch reports a rule set containing an address and the most
+// specific of its prefixes that matched
+type IPSetMatch struct {
+	Set    string
+	Prefix string
+}
+
+// IPRuleStore holds named rule sets, persisted as JSON in a local file.
+// Lookups use a prefix trie rebuilt whenever the sets change.
+type IPRuleStore struct {
+	mu   sync.RWMutex
+	path string
+	sets map[string]IPRuleSet
+	trie *PrefixTrie
+}
+
+// NewIPRuleStore creates a store backed by path. A missing file is an empty
+// store; an empty path keeps the sets in memory only.
+func NewIPRuleStore(path string) (*IPRuleStore, error) {
+	s := &IPRuleStore{path: path, sets: make(map[string]IPRuleSet), trie: NewPrefixTrie()}
+	if path == "" {
+		return s, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var sets map[string]IPRuleSet
+	if err := json.Unmarshal(data, &sets); err != nil {
+		return nil, fmt.Errorf("invalid rule set file %s: %w", path, err)
+	}
+	for name, set := range sets {
+		set.Name = name
+		if set, err = normalizeIPRuleSet(set); err != nil {
+			return nil, fmt.Errorf("rule set file %s: %w", path, err)
+		}
+		s.sets[name] = set
+	}
+	s.trie = buildIPSetTrie(s.sets)
+	return s, nil
+}
+
+// List returns every rule set sorted by name
+func (s *IPRuleStore) List() []IPRuleSet {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	sets := make([]IPRuleSet, 0, len(s.sets))
+	for _, set := range s.sets {
+		sets = append(sets, set)
+	}
+	sort.Slice(sets, func(i, j int) bool { return sets[i].Name < sets[j].Name })
+	return sets
+}
+
+// Get returns the named rule set
+func (s *IPRuleStore) Get(name string) (IPRuleSet, error) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	set, ok := s.sets[name]
+	if !ok {
+		return IPRuleSet{}, ErrIPSetNotFound
+	}
+	return set, nil
+}
+
+// Put creates or replaces a rule set and saves the file
+func (s *IPRuleStore) Put(set IPRuleSet) (IPRuleSet, error) {
+	set, err := normalizeIPRuleSet(set)
+	if err != nil {
+		return IPRuleSet{}, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sets := make(map[string]IPRuleSet, len(s.sets)+1)
+	for name, existing := range s.sets {
+		sets[name] = existing
+	}
+	sets[set.Name] = set
+	if err := s.commit(sets); err != nil {
+		return IPRuleSet{}, err
+	}
+	return set, nil
+}
+
+// Delete removes a rule set and saves the file
+func (s *IPRuleStore) Delete(name string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if _, ok := s.sets[name]; !ok {
+		return ErrIPSetNotFound
+	}
+	sets := make(map[string]IPRuleSet, len(s.sets))
+	for n, existing := range s.sets {
+		if n != name {
+			sets[n] = existing
+		}
+	}
+	return s.commit(sets)
+}
+
+// Match returns every rule set containing addr, sorted by set name
+func (s *IPRuleStore) Match(addr netip.Addr) []IPSetMatch {
+	s.mu.RLock()
+	trie := s.trie
+	s.mu.RUnlock()
+
+	// Matches arrive least specific first, so later ones win per set
+	best := make(map[string]string)
+	for _, m := range trie.Lookup(addr) {
+		best[m.Value] = m.Prefix.String()
+	}
+
+	matches := make([]IPSetMatch, 0, len(best))
+	for set, prefix := range best {
+		matches = append(matches, IPSetMatch{Set: set, Prefix: prefix})
+	}
+	sort.Slice(matches, func(i, j int) bool { return matches[i].Set < matches[j].Set })
+	return matches
+}
+
+// InSets reports whether addr is in any of the named rule sets
+func (s *IPRuleStore) InSets(addr netip.Addr, names ...string) bool {
+	s.mu.RLock()
+	trie := s.trie
+	s.mu.RUnlock()
+
+	for _, m := range trie.Lookup(addr) {
+		for _, name := range names {
+			if m.Value == name {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// commit saves sets to the file and swaps them in; s.mu must be held
+func (s *IPRuleStore) commit(sets map[string]IPRuleSet) error {
+	if s.path != "" {
+		if err := writeIPRuleSets(s.path, sets); err != nil {
+			return err
+		}
+	}
+	s.sets = sets
+	s.trie = buildIPSetTrie(sets)
+	return nil
+}
+
+// writeIPRuleSets writes the file atomically through a temporary file
+func writeIPRuleSets(path string, sets map[string]IPRuleSet) error {
+	data, err := json.MarshalIndent(sets, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(path), ".ipsets-*.json")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(append(data, '\n')); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
+
+// normalizeIPRuleSet validates the name and prefixes of a rule set
+func normalizeIPRuleSet(set IPRuleSet) (IPRuleSet, error) {
+	set.Name = strings.TrimSpace(set.Name)
+	if !ipSetNamePattern.MatchString(set.Name) {
+		return IPRuleSet{}, fmt.Errorf("invalid rule set name %q, use lowercase letters, digits, '_' and '-'", set.Name)
+	}
+
+	prefixes := make([]string, 0, len(set.Prefixes))
+	seen := make(map[string]bool, len(set.Prefixes))
+	for _, p := range set.Prefixes {
+		prefix, err := ParseCIDR(p)
+		if err != nil {
+			return IPRuleSet{}, fmt.Errorf("rule set %q: %w", set.Name, err)
+		}
+		if s := prefix.String(); !seen[s] {
+			seen[s] = true
+			prefixes = append(prefixes, s)
+		}
+	}
+	set.Prefixes = prefixes
+	return set, nil
+}
+
+// buildIPSetTrie indexes every prefix of every set
+func buildIPSetTrie(sets map[string]IPRuleSet) *PrefixTrie {
+	trie := NewPrefixTrie()
+	for name, set := range sets {
+		for _, p := range set.Prefixes {
+			if prefix, err := netip.ParsePrefix(p); err == nil {
+				trie.Insert(prefix, name)
+			}
+		}
+	}
+	return trie
+}
+
+var (
+	ipRuleStore   *IPRuleStore
+	ipRuleStoreMu sync.RWMutex
+)
+
+// ConfigureIPSets loads the rule set file used by GetIPRuleStore
+func ConfigureIPSets(path string) error {
+	store, err := NewIPRuleStore(path)
+	if err != nil {
+		return err
+	}
+	SetIPRuleStore(store)
+	return nil
+}
+
+// SetIPRuleStore replaces the rule store returned by GetIPRuleStore
+func SetIPRuleStore(store *IPRuleStore) {
+	ipRuleStoreMu.Lock()
+	ipRuleStore = store
+	ipRuleStoreMu.Unlock()
+}
+
+// GetIPRuleStore returns the configured rule store, or an empty in-memory
+// store if none was configured
+func GetIPRuleStore() *IPRuleStore {
+	ipRuleStoreMu.RLock()
+	store := ipRuleStore
+	ipRuleStoreMu.RUnlock()
+	if store != nil {
+		return store
+	}
+
+	ipRuleStoreMu.Lock()
+	defer ipRuleStoreMu.Unlock()
+	if ipRuleStore == nil {
+		ipRuleStore, _ = NewIPRuleStore("")
+	}
+	return ipRuleStore
+}
+
+// MatchIPSets returns every configured rule set containing ip
+func MatchIPSets(ip string) []IPSetMatch {
+	addr, err := netip.ParseAddr(strings.TrimSpace(ip))
+	if err != nil {
+		return nil
+	}
+	return GetIPRuleStore().Match(addr.Unmap().WithZone(""))
+}
@@ -0,0 +1,108 @@
+package service
+
+import (
+	"errors"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestIPRuleStorePersistence(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "ipsets.json")
+
+	store, err := NewIPRuleStore(path)
+	if err != nil {
+		t.Fatalf("NewIPRuleStore() on missing file error = %v", err)
+	}
+
+	office, err := store.Put(IPRuleSet{
+		Name:        "office",
+		Description: "HQ and branch",
+		Prefixes:    []string{"10.1.0.0/16", "10.1.0.0/16", "192.0.2.77", "2001:db8:10::/48"},
+	})
+	if err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+	want := []string{"10.1.0.0/16", "192.0.2.77/32", "2001:db8:10::/48"}
+	if !reflect.DeepEqual(office.Prefixes, want) {
+		t.Errorf("normalized prefixes = %v, want %v", office.Prefixes, want)
+	}
+	if _, err := store.Put(IPRuleSet{Name: "vpn", Prefixes: []string{"10.1.8.0/22"}}); err != nil {
+		t.Fatalf("Put() error = %v", err)
+	}
+
+	// A new store sees the saved sets
+	reloaded, err := NewIPRuleStore(path)
+	if err != nil {
+		t.Fatalf("NewIPRuleStore() error = %v", err)
+	}
+	if sets := reloaded.List(); len(sets) != 2 || sets[0].Name != "office" || sets[1].Name != "vpn" {
+		t.Fatalf("List() = %+v", sets)
+	}
+
+	matches := reloaded.Match(netip.MustParseAddr("10.1.9.1"))
+	wantMatches := []IPSetMatch{{Set: "office", Prefix: "10.1.0.0/16"}, {Set: "vpn", Prefix: "10.1.8.0/22"}}
+	if !reflect.DeepEqual(matches, wantMatches) {
+		t.Errorf("Match() = %+v, want %+v", matches, wantMatches)
+	}
+	if !reloaded.InSets(netip.MustParseAddr("192.0.2.77"), "blocked", "office") {
+		t.Error("InSets(office) = false, want true")
+	}
+	if reloaded.InSets(netip.MustParseAddr("192.0.2.78"), "office") {
+		t.Error("InSets(192.0.2.78, office) = true, want false")
+	}
+
+	if err := reloaded.Delete("vpn"); err != nil {
+		t.Fatalf("Delete() error = %v", err)
+	}
+	if err := reloaded.Delete("vpn"); !errors.Is(err, ErrIPSetNotFound) {
+		t.Errorf("Delete() twice error = %v, want ErrIPSetNotFound", err)
+	}
+	if _, err := reloaded.Get("vpn"); !errors.Is(err, ErrIPSetNotFound) {
+		t.Errorf("Get() after delete error = %v, want ErrIPSetNotFound", err)
+	}
+	if m := reloaded.Match(netip.MustParseAddr("10.1.9.1")); len(m) != 1 {
+		t.Errorf("Match() after delete = %+v, want only office", m)
+	}
+}
+
+func TestIPRuleStoreValidation(t *testing.T) {
+	store, _ := NewIPRuleStore("")
+
+	for _, set := range []IPRuleSet{
+		{Name: "Office", Prefixes: []string{"10.0.0.0/8"}},
+		{Name: "../etc", Prefixes: []string{"10.0.0.0/8"}},
+		{Name: "office", Prefixes: []string{"10.0.0.0/40"}},
+	} {
+		if _, err := store.Put(set); err == nil {
+			t.Errorf("Put(%+v) should fail", set)
+		}
+	}
+
+	path := filepath.Join(t.TempDir(), "bad.json")
+	if err := os.WriteFile(path, []byte(`{"office": {"prefixes": ["not-a-prefix"]}}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewIPRuleStore(path); err == nil {
+		t.Error("NewIPRuleStore() with invalid prefix should fail")
+	}
+}
+
+func TestMatchIPSets(t *testing.T) {
+	defer SetIPRuleStore(nil)
+
+	store, _ := NewIPRuleStore("")
+	if _, err := store.Put(IPRuleSet{Name: "blocked", Prefixes: []string{"198.51.100.0/24"}}); err != nil {
+		t.Fatal(err)
+	}
+	SetIPRuleStore(store)
+
+	if m := MatchIPSets("198.51.100.9"); len(m) != 1 || m[0].Set != "blocked" {
+		t.Errorf("MatchIPSets() = %+v, want blocked", m)
+	}
+	if m := MatchIPSets("not-an-ip"); m != nil {
+		t.Errorf("MatchIPSets(invalid) = %+v, want nil", m)
+	}
+}
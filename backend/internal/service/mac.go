@@ -0,0 +1,330 @@
+package service
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"net"
+	"net/netip"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// oui.csv is a snapshot in the IEEE registry CSV format. It only covers
+// common vendors; set OUI_REGISTRY_PATH to the full IEEE files for the rest.
+//
+//go:embed oui.csv
+var ouiRegistryData []byte
+
+// ErrInvalidMAC is returned for strings that are not a 48-bit MAC address
+var ErrInvalidMAC = errors.New("invalid MAC address")
+
+// MACInfo describes a 48-bit MAC address
+type MACInfo struct {
+	Address   net.HardwareAddr
+	Multicast bool // I/G bit: group rather than individual address
+	Local     bool // U/L bit: locally administered, e.g. randomized Wi-Fi addresses
+	Broadcast bool
+	Special   string // well-known use such as "IPv6 multicast" or "VRRP (IPv4)"
+
+	Vendor         string
+	VendorPrefix   string // assigned block, e.g. "00:1B:63" or "70:B3:D5:0A:B"
+	VendorRegistry string // "MA-L", "MA-M", "MA-S", "IAB" or "CID"
+
+	InterfaceID [8]byte    // modified EUI-64 interface identifier
+	LinkLocal   netip.Addr // fe80::/64 SLAAC address derived from the MAC
+}
+
+// specialMAC is a well-known address or range identified by its leading bits
+type specialMAC struct {
+	prefix []byte
+	bits   int
+	name   string
+}
+
+var specialMACs = []specialMAC{
+	{[]byte{0, 0, 0, 0, 0, 0}, 48, "Null address"},
+	{[]byte{0x01, 0x00, 0x5e, 0x00}, 25, "IPv4 multicast"},
+	{[]byte{0x33, 0x33}, 16, "IPv6 multicast"},
+	{[]byte{0x00, 0x00, 0x5e, 0x00, 0x01}, 40, "VRRP (IPv4)"},
+	{[]byte{0x00, 0x00, 0x5e, 0x00, 0x02}, 40, "VRRP (IPv6)"},
+	{[]byte{0x01, 0x80, 0xc2, 0x00, 0x00, 0x00}, 48, "Spanning Tree (802.1D)"},
+	{[]byte{0x01, 0x80, 0xc2, 0x00, 0x00, 0x02}, 48, "Slow protocols (LACP)"},
+	{[]byte{0x01, 0x80, 0xc2, 0x00, 0x00, 0x0e}, 48, "LLDP"},
+	{[]byte{0x01, 0x80, 0xc2, 0x00, 0x00, 0x00}, 44, "IEEE 802.1 link-local"},
+	{[]byte{0x01, 0x00, 0x0c, 0xcc, 0xcc, 0xcc}, 48, "Cisco CDP/VTP"},
+}
+
+// ParseMAC parses a 48-bit MAC address in colon (00:1b:63:84:45:e6), dash
+// (00-1B-63-84-45-E6), Cisco dot (001b.6384.45e6) or bare hex notation
+func ParseMAC(s string) (net.HardwareAddr, error) {
+	s = strings.TrimSpace(s)
+	if len(s) == 12 && !strings.ContainsAny(s, ":-.") {
+		mac, err := hex.DecodeString(s)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %q", ErrInvalidMAC, s)
+		}
+		return net.HardwareAddr(mac), nil
+	}
+
+	mac, err := net.ParseMAC(s)
+	if err != nil || len(mac) != 6 {
+		return nil, fmt.Errorf("%w: %q", ErrInvalidMAC, s)
+	}
+	return mac, nil
+}
+
+// LookupMAC parses a MAC address and describes its bits, vendor and EUI-64
+// derived IPv6 addresses
+func LookupMAC(s string) (*MACInfo, error) {
+	mac, err := ParseMAC(s)
+	if err != nil {
+		return nil, err
+	}
+
+	info := &MACInfo{
+		Address:   mac,
+		Multicast: mac[0]&0x01 != 0,
+		Local:     mac[0]&0x02 != 0,
+		Broadcast: bytes.Equal(mac, []byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff}),
+	}
+	for _, sp := range specialMACs {
+		if macHasPrefix(mac, sp.prefix, sp.bits) {
+			info.Special = sp.name
+			break
+		}
+	}
+	if info.Broadcast {
+		info.Special = "Broadcast"
+	}
+
+	if entry, ok := currentOUIRegistry().Lookup(mac); ok {
+		info.Vendor = entry.Organization
+		info.VendorPrefix = entry.Prefix
+		info.VendorRegistry = entry.Registry
+	}
+
+	info.InterfaceID = EUI64InterfaceID(mac)
+	var ll [16]byte
+	ll[0], ll[1] = 0xfe, 0x80
+	copy(ll[8:], info.InterfaceID[:])
+	info.LinkLocal = netip.AddrFrom16(ll)
+	return info, nil
+}
+
+// EUI64InterfaceID returns the modified EUI-64 interface identifier of a MAC
+// address (RFC 4291 appendix A): ff:fe is inserted in the middle and the
+// universal/local bit is inverted
+func EUI64InterfaceID(mac net.HardwareAddr) [8]byte {
+	var id [8]byte
+	if len(mac) != 6 {
+		return id
+	}
+	copy(id[0:3], mac[0:3])
+	id[3], id[4] = 0xff, 0xfe
+	copy(id[5:8], mac[3:6])
+	id[0] ^= 0x02
+	return id
+}
+
+// MACFromEUI64 extracts the MAC address embedded in an IPv6 address whose
+// interface identifier was derived with EUI-64, as SLAAC did before privacy
+// and stable-opaque addresses
+func MACFromEUI64(addr netip.Addr) (net.HardwareAddr, bool) {
+	if !addr.Is6() || addr.Is4In6() {
+		return nil, false
+	}
+	b := addr.As16()
+	if b[11] != 0xff || b[12] != 0xfe {
+		return nil, false
+	}
+	return net.HardwareAddr{b[8] ^ 0x02, b[9], b[10], b[13], b[14], b[15]}, true
+}
+
+// macHasPrefix reports whether the first bits of mac equal those of prefix
+func macHasPrefix(mac net.HardwareAddr, prefix []byte, bits int) bool {
+	for i := 0; i < bits; i += 8 {
+		mask := byte(0xff)
+		if bits-i < 8 {
+			mask = byte(0xff << (8 - (bits - i)))
+		}
+		if mac[i/8]&mask != prefix[i/8]&mask {
+			return false
+		}
+	}
+	return true
+}
+
+// OUIEntry is one assignment of the IEEE registry
+type OUIEntry struct {
+	Registry     string
+	Prefix       string // assignment in colon notation
+	Organization string
+}
+
+// OUIRegistry maps IEEE MA-L (24-bit), MA-M (28-bit) and MA-S/IAB (36-bit)
+// assignments to their organizations
+type OUIRegistry struct {
+	entries map[int]map[uint64]OUIEntry // by prefix length in bits
+	count   int
+}
+
+// ouiRegistryBits maps the registry column of the IEEE files to prefix lengths
+var ouiRegistryBits = map[string]int{
+	"MA-L": 24,
+	"CID":  24,
+	"MA-M": 28,
+	"MA-S": 36,
+	"IAB":  36,
+}
+
+// NewOUIRegistry creates an empty registry
+func NewOUIRegistry() *OUIRegistry {
+	return &OUIRegistry{entries: make(map[int]map[uint64]OUIEntry)}
+}
+
+// Add parses IEEE registry CSV data (oui.csv, mam.csv, oui36.csv or cid.csv)
+// into the registry
+func (r *OUIRegistry) Add(data io.Reader) error {
+	reader := csv.NewReader(data)
+	reader.FieldsPerRecord = -1
+
+	for line := 1; ; line++ {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid OUI registry: %w", err)
+		}
+		if line == 1 && len(fields) > 0 && fields[0] == "Registry" {
+			continue
+		}
+		if len(fields) < 3 {
+			return fmt.Errorf("OUI registry line %d: expected at least 3 columns", line)
+		}
+
+		registry := strings.TrimSpace(fields[0])
+		bits, ok := ouiRegistryBits[registry]
+		assignment := strings.TrimSpace(fields[1])
+		if !ok || len(assignment)*4 != bits {
+			return fmt.Errorf("OUI registry line %d: invalid %s assignment %q", line, registry, assignment)
+		}
+		key, err := strconv.ParseUint(assignment, 16, 64)
+		if err != nil {
+			return fmt.Errorf("OUI registry line %d: invalid assignment %q", line, assignment)
+		}
+
+		if r.entries[bits] == nil {
+			r.entries[bits] = make(map[uint64]OUIEntry)
+		}
+		if _, exists := r.entries[bits][key]; !exists {
+			r.count++
+		}
+		r.entries[bits][key] = OUIEntry{
+			Registry:     registry,
+			Prefix:       formatOUIPrefix(assignment),
+			Organization: strings.TrimSpace(fields[2]),
+		}
+	}
+}
+
+// Lookup returns the most specific assignment containing mac. The
+// individual/group bit is ignored so multicast addresses resolve as well.
+func (r *OUIRegistry) Lookup(mac net.HardwareAddr) (OUIEntry, bool) {
+	if len(mac) != 6 {
+		return OUIEntry{}, false
+	}
+	var v uint64
+	for i, b := range mac {
+		if i == 0 {
+			b &^= 0x01
+		}
+		v = v<<8 | uint64(b)
+	}
+
+	for _, bits := range []int{36, 28, 24} {
+		if entry, ok := r.entries[bits][v>>(48-bits)]; ok {
+			return entry, true
+		}
+	}
+	return OUIEntry{}, false
+}
+
+// Len returns the number of assignments
+func (r *OUIRegistry) Len() int {
+	return r.count
+}
+
+// formatOUIPrefix writes an assignment in colon notation, e.g. 70:B3:D5:0A:B
+func formatOUIPrefix(assignment string) string {
+	assignment = strings.ToUpper(assignment)
+	var parts []string
+	for len(assignment) > 2 {
+		parts = append(parts, assignment[:2])
+		assignment = assignment[2:]
+	}
+	return strings.Join(append(parts, assignment), ":")
+}
+
+var (
+	ouiRegistry   *OUIRegistry
+	ouiRegistryMu sync.RWMutex
+	ouiOnce       sync.Once
+)
+
+// LoadOUIRegistry replaces the embedded snapshot with IEEE registry files,
+// e.g. oui.csv, mam.csv and oui36.csv downloaded from standards-oui.ieee.org
+func LoadOUIRegistry(paths ...string) error {
+	registry := NewOUIRegistry()
+	for _, path := range paths {
+		f, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		err = registry.Add(f)
+		f.Close()
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	SetOUIRegistry(registry)
+	return nil
+}
+
+// SetOUIRegistry replaces the registry used by LookupMAC
+func SetOUIRegistry(registry *OUIRegistry) {
+	ouiOnce.Do(func() {})
+
+	ouiRegistryMu.Lock()
+	ouiRegistry = registry
+	ouiRegistryMu.Unlock()
+}
+
+// GetOUIRegistrySize returns the number of assignments in the active registry
+func GetOUIRegistrySize() int {
+	return currentOUIRegistry().Len()
+}
+
+// currentOUIRegistry returns the active registry, loading the embedded
+// snapshot on first use
+func currentOUIRegistry() *OUIRegistry {
+	ouiOnce.Do(func() {
+		registry := NewOUIRegistry()
+		if err := registry.Add(bytes.NewReader(ouiRegistryData)); err != nil {
+			slog.Warn("Failed to load OUI registry", "error", err)
+		}
+		ouiRegistry = registry
+	})
+
+	ouiRegistryMu.RLock()
+	defer ouiRegistryMu.RUnlock()
+	return ouiRegistry
+}
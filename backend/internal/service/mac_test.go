@@ -0,0 +1,144 @@
+package service
+
+import (
+	"errors"
+	"net/netip"
+	"strings"
+	"testing"
+)
+
+func TestParseMAC(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"00:1b:63:84:45:e6", "00:1b:63:84:45:e6"},
+		{"00-1B-63-84-45-E6", "00:1b:63:84:45:e6"},
+		{"001b.6384.45e6", "00:1b:63:84:45:e6"},
+		{"001B638445E6", "00:1b:63:84:45:e6"},
+		{" 00:1b:63:84:45:e6 ", "00:1b:63:84:45:e6"},
+		{"00:1b:63:84:45", ""},
+		{"00:1b:63:84:45:e6:00:01", ""},
+		{"001b638445zz", ""},
+		{"", ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			mac, err := ParseMAC(tt.input)
+			if tt.want == "" {
+				if !errors.Is(err, ErrInvalidMAC) {
+					t.Errorf("ParseMAC(%q) error = %v, want ErrInvalidMAC", tt.input, err)
+				}
+				return
+			}
+			if err != nil || mac.String() != tt.want {
+				t.Errorf("ParseMAC(%q) = %v, %v, want %s", tt.input, mac, err, tt.want)
+			}
+		})
+	}
+}
+
+func TestLookupMAC(t *testing.T) {
+	tests := []struct {
+		input     string
+		vendor    string
+		multicast bool
+		local     bool
+		special   string
+		linkLocal string
+	}{
+		{"00:1b:63:84:45:e6", "Apple, Inc.", false, false, "", "fe80::21b:63ff:fe84:45e6"},
+		{"00:50:56:12:34:56", "VMware, Inc.", false, false, "", "fe80::250:56ff:fe12:3456"},
+		{"01:00:5e:00:00:fb", "ICANN, IANA Department", true, false, "IPv4 multicast", "fe80::300:5eff:fe00:fb"},
+		{"33:33:00:00:00:01", "", true, true, "IPv6 multicast", "fe80::3133:ff:fe00:1"},
+		{"00:00:5e:00:01:0a", "ICANN, IANA Department", false, false, "VRRP (IPv4)", "fe80::200:5eff:fe00:10a"},
+		{"01:80:c2:00:00:0e", "IEEE 802.1 Working Group", true, false, "LLDP", "fe80::380:c2ff:fe00:e"},
+		{"ff:ff:ff:ff:ff:ff", "", true, true, "Broadcast", "fe80::fdff:ffff:feff:ffff"},
+		{"52:54:00:12:34:56", "", false, true, "", "fe80::5054:ff:fe12:3456"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			info, err := LookupMAC(tt.input)
+			if err != nil {
+				t.Fatalf("LookupMAC() error = %v", err)
+			}
+			if info.Vendor != tt.vendor {
+				t.Errorf("Vendor = %q, want %q", info.Vendor, tt.vendor)
+			}
+			if info.Multicast != tt.multicast || info.Local != tt.local {
+				t.Errorf("Multicast, Local = %v, %v, want %v, %v", info.Multicast, info.Local, tt.multicast, tt.local)
+			}
+			if info.Special != tt.special {
+				t.Errorf("Special = %q, want %q", info.Special, tt.special)
+			}
+			if info.LinkLocal.String() != tt.linkLocal {
+				t.Errorf("LinkLocal = %s, want %s", info.LinkLocal, tt.linkLocal)
+			}
+		})
+	}
+}
+
+func TestMACFromEUI64(t *testing.T) {
+	tests := []struct {
+		addr string
+		want string
+	}{
+		{"fe80::21b:63ff:fe84:45e6", "00:1b:63:84:45:e6"},
+		{"2001:db8:1:2:250:56ff:fe12:3456", "00:50:56:12:34:56"},
+		{"2001:db8::1", ""},
+		{"2001:db8:1:2:a1b2:c3d4:e5f6:789", ""},
+		{"192.0.2.1", ""},
+	}
+
+	for _, tt := range tests {
+		mac, ok := MACFromEUI64(netip.MustParseAddr(tt.addr))
+		if got := mac.String(); ok != (tt.want != "") || got != tt.want {
+			t.Errorf("MACFromEUI64(%s) = %s, %v, want %s", tt.addr, got, ok, tt.want)
+		}
+	}
+}
+
+func TestOUIRegistry(t *testing.T) {
+	registry := NewOUIRegistry()
+	err := registry.Add(strings.NewReader(`Registry,Assignment,Organization Name,Organization Address
+MA-L,70B3D5,IEEE Registration Authority,"445 Hoes Lane Piscataway NJ US 08554"
+MA-M,70B3D51,Example Medium Block,
+MA-S,70B3D50AB,"Example Small Block, Ltd.",
+`))
+	if err != nil {
+		t.Fatalf("Add() error = %v", err)
+	}
+	if registry.Len() != 3 {
+		t.Errorf("Len() = %d, want 3", registry.Len())
+	}
+
+	tests := []struct {
+		mac      string
+		name     string
+		prefix   string
+		registry string
+	}{
+		{"70:b3:d5:0a:b1:23", "Example Small Block, Ltd.", "70:B3:D5:0A:B", "MA-S"},
+		{"70:b3:d5:1f:00:01", "Example Medium Block", "70:B3:D5:1", "MA-M"},
+		{"70:b3:d5:2f:00:01", "IEEE Registration Authority", "70:B3:D5", "MA-L"},
+	}
+	for _, tt := range tests {
+		mac, _ := ParseMAC(tt.mac)
+		entry, ok := registry.Lookup(mac)
+		if !ok || entry.Organization != tt.name || entry.Prefix != tt.prefix || entry.Registry != tt.registry {
+			t.Errorf("Lookup(%s) = %+v, %v", tt.mac, entry, ok)
+		}
+	}
+
+	for _, bad := range []string{"MA-L,70B3D5F,Too Long,", "XX-L,70B3D5,Unknown,", "MA-L,ZZZZZZ,Bad Hex,"} {
+		if err := NewOUIRegistry().Add(strings.NewReader(bad)); err == nil {
+			t.Errorf("Add(%q) should fail", bad)
+		}
+	}
+
+	if GetOUIRegistrySize() == 0 {
+		t.Error("embedded OUI registry is empty")
+	}
+}
@@ -0,0 +1,316 @@
+package service
+
+import (
+	"bytes"
+	_ "embed"
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// ports.csv is a snapshot of the IANA Service Name and Transport Protocol
+// Port Number Registry in its CSV format, covering commonly seen services.
+// Set PORTS_REGISTRY_PATH to the full service-names-port-numbers.csv.
+//
+//go:embed ports.csv
+var portRegistryData []byte
+
+// ports_unofficial.csv lists common uses of ports that IANA has not assigned
+// to them, in the same column layout
+//
+//go:embed ports_unofficial.csv
+var unofficialPortsData []byte
+
+// PortProtocols are the transport protocols of the registry
+var PortProtocols = []string{"tcp", "udp", "sctp", "dccp"}
+
+// PortAssignment is one service on a port or port range
+type PortAssignment struct {
+	Service     string
+	Port        int
+	PortEnd     int // last port of a range, equal to Port otherwise
+	Protocol    string
+	Description string
+	Reference   string
+	Official    bool // assigned by IANA rather than common practice
+}
+
+// Ports returns the port or range in "6000-6063" form
+func (a PortAssignment) Ports() string {
+	if a.PortEnd != a.Port {
+		return fmt.Sprintf("%d-%d", a.Port, a.PortEnd)
+	}
+	return strconv.Itoa(a.Port)
+}
+
+// PortRange returns the IANA range a port falls in: "system" (0-1023),
+// "user" (1024-49151) or "dynamic" (49152-65535)
+func PortRange(port int) string {
+	switch {
+	case port < 1024:
+		return "system"
+	case port < 49152:
+		return "user"
+	}
+	return "dynamic"
+}
+
+// PortRegistry indexes port assignments by port and service name
+type PortRegistry struct {
+	entries []PortAssignment
+	byPort  map[int][]int // single ports to entry indexes
+	ranges  []int         // entry indexes of port ranges
+}
+
+// ParsePortRegistry parses the official and unofficial registry CSV data.
+// Rows without a service name or port, such as Reserved and Unassigned
+// ranges or SRV-only names, are skipped.
+func ParsePortRegistry(official, unofficial io.Reader) (*PortRegistry, error) {
+	r := &PortRegistry{byPort: make(map[int][]int)}
+	if err := r.add(official, true); err != nil {
+		return nil, err
+	}
+	if unofficial != nil {
+		if err := r.add(unofficial, false); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// add reads registry rows with the columns service, port, protocol,
+// description and, for IANA files, reference in the ninth column
+func (r *PortRegistry) add(data io.Reader, official bool) error {
+	reader := csv.NewReader(data)
+	reader.FieldsPerRecord = -1
+
+	for line := 1; ; line++ {
+		fields, err := reader.Read()
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return fmt.Errorf("invalid port registry: %w", err)
+		}
+		if line == 1 && fields[0] == "Service Name" {
+			continue
+		}
+		if len(fields) < 4 {
+			return fmt.Errorf("port registry line %d: expected at least 4 columns", line)
+		}
+
+		service, ports, protocol := strings.TrimSpace(fields[0]), strings.TrimSpace(fields[1]), strings.TrimSpace(fields[2])
+		if service == "" || ports == "" {
+			continue
+		}
+		start, end, err := parsePortRange(ports)
+		if err != nil {
+			return fmt.Errorf("port registry line %d: %w", line, err)
+		}
+
+		entry := PortAssignment{
+			Service:     service,
+			Port:        start,
+			PortEnd:     end,
+			Protocol:    strings.ToLower(protocol),
+			Description: strings.TrimSpace(fields[3]),
+			Official:    official,
+		}
+		if len(fields) > 8 {
+			entry.Reference = strings.TrimSpace(fields[8])
+		}
+
+		index := len(r.entries)
+		r.entries = append(r.entries, entry)
+		if start == end {
+			r.byPort[start] = append(r.byPort[start], index)
+		} else {
+			r.ranges = append(r.ranges, index)
+		}
+	}
+}
+
+// Lookup returns the assignments of a port, official ones first. An empty
+// protocol matches every protocol.
+func (r *PortRegistry) Lookup(port int, protocol string) []PortAssignment {
+	var out []PortAssignment
+	for _, i := range r.byPort[port] {
+		if protocol == "" || r.entries[i].Protocol == protocol {
+			out = append(out, r.entries[i])
+		}
+	}
+	for _, i := range r.ranges {
+		e := r.entries[i]
+		if port >= e.Port && port <= e.PortEnd && (protocol == "" || e.Protocol == protocol) {
+			out = append(out, e)
+		}
+	}
+	sortPortAssignments(out)
+	return out
+}
+
+// Search finds assignments whose service name or description contains the
+// query, case-insensitively. Exact service name matches come first, then
+// name prefixes, then other matches, each ordered by port.
+func (r *PortRegistry) Search(query, protocol string) []PortAssignment {
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return nil
+	}
+
+	type ranked struct {
+		rank  int
+		entry PortAssignment
+	}
+	var matches []ranked
+	for _, e := range r.entries {
+		if protocol != "" && e.Protocol != protocol {
+			continue
+		}
+		name := strings.ToLower(e.Service)
+		switch {
+		case name == query:
+			matches = append(matches, ranked{0, e})
+		case strings.HasPrefix(name, query):
+			matches = append(matches, ranked{1, e})
+		case strings.Contains(name, query), strings.Contains(strings.ToLower(e.Description), query):
+			matches = append(matches, ranked{2, e})
+		}
+	}
+
+	sort.SliceStable(matches, func(i, j int) bool {
+		if matches[i].rank != matches[j].rank {
+			return matches[i].rank < matches[j].rank
+		}
+		return portAssignmentLess(matches[i].entry, matches[j].entry)
+	})
+	out := make([]PortAssignment, len(matches))
+	for i, m := range matches {
+		out[i] = m.entry
+	}
+	return out
+}
+
+// Len returns the number of assignments
+func (r *PortRegistry) Len() int {
+	return len(r.entries)
+}
+
+// sortPortAssignments orders assignments by port, official first, then protocol
+func sortPortAssignments(entries []PortAssignment) {
+	sort.SliceStable(entries, func(i, j int) bool {
+		return portAssignmentLess(entries[i], entries[j])
+	})
+}
+
+func portAssignmentLess(a, b PortAssignment) bool {
+	if a.Port != b.Port {
+		return a.Port < b.Port
+	}
+	if a.Official != b.Official {
+		return a.Official
+	}
+	return protocolOrder(a.Protocol) < protocolOrder(b.Protocol)
+}
+
+// protocolOrder sorts protocols as listed in PortProtocols
+func protocolOrder(protocol string) int {
+	for i, p := range PortProtocols {
+		if p == protocol {
+			return i
+		}
+	}
+	return len(PortProtocols)
+}
+
+// ParsePort parses a port number between 0 and 65535
+func ParsePort(s string) (int, error) {
+	port, err := strconv.Atoi(strings.TrimSpace(s))
+	if err != nil || port < 0 || port > 65535 {
+		return 0, fmt.Errorf("invalid port %q, expected 0-65535", s)
+	}
+	return port, nil
+}
+
+// parsePortRange parses "80" or "6000-6063"
+func parsePortRange(s string) (int, int, error) {
+	first, last, isRange := strings.Cut(s, "-")
+	start, err := ParsePort(first)
+	if err != nil {
+		return 0, 0, err
+	}
+	if !isRange {
+		return start, start, nil
+	}
+	end, err := ParsePort(last)
+	if err != nil || end < start {
+		return 0, 0, fmt.Errorf("invalid port range %q", s)
+	}
+	return start, end, nil
+}
+
+var (
+	portRegistry   *PortRegistry
+	portRegistryMu sync.RWMutex
+	portOnce       sync.Once
+)
+
+// LoadPortRegistry replaces the embedded IANA snapshot with a full
+// service-names-port-numbers.csv; the unofficial list is kept
+func LoadPortRegistry(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	registry, err := ParsePortRegistry(f, bytes.NewReader(unofficialPortsData))
+	if err != nil {
+		return fmt.Errorf("%s: %w", path, err)
+	}
+	SetPortRegistry(registry)
+	return nil
+}
+
+// SetPortRegistry replaces the registry used by LookupPort and SearchPorts
+func SetPortRegistry(registry *PortRegistry) {
+	portOnce.Do(func() {})
+
+	portRegistryMu.Lock()
+	portRegistry = registry
+	portRegistryMu.Unlock()
+}
+
+// LookupPort returns the assignments of a port in the active registry
+func LookupPort(port int, protocol string) []PortAssignment {
+	return currentPortRegistry().Lookup(port, protocol)
+}
+
+// SearchPorts searches the active registry by service name and description
+func SearchPorts(query, protocol string) []PortAssignment {
+	return currentPortRegistry().Search(query, protocol)
+}
+
+// currentPortRegistry returns the active registry, loading the embedded
+// snapshot on first use
+func currentPortRegistry() *PortRegistry {
+	portOnce.Do(func() {
+		registry, err := ParsePortRegistry(bytes.NewReader(portRegistryData), bytes.NewReader(unofficialPortsData))
+		if err != nil {
+			slog.Warn("Failed to load port registry", "error", err)
+			registry = &PortRegistry{byPort: make(map[int][]int)}
+		}
+		portRegistry = registry
+	})
+
+	portRegistryMu.RLock()
+	defer portRegistryMu.RUnlock()
+	return portRegistry
+}
@@ -0,0 +1,88 @@
+package service
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestLookupPort(t *testing.T) {
+	tests := []struct {
+		port     int
+		protocol string
+		want     []string // service/protocol, with * for unofficial
+	}{
+		{22, "", []string{"ssh/tcp", "ssh/udp", "ssh/sctp"}},
+		{8443, "udp", []string{"pcsync-https/udp", "https-alt/udp*"}},
+		{8443, "", []string{"pcsync-https/tcp", "pcsync-https/udp", "https-alt/tcp*", "https-alt/udp*"}},
+		{3868, "sctp", []string{"diameter/sctp"}},
+		{6010, "tcp", []string{"x11/tcp"}},
+		{51820, "", []string{"wireguard/udp*"}},
+		{0, "", nil},
+		{40000, "", nil},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, a := range LookupPort(tt.port, tt.protocol) {
+			s := a.Service + "/" + a.Protocol
+			if !a.Official {
+				s += "*"
+			}
+			got = append(got, s)
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("LookupPort(%d, %q) = %v, want %v", tt.port, tt.protocol, got, tt.want)
+		}
+	}
+}
+
+func TestSearchPorts(t *testing.T) {
+	results := SearchPorts("HTTPS", "tcp")
+	if len(results) < 3 {
+		t.Fatalf("SearchPorts(https) = %+v", results)
+	}
+	if results[0].Service != "https" || results[0].Port != 443 {
+		t.Errorf("first result = %+v, want the exact https match", results[0])
+	}
+	if results[1].Service != "https-alt" {
+		t.Errorf("second result = %+v, want the https-alt prefix match", results[1])
+	}
+
+	if results := SearchPorts("kubernetes", ""); len(results) == 0 || results[0].Official {
+		t.Errorf("SearchPorts(kubernetes) = %+v, want unofficial matches", results)
+	}
+	if results := SearchPorts("  ", ""); results != nil {
+		t.Errorf("SearchPorts(blank) = %+v, want nil", results)
+	}
+}
+
+func TestParsePortRegistry(t *testing.T) {
+	registry, err := ParsePortRegistry(strings.NewReader(`Service Name,Port Number,Transport Protocol,Description
+,0,tcp,Reserved
+_sip,,,SRV name only
+svc,1000-1010,tcp,A range
+`), nil)
+	if err != nil {
+		t.Fatalf("ParsePortRegistry() error = %v", err)
+	}
+	if registry.Len() != 1 {
+		t.Errorf("Len() = %d, want 1", registry.Len())
+	}
+	if got := registry.Lookup(1005, "tcp"); len(got) != 1 || got[0].Ports() != "1000-1010" {
+		t.Errorf("Lookup(1005) = %+v", got)
+	}
+
+	for _, bad := range []string{"svc,70000,tcp,Too high", "svc,20-10,tcp,Backwards", "svc,80"} {
+		if _, err := ParsePortRegistry(strings.NewReader(bad), nil); err == nil {
+			t.Errorf("ParsePortRegistry(%q) should fail", bad)
+		}
+	}
+}
+
+func TestPortRange(t *testing.T) {
+	for port, want := range map[int]string{0: "system", 1023: "system", 1024: "user", 49151: "user", 49152: "dynamic", 65535: "dynamic"} {
+		if got := PortRange(port); got != want {
+			t.Errorf("PortRange(%d) = %s, want %s", port, got, want)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package service
+
+import "net/netip"
+
+// PrefixMatch is a prefix found by a trie lookup and the value stored with it
+type PrefixMatch struct {
+	Prefix netip.Prefix
+	Value  string
+}
+
+// PrefixTrie is a binary trie of IPv4 and IPv6 prefixes. A lookup walks at
+// most one node per address bit and returns every stored prefix that
+// contains the address. It is not safe for concurrent writes; build it
+// once and share it read-only.
+type PrefixTrie struct {
+	v4, v6 *trieNode
+	size   int
+}
+
+type trieNode struct {
+	child   [2]*trieNode
+	entries []PrefixMatch
+}
+
+// NewPrefixTrie creates an empty trie
+func NewPrefixTrie() *PrefixTrie {
+	return &PrefixTrie{v4: &trieNode{}, v6: &trieNode{}}
+}
+
+// Insert stores value under prefix. A prefix may hold several values.
+func (t *PrefixTrie) Insert(prefix netip.Prefix, value string) {
+	prefix = prefix.Masked()
+	addr := prefix.Addr()
+	node := t.root(addr)
+	b := addr.AsSlice()
+	for i := 0; i < prefix.Bits(); i++ {
+		bit := addrBit(b, i)
+		if node.child[bit] == nil {
+			node.child[bit] = &trieNode{}
+		}
+		node = node.child[bit]
+	}
+	for _, e := range node.entries {
+		if e.Value == value {
+			return
+		}
+	}
+	node.entries = append(node.entries, PrefixMatch{Prefix: prefix, Value: value})
+	t.size++
+}
+
+// Lookup returns every stored prefix containing addr, least specific first
+func (t *PrefixTrie) Lookup(addr netip.Addr) []PrefixMatch {
+	var matches []PrefixMatch
+	t.walk(addr, func(node *trieNode) bool {
+		matches = append(matches, node.entries...)
+		return true
+	})
+	return matches
+}
+
+// Contains reports whether any stored prefix contains addr
+func (t *PrefixTrie) Contains(addr netip.Addr) bool {
+	found := false
+	t.walk(addr, func(node *trieNode) bool {
+		found = len(node.entries) > 0
+		return !found
+	})
+	return found
+}
+
+// Len returns the number of stored prefix and value pairs
+func (t *PrefixTrie) Len() int {
+	return t.size
+}
+
+// walk visits the nodes on addr's path until visit returns false
+func (t *PrefixTrie) walk(addr netip.Addr, visit func(*trieNode) bool) {
+	if !addr.IsValid() {
+		return
+	}
+	addr = addr.Unmap()
+	node := t.root(addr)
+	b := addr.AsSlice()
+	for i := 0; node != nil; i++ {
+		if !visit(node) || i == addr.BitLen() {
+			return
+		}
+		node = node.child[addrBit(b, i)]
+	}
+}
+
+// root returns the root node for the address family
+func (t *PrefixTrie) root(addr netip.Addr) *trieNode {
+	if addr.Is4() {
+		return t.v4
+	}
+	return t.v6
+}
+
+// addrBit returns bit i of an address, counting from the most significant
+func addrBit(b []byte, i int) int {
+	return int(b[i/8]>>(7-uint(i%8))) & 1
+}
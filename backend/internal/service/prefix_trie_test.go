@@ -0,0 +1,62 @@
+package service
+
+import (
+	"net/netip"
+	"testing"
+)
+
+func TestPrefixTrie(t *testing.T) {
+	trie := NewPrefixTrie()
+	for _, e := range []struct{ prefix, value string }{
+		{"10.0.0.0/8", "private"},
+		{"10.1.0.0/16", "office"},
+		{"10.1.2.0/24", "office"},
+		{"10.1.2.0/24", "lab"},
+		{"10.1.2.0/24", "lab"}, // duplicate
+		{"0.0.0.0/0", "any"},
+		{"2001:db8::/32", "docs"},
+		{"2001:db8:1::1/128", "host"},
+	} {
+		trie.Insert(netip.MustParsePrefix(e.prefix), e.value)
+	}
+
+	if trie.Len() != 7 {
+		t.Errorf("Len() = %d, want 7", trie.Len())
+	}
+
+	tests := []struct {
+		addr string
+		want []string
+	}{
+		{"10.1.2.3", []string{"0.0.0.0/0 any", "10.0.0.0/8 private", "10.1.0.0/16 office", "10.1.2.0/24 office", "10.1.2.0/24 lab"}},
+		{"10.9.9.9", []string{"0.0.0.0/0 any", "10.0.0.0/8 private"}},
+		{"192.0.2.1", []string{"0.0.0.0/0 any"}},
+		{"::ffff:10.1.0.1", []string{"0.0.0.0/0 any", "10.0.0.0/8 private", "10.1.0.0/16 office"}},
+		{"2001:db8:1::1", []string{"2001:db8::/32 docs", "2001:db8:1::1/128 host"}},
+		{"2001:db9::1", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.addr, func(t *testing.T) {
+			var got []string
+			for _, m := range trie.Lookup(netip.MustParseAddr(tt.addr)) {
+				got = append(got, m.Prefix.String()+" "+m.Value)
+			}
+			if len(got) != len(tt.want) {
+				t.Fatalf("Lookup(%s) = %v, want %v", tt.addr, got, tt.want)
+			}
+			for i := range got {
+				if got[i] != tt.want[i] {
+					t.Errorf("Lookup(%s)[%d] = %s, want %s", tt.addr, i, got[i], tt.want[i])
+				}
+			}
+			if trie.Contains(netip.MustParseAddr(tt.addr)) != (len(tt.want) > 0) {
+				t.Errorf("Contains(%s) disagrees with Lookup", tt.addr)
+			}
+		})
+	}
+
+	if NewPrefixTrie().Contains(netip.Addr{}) {
+		t.Error("Contains(invalid) = true, want false")
+	}
+}
@@ -0,0 +1,421 @@
+package service
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+//go:embed useragents.json
+var userAgentRulesData []byte
+
+// UserAgentInfo represents a parsed User-Agent string
+type UserAgentInfo struct {
+	UserAgent      string
+	BrowserName    string
+	BrowserVersion string
+	EngineName     string
+	EngineVersion  string
+	OSName         string
+	OSVersion      string
+	DeviceType     string // "desktop", "mobile", "tablet", "tv", "console" or "bot"
+	DeviceVendor   string
+	DeviceModel    string
+	IsBot          bool
+	BotName        string
+	BotCategory    string
+}
+
+// ClientHints represents the User-Agent Client Hints (Sec-CH-UA*) headers
+type ClientHints struct {
+	Brands          []ClientHintBrand
+	FullVersionList []ClientHintBrand
+	Mobile          *bool
+	Platform        string
+	PlatformVersion string
+	Model           string
+	Architecture    string
+	Bitness         string
+}
+
+// ClientHintBrand represents one brand/version pair of Sec-CH-UA
+type ClientHintBrand struct {
+	Brand   string
+	Version string
+}
+
+// uaRule is one regular expression rule from the rules file. Name, Version,
+// Vendor and Model may reference capture groups as $1; Version defaults to
+// the first non-empty group.
+type uaRule struct {
+	Pattern  string `json:"pattern"`
+	Name     string `json:"name,omitempty"`
+	Version  string `json:"version,omitempty"`
+	Category string `json:"category,omitempty"`
+	Type     string `json:"type,omitempty"`
+	Vendor   string `json:"vendor,omitempty"`
+	Model    string `json:"model,omitempty"`
+
+	re *regexp.Regexp
+}
+
+// UserAgentRules is the parsed rules file used to classify User-Agents
+type UserAgentRules struct {
+	Version  string   `json:"version"`
+	Bots     []uaRule `json:"bots"`
+	Browsers []uaRule `json:"browsers"`
+	Engines  []uaRule `json:"engines"`
+	OS       []uaRule `json:"os"`
+	Devices  []uaRule `json:"devices"`
+}
+
+var (
+	userAgentRules   *UserAgentRules
+	userAgentRulesMu sync.RWMutex
+	userAgentOnce    sync.Once
+)
+
+// ParseUserAgentRules parses and compiles a rules file
+func ParseUserAgentRules(data []byte) (*UserAgentRules, error) {
+	var rules UserAgentRules
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return nil, fmt.Errorf("invalid user agent rules: %w", err)
+	}
+
+	sections := map[string][]uaRule{
+		"bots":     rules.Bots,
+		"browsers": rules.Browsers,
+		"engines":  rules.Engines,
+		"os":       rules.OS,
+		"devices":  rules.Devices,
+	}
+	for section, list := range sections {
+		for i := range list {
+			re, err := regexp.Compile(list[i].Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("invalid %s rule %q: %w", section, list[i].Pattern, err)
+			}
+			list[i].re = re
+		}
+	}
+	return &rules, nil
+}
+
+// LoadUserAgentRules replaces the embedded rules with the file at path, so
+// newer rules can be deployed without rebuilding
+func LoadUserAgentRules(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	rules, err := ParseUserAgentRules(data)
+	if err != nil {
+		return err
+	}
+	SetUserAgentRules(rules)
+	return nil
+}
+
+// SetUserAgentRules replaces the rules used by ParseUserAgent
+func SetUserAgentRules(rules *UserAgentRules) {
+	userAgentOnce.Do(func() {})
+
+	userAgentRulesMu.Lock()
+	userAgentRules = rules
+	userAgentRulesMu.Unlock()
+}
+
+// GetUserAgentRulesVersion returns the version string of the active rules
+func GetUserAgentRulesVersion() string {
+	return currentUserAgentRules().Version
+}
+
+// currentUserAgentRules returns the active rules, loading the embedded file
+// on first use
+func currentUserAgentRules() *UserAgentRules {
+	userAgentOnce.Do(func() {
+		rules, err := ParseUserAgentRules(userAgentRulesData)
+		if err != nil {
+			slog.Warn("Failed to load user agent rules", "error", err)
+			rules = &UserAgentRules{}
+		}
+		userAgentRules = rules
+	})
+
+	userAgentRulesMu.RLock()
+	defer userAgentRulesMu.RUnlock()
+	return userAgentRules
+}
+
+// ParseUserAgent classifies a User-Agent string into browser, engine, OS,
+// device and bot details
+func ParseUserAgent(ua string) UserAgentInfo {
+	rules := currentUserAgentRules()
+	info := UserAgentInfo{UserAgent: ua}
+	if strings.TrimSpace(ua) == "" {
+		return info
+	}
+
+	if rule, m := matchRule(rules.Bots, ua); rule != nil {
+		info.IsBot = true
+		info.BotName = expandRule(rule.Name, ua, m)
+		info.BotCategory = rule.Category
+	}
+	if rule, m := matchRule(rules.Browsers, ua); rule != nil {
+		info.BrowserName = expandRule(rule.Name, ua, m)
+		info.BrowserVersion = ruleVersion(rule, ua, m)
+	}
+	if rule, m := matchRule(rules.Engines, ua); rule != nil {
+		info.EngineName = expandRule(rule.Name, ua, m)
+		info.EngineVersion = ruleVersion(rule, ua, m)
+	}
+	if rule, m := matchRule(rules.OS, ua); rule != nil {
+		info.OSName = expandRule(rule.Name, ua, m)
+		info.OSVersion = strings.ReplaceAll(ruleVersion(rule, ua, m), "_", ".")
+	}
+
+	info.DeviceType = "desktop"
+	if rule, m := matchRule(rules.Devices, ua); rule != nil {
+		info.DeviceType = rule.Type
+		info.DeviceVendor = expandRule(rule.Vendor, ua, m)
+		info.DeviceModel = expandRule(rule.Model, ua, m)
+	}
+	if info.IsBot && info.BotCategory != "headless" {
+		info.DeviceType = "bot"
+	}
+
+	return info
+}
+
+// ApplyClientHints refines a parsed User-Agent with Client Hints, which are
+// more precise than the frozen User-Agent string of Chromium browsers
+func ApplyClientHints(info *UserAgentInfo, hints ClientHints) {
+	if hints.Platform != "" {
+		if name := normalizeHintPlatform(hints.Platform); name != info.OSName {
+			info.OSName = name
+			info.OSVersion = ""
+		}
+		if hints.PlatformVersion != "" {
+			info.OSVersion = hintPlatformVersion(info.OSName, hints.PlatformVersion)
+		}
+	}
+	if hints.Mobile != nil && !info.IsBot {
+		if *hints.Mobile {
+			info.DeviceType = "mobile"
+		} else if info.DeviceType == "mobile" {
+			info.DeviceType = "desktop"
+		}
+	}
+	if hints.Model != "" {
+		info.DeviceModel = hints.Model
+	}
+
+	brands := hints.FullVersionList
+	if len(brands) == 0 {
+		brands = hints.Brands
+	}
+	if brand, ok := primaryBrand(brands); ok {
+		info.BrowserName = normalizeHintBrand(brand.Brand)
+		info.BrowserVersion = brand.Version
+	}
+}
+
+// ParseClientHintBrands parses a Sec-CH-UA or Sec-CH-UA-Full-Version-List
+// structured header such as `"Chromium";v="124", "Not-A.Brand";v="99"`
+func ParseClientHintBrands(header string) []ClientHintBrand {
+	var brands []ClientHintBrand
+	for _, item := range splitQuoted(header, ',') {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+		var brand ClientHintBrand
+		for i, part := range splitQuoted(item, ';') {
+			part = strings.TrimSpace(part)
+			if i == 0 {
+				brand.Brand = unquoteHint(part)
+				continue
+			}
+			if key, value, ok := strings.Cut(part, "="); ok && strings.TrimSpace(key) == "v" {
+				brand.Version = unquoteHint(strings.TrimSpace(value))
+			}
+		}
+		if brand.Brand != "" {
+			brands = append(brands, brand)
+		}
+	}
+	return brands
+}
+
+// ParseClientHintString unquotes a structured-header string hint such as
+// Sec-CH-UA-Platform
+func ParseClientHintString(header string) string {
+	return unquoteHint(strings.TrimSpace(header))
+}
+
+// ParseClientHintBool parses a structured-header boolean (?1 or ?0)
+func ParseClientHintBool(header string) *bool {
+	var v bool
+	switch strings.TrimSpace(header) {
+	case "?1":
+		v = true
+	case "?0":
+		v = false
+	default:
+		return nil
+	}
+	return &v
+}
+
+// matchRule returns the first rule matching ua and its submatch indices
+func matchRule(rules []uaRule, ua string) (*uaRule, []int) {
+	for i := range rules {
+		if rules[i].re == nil {
+			continue
+		}
+		if m := rules[i].re.FindStringSubmatchIndex(ua); m != nil {
+			return &rules[i], m
+		}
+	}
+	return nil, nil
+}
+
+// expandRule substitutes capture groups into a rule template
+func expandRule(template, ua string, m []int) string {
+	if !strings.Contains(template, "$") {
+		return template
+	}
+	var dst []byte
+	for i := 0; i < len(template); i++ {
+		if template[i] == '$' && i+1 < len(template) && template[i+1] >= '0' && template[i+1] <= '9' {
+			n := int(template[i+1] - '0')
+			if 2*n+1 < len(m) && m[2*n] >= 0 {
+				dst = append(dst, ua[m[2*n]:m[2*n+1]]...)
+			}
+			i++
+			continue
+		}
+		dst = append(dst, template[i])
+	}
+	return strings.TrimSpace(string(dst))
+}
+
+// ruleVersion returns the rule's fixed version or its first non-empty group
+func ruleVersion(rule *uaRule, ua string, m []int) string {
+	if rule.Version != "" {
+		return expandRule(rule.Version, ua, m)
+	}
+	for n := 1; 2*n+1 < len(m); n++ {
+		if m[2*n] >= 0 && m[2*n+1] > m[2*n] {
+			return ua[m[2*n]:m[2*n+1]]
+		}
+	}
+	return ""
+}
+
+// primaryBrand picks the most specific brand from a Client Hints brand list,
+// skipping GREASE entries and preferring a vendor brand over "Chromium"
+func primaryBrand(brands []ClientHintBrand) (ClientHintBrand, bool) {
+	var chromium *ClientHintBrand
+	for i, b := range brands {
+		if isGreaseBrand(b.Brand) {
+			continue
+		}
+		if b.Brand == "Chromium" {
+			chromium = &brands[i]
+			continue
+		}
+		return b, true
+	}
+	if chromium != nil {
+		return *chromium, true
+	}
+	return ClientHintBrand{}, false
+}
+
+// isGreaseBrand reports whether a brand is a randomized placeholder such as
+// "Not-A.Brand" or "Not_A Brand"
+func isGreaseBrand(brand string) bool {
+	lower := strings.ToLower(brand)
+	return strings.HasPrefix(lower, "not") && strings.Contains(lower, "brand")
+}
+
+// normalizeHintBrand maps Sec-CH-UA brands to the names used by the rules file
+func normalizeHintBrand(brand string) string {
+	switch brand {
+	case "Google Chrome":
+		return "Chrome"
+	case "Microsoft Edge":
+		return "Edge"
+	case "Opera GX":
+		return "Opera"
+	default:
+		return brand
+	}
+}
+
+// normalizeHintPlatform maps Sec-CH-UA-Platform values to the names used by
+// the rules file
+func normalizeHintPlatform(platform string) string {
+	switch platform {
+	case "Chrome OS", "Chromium OS":
+		return "ChromeOS"
+	default:
+		return platform
+	}
+}
+
+// hintPlatformVersion converts Sec-CH-UA-Platform-Version to a marketing
+// version; on Windows 13.0.0 and later mean Windows 11
+func hintPlatformVersion(platform, version string) string {
+	if platform != "Windows" {
+		return version
+	}
+	major, _, _ := strings.Cut(version, ".")
+	n, err := strconv.Atoi(major)
+	switch {
+	case err != nil:
+		return version
+	case n >= 13:
+		return "11"
+	case n > 0:
+		return "10"
+	default:
+		return version
+	}
+}
+
+// splitQuoted splits s on sep, ignoring separators inside double quotes
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	inQuote := false
+	start := 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && inQuote:
+			i++
+		case s[i] == '"':
+			inQuote = !inQuote
+		case s[i] == sep && !inQuote:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return append(parts, s[start:])
+}
+
+// unquoteHint removes structured-header string quoting
+func unquoteHint(s string) string {
+	if len(s) >= 2 && s[0] == '"' && s[len(s)-1] == '"' {
+		s = s[1 : len(s)-1]
+		s = strings.ReplaceAll(s, `\"`, `"`)
+		s = strings.ReplaceAll(s, `\\`, `\`)
+	}
+	return s
+}
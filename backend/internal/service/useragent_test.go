@@ -0,0 +1,205 @@
+package service
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseUserAgent(t *testing.T) {
+	tests := []struct {
+		name    string
+		ua      string
+		browser string
+		version string
+		engine  string
+		os      string
+		osVer   string
+		device  string
+		vendor  string
+		model   string
+		bot     string
+	}{
+		{
+			name:    "Chrome on Windows",
+			ua:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36",
+			browser: "Chrome", version: "124.0.0.0", engine: "Blink",
+			os: "Windows", osVer: "10", device: "desktop",
+		},
+		{
+			name:    "Edge on Windows",
+			ua:      "Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36 Edg/124.0.2478.51",
+			browser: "Edge", version: "124.0.2478.51", engine: "Blink",
+			os: "Windows", osVer: "10", device: "desktop",
+		},
+		{
+			name:    "Firefox on Linux",
+			ua:      "Mozilla/5.0 (X11; Ubuntu; Linux x86_64; rv:125.0) Gecko/20100101 Firefox/125.0",
+			browser: "Firefox", version: "125.0", engine: "Gecko",
+			os: "Ubuntu", device: "desktop",
+		},
+		{
+			name:    "Safari on macOS",
+			ua:      "Mozilla/5.0 (Macintosh; Intel Mac OS X 10_15_7) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Safari/605.1.15",
+			browser: "Safari", version: "17.4.1", engine: "WebKit",
+			os: "macOS", osVer: "10.15.7", device: "desktop",
+		},
+		{
+			name:    "Safari on iPhone",
+			ua:      "Mozilla/5.0 (iPhone; CPU iPhone OS 17_4_1 like Mac OS X) AppleWebKit/605.1.15 (KHTML, like Gecko) Version/17.4.1 Mobile/15E148 Safari/604.1",
+			browser: "Safari", version: "17.4.1", engine: "WebKit",
+			os: "iOS", osVer: "17.4.1", device: "mobile", vendor: "Apple", model: "iPhone",
+		},
+		{
+			name:    "Samsung phone",
+			ua:      "Mozilla/5.0 (Linux; Android 14; SM-S928B) AppleWebKit/537.36 (KHTML, like Gecko) SamsungBrowser/24.0 Chrome/117.0.0.0 Mobile Safari/537.36",
+			browser: "Samsung Internet", version: "24.0", engine: "Blink",
+			os: "Android", osVer: "14", device: "mobile", vendor: "Samsung", model: "SM-S928B",
+		},
+		{
+			name:    "WeChat on Android",
+			ua:      "Mozilla/5.0 (Linux; Android 13; Pixel 7 Build/TQ3A.230901.001; wv) AppleWebKit/537.36 (KHTML, like Gecko) Version/4.0 Chrome/116.0.0.0 Mobile Safari/537.36 MicroMessenger/8.0.47.2560(0x28002F35) WeChat/arm64",
+			browser: "WeChat", version: "8.0.47.2560", engine: "Blink",
+			os: "Android", osVer: "13", device: "mobile", vendor: "Google", model: "Pixel 7",
+		},
+		{
+			name:    "Android tablet",
+			ua:      "Mozilla/5.0 (Linux; Android 12; Lenovo TB-J606F) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/120.0.0.0 Safari/537.36",
+			browser: "Chrome", version: "120.0.0.0", engine: "Blink",
+			os: "Android", osVer: "12", device: "tablet",
+		},
+		{
+			name:    "Internet Explorer 11",
+			ua:      "Mozilla/5.0 (Windows NT 6.1; WOW64; Trident/7.0; rv:11.0) like Gecko",
+			browser: "Internet Explorer", version: "11.0", engine: "Trident",
+			os: "Windows", osVer: "7", device: "desktop",
+		},
+		{
+			name:   "PlayStation",
+			ua:     "Mozilla/5.0 (PlayStation 5 3.11) AppleWebKit/605.1.15 (KHTML, like Gecko)",
+			engine: "WebKit",
+			device: "console", vendor: "Sony", model: "PlayStation 5",
+		},
+		{
+			name:   "Googlebot",
+			ua:     "Mozilla/5.0 (compatible; Googlebot/2.1; +http://www.google.com/bot.html)",
+			device: "bot",
+			bot:    "Googlebot",
+		},
+		{
+			name:   "curl",
+			ua:     "curl/8.5.0",
+			device: "bot",
+			bot:    "curl",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ParseUserAgent(tt.ua)
+
+			if got.BrowserName != tt.browser || got.BrowserVersion != tt.version {
+				t.Errorf("browser = %s %s, want %s %s", got.BrowserName, got.BrowserVersion, tt.browser, tt.version)
+			}
+			if got.EngineName != tt.engine {
+				t.Errorf("engine = %s, want %s", got.EngineName, tt.engine)
+			}
+			if got.OSName != tt.os || got.OSVersion != tt.osVer {
+				t.Errorf("os = %s %s, want %s %s", got.OSName, got.OSVersion, tt.os, tt.osVer)
+			}
+			if got.DeviceType != tt.device || got.DeviceVendor != tt.vendor || got.DeviceModel != tt.model {
+				t.Errorf("device = %s %s %s, want %s %s %s",
+					got.DeviceType, got.DeviceVendor, got.DeviceModel, tt.device, tt.vendor, tt.model)
+			}
+			if got.IsBot != (tt.bot != "") || got.BotName != tt.bot {
+				t.Errorf("bot = %v %s, want %s", got.IsBot, got.BotName, tt.bot)
+			}
+		})
+	}
+}
+
+func TestParseUserAgentEmpty(t *testing.T) {
+	got := ParseUserAgent("  ")
+	if got.BrowserName != "" || got.DeviceType != "" || got.IsBot {
+		t.Errorf("ParseUserAgent of blank string = %+v, want zero value", got)
+	}
+}
+
+func TestClientHints(t *testing.T) {
+	brands := ParseClientHintBrands(`"Chromium";v="124", "Google Chrome";v="124", "Not-A.Brand";v="99"`)
+	if len(brands) != 3 || brands[1].Brand != "Google Chrome" || brands[1].Version != "124" {
+		t.Fatalf("ParseClientHintBrands = %+v", brands)
+	}
+
+	if got := ParseClientHintString(`"Windows"`); got != "Windows" {
+		t.Errorf("ParseClientHintString = %q, want Windows", got)
+	}
+	if got := ParseClientHintBool("?1"); got == nil || !*got {
+		t.Errorf("ParseClientHintBool(?1) = %v, want true", got)
+	}
+	if got := ParseClientHintBool("yes"); got != nil {
+		t.Errorf("ParseClientHintBool(yes) = %v, want nil", *got)
+	}
+
+	// Client Hints reveal Windows 11 and the full version behind the frozen UA
+	info := ParseUserAgent("Mozilla/5.0 (Windows NT 10.0; Win64; x64) AppleWebKit/537.36 (KHTML, like Gecko) Chrome/124.0.0.0 Safari/537.36")
+	mobile := false
+	ApplyClientHints(&info, ClientHints{
+		Brands:          brands,
+		FullVersionList: ParseClientHintBrands(`"Chromium";v="124.0.6367.91", "Google Chrome";v="124.0.6367.91", "Not-A.Brand";v="99.0.0.0"`),
+		Mobile:          &mobile,
+		Platform:        "Windows",
+		PlatformVersion: "15.0.0",
+	})
+
+	if info.BrowserName != "Chrome" || info.BrowserVersion != "124.0.6367.91" {
+		t.Errorf("browser = %s %s, want Chrome 124.0.6367.91", info.BrowserName, info.BrowserVersion)
+	}
+	if info.OSName != "Windows" || info.OSVersion != "11" {
+		t.Errorf("os = %s %s, want Windows 11", info.OSName, info.OSVersion)
+	}
+	if info.DeviceType != "desktop" {
+		t.Errorf("device = %s, want desktop", info.DeviceType)
+	}
+}
+
+func TestLoadUserAgentRules(t *testing.T) {
+	defer SetUserAgentRules(mustParseEmbeddedRules(t))
+
+	path := filepath.Join(t.TempDir(), "rules.json")
+	rules := `{"version": "test", "browsers": [{"pattern": "Ladybird/([\\d.]+)", "name": "Ladybird"}]}`
+	if err := os.WriteFile(path, []byte(rules), 0o644); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := LoadUserAgentRules(path); err != nil {
+		t.Fatalf("LoadUserAgentRules() error = %v", err)
+	}
+	if v := GetUserAgentRulesVersion(); v != "test" {
+		t.Errorf("rules version = %s, want test", v)
+	}
+	if got := ParseUserAgent("Mozilla/5.0 Ladybird/1.0"); got.BrowserName != "Ladybird" || got.BrowserVersion != "1.0" {
+		t.Errorf("browser = %s %s, want Ladybird 1.0", got.BrowserName, got.BrowserVersion)
+	}
+
+	// Invalid patterns are rejected and the active rules are kept
+	bad := filepath.Join(t.TempDir(), "bad.json")
+	if err := os.WriteFile(bad, []byte(`{"bots": [{"pattern": "(unclosed"}]}`), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := LoadUserAgentRules(bad); err == nil {
+		t.Error("LoadUserAgentRules() with invalid pattern should fail")
+	}
+	if v := GetUserAgentRulesVersion(); v != "test" {
+		t.Errorf("rules version after failed load = %s, want test", v)
+	}
+}
+
+func mustParseEmbeddedRules(t *testing.T) *UserAgentRules {
+	t.Helper()
+	rules, err := ParseUserAgentRules(userAgentRulesData)
+	if err != nil {
+		t.Fatalf("embedded rules: %v", err)
+	}
+	return rules
+}
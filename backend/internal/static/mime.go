@@ -0,0 +1,88 @@
+package static
+
+import (
+	"fmt"
+	"mime"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// builtinTypes are the media types of the files a Next.js export and the
+// WASM tools produce. They take precedence over the system MIME tables,
+// which differ between hosts and miss several of these.
+var builtinTypes = map[string]string{
+	".html":        "text/html; charset=utf-8",
+	".htm":         "text/html; charset=utf-8",
+	".txt":         "text/plain; charset=utf-8",
+	".css":         "text/css; charset=utf-8",
+	".js":          "text/javascript; charset=utf-8",
+	".mjs":         "text/javascript; charset=utf-8",
+	".json":        "application/json",
+	".map":         "application/json",
+	".webmanifest": "application/manifest+json",
+	".xml":         "application/xml",
+	".wasm":        "application/wasm",
+	".ico":         "image/x-icon",
+	".svg":         "image/svg+xml",
+	".png":         "image/png",
+	".jpg":         "image/jpeg",
+	".jpeg":        "image/jpeg",
+	".gif":         "image/gif",
+	".webp":        "image/webp",
+	".avif":        "image/avif",
+	".woff":        "font/woff",
+	".woff2":       "font/woff2",
+	".ttf":         "font/ttf",
+	".otf":         "font/otf",
+	".pdf":         "application/pdf",
+	".csv":         "text/csv; charset=utf-8",
+	".md":          "text/markdown; charset=utf-8",
+}
+
+// Types resolves the media type of files by extension, then by content
+type Types struct {
+	byExt map[string]string
+}
+
+// NewTypes returns a resolver with the built-in types and overrides mapping
+// extensions such as ".wasm" to media types
+func NewTypes(overrides map[string]string) (*Types, error) {
+	t := &Types{byExt: make(map[string]string, len(builtinTypes)+len(overrides))}
+	for ext, typ := range builtinTypes {
+		t.byExt[ext] = typ
+	}
+	for ext, typ := range overrides {
+		if err := ValidateType(ext, typ); err != nil {
+			return nil, err
+		}
+		t.byExt[strings.ToLower(ext)] = typ
+	}
+	return t, nil
+}
+
+// ValidateType checks an extension and media type pair of an override
+func ValidateType(ext, typ string) error {
+	if !strings.HasPrefix(ext, ".") || len(ext) < 2 || strings.ContainsAny(ext, "/ ") {
+		return fmt.Errorf("invalid extension %q, expected e.g. .wasm", ext)
+	}
+	if _, _, err := mime.ParseMediaType(typ); err != nil {
+		return fmt.Errorf("invalid media type %q for %s: %v", typ, ext, err)
+	}
+	return nil
+}
+
+// ContentType returns the media type of a file. Unknown extensions fall
+// back to the system MIME tables, then to sniffing the content.
+func (t *Types) ContentType(name string, data []byte) string {
+	ext := strings.ToLower(path.Ext(name))
+	if typ, ok := t.byExt[ext]; ok {
+		return typ
+	}
+	if ext != "" {
+		if typ := mime.TypeByExtension(ext); typ != "" {
+			return typ
+		}
+	}
+	return http.DetectContentType(data)
+}
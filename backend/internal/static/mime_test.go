@@ -0,0 +1,84 @@
+package static
+
+import (
+	"net/http"
+	"testing"
+	"testing/fstest"
+)
+
+func TestTypesContentType(t *testing.T) {
+	types, err := NewTypes(map[string]string{
+		".wasm": "application/wasm; profile=tools",
+		".DAT":  "application/x-utils-data",
+	})
+	if err != nil {
+		t.Fatalf("NewTypes: %v", err)
+	}
+
+	tests := []struct {
+		name string
+		data string
+		want string
+	}{
+		{"index.html", "", "text/html; charset=utf-8"},
+		{"_next/static/chunks/main.js", "", "text/javascript; charset=utf-8"},
+		{"worker.mjs", "", "text/javascript; charset=utf-8"},
+		{"_next/static/css/app.css", "", "text/css; charset=utf-8"},
+		{"favicon.ico", "", "image/x-icon"},
+		{"manifest.webmanifest", "", "application/manifest+json"},
+		{"about.txt", "", "text/plain; charset=utf-8"},
+		{"main.js.map", "", "application/json"},
+		{"sitemap.xml", "", "application/xml"},
+		{"hero.webp", "", "image/webp"},
+		{"hero.avif", "", "image/avif"},
+		{"LOGO.PNG", "", "image/png"},
+		{"font.woff2", "", "font/woff2"},
+		{"tools/subnet.wasm", "", "application/wasm; profile=tools"},
+		{"table.dat", "", "application/x-utils-data"},
+		{"LICENSE", "MIT License", "text/plain; charset=utf-8"},
+		{"blob.unknownext", "\x89PNG\r\n\x1a\n", "image/png"},
+		{"empty", "", "text/plain; charset=utf-8"},
+	}
+	for _, tt := range tests {
+		if got := types.ContentType(tt.name, []byte(tt.data)); got != tt.want {
+			t.Errorf("ContentType(%q) = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestNewTypesRejectsInvalidOverrides(t *testing.T) {
+	for ext, typ := range map[string]string{
+		"wasm":  "application/wasm",
+		".":     "text/plain",
+		"./x":   "text/plain",
+		".wasm": "not a type",
+	} {
+		if _, err := NewTypes(map[string]string{ext: typ}); err == nil {
+			t.Errorf("NewTypes(%q: %q) succeeded, want an error", ext, typ)
+		}
+	}
+}
+
+func TestServerUsesTypes(t *testing.T) {
+	types, err := NewTypes(map[string]string{".wasm": "application/x-custom"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewServer(fstest.MapFS{
+		"manifest.webmanifest": {Data: []byte(`{"name":"utils"}`)},
+		"tools/subnet.wasm":    {Data: []byte("\x00asm")},
+	}, Options{Types: types})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for path, want := range map[string]string{
+		"/manifest.webmanifest": "application/manifest+json",
+		"/tools/subnet.wasm":    "application/x-custom",
+	} {
+		w := serve(s, http.MethodGet, path, nil)
+		if got := w.Header().Get("Content-Type"); got != want {
+			t.Errorf("GET %s Content-Type = %q, want %q", path, got, want)
+		}
+	}
+}
@@ -0,0 +1,359 @@
+package static
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/base64"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lRoccoon/utils-helper/internal/compress"
+)
+
+// Cache-Control values by kind of file
+const (
+	// Next.js puts a content hash in every file name under _next/static, so
+	// a changed file always has a new URL
+	cacheImmutable = "public, max-age=31536000, immutable"
+	// Pages keep their URL across deploys and must be revalidated
+	cacheRevalidate = "no-cache"
+	// Other files, e.g. favicon.ico, may be reused for a while
+	cacheShort = "public, max-age=3600"
+)
+
+// minPrecompressSize is the smallest file compressed at startup; below it the
+// saving does not pay for the extra request header and decoding
+const minPrecompressSize = 256
+
+// notFoundPage is the page Next.js exports for unknown routes
+const notFoundPage = "404.html"
+
+// siblingCodings map the suffixes of prebuilt compressed files to their codings
+var siblingCodings = map[string]string{
+	".br":  compress.Brotli,
+	".gz":  compress.Gzip,
+	".zst": compress.Zstd,
+}
+
+// File is a static file held in memory with its precomputed headers
+type File struct {
+	Name         string
+	Data         []byte
+	ETag         string
+	ContentType  string
+	CacheControl string
+	// Variants are compressed copies of Data by content coding
+	Variants map[string]Variant
+	// codings lists the keys of Variants in server preference order
+	codings []string
+}
+
+// Variant is a compressed copy of a file
+type Variant struct {
+	Data []byte
+	ETag string
+}
+
+// Options configure a Server
+type Options struct {
+	// ModTime is sent as Last-Modified; embedded files carry no time of
+	// their own, so the build time of the binary is a good choice
+	ModTime time.Time
+	// Types resolves the media type of each file; nil uses the built-in
+	// types
+	Types *Types
+	// Precompress lists the codings, in preference order, that compressible
+	// files are compressed into once at startup. A prebuilt name.br, name.gz
+	// or name.zst next to a file is used as is instead.
+	Precompress []string
+}
+
+// Server serves the files of a filesystem with validators and caching
+// headers. Unknown pages get the exported 404 page, unknown assets a plain
+// 404. Pages are canonical without a trailing slash, as next.config.js
+// exports them, and /about/ redirects to /about.
+type Server struct {
+	files   map[string]*File
+	modTime time.Time
+}
+
+// NewServer reads every file of fsys, precomputes its ETag and prepares its
+// compressed variants
+func NewServer(fsys fs.FS, opts Options) (*Server, error) {
+	s := &Server{files: make(map[string]*File), modTime: opts.ModTime}
+	types := opts.Types
+	if types == nil {
+		types, _ = NewTypes(nil)
+	}
+	data := make(map[string][]byte)
+	err := fs.WalkDir(fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if hidden(name) {
+			// Never publish files like .gitkeep or .env that end up in dist
+			if d.IsDir() {
+				return fs.SkipDir
+			}
+			return nil
+		}
+		if d.IsDir() {
+			return nil
+		}
+		data[name], err = fs.ReadFile(fsys, name)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// Prebuilt compressed siblings become variants of their file instead of
+	// files of their own
+	prebuilt := make(map[string]map[string][]byte)
+	for name, b := range data {
+		coding, ok := siblingCodings[path.Ext(name)]
+		base := strings.TrimSuffix(name, path.Ext(name))
+		if _, exists := data[base]; !ok || !exists {
+			continue
+		}
+		if prebuilt[base] == nil {
+			prebuilt[base] = make(map[string][]byte)
+		}
+		prebuilt[base][coding] = b
+		delete(data, name)
+	}
+
+	for name, b := range data {
+		f := &File{
+			Name:         name,
+			Data:         b,
+			ETag:         etag(b, ""),
+			ContentType:  types.ContentType(name, b),
+			CacheControl: cacheControl(name),
+		}
+		if err := f.addVariants(prebuilt[name], opts.Precompress); err != nil {
+			return nil, err
+		}
+		s.files[name] = f
+	}
+	return s, nil
+}
+
+// addVariants adds the prebuilt variants of a file, then compresses it into
+// the missing codings if its media type compresses and the result is smaller
+func (f *File) addVariants(prebuilt map[string][]byte, codings []string) error {
+	compressible := len(f.Data) >= minPrecompressSize && compress.Compressible(f.ContentType)
+
+	for _, coding := range compress.Encodings {
+		b, ok := prebuilt[coding]
+		if !ok && compressible && contains(codings, coding) {
+			var err error
+			if b, err = compress.Bytes(coding, f.Data); err != nil {
+				return fmt.Errorf("compress %s: %w", f.Name, err)
+			}
+			ok = len(b) < len(f.Data)
+		}
+		if !ok {
+			continue
+		}
+		if f.Variants == nil {
+			f.Variants = make(map[string]Variant)
+		}
+		f.Variants[coding] = Variant{Data: b, ETag: etag(f.Data, coding)}
+		f.codings = append(f.codings, coding)
+	}
+	return nil
+}
+
+// Len returns the number of files
+func (s *Server) Len() int {
+	return len(s.files)
+}
+
+// Open returns the file with the given slash-separated name, without a
+// leading slash
+func (s *Server) Open(name string) (*File, bool) {
+	f, ok := s.files[name]
+	return f, ok
+}
+
+// ServeHTTP serves the file for the request path. Pages are found as
+// name.html or name/index.html and redirected to their canonical URL;
+// unknown pages get the 404 page with status 404. Paths that name an asset,
+// anything under _next/ or with a file extension, and hidden files get a
+// plain 404 so broken asset URLs are not masked by HTML. Paths with ".."
+// segments are rejected rather than cleaned.
+func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodGet && r.Method != http.MethodHead {
+		w.Header().Set("Allow", "GET, HEAD")
+		http.Error(w, "405 method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	p := r.URL.Path
+	if !strings.HasPrefix(p, "/") || strings.ContainsAny(p, "\\\x00") || hasDotDot(p) {
+		http.Error(w, "400 bad request", http.StatusBadRequest)
+		return
+	}
+	dir := p != "/" && strings.HasSuffix(p, "/")
+	name := strings.TrimPrefix(path.Clean(p), "/")
+
+	// Collapse duplicate slashes and dot segments before looking anything up
+	if clean := canonicalPath(name, dir); clean != p {
+		s.redirect(w, r, clean)
+		return
+	}
+
+	if name == "" {
+		name = "index.html"
+	}
+	if f, ok := s.files[name]; ok && !dir {
+		s.ServeFile(w, r, f)
+		return
+	}
+	for _, page := range []string{name + ".html", name + "/index.html"} {
+		f, ok := s.files[page]
+		if !ok {
+			continue
+		}
+		if dir {
+			s.redirect(w, r, canonicalPath(name, false))
+			return
+		}
+		s.ServeFile(w, r, f)
+		return
+	}
+
+	if f, ok := s.files[notFoundPage]; ok && !isAsset(name) && !hidden(name) {
+		s.serveNotFound(w, r, f)
+		return
+	}
+	http.Error(w, "404 page not found", http.StatusNotFound)
+}
+
+// redirect sends a permanent redirect to a path on this server, keeping the
+// query
+func (s *Server) redirect(w http.ResponseWriter, r *http.Request, target string) {
+	u := url.URL{Path: target, RawQuery: r.URL.RawQuery}
+	http.Redirect(w, r, u.String(), http.StatusMovedPermanently)
+}
+
+// serveNotFound sends the 404 page. It has no validators, so a client never
+// caches a missing page as if it were content.
+func (s *Server) serveNotFound(w http.ResponseWriter, r *http.Request, f *File) {
+	data, _ := s.negotiate(w, r, f)
+	h := w.Header()
+	h.Set("Cache-Control", cacheRevalidate)
+	h.Set("Content-Type", "text/html; charset=utf-8")
+	h.Set("Content-Length", strconv.Itoa(len(data)))
+	w.WriteHeader(http.StatusNotFound)
+	if r.Method != http.MethodHead {
+		_, _ = w.Write(data)
+	}
+}
+
+// ServeFile writes a file with http.ServeContent, which answers
+// If-None-Match and If-Modified-Since with 304, serves Range requests and
+// omits the body for HEAD. A file with compressed variants is sent in the
+// coding the client prefers.
+func (s *Server) ServeFile(w http.ResponseWriter, r *http.Request, f *File) {
+	data, tag := s.negotiate(w, r, f)
+	h := w.Header()
+	h.Set("ETag", tag)
+	h.Set("Cache-Control", f.CacheControl)
+	// Set explicitly, ServeContent would sniff compressed bytes otherwise
+	h.Set("Content-Type", f.ContentType)
+	http.ServeContent(w, r, f.Name, s.modTime, bytes.NewReader(data))
+}
+
+// negotiate picks the representation of a file the client prefers and sets
+// Content-Encoding and Vary for it
+func (s *Server) negotiate(w http.ResponseWriter, r *http.Request, f *File) (data []byte, etag string) {
+	if len(f.codings) == 0 {
+		return f.Data, f.ETag
+	}
+	h := w.Header()
+	h.Add("Vary", "Accept-Encoding")
+	if coding := compress.Negotiate(r.Header.Get("Accept-Encoding"), f.codings); coding != "" {
+		h.Set("Content-Encoding", coding)
+		v := f.Variants[coding]
+		return v.Data, v.ETag
+	}
+	return f.Data, f.ETag
+}
+
+// canonicalPath returns the URL path of a cleaned name
+func canonicalPath(name string, dir bool) string {
+	if name == "" {
+		return "/"
+	}
+	if dir {
+		return "/" + name + "/"
+	}
+	return "/" + name
+}
+
+// hasDotDot reports whether a URL path has a ".." segment
+func hasDotDot(p string) bool {
+	for _, seg := range strings.Split(p, "/") {
+		if seg == ".." {
+			return true
+		}
+	}
+	return false
+}
+
+// hidden reports whether a name has a segment starting with a dot, except
+// .well-known, which holds files meant to be public
+func hidden(name string) bool {
+	for _, seg := range strings.Split(name, "/") {
+		if strings.HasPrefix(seg, ".") && seg != "." && seg != ".well-known" {
+			return true
+		}
+	}
+	return false
+}
+
+// isAsset reports whether a missing name is an asset rather than a page:
+// everything under _next/ and any last segment with an extension
+func isAsset(name string) bool {
+	return strings.HasPrefix(name, "_next/") || path.Ext(path.Base(name)) != ""
+}
+
+// etag derives an ETag from the identity content of a file; variants get the
+// coding as a suffix so each representation has its own validator
+func etag(data []byte, coding string) string {
+	sum := sha256.Sum256(data)
+	tag := base64.RawURLEncoding.EncodeToString(sum[:12])
+	if coding != "" {
+		tag += "-" + coding
+	}
+	return `"` + tag + `"`
+}
+
+func contains(list []string, s string) bool {
+	for _, v := range list {
+		if v == s {
+			return true
+		}
+	}
+	return false
+}
+
+// cacheControl picks the caching policy of a file
+func cacheControl(name string) string {
+	switch {
+	case strings.HasPrefix(name, "_next/static/"):
+		return cacheImmutable
+	case strings.HasSuffix(name, ".html"), strings.HasSuffix(name, ".txt"):
+		// .txt files are the React Server Component payloads of the pages
+		return cacheRevalidate
+	}
+	return cacheShort
+}
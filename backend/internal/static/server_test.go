@@ -0,0 +1,316 @@
+package static
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"testing/fstest"
+	"time"
+
+	"github.com/lRoccoon/utils-helper/internal/compress"
+)
+
+var testModTime = time.Date(2024, 5, 1, 12, 0, 0, 0, time.UTC)
+
+func newTestServer(t *testing.T) *Server {
+	t.Helper()
+	s, err := NewServer(fstest.MapFS{
+		"index.html":                      {Data: []byte("<html>home</html>")},
+		"about.html":                      {Data: []byte("<html>about</html>")},
+		"docs/index.html":                 {Data: []byte("<html>docs</html>")},
+		"404.html":                        {Data: []byte("<html>not found</html>")},
+		"favicon.ico":                     {Data: []byte("icon")},
+		"_next/static/chunks/main-abc.js": {Data: []byte("0123456789abcdefghij")},
+		".gitkeep":                        {Data: []byte("")},
+		".git/config":                     {Data: []byte("[core]")},
+		".well-known/security.txt":        {Data: []byte("Contact: mailto:security@example.com")},
+	}, Options{ModTime: testModTime})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	return s
+}
+
+func serve(s *Server, method, path string, headers map[string]string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest(method, path, nil)
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+	w := httptest.NewRecorder()
+	s.ServeHTTP(w, req)
+	return w
+}
+
+func TestServerResolvesPaths(t *testing.T) {
+	s := newTestServer(t)
+	if s.Len() != 7 {
+		t.Errorf("Len() = %d, want 7 without hidden files", s.Len())
+	}
+
+	tests := []struct {
+		path string
+		body string
+	}{
+		{"/", "<html>home</html>"},
+		{"/about", "<html>about</html>"},
+		{"/about.html", "<html>about</html>"},
+		{"/docs", "<html>docs</html>"},
+		{"/index.html", "<html>home</html>"},
+		{"/.well-known/security.txt", "Contact: mailto:security@example.com"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := serve(s, http.MethodGet, tt.path, nil)
+			if w.Code != http.StatusOK || w.Body.String() != tt.body {
+				t.Errorf("GET %s = %d %q, want 200 %q", tt.path, w.Code, w.Body.String(), tt.body)
+			}
+		})
+	}
+}
+
+func TestServerNotFound(t *testing.T) {
+	s := newTestServer(t)
+
+	// Unknown pages get the exported 404 page
+	for _, path := range []string{"/tools/subnet", "/404-typo", "/docs/missing"} {
+		w := serve(s, http.MethodGet, path, nil)
+		if w.Code != http.StatusNotFound || w.Body.String() != "<html>not found</html>" {
+			t.Errorf("GET %s = %d %q, want 404 page", path, w.Code, w.Body.String())
+		}
+		if w.Header().Get("Content-Type") != "text/html; charset=utf-8" || w.Header().Get("ETag") != "" {
+			t.Errorf("GET %s has Content-Type %q and ETag %q", path, w.Header().Get("Content-Type"), w.Header().Get("ETag"))
+		}
+	}
+
+	w := serve(s, http.MethodHead, "/tools/subnet", nil)
+	if w.Code != http.StatusNotFound || w.Body.Len() != 0 {
+		t.Errorf("HEAD unknown page = %d with %d bytes, want 404 without body", w.Code, w.Body.Len())
+	}
+
+	// Missing assets and hidden files get a plain 404
+	for _, path := range []string{
+		"/favicon.icox",
+		"/_next/static/chunks/missing.js",
+		"/_next/static/chunks",
+		"/_next/static/",
+		"/favicon.ico/",
+		"/.gitkeep",
+		"/.git/config",
+	} {
+		w := serve(s, http.MethodGet, path, nil)
+		if w.Code != http.StatusNotFound || strings.Contains(w.Body.String(), "<html>") {
+			t.Errorf("GET %s = %d %q, want a plain 404", path, w.Code, w.Body.String())
+		}
+	}
+
+	// Without a 404 page every miss is a plain 404
+	bare, err := NewServer(fstest.MapFS{"index.html": {Data: []byte("<html>home</html>")}}, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if w := serve(bare, http.MethodGet, "/tools/subnet", nil); w.Code != http.StatusNotFound {
+		t.Errorf("GET unknown page without 404.html = %d, want 404", w.Code)
+	}
+}
+
+func TestServerRedirectsAndTraversal(t *testing.T) {
+	s := newTestServer(t)
+
+	redirects := []struct {
+		path     string
+		location string
+	}{
+		{"/about/", "/about"},
+		{"/docs/?tab=api", "/docs?tab=api"},
+		{"//about", "/about"},
+		{"/docs/./index.html", "/docs/index.html"},
+		{"/a%3Fb/./c", "/a%3Fb/c"},
+	}
+	for _, tt := range redirects {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.URL.Path, req.URL.RawQuery, _ = strings.Cut(tt.path, "?")
+		if unescaped, err := url.PathUnescape(req.URL.Path); err == nil {
+			req.URL.Path = unescaped
+		}
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		if w.Code != http.StatusMovedPermanently || w.Header().Get("Location") != tt.location {
+			t.Errorf("GET %s = %d to %q, want 301 to %q", tt.path, w.Code, w.Header().Get("Location"), tt.location)
+		}
+	}
+
+	for _, path := range []string{"/../../etc/passwd", "/docs/../../etc/passwd", "/docs/%2e%2e/index.html", "/a\\..\\b"} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.URL.Path, _ = url.PathUnescape(path)
+		w := httptest.NewRecorder()
+		s.ServeHTTP(w, req)
+		if w.Code != http.StatusBadRequest {
+			t.Errorf("GET %s = %d, want 400", path, w.Code)
+		}
+	}
+}
+
+func TestServerCachingHeaders(t *testing.T) {
+	s := newTestServer(t)
+
+	tests := []struct {
+		path         string
+		cacheControl string
+		contentType  string
+	}{
+		{"/", "no-cache", "text/html; charset=utf-8"},
+		{"/_next/static/chunks/main-abc.js", "public, max-age=31536000, immutable", "text/javascript; charset=utf-8"},
+		{"/favicon.ico", "public, max-age=3600", "image/x-icon"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			w := serve(s, http.MethodGet, tt.path, nil)
+			if got := w.Header().Get("Cache-Control"); got != tt.cacheControl {
+				t.Errorf("Cache-Control = %q, want %q", got, tt.cacheControl)
+			}
+			if got := w.Header().Get("Content-Type"); got != tt.contentType {
+				t.Errorf("Content-Type = %q, want %q", got, tt.contentType)
+			}
+			if w.Header().Get("ETag") == "" {
+				t.Error("missing ETag")
+			}
+			if got := w.Header().Get("Last-Modified"); got != testModTime.Format(http.TimeFormat) {
+				t.Errorf("Last-Modified = %q", got)
+			}
+		})
+	}
+}
+
+func TestServerConditionalRequests(t *testing.T) {
+	s := newTestServer(t)
+	etag := serve(s, http.MethodGet, "/favicon.ico", nil).Header().Get("ETag")
+
+	w := serve(s, http.MethodGet, "/favicon.ico", map[string]string{"If-None-Match": etag})
+	if w.Code != http.StatusNotModified || w.Body.Len() != 0 {
+		t.Errorf("If-None-Match = %d with %d bytes, want 304 without body", w.Code, w.Body.Len())
+	}
+
+	w = serve(s, http.MethodGet, "/favicon.ico", map[string]string{"If-None-Match": `"stale"`})
+	if w.Code != http.StatusOK {
+		t.Errorf("stale If-None-Match = %d, want 200", w.Code)
+	}
+
+	w = serve(s, http.MethodGet, "/favicon.ico", map[string]string{
+		"If-Modified-Since": testModTime.Add(time.Hour).Format(http.TimeFormat),
+	})
+	if w.Code != http.StatusNotModified {
+		t.Errorf("If-Modified-Since = %d, want 304", w.Code)
+	}
+
+	// Each file has its own ETag
+	if other := serve(s, http.MethodGet, "/about", nil).Header().Get("ETag"); other == etag {
+		t.Error("different files share an ETag")
+	}
+}
+
+func TestServerRangeAndHead(t *testing.T) {
+	s := newTestServer(t)
+	path := "/_next/static/chunks/main-abc.js"
+
+	w := serve(s, http.MethodGet, path, map[string]string{"Range": "bytes=10-14"})
+	if w.Code != http.StatusPartialContent || w.Body.String() != "abcde" {
+		t.Errorf("Range = %d %q, want 206 %q", w.Code, w.Body.String(), "abcde")
+	}
+	if got := w.Header().Get("Content-Range"); got != "bytes 10-14/20" {
+		t.Errorf("Content-Range = %q", got)
+	}
+
+	w = serve(s, http.MethodGet, path, map[string]string{"Range": "bytes=50-60"})
+	if w.Code != http.StatusRequestedRangeNotSatisfiable {
+		t.Errorf("unsatisfiable Range = %d, want 416", w.Code)
+	}
+
+	w = serve(s, http.MethodHead, path, nil)
+	if w.Code != http.StatusOK || w.Body.Len() != 0 {
+		t.Errorf("HEAD = %d with %d bytes, want 200 without body", w.Code, w.Body.Len())
+	}
+	if got := w.Header().Get("Content-Length"); got != "20" {
+		t.Errorf("HEAD Content-Length = %q, want 20", got)
+	}
+
+	w = serve(s, http.MethodPost, path, nil)
+	if w.Code != http.StatusMethodNotAllowed || w.Header().Get("Allow") != "GET, HEAD" {
+		t.Errorf("POST = %d Allow %q, want 405 with Allow", w.Code, w.Header().Get("Allow"))
+	}
+}
+
+func TestServerCompressedVariants(t *testing.T) {
+	script := []byte(strings.Repeat("console.log('utils-helper');\n", 50))
+	prebuilt, err := compress.Bytes(compress.Gzip, []byte("body{margin:0}"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s, err := NewServer(fstest.MapFS{
+		"app.js":        {Data: script},
+		"small.js":      {Data: []byte("x()")},
+		"logo.png":      {Data: make([]byte, 1024)},
+		"style.css":     {Data: []byte("body{margin:0}")},
+		"style.css.gz":  {Data: prebuilt},
+		"orphan.txt.br": {Data: []byte("kept as a file")},
+	}, Options{ModTime: testModTime, Precompress: []string{compress.Brotli, compress.Gzip}})
+	if err != nil {
+		t.Fatalf("NewServer: %v", err)
+	}
+	if s.Len() != 5 {
+		t.Errorf("Len() = %d, want 5, prebuilt siblings are not files", s.Len())
+	}
+
+	identity := serve(s, http.MethodGet, "/app.js", nil)
+	if identity.Header().Get("Content-Encoding") != "" || identity.Body.String() != string(script) {
+		t.Error("identity response is not the plain file")
+	}
+	if identity.Header().Get("Vary") != "Accept-Encoding" {
+		t.Errorf("Vary = %q, want Accept-Encoding", identity.Header().Get("Vary"))
+	}
+
+	tags := map[string]bool{identity.Header().Get("ETag"): true}
+	for _, coding := range []string{compress.Brotli, compress.Gzip} {
+		w := serve(s, http.MethodGet, "/app.js", map[string]string{"Accept-Encoding": coding})
+		if got := w.Header().Get("Content-Encoding"); got != coding {
+			t.Fatalf("Content-Encoding = %q, want %q", got, coding)
+		}
+		if got := w.Header().Get("Content-Type"); got != "text/javascript; charset=utf-8" {
+			t.Errorf("Content-Type = %q", got)
+		}
+		if w.Body.Len() >= len(script) {
+			t.Errorf("%s variant is %d bytes, not smaller than %d", coding, w.Body.Len(), len(script))
+		}
+		tag := w.Header().Get("ETag")
+		if tags[tag] {
+			t.Errorf("%s variant reuses ETag %s", coding, tag)
+		}
+		tags[tag] = true
+
+		w = serve(s, http.MethodGet, "/app.js", map[string]string{"Accept-Encoding": coding, "If-None-Match": tag})
+		if w.Code != http.StatusNotModified {
+			t.Errorf("If-None-Match with %s ETag = %d, want 304", coding, w.Code)
+		}
+	}
+
+	// Brotli is preferred over gzip
+	w := serve(s, http.MethodGet, "/app.js", map[string]string{"Accept-Encoding": "gzip, deflate, br"})
+	if got := w.Header().Get("Content-Encoding"); got != compress.Brotli {
+		t.Errorf("Content-Encoding = %q, want br", got)
+	}
+
+	// The prebuilt sibling is served although the file is too small to be
+	// compressed at startup
+	w = serve(s, http.MethodGet, "/style.css", map[string]string{"Accept-Encoding": "br, gzip"})
+	if w.Header().Get("Content-Encoding") != compress.Gzip || w.Body.String() != string(prebuilt) {
+		t.Errorf("style.css = %q encoded, want the prebuilt gzip file", w.Header().Get("Content-Encoding"))
+	}
+
+	for _, path := range []string{"/small.js", "/logo.png"} {
+		w := serve(s, http.MethodGet, path, map[string]string{"Accept-Encoding": "br, gzip"})
+		if w.Header().Get("Content-Encoding") != "" || w.Header().Get("Vary") != "" {
+			t.Errorf("%s has no variants but is sent encoded or with Vary", path)
+		}
+	}
+}
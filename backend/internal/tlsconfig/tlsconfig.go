@@ -0,0 +1,228 @@
+// Package tlsconfig builds the TLS configuration of the HTTPS listener and
+// reloads its certificate and client CA bundle when the files change.
+package tlsconfig
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/lRoccoon/utils-helper/internal/filestamp"
+)
+
+// Versions maps the configurable minimum versions to their protocol IDs
+var Versions = map[string]uint16{
+	"1.2": tls.VersionTLS12,
+	"1.3": tls.VersionTLS13,
+}
+
+// ClientAuthModes maps the configurable client certificate policies, used
+// when a client CA bundle is set
+var ClientAuthModes = map[string]tls.ClientAuthType{
+	"require":         tls.RequireAndVerifyClientCert,
+	"verify_if_given": tls.VerifyClientCertIfGiven,
+}
+
+// Options configure the server side of TLS
+type Options struct {
+	CertFile string
+	KeyFile  string
+	// ClientCAFile enables client certificate verification against the
+	// bundle, with ClientAuth deciding whether a certificate is required
+	ClientCAFile string
+	ClientAuth   tls.ClientAuthType
+	MinVersion   uint16
+	// CipherSuites apply to TLS 1.2; nil keeps Go's defaults
+	CipherSuites []uint16
+	// HTTP2 offers h2 during ALPN besides HTTP/1.1
+	HTTP2 bool
+}
+
+// ParseVersion parses a minimum TLS version such as "1.2"
+func ParseVersion(s string) (uint16, error) {
+	if v, ok := Versions[s]; ok {
+		return v, nil
+	}
+	return 0, fmt.Errorf("unsupported TLS version %q, expected 1.2 or 1.3", s)
+}
+
+// ParseCipherSuites looks up cipher suites by their standard names, e.g.
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256. Suites Go considers insecure are
+// rejected. TLS 1.3 suites are not configurable and are rejected too.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	if len(names) == 0 {
+		return nil, nil
+	}
+	secure := make(map[string]*tls.CipherSuite)
+	for _, s := range tls.CipherSuites() {
+		secure[s.Name] = s
+	}
+	insecure := make(map[string]bool)
+	for _, s := range tls.InsecureCipherSuites() {
+		insecure[s.Name] = true
+	}
+
+	ids := make([]uint16, 0, len(names))
+	for _, name := range names {
+		s, ok := secure[name]
+		switch {
+		case insecure[name]:
+			return nil, fmt.Errorf("cipher suite %s is insecure", name)
+		case !ok:
+			return nil, fmt.Errorf("unknown cipher suite %q", name)
+		case !supportsTLS12(s):
+			return nil, fmt.Errorf("cipher suite %s is a TLS 1.3 suite, which cannot be configured", name)
+		}
+		ids = append(ids, s.ID)
+	}
+	return ids, nil
+}
+
+func supportsTLS12(s *tls.CipherSuite) bool {
+	for _, v := range s.SupportedVersions {
+		if v == tls.VersionTLS12 {
+			return true
+		}
+	}
+	return false
+}
+
+// Reloader serves the certificate and client CA bundle most recently
+// loaded from disk. Handshakes pick up a reloaded certificate at once;
+// established connections keep the one they started with.
+type Reloader struct {
+	opts Options
+
+	mu     sync.RWMutex
+	config *tls.Config
+	stamps []filestamp.Stamp
+}
+
+// New loads the files and returns a reloader for them
+func New(opts Options) (*Reloader, error) {
+	if opts.CertFile == "" || opts.KeyFile == "" {
+		return nil, errors.New("a certificate and a key file are required")
+	}
+	r := &Reloader{opts: opts}
+	if _, err := r.Reload(); err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+// files returns the files the configuration is built from
+func (r *Reloader) files() []string {
+	files := []string{r.opts.CertFile, r.opts.KeyFile}
+	if r.opts.ClientCAFile != "" {
+		files = append(files, r.opts.ClientCAFile)
+	}
+	return files
+}
+
+// Reload loads the files again if any of them changed, reporting whether
+// it did. Files that fail to load leave the previous configuration in
+// place, e.g. while a certificate and its key are replaced one by one.
+func (r *Reloader) Reload() (bool, error) {
+	files := r.files()
+	stamps := make([]filestamp.Stamp, len(files))
+	for i, f := range files {
+		// A missing file gets the zero stamp and fails to load below
+		stamps[i], _ = filestamp.Stat(f)
+	}
+
+	r.mu.RLock()
+	unchanged := r.config != nil && slices.EqualFunc(r.stamps, stamps, filestamp.Stamp.Equal)
+	r.mu.RUnlock()
+	if unchanged {
+		return false, nil
+	}
+
+	config, err := r.load()
+	if err != nil {
+		return false, err
+	}
+	r.mu.Lock()
+	r.config = config
+	r.stamps = stamps
+	r.mu.Unlock()
+	return true, nil
+}
+
+// load builds the configuration handshakes use from the files
+func (r *Reloader) load() (*tls.Config, error) {
+	cert, err := tls.LoadX509KeyPair(r.opts.CertFile, r.opts.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("load certificate: %w", err)
+	}
+
+	config := r.base()
+	config.Certificates = []tls.Certificate{cert}
+	if r.opts.ClientCAFile != "" {
+		pem, err := os.ReadFile(r.opts.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("load client CA bundle: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(pem) {
+			return nil, fmt.Errorf("client CA bundle %s holds no PEM certificates", r.opts.ClientCAFile)
+		}
+		config.ClientCAs = pool
+		config.ClientAuth = r.opts.ClientAuth
+	}
+	return config, nil
+}
+
+// base returns the settings shared by the listener and every handshake
+func (r *Reloader) base() *tls.Config {
+	protos := []string{"http/1.1"}
+	if r.opts.HTTP2 {
+		protos = []string{"h2", "http/1.1"}
+	}
+	return &tls.Config{
+		MinVersion:   r.opts.MinVersion,
+		CipherSuites: r.opts.CipherSuites,
+		NextProtos:   protos,
+	}
+}
+
+// Config returns the configuration for the listener, which takes the
+// current certificate and client CAs from the reloader on every handshake
+func (r *Reloader) Config() *tls.Config {
+	config := r.base()
+	config.GetConfigForClient = func(*tls.ClientHelloInfo) (*tls.Config, error) {
+		r.mu.RLock()
+		defer r.mu.RUnlock()
+		return r.config, nil
+	}
+	return config
+}
+
+// Leaf returns the certificate currently served
+func (r *Reloader) Leaf() (*x509.Certificate, error) {
+	r.mu.RLock()
+	cert := r.config.Certificates[0]
+	r.mu.RUnlock()
+	if cert.Leaf != nil {
+		return cert.Leaf, nil
+	}
+	return x509.ParseCertificate(cert.Certificate[0])
+}
+
+// Describe names the served certificate and its expiry, for logs
+func (r *Reloader) Describe() string {
+	leaf, err := r.Leaf()
+	if err != nil {
+		return "unparsable certificate"
+	}
+	names := leaf.DNSNames
+	if len(names) == 0 {
+		names = []string{leaf.Subject.CommonName}
+	}
+	return fmt.Sprintf("%s, expires %s", strings.Join(names, ","), leaf.NotAfter.UTC().Format(time.RFC3339))
+}
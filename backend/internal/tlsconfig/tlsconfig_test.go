@@ -0,0 +1,215 @@
+package tlsconfig
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// issue creates a certificate for name, signed by parent or self-signed
+// when parent is nil, and returns it with its key
+func issue(t *testing.T, name string, isCA bool, parent *x509.Certificate, parentKey *ecdsa.PrivateKey) (*x509.Certificate, *ecdsa.PrivateKey, []byte, []byte) {
+	t.Helper()
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	serial, _ := rand.Int(rand.Reader, big.NewInt(1<<62))
+	tmpl := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		DNSNames:              []string{name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		IsCA:                  isCA,
+		BasicConstraintsValid: true,
+	}
+	if parent == nil {
+		parent, parentKey = tmpl, key
+	}
+	der, err := x509.CreateCertificate(rand.Reader, tmpl, parent, &key.PublicKey, parentKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	cert, _ := x509.ParseCertificate(der)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return cert, key,
+		pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER})
+}
+
+func writeFile(t *testing.T, path string, data []byte, modTime time.Time) {
+	t.Helper()
+	if err := os.WriteFile(path, data, 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// Distinct times make changes visible on file systems with coarse
+	// timestamps
+	if err := os.Chtimes(path, modTime, modTime); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// handshake connects to ln and returns the name of the server certificate
+func handshake(t *testing.T, ln net.Listener, config *tls.Config) (string, error) {
+	t.Helper()
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		_ = conn.(*tls.Conn).Handshake()
+	}()
+
+	conn, err := tls.Dial("tcp", ln.Addr().String(), config)
+	if err != nil {
+		return "", err
+	}
+	defer conn.Close()
+	// With TLS 1.3 a rejected client certificate only surfaces on read
+	conn.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+	var ne net.Error
+	if _, err := conn.Read(make([]byte, 1)); err != nil && !errors.Is(err, io.EOF) && !(errors.As(err, &ne) && ne.Timeout()) {
+		return "", err
+	}
+	return conn.ConnectionState().PeerCertificates[0].Subject.CommonName, nil
+}
+
+func TestReloadCertificate(t *testing.T) {
+	dir := t.TempDir()
+	certFile, keyFile := filepath.Join(dir, "tls.crt"), filepath.Join(dir, "tls.key")
+	start := time.Now().Add(-time.Hour)
+
+	_, _, certPEM, keyPEM := issue(t, "old.example", false, nil, nil)
+	writeFile(t, certFile, certPEM, start)
+	writeFile(t, keyFile, keyPEM, start)
+
+	r, err := New(Options{CertFile: certFile, KeyFile: keyFile, MinVersion: tls.VersionTLS12, HTTP2: true})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", r.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	client := &tls.Config{InsecureSkipVerify: true, NextProtos: []string{"h2"}}
+	if name, err := handshake(t, ln, client); err != nil || name != "old.example" {
+		t.Fatalf("handshake = %q, %v, want old.example", name, err)
+	}
+	if changed, err := r.Reload(); changed || err != nil {
+		t.Errorf("Reload() without changes = %v, %v", changed, err)
+	}
+
+	// A certificate without its matching key is not swapped in
+	_, _, certPEM, keyPEM = issue(t, "new.example", false, nil, nil)
+	writeFile(t, certFile, certPEM, start.Add(time.Minute))
+	if _, err := r.Reload(); err == nil {
+		t.Error("Reload() with a mismatched key error = nil")
+	}
+	if name, err := handshake(t, ln, client); err != nil || name != "old.example" {
+		t.Errorf("handshake after a failed reload = %q, %v, want old.example", name, err)
+	}
+
+	writeFile(t, keyFile, keyPEM, start.Add(time.Minute))
+	if changed, err := r.Reload(); !changed || err != nil {
+		t.Fatalf("Reload() = %v, %v, want a reload", changed, err)
+	}
+	if name, err := handshake(t, ln, client); err != nil || name != "new.example" {
+		t.Errorf("handshake after reload = %q, %v, want new.example", name, err)
+	}
+}
+
+func TestClientCertificates(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	ca, caKey, caPEM, _ := issue(t, "Test CA", true, nil, nil)
+	_, _, certPEM, keyPEM := issue(t, "server.example", false, ca, caKey)
+	_, _, clientPEM, clientKeyPEM := issue(t, "client", false, ca, caKey)
+	_, _, strangerPEM, strangerKeyPEM := issue(t, "stranger", false, nil, nil)
+
+	files := map[string][]byte{"tls.crt": certPEM, "tls.key": keyPEM, "ca.crt": caPEM}
+	for name, data := range files {
+		writeFile(t, filepath.Join(dir, name), data, now)
+	}
+	r, err := New(Options{
+		CertFile:     filepath.Join(dir, "tls.crt"),
+		KeyFile:      filepath.Join(dir, "tls.key"),
+		ClientCAFile: filepath.Join(dir, "ca.crt"),
+		ClientAuth:   tls.RequireAndVerifyClientCert,
+		MinVersion:   tls.VersionTLS12,
+	})
+	if err != nil {
+		t.Fatalf("New() error = %v", err)
+	}
+	ln, err := tls.Listen("tcp", "127.0.0.1:0", r.Config())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	withCert := func(certPEM, keyPEM []byte) *tls.Config {
+		config := &tls.Config{RootCAs: pool, ServerName: "server.example"}
+		if certPEM != nil {
+			cert, err := tls.X509KeyPair(certPEM, keyPEM)
+			if err != nil {
+				t.Fatal(err)
+			}
+			config.Certificates = []tls.Certificate{cert}
+		}
+		return config
+	}
+
+	if _, err := handshake(t, ln, withCert(clientPEM, clientKeyPEM)); err != nil {
+		t.Errorf("handshake with a client certificate error = %v", err)
+	}
+	if _, err := handshake(t, ln, withCert(nil, nil)); err == nil {
+		t.Error("handshake without a client certificate succeeded")
+	}
+	if _, err := handshake(t, ln, withCert(strangerPEM, strangerKeyPEM)); err == nil {
+		t.Error("handshake with a certificate from another CA succeeded")
+	}
+}
+
+func TestParseCipherSuites(t *testing.T) {
+	ids, err := ParseCipherSuites([]string{"TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256", "TLS_ECDHE_ECDSA_WITH_CHACHA20_POLY1305_SHA256"})
+	if err != nil || len(ids) != 2 || ids[0] != tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256 {
+		t.Errorf("ParseCipherSuites() = %v, %v", ids, err)
+	}
+	for _, name := range []string{"TLS_RSA_WITH_RC4_128_SHA", "TLS_AES_128_GCM_SHA256", "TLS_FAKE"} {
+		if _, err := ParseCipherSuites([]string{name}); err == nil {
+			t.Errorf("ParseCipherSuites(%s) error = nil", name)
+		}
+	}
+	if ids, err := ParseCipherSuites(nil); ids != nil || err != nil {
+		t.Errorf("ParseCipherSuites(nil) = %v, %v, want Go's defaults", ids, err)
+	}
+
+	if v, err := ParseVersion("1.3"); err != nil || v != tls.VersionTLS13 {
+		t.Errorf("ParseVersion(1.3) = %v, %v", v, err)
+	}
+	if _, err := ParseVersion("1.0"); err == nil {
+		t.Error("ParseVersion(1.0) error = nil")
+	}
+}